@@ -1,20 +1,37 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
 
 	"github.com/taknuki/go-opentype/opentype"
 )
 
+var (
+	disasm = flag.String("disasm", "", "disassemble the program of the target: fpgm, prep or a glyph id")
+	asm    = flag.String("asm", "", "replace the program of the target with the assembled -src: fpgm, prep or a glyph id")
+	src    = flag.String("src", "", "source file of the program for -asm")
+	dehint = flag.Bool("dehint", false, "remove the TrueType hinting")
+	output = flag.String("o", "", "output font file for -asm and -dehint")
+)
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("usage: go-opentype fontfile")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-opentype [options] fontfile")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
-	fileName := os.Args[1]
+	fileName := flag.Arg(0)
 	err := cmdMain(fileName)
 	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -29,9 +46,20 @@ func cmdMain(fileName string) (err error) {
 	if err != nil {
 		return
 	}
-	if ttc {
+	switch {
+	case "" != *disasm || "" != *asm:
+		if ttc {
+			return fmt.Errorf("programs of a font collection are not supported")
+		}
+		return editProgram(f)
+	case *dehint:
+		if ttc {
+			return fmt.Errorf("dehinting a font collection is not supported")
+		}
+		return dehintFont(f)
+	case ttc:
 		dumpFontCollection(f)
-	} else {
+	default:
 		dumpFont(f)
 	}
 	return nil
@@ -62,9 +90,135 @@ func dumpCMap(font *opentype.Font) {
 	for _, er := range cm.EncodingRecords {
 		fmt.Printf("platform: %s encoding: %s format: %d\n", er.PlatformID, er.EncodingID.String(er.PlatformID), er.Subtable.GetFormatNumber())
 		for i := int32(32); i <= 300; i++ {
-			if val, ok := er.GetCMap()[i]; ok {
+			if val, ok := er.CMap()[i]; ok {
 				fmt.Printf("char:%s gid:%d\n", string(rune(i)), val)
 			}
 		}
 	}
 }
+
+func editProgram(f *os.File) error {
+	font, err := opentype.ParseFont(f)
+	if err != nil {
+		return err
+	}
+	if "" != *disasm {
+		program, err := getProgram(font, *disasm)
+		if err != nil {
+			return err
+		}
+		s, err := opentype.Disassemble(program)
+		if err != nil {
+			return err
+		}
+		fmt.Print(s)
+		return nil
+	}
+	if "" == *src || "" == *output {
+		return fmt.Errorf("-asm requires -src and -o")
+	}
+	b, err := ioutil.ReadFile(*src)
+	if err != nil {
+		return err
+	}
+	program, err := opentype.Assemble(string(b))
+	if err != nil {
+		return err
+	}
+	err = setProgram(font, *asm, program)
+	if err != nil {
+		return err
+	}
+	return writeFont(font, *output)
+}
+
+func getProgram(font *opentype.Font, target string) ([]uint8, error) {
+	switch target {
+	case "fpgm":
+		if !font.Fpgm.Exists() {
+			return nil, nil
+		}
+		return font.Fpgm.Values, nil
+	case "prep":
+		if !font.Prep.Exists() {
+			return nil, nil
+		}
+		return font.Prep.Values, nil
+	}
+	gid, err := parseGlyphID(font, target)
+	if err != nil {
+		return nil, err
+	}
+	g, err := font.Glyf.Glyph(gid)
+	if err != nil {
+		return nil, err
+	}
+	return g.Instructions, nil
+}
+
+func setProgram(font *opentype.Font, target string, program []uint8) error {
+	switch target {
+	case "fpgm":
+		font.Fpgm = &opentype.Fpgm{Values: program}
+		return nil
+	case "prep":
+		font.Prep = &opentype.Prep{Values: program}
+		return nil
+	}
+	gid, err := parseGlyphID(font, target)
+	if err != nil {
+		return err
+	}
+	g, err := font.Glyf.Glyph(gid)
+	if err != nil {
+		return err
+	}
+	// an empty glyph has no room for the instructions, that would be dropped silently.
+	if 0 == g.NumberOfContours {
+		return fmt.Errorf("glyph %d has no outline for the instructions", gid)
+	}
+	g.Instructions = program
+	err = font.Glyf.SetGlyph(gid, g)
+	if err != nil {
+		return err
+	}
+	if font.Maxp.Exists() && int(font.Maxp.MaxSizeOfInstructions) < len(program) {
+		font.Maxp.MaxSizeOfInstructions = uint16(len(program))
+	}
+	return font.UpdateLoca()
+}
+
+func parseGlyphID(font *opentype.Font, target string) (uint16, error) {
+	gid, err := strconv.ParseUint(target, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("target must be fpgm, prep or a glyph id: %s", target)
+	}
+	if !font.Glyf.Exists() || int(gid) >= font.Glyf.Len() {
+		return 0, fmt.Errorf("glyph %d does not exist", gid)
+	}
+	return uint16(gid), nil
+}
+
+func dehintFont(f *os.File) error {
+	if "" == *output {
+		return fmt.Errorf("-dehint requires -o")
+	}
+	font, err := opentype.ParseFont(f)
+	if err != nil {
+		return err
+	}
+	err = font.Dehint()
+	if err != nil {
+		return err
+	}
+	return writeFont(font, *output)
+}
+
+func writeFont(font *opentype.Font, fileName string) error {
+	out, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return opentype.NewBuilder(font.SfntVersion).WithTables(font.Tables()).Build(out)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFont writes a TrueType font of an empty glyph 0 and a square glyph 1 without instructions.
+func writeTestFont(t *testing.T, fileName string) {
+	be := func(values ...interface{}) []byte {
+		b := &bytes.Buffer{}
+		for _, v := range values {
+			binary.Write(b, binary.BigEndian, v)
+		}
+		return b.Bytes()
+	}
+	// the simple glyph of the on-curve points, whose coordinates are the deltas of int16 followed by the padding.
+	square := be(
+		[]int16{1, 0, 0, 100, 100}, []uint16{3, 0}, []uint8{1, 1, 1, 1},
+		[]int16{0, 0, 100, 0}, []int16{0, 100, 0, -100}, uint16(0),
+	)
+	tables := []struct {
+		tag  string
+		data []byte
+	}{
+		{"glyf", square},
+		{"head", be(uint32(0x00010000), uint32(0), uint32(0), uint32(0x5F0F3CF5), uint16(0), uint16(1000),
+			make([]byte, 16), []int16{0, 0, 100, 100}, uint16(0), uint16(8), int16(2), int16(0), int16(0))},
+		{"hhea", be(uint32(0x00010000), int16(800), int16(-200), make([]byte, 26), uint16(2))},
+		{"hmtx", be([]uint16{500, 0, 600, 0})},
+		{"loca", be([]uint16{0, 0, uint16(len(square) / 2)})},
+		{"maxp", be(uint32(0x00010000), uint16(2), []uint16{4, 1, 0, 0, 2, 0, 0, 0, 0, 64, 0, 0, 0})},
+		{"name", be([]uint16{0, 0, 6})},
+	}
+	font := be(uint32(0x00010000), uint16(len(tables)), []uint16{64, 2, 48})
+	offset := len(font) + 16*len(tables)
+	var body []byte
+	for _, tb := range tables {
+		length := len(tb.data)
+		for 0 != len(tb.data)%4 {
+			tb.data = append(tb.data, 0)
+		}
+		checkSum := uint32(0)
+		for i := 0; i < len(tb.data); i += 4 {
+			checkSum += binary.BigEndian.Uint32(tb.data[i:])
+		}
+		font = append(font, be([]byte(tb.tag), checkSum, uint32(offset), uint32(length))...)
+		body = append(body, tb.data...)
+		offset += len(tb.data)
+	}
+	err := ioutil.WriteFile(fileName, append(font, body...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runCommand runs the command with the flags on the font, and returns what it prints.
+func runCommand(t *testing.T, fileName string, disasmTarget, asmTarget, srcFile, outFile string) (string, error) {
+	*disasm, *asm, *src, *output = disasmTarget, asmTarget, srcFile, outFile
+	defer func() {
+		*disasm, *asm, *src, *output = "", "", "", ""
+	}()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = cmdMain(fileName)
+	os.Stdout = stdout
+	w.Close()
+	printed, _ := ioutil.ReadAll(r)
+	r.Close()
+	return string(printed), err
+}
+
+func TestAssembleDisassembleRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-opentype")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fontFile := filepath.Join(dir, "font.ttf")
+	writeTestFont(t, fontFile)
+	srcFile := filepath.Join(dir, "src.txt")
+	err = ioutil.WriteFile(srcFile, []byte("PUSH 1 2 300 // comment\nSWAP\nIF\nPOP\nELSE\nMDAP[1]\nEIF\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "PUSHB[2] 1 2\nPUSHW[1] 300\nSWAP\nIF\n  POP\nELSE\n  MDAP[1]\nEIF\n"
+	for _, target := range []string{"1", "fpgm", "prep"} {
+		outFile := filepath.Join(dir, target+".ttf")
+		if _, err := runCommand(t, fontFile, "", target, srcFile, outFile); err != nil {
+			t.Fatalf("assembling %s failed: %s", target, err)
+		}
+		got, err := runCommand(t, outFile, target, "", "", "")
+		if err != nil {
+			t.Fatalf("disassembling %s failed: %s", target, err)
+		}
+		if want != got {
+			t.Errorf("program of %s is %q, want %q", target, got, want)
+		}
+		// the disassembled program is assembled into the same program.
+		err = ioutil.WriteFile(srcFile, []byte(got), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := runCommand(t, outFile, "", target, srcFile, outFile+".2"); err != nil {
+			t.Fatal(err)
+		}
+		if again, err := runCommand(t, outFile+".2", target, "", "", ""); err != nil || want != again {
+			t.Errorf("program of %s assembled from the disassembly is %q, %v", target, again, err)
+		}
+	}
+	if got, err := runCommand(t, fontFile, "1", "", "", ""); err != nil || "" != got {
+		t.Errorf("program of the source font is %q, %v", got, err)
+	}
+	_, err = runCommand(t, fontFile, "", "0", srcFile, filepath.Join(dir, "empty.ttf"))
+	if err == nil || !strings.Contains(err.Error(), "no outline") {
+		t.Errorf("assembling into the empty glyph returns %v", err)
+	}
+}
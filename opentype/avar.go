@@ -0,0 +1,284 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// Avar is an "avar" table.
+// The axis variations table modifies the normalization of the coordinates of the variation axes,
+// by the piecewise linear maps of the axes and, since version 2, the deltas depending on all axes.
+type Avar struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Segment maps of the axes, in the order of the axes of fvar.
+	SegmentMaps [][]*AxisValueMap
+	// Map from the axis indices to the delta-sets of VarStore, only in version 2.
+	AxisIndexMap *DeltaSetIndexMap
+	// Item variation store of the deltas of the normalized coordinates in 2.14 units, only in version 2.
+	VarStore *ItemVariationStore
+}
+
+// AxisValueMap maps a normalized coordinate to the modified one.
+type AxisValueMap struct {
+	// A normalized coordinate value obtained using default normalization.
+	FromCoordinate F2Dot14
+	// The modified, normalized coordinate value.
+	ToCoordinate F2Dot14
+}
+
+func parseAvar(f *os.File, offset, length uint32) (a *Avar, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	a = &Avar{}
+	a.MajorVersion = r.uint16()
+	a.MinorVersion = r.uint16()
+	// reserved
+	r.uint16()
+	axisCount := int(r.uint16())
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	a.SegmentMaps = make([][]*AxisValueMap, axisCount)
+	for i := range a.SegmentMaps {
+		count := int(r.uint16())
+		if !r.available(count, 4) {
+			// the offsets of avar2 follow the segment maps, so that they can not be found either.
+			return nil, r.errorf("failed to parse segment map: %s")
+		}
+		a.SegmentMaps[i] = make([]*AxisValueMap, count)
+		for j := range a.SegmentMaps[i] {
+			m := &AxisValueMap{}
+			r.read(m)
+			a.SegmentMaps[i][j] = m
+		}
+	}
+	if 2 <= a.MajorVersion {
+		axisIndexMapOffset := r.uint32()
+		varStoreOffset := r.uint32()
+		if 0 != axisIndexMapOffset {
+			a.AxisIndexMap = parseDeltaSetIndexMap(r, int64(axisIndexMapOffset))
+		}
+		if 0 != varStoreOffset {
+			a.VarStore = parseItemVariationStore(r, int64(varStoreOffset))
+		}
+	}
+	return a, r.errorf("failed to parse avar: %s")
+}
+
+// Tag is table name.
+func (a *Avar) Tag() Tag {
+	return String2Tag("avar")
+}
+
+// store writes binary expression of this table.
+func (a *Avar) store(w *errWriter) {
+	b, err := packOffsetNode(a.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+func (a *Avar) node() *offsetNode {
+	majorVersion := a.MajorVersion
+	if a.AxisIndexMap != nil || a.VarStore != nil {
+		majorVersion = 2
+	}
+	n := &offsetNode{}
+	n.uint16(majorVersion)
+	n.uint16(a.MinorVersion)
+	n.uint16(0)
+	n.uint16(uint16(len(a.SegmentMaps)))
+	for _, sm := range a.SegmentMaps {
+		n.uint16(uint16(len(sm)))
+		for _, m := range sm {
+			n.int16(int16(m.FromCoordinate))
+			n.int16(int16(m.ToCoordinate))
+		}
+	}
+	if 2 <= majorVersion {
+		if a.AxisIndexMap == nil {
+			n.uint32(0)
+		} else {
+			n.offset32(a.AxisIndexMap.node())
+		}
+		if a.VarStore == nil {
+			n.uint32(0)
+		} else {
+			n.offset32(a.VarStore.node())
+		}
+	}
+	return n
+}
+
+// CheckSum for this table.
+func (a *Avar) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(a)
+}
+
+// Length returns the size(byte) of this table.
+func (a *Avar) Length() uint32 {
+	b, err := packOffsetNode(a.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (a *Avar) Exists() bool {
+	return a != nil
+}
+
+// clone returns a deep copy of this table.
+func (a *Avar) clone() *Avar {
+	if a == nil {
+		return nil
+	}
+	c := *a
+	c.SegmentMaps = make([][]*AxisValueMap, len(a.SegmentMaps))
+	for i, sm := range a.SegmentMaps {
+		c.SegmentMaps[i] = make([]*AxisValueMap, len(sm))
+		for j, m := range sm {
+			cm := *m
+			c.SegmentMaps[i][j] = &cm
+		}
+	}
+	c.AxisIndexMap = a.AxisIndexMap.clone()
+	c.VarStore = a.VarStore.clone()
+	return &c
+}
+
+// mapCoordinates modifies the normalized coordinates by the segment maps, and then by the deltas of version 2.
+func (a *Avar) mapCoordinates(coords []F2Dot14) []F2Dot14 {
+	mapped := make([]F2Dot14, len(coords))
+	for i, v := range coords {
+		mapped[i] = v
+		if i < len(a.SegmentMaps) {
+			mapped[i] = mapSegment(a.SegmentMaps[i], v)
+		}
+	}
+	if a.VarStore == nil {
+		return mapped
+	}
+	// the deltas are interpolated at the coordinates mapped by the segment maps.
+	fs := coordinatesFloat(mapped)
+	ret := make([]F2Dot14, len(mapped))
+	for i, v := range mapped {
+		outer, inner := uint16(0), uint16(i)
+		if a.AxisIndexMap != nil {
+			outer, inner = a.AxisIndexMap.Get(i)
+		}
+		d := a.VarStore.Delta(outer, inner, fs)
+		ret[i] = clampF2Dot14(float64(v) + math.Floor(d+0.5))
+	}
+	return ret
+}
+
+// mapSegment maps the coordinate by the piecewise linear map of an axis.
+// The coordinates outside of the map are shifted by the nearest entry.
+func mapSegment(sm []*AxisValueMap, v F2Dot14) F2Dot14 {
+	if 0 == len(sm) {
+		return v
+	}
+	if v <= sm[0].FromCoordinate {
+		return clampF2Dot14(float64(v) + float64(sm[0].ToCoordinate-sm[0].FromCoordinate))
+	}
+	for i := 1; i < len(sm); i++ {
+		prev, next := sm[i-1], sm[i]
+		if v > next.FromCoordinate {
+			continue
+		}
+		if v == next.FromCoordinate || next.FromCoordinate == prev.FromCoordinate {
+			return next.ToCoordinate
+		}
+		t := float64(v-prev.FromCoordinate) / float64(next.FromCoordinate-prev.FromCoordinate)
+		return roundF2Dot14(float64(prev.ToCoordinate) + t*float64(next.ToCoordinate-prev.ToCoordinate))
+	}
+	last := sm[len(sm)-1]
+	return clampF2Dot14(float64(v) + float64(last.ToCoordinate-last.FromCoordinate))
+}
+
+// roundF2Dot14 rounds the value in 2.14 units.
+func roundF2Dot14(v float64) F2Dot14 {
+	return clampF2Dot14(math.Floor(v + 0.5))
+}
+
+// clampF2Dot14 limits the value in 2.14 units to the range of the normalized coordinates, from -1 to 1.
+func clampF2Dot14(v float64) F2Dot14 {
+	if v < -0x4000 {
+		return -0x4000
+	}
+	if v > 0x4000 {
+		return 0x4000
+	}
+	return F2Dot14(v)
+}
+
+// coordinatesFloat converts the normalized coordinates into float64.
+func coordinatesFloat(coords []F2Dot14) []float64 {
+	fs := make([]float64, len(coords))
+	for i, v := range coords {
+		fs[i] = v.Float()
+	}
+	return fs
+}
+
+// NormalizeCoordinates converts the location in the user space of the axes into the normalized coordinates, in the order of the axes of fvar.
+// Each value is clamped to the range of the axis and normalized to -1 to 1 by the default value, then modified by avar.
+// The coordinates are quantized to 2.14, as all variation tables use them.
+// The axes missing in the location are at their default values.
+func (font *Font) NormalizeCoordinates(location map[Tag]float64) ([]F2Dot14, error) {
+	err := tableRequired(font.Fvar)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing coordinates failed: %s", err)
+	}
+	for tag := range location {
+		if !font.hasAxis(tag) {
+			return nil, fmt.Errorf("normalizing coordinates failed: no axis %s", tag)
+		}
+	}
+	coords := make([]F2Dot14, len(font.Fvar.Axes))
+	for i, a := range font.Fvar.Axes {
+		v, ok := location[a.AxisTag]
+		if !ok {
+			continue
+		}
+		coords[i] = roundF2Dot14(normalizeAxisValue(a, v) * 0x4000)
+	}
+	if font.Avar.Exists() {
+		coords = font.Avar.mapCoordinates(coords)
+	}
+	return coords, nil
+}
+
+// normalizeAxisValue clamps the value in the user space to the range of the axis, and normalizes it to -1 to 1 by the default value without avar.
+func normalizeAxisValue(a *VariationAxisRecord, v float64) float64 {
+	min, def, max := a.MinValue.Float(), a.DefaultValue.Float(), a.MaxValue.Float()
+	v = math.Max(min, math.Min(max, v))
+	switch {
+	case v < def && def > min:
+		return (v - def) / (def - min)
+	case v > def && max > def:
+		return (v - def) / (max - def)
+	}
+	return 0
+}
+
+// hasAxis returns true if fvar has the axis.
+func (font *Font) hasAxis(tag Tag) bool {
+	for _, a := range font.Fvar.Axes {
+		if a.AxisTag == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package opentype
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// parseTestAvar writes the data into a file, and parses it as avar.
+func parseTestAvar(t *testing.T, data []byte) (*Avar, error) {
+	f, err := ioutil.TempFile("", "avar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseAvar(f, 0, uint32(len(data)))
+}
+
+func TestParseAvarTruncatedSegmentMap(t *testing.T) {
+	segmentMap := testTableData(3, 0xC000, 0xC000, 0, 0, 0x4000, 0x2000)
+	header := testTableData(2, 0, 0, 2)
+	offsets := testTableData(0, 0, 0, 0)
+	data := append(append(append([]byte{}, header...), segmentMap...), segmentMap...)
+	a, err := parseTestAvar(t, append(data, offsets...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 2 != len(a.SegmentMaps) || 3 != len(a.SegmentMaps[1]) || 0x2000 != a.SegmentMaps[1][2].ToCoordinate {
+		t.Errorf("segment maps are not parsed")
+	}
+	// the second segment map claims 3 maps, but the data ends at the offsets of avar2.
+	data = append(append(append([]byte{}, header...), segmentMap...), segmentMap[:2]...)
+	_, err = parseTestAvar(t, append(data, offsets...))
+	if err == nil {
+		t.Errorf("truncated segment map is parsed")
+	}
+}
+
+// newTestAvar returns avar for the axes of newTestFvarFont, that maps the weight 0.5 to 0.75 and keeps the width.
+// In version 2, the width grows by 0.25 at the maximum weight.
+func newTestAvar(version uint16) *Avar {
+	a := &Avar{
+		MajorVersion: version,
+		SegmentMaps: [][]*AxisValueMap{
+			{{-0x4000, -0x4000}, {0, 0}, {0x2000, 0x3000}, {0x4000, 0x4000}},
+			{{-0x4000, -0x4000}, {0, 0}, {0x4000, 0x4000}},
+		},
+	}
+	if 2 == version {
+		a.VarStore = &ItemVariationStore{
+			Format:           1,
+			AxisCount:        2,
+			VariationRegions: [][]*RegionAxisCoordinates{{{StartCoord: 0, PeakCoord: 0x4000, EndCoord: 0x4000}, {}}},
+			ItemVariationData: []*ItemVariationData{
+				{RegionIndexes: []uint16{0}, DeltaSets: [][]int32{{0}, {0x1000}}},
+			},
+		}
+	}
+	return a
+}
+
+func TestAvarRoundTrip(t *testing.T) {
+	for _, version := range []uint16{1, 2} {
+		font := newTestFvarFont(t)
+		font.Avar = newTestAvar(version)
+		parsed := writeTestFont(t, font)
+		if !reflect.DeepEqual(font.Avar, parsed.Avar) {
+			t.Errorf("avar version %d is %+v, want %+v", version, *parsed.Avar, *font.Avar)
+		}
+	}
+}
+
+func TestNormalizeCoordinates(t *testing.T) {
+	font := newTestFvarFont(t)
+	wght, wdth := String2Tag("wght"), String2Tag("wdth")
+	tests := []struct {
+		name     string
+		avar     *Avar
+		location map[Tag]float64
+		want     []F2Dot14
+	}{
+		{"default", nil, nil, []F2Dot14{0, 0}},
+		{"without avar", nil, map[Tag]float64{wght: 650, wdth: 75}, []F2Dot14{0x2000, -0x2000}},
+		{"clamped", nil, map[Tag]float64{wght: 1000, wdth: 0}, []F2Dot14{0x4000, -0x4000}},
+		{"segment map", newTestAvar(1), map[Tag]float64{wght: 650, wdth: 75}, []F2Dot14{0x3000, -0x2000}},
+		{"interpolated", newTestAvar(1), map[Tag]float64{wght: 525}, []F2Dot14{0x1800, 0}},
+		{"negative", newTestAvar(1), map[Tag]float64{wght: 250}, []F2Dot14{-0x2000, 0}},
+		{"deltas", newTestAvar(2), map[Tag]float64{wght: 900}, []F2Dot14{0x4000, 0x1000}},
+		// the deltas are interpolated at the coordinates mapped by the segment maps.
+		{"interpolated deltas", newTestAvar(2), map[Tag]float64{wght: 650, wdth: 75}, []F2Dot14{0x3000, -0x2000 + 0xC00}},
+	}
+	for _, tt := range tests {
+		font.Avar = tt.avar
+		got, err := font.NormalizeCoordinates(tt.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%s: coordinates are %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := font.NormalizeCoordinates(map[Tag]float64{String2Tag("opsz"): 12}); err == nil {
+		t.Errorf("unknown axis is normalized")
+	}
+}
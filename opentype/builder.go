@@ -44,12 +44,14 @@ func (b *Builder) WithTables(tables []Table) *Builder {
 }
 
 // Build creates new font file.
+// hmtx and vmtx are written optimized with hhea and vhea by Hmtx.Optimize and Vmtx.Optimize, without modifying the tables of Builder.
 func (b *Builder) Build(writer io.Writer) (err error) {
-	numTables := len(b.tables)
+	tables := optimizeMetrics(b.tables)
+	numTables := len(tables)
 	offsetTable := createOffsetTable(b.sfntVersion, uint16(numTables))
 	offset := offsetTable.Length() + TableRecordLength*uint32(numTables)
 	tableRecords := make(map[string]*TableRecord, numTables)
-	for _, t := range b.tables {
+	for _, t := range tables {
 		tr, err := createTableRecord(t, offset)
 		if err != nil {
 			return fmt.Errorf("failed to create TableRecord: %s cause: %s", t.Tag(), err)
@@ -59,11 +61,41 @@ func (b *Builder) Build(writer io.Writer) (err error) {
 	}
 	w := newErrWriter(writer)
 	w.write(offsetTable)
-	for _, t := range b.tables {
+	for _, t := range tables {
 		w.write(tableRecords[t.Tag().String()])
 	}
-	for _, t := range b.tables {
+	for _, t := range tables {
 		t.store(w)
 	}
 	return w.errorf("failed to create font file: %s")
 }
+
+// optimizeMetrics returns the tables whose hmtx and vmtx are replaced with the optimized copies, and hhea and vhea with the copies updated for them.
+// The metrics are kept as they are if the header table of them is missing.
+func optimizeMetrics(tables []Table) []Table {
+	ret := append([]Table{}, tables...)
+	hhea, hmtx, vhea, vmtx := -1, -1, -1, -1
+	for i, t := range ret {
+		switch t.(type) {
+		case *Hhea:
+			hhea = i
+		case *Hmtx:
+			hmtx = i
+		case *Vhea:
+			vhea = i
+		case *Vmtx:
+			vmtx = i
+		}
+	}
+	if 0 <= hhea && 0 <= hmtx {
+		h, m := ret[hhea].(*Hhea).clone(), ret[hmtx].(*Hmtx).clone()
+		m.Optimize(h)
+		ret[hhea], ret[hmtx] = h, m
+	}
+	if 0 <= vhea && 0 <= vmtx {
+		h, m := ret[vhea].(*Vhea).clone(), ret[vmtx].(*Vmtx).clone()
+		m.Optimize(h)
+		ret[vhea], ret[vmtx] = h, m
+	}
+	return ret
+}
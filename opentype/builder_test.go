@@ -0,0 +1,26 @@
+package opentype
+
+import (
+	"testing"
+)
+
+func TestBuildOptimizesHmtx(t *testing.T) {
+	glyphs := []*Glyph{{}, testSquare(0, 0, 100), testSquare(0, 0, 100), testSquare(10, 0, 100)}
+	font := newTestFont(t, glyphs, []uint16{500, 600, 600, 600})
+	if 2 != font.Hhea.NumberOfHMetrics || 2 != len(font.Hmtx.HMetrics) {
+		t.Fatalf("numberOfHMetrics is %d, want 2", font.Hhea.NumberOfHMetrics)
+	}
+	advanceWidth, lsb := font.Hmtx.get(3)
+	if 600 != advanceWidth || 10 != lsb {
+		t.Errorf("metrics of glyph 3 are %d, %d, want 600, 10", advanceWidth, lsb)
+	}
+	src := &Hmtx{HMetrics: []*LongHorMetric{{500, 0}, {600, 0}, {600, 0}}}
+	hhea := &Hhea{NumberOfHMetrics: 3}
+	tables := optimizeMetrics([]Table{hhea, src})
+	if 2 != tables[0].(*Hhea).NumberOfHMetrics {
+		t.Errorf("numberOfHMetrics of the written hhea is %d, want 2", tables[0].(*Hhea).NumberOfHMetrics)
+	}
+	if 3 != hhea.NumberOfHMetrics || 3 != len(src.HMetrics) {
+		t.Errorf("the tables of Builder are modified")
+	}
+}
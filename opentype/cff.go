@@ -0,0 +1,710 @@
+package opentype
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Cff is a "CFF " table.
+// The Compact Font Format table contains the PostScript outlines of the glyphs of the fonts with CFF outlines.
+// The table is written as it is parsed, and its charstrings are interpreted only for the bounding boxes of the glyphs.
+type Cff struct {
+	data        []byte
+	charStrings [][]byte
+	globalSubrs [][]byte
+	// local subroutines of each font dict, that a font without CID has only one of.
+	localSubrs [][][]byte
+	// index of the font dict of each glyph, or nil if the font has no CID.
+	fdSelect []uint8
+}
+
+// top dict and private dict operators, whose escaped ones are 1200 + the second byte.
+const (
+	cffOpCharStrings    = 17
+	cffOpPrivate        = 18
+	cffOpSubrs          = 19
+	cffOpCharstringType = 1206
+	cffOpFDArray        = 1236
+	cffOpFDSelect       = 1237
+)
+
+func parseCff(f *os.File, offset, length uint32) (c *Cff, err error) {
+	_, err = f.Seek(int64(offset), 0)
+	if err != nil {
+		return
+	}
+	c = &Cff{
+		data: make([]byte, length),
+	}
+	_, err = io.ReadFull(f, c.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CFF: %s", err)
+	}
+	err = c.parse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CFF: %s", err)
+	}
+	return c, nil
+}
+
+// parse reads the indices of the charstrings and the subroutines from the data.
+func (c *Cff) parse() error {
+	d := c.data
+	if len(d) < 4 {
+		return fmt.Errorf("header is truncated")
+	}
+	if 1 != d[0] {
+		return fmt.Errorf("major version %d is not supported", d[0])
+	}
+	_, next, err := parseCffIndex(d, int(d[2]))
+	if err != nil {
+		return fmt.Errorf("name index: %s", err)
+	}
+	topDicts, next, err := parseCffIndex(d, next)
+	if err != nil {
+		return fmt.Errorf("top dict index: %s", err)
+	}
+	if 0 == len(topDicts) {
+		return fmt.Errorf("no top dict")
+	}
+	_, next, err = parseCffIndex(d, next)
+	if err != nil {
+		return fmt.Errorf("string index: %s", err)
+	}
+	c.globalSubrs, _, err = parseCffIndex(d, next)
+	if err != nil {
+		return fmt.Errorf("global subrs index: %s", err)
+	}
+	top, err := parseCffDict(topDicts[0])
+	if err != nil {
+		return fmt.Errorf("top dict: %s", err)
+	}
+	if t, ok := top[cffOpCharstringType]; ok && (1 != len(t) || 2 != t[0]) {
+		return fmt.Errorf("charstring type %v is not supported", t)
+	}
+	cs, ok := top[cffOpCharStrings]
+	if !ok || 1 != len(cs) {
+		return fmt.Errorf("no charstrings")
+	}
+	c.charStrings, _, err = parseCffIndex(d, int(cs[0]))
+	if err != nil {
+		return fmt.Errorf("charstrings index: %s", err)
+	}
+	fdArray, ok := top[cffOpFDArray]
+	if !ok {
+		subrs, err := c.parsePrivate(top)
+		if err != nil {
+			return err
+		}
+		c.localSubrs = [][][]byte{subrs}
+		return nil
+	}
+	if 1 != len(fdArray) {
+		return fmt.Errorf("invalid font dict index")
+	}
+	fontDicts, _, err := parseCffIndex(d, int(fdArray[0]))
+	if err != nil {
+		return fmt.Errorf("font dict index: %s", err)
+	}
+	for i, fd := range fontDicts {
+		dict, err := parseCffDict(fd)
+		if err != nil {
+			return fmt.Errorf("font dict %d: %s", i, err)
+		}
+		subrs, err := c.parsePrivate(dict)
+		if err != nil {
+			return fmt.Errorf("font dict %d: %s", i, err)
+		}
+		c.localSubrs = append(c.localSubrs, subrs)
+	}
+	fdSelect, ok := top[cffOpFDSelect]
+	if !ok || 1 != len(fdSelect) {
+		return fmt.Errorf("no font dict select")
+	}
+	c.fdSelect, err = parseCffFDSelect(d, int(fdSelect[0]), len(c.charStrings))
+	if err != nil {
+		return fmt.Errorf("font dict select: %s", err)
+	}
+	return nil
+}
+
+// parsePrivate returns the local subroutines of the private dict that the dict refers to.
+func (c *Cff) parsePrivate(dict map[int][]float64) ([][]byte, error) {
+	p, ok := dict[cffOpPrivate]
+	if !ok {
+		return nil, nil
+	}
+	if 2 != len(p) || p[0] < 0 || p[1] < 0 || int(p[0]+p[1]) > len(c.data) {
+		return nil, fmt.Errorf("invalid private dict")
+	}
+	size, offset := int(p[0]), int(p[1])
+	private, err := parseCffDict(c.data[offset : offset+size])
+	if err != nil {
+		return nil, fmt.Errorf("private dict: %s", err)
+	}
+	s, ok := private[cffOpSubrs]
+	if !ok || 1 != len(s) {
+		return nil, nil
+	}
+	subrs, _, err := parseCffIndex(c.data, offset+int(s[0]))
+	if err != nil {
+		return nil, fmt.Errorf("local subrs index: %s", err)
+	}
+	return subrs, nil
+}
+
+// parseCffIndex returns the objects of the index at the offset, and the offset that follows the index.
+func parseCffIndex(d []byte, offset int) (objects [][]byte, next int, err error) {
+	if offset < 0 || offset+2 > len(d) {
+		return nil, 0, fmt.Errorf("offset %d is out of the table", offset)
+	}
+	count := int(binary.BigEndian.Uint16(d[offset:]))
+	if 0 == count {
+		return nil, offset + 2, nil
+	}
+	if offset+3 > len(d) {
+		return nil, 0, fmt.Errorf("index is truncated")
+	}
+	offSize := int(d[offset+2])
+	if offSize < 1 || 4 < offSize {
+		return nil, 0, fmt.Errorf("invalid offset size %d", offSize)
+	}
+	start := offset + 3
+	base := start + (count+1)*offSize - 1
+	if base >= len(d) {
+		return nil, 0, fmt.Errorf("index is truncated")
+	}
+	offsets := make([]int, count+1)
+	for i := range offsets {
+		v := 0
+		for _, b := range d[start+i*offSize : start+(i+1)*offSize] {
+			v = v<<8 | int(b)
+		}
+		offsets[i] = base + v
+		if offsets[i] > len(d) || (0 < i && offsets[i] < offsets[i-1]) {
+			return nil, 0, fmt.Errorf("invalid offset of object %d", i)
+		}
+	}
+	objects = make([][]byte, count)
+	for i := range objects {
+		objects[i] = d[offsets[i]:offsets[i+1]]
+	}
+	return objects, offsets[count], nil
+}
+
+// parseCffDict returns the operands of the operators of the dict.
+func parseCffDict(d []byte) (map[int][]float64, error) {
+	dict := map[int][]float64{}
+	var operands []float64
+	for i := 0; i < len(d); {
+		b0 := d[i]
+		switch {
+		case b0 <= 21:
+			op := int(b0)
+			i++
+			if 12 == b0 {
+				if i >= len(d) {
+					return nil, fmt.Errorf("operator is truncated")
+				}
+				op = 1200 + int(d[i])
+				i++
+			}
+			dict[op] = operands
+			operands = nil
+		case 30 == b0:
+			v, n, err := parseCffReal(d[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, v)
+			i += 1 + n
+		default:
+			v, n, err := parseCffInteger(d[i:], true)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, v)
+			i += n
+		}
+	}
+	return dict, nil
+}
+
+// parseCffInteger returns the integer operand at the beginning of d and its size.
+// 29 is a 32-bit integer in a dict, and 255 is a 16.16 fixed-point number in a charstring.
+func parseCffInteger(d []byte, inDict bool) (v float64, n int, err error) {
+	b0 := d[0]
+	size := 1
+	switch {
+	case 28 == b0:
+		size = 3
+	case 29 == b0 && inDict, 255 == b0 && !inDict:
+		size = 5
+	case 247 <= b0 && b0 <= 254:
+		size = 2
+	case b0 < 32 || 255 == b0:
+		return 0, 0, fmt.Errorf("invalid operand %d", b0)
+	}
+	if len(d) < size {
+		return 0, 0, fmt.Errorf("operand is truncated")
+	}
+	switch {
+	case 28 == b0:
+		v = float64(int16(binary.BigEndian.Uint16(d[1:])))
+	case 29 == b0:
+		v = float64(int32(binary.BigEndian.Uint32(d[1:])))
+	case 255 == b0:
+		v = float64(int32(binary.BigEndian.Uint32(d[1:]))) / 0x10000
+	case b0 <= 246:
+		v = float64(int(b0) - 139)
+	case b0 <= 250:
+		v = float64((int(b0)-247)*256 + int(d[1]) + 108)
+	default:
+		v = float64(-(int(b0)-251)*256 - int(d[1]) - 108)
+	}
+	return v, size, nil
+}
+
+// parseCffReal returns the real number operand of the nibbles at the beginning of d and its size.
+func parseCffReal(d []byte) (float64, int, error) {
+	s := ""
+	for i, b := range d {
+		for _, nibble := range []byte{b >> 4, b & 0xF} {
+			switch {
+			case nibble <= 9:
+				s += string('0' + rune(nibble))
+			case 0xA == nibble:
+				s += "."
+			case 0xB == nibble:
+				s += "E"
+			case 0xC == nibble:
+				s += "E-"
+			case 0xE == nibble:
+				s += "-"
+			case 0xF == nibble:
+				var v float64
+				_, err := fmt.Sscan(s, &v)
+				if err != nil && "" != s {
+					return 0, 0, fmt.Errorf("invalid real number %s", s)
+				}
+				return v, i + 1, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("real number is truncated")
+}
+
+// parseCffFDSelect returns the index of the font dict of each glyph, from the font dict select of format 0 or 3 at the offset.
+func parseCffFDSelect(d []byte, offset, numGlyphs int) ([]uint8, error) {
+	if offset < 0 || offset >= len(d) {
+		return nil, fmt.Errorf("offset %d is out of the table", offset)
+	}
+	fds := make([]uint8, numGlyphs)
+	switch d[offset] {
+	case 0:
+		if offset+1+numGlyphs > len(d) {
+			return nil, fmt.Errorf("format 0 is truncated")
+		}
+		copy(fds, d[offset+1:])
+	case 3:
+		if offset+3 > len(d) {
+			return nil, fmt.Errorf("format 3 is truncated")
+		}
+		nRanges := int(binary.BigEndian.Uint16(d[offset+1:]))
+		ranges := offset + 3
+		if ranges+3*nRanges+2 > len(d) {
+			return nil, fmt.Errorf("format 3 is truncated")
+		}
+		for i := 0; i < nRanges; i++ {
+			r := ranges + 3*i
+			first, last := int(binary.BigEndian.Uint16(d[r:])), int(binary.BigEndian.Uint16(d[r+3:]))
+			for gid := first; gid < last && gid < numGlyphs; gid++ {
+				fds[gid] = d[r+2]
+			}
+		}
+	default:
+		return nil, fmt.Errorf("format %d is not supported", d[offset])
+	}
+	return fds, nil
+}
+
+// Tag is table name.
+func (c *Cff) Tag() Tag {
+	return String2Tag("CFF ")
+}
+
+// store writes binary expression of this table.
+func (c *Cff) store(w *errWriter) {
+	w.writeBin(c.data)
+	padSpace(w, c.Length())
+}
+
+// CheckSum for this table.
+func (c *Cff) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(c)
+}
+
+// Length returns the size(byte) of this table.
+func (c *Cff) Length() uint32 {
+	return uint32(len(c.data))
+}
+
+// Exists returns true if this is not nil.
+func (c *Cff) Exists() bool {
+	return c != nil
+}
+
+// clone returns a copy of this table.
+// The parsed indices refer to the data, that is never modified, so they are shared.
+func (c *Cff) clone() *Cff {
+	if c == nil {
+		return nil
+	}
+	cc := *c
+	return &cc
+}
+
+// Len returns the number of the glyphs.
+func (c *Cff) Len() int {
+	return len(c.charStrings)
+}
+
+// Bounds returns the bounding box of the outline of the glyph, in font design units.
+// The minimums are rounded down and the maximums are rounded up, and the box of an empty glyph is all zero.
+func (c *Cff) Bounds(gid uint16) (xMin, yMin, xMax, yMax int16, err error) {
+	if int(gid) >= len(c.charStrings) {
+		return 0, 0, 0, 0, fmt.Errorf("glyph %d does not exist", gid)
+	}
+	fd := 0
+	if c.fdSelect != nil {
+		fd = int(c.fdSelect[gid])
+	}
+	if fd >= len(c.localSubrs) {
+		return 0, 0, 0, 0, fmt.Errorf("glyph %d refers to font dict %d that does not exist", gid, fd)
+	}
+	ci := &charstringInterpreter{
+		globalSubrs: c.globalSubrs,
+		localSubrs:  c.localSubrs[fd],
+		bounds:      cffBounds{xMin: math.Inf(1), yMin: math.Inf(1), xMax: math.Inf(-1), yMax: math.Inf(-1)},
+	}
+	err = ci.run(c.charStrings[gid], 0)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("glyph %d: %s", gid, err)
+	}
+	b := ci.bounds
+	if b.xMin > b.xMax {
+		return 0, 0, 0, 0, nil
+	}
+	return int16(math.Floor(b.xMin)), int16(math.Floor(b.yMin)), int16(math.Ceil(b.xMax)), int16(math.Ceil(b.yMax)), nil
+}
+
+// cffBounds is the bounding box of an outline in progress.
+type cffBounds struct {
+	xMin, yMin, xMax, yMax float64
+}
+
+func (b *cffBounds) add(x, y float64) {
+	b.xMin, b.yMin = math.Min(b.xMin, x), math.Min(b.yMin, y)
+	b.xMax, b.yMax = math.Max(b.xMax, x), math.Max(b.yMax, y)
+}
+
+// cffSubrsLimit is the maximum nesting depth of the subroutine calls of a charstring.
+const cffSubrsLimit = 10
+
+// charstringInterpreter runs a Type 2 charstring to find the bounding box of its outline.
+// The hints are only counted to skip the masks, and the arithmetic operators are not supported.
+type charstringInterpreter struct {
+	globalSubrs [][]byte
+	localSubrs  [][]byte
+	stack       []float64
+	x, y        float64
+	nStems      int
+	// whether the width, that is the first operand of the first stack-clearing operator if it has an extra one, is already read.
+	seenWidth bool
+	// whether the current point is the start of a contour that has no segment yet.
+	moved  bool
+	ended  bool
+	bounds cffBounds
+}
+
+// cffSubrBias returns the bias of the subroutine numbers of the subroutines.
+func cffSubrBias(subrs [][]byte) int {
+	switch n := len(subrs); {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	default:
+		return 32768
+	}
+}
+
+// width removes the width from the bottom of the stack, if the operator of the expected number of operands has an extra one.
+func (ci *charstringInterpreter) width(odd bool) {
+	if !ci.seenWidth && odd && 0 < len(ci.stack) {
+		ci.stack = ci.stack[1:]
+	}
+	ci.seenWidth = true
+}
+
+// moveTo starts a new contour at the current point moved by (dx, dy).
+func (ci *charstringInterpreter) moveTo(dx, dy float64) {
+	ci.x += dx
+	ci.y += dy
+	ci.moved = true
+}
+
+// lineTo adds the line from the current point to the point moved by (dx, dy).
+func (ci *charstringInterpreter) lineTo(dx, dy float64) {
+	ci.begin()
+	ci.x += dx
+	ci.y += dy
+	ci.bounds.add(ci.x, ci.y)
+}
+
+// curveTo adds the cubic Bézier curve from the current point by the relative control points.
+func (ci *charstringInterpreter) curveTo(dxa, dya, dxb, dyb, dxc, dyc float64) {
+	ci.begin()
+	x0, y0 := ci.x, ci.y
+	x1, y1 := x0+dxa, y0+dya
+	x2, y2 := x1+dxb, y1+dyb
+	x3, y3 := x2+dxc, y2+dyc
+	ci.bounds.add(x3, y3)
+	for _, t := range cubicExtrema(x0, x1, x2, x3) {
+		ci.bounds.add(cubicAt(x0, x1, x2, x3, t), cubicAt(y0, y1, y2, y3, t))
+	}
+	for _, t := range cubicExtrema(y0, y1, y2, y3) {
+		ci.bounds.add(cubicAt(x0, x1, x2, x3, t), cubicAt(y0, y1, y2, y3, t))
+	}
+	ci.x, ci.y = x3, y3
+}
+
+// begin adds the start point of the contour to the bounds, when the contour has its first segment.
+func (ci *charstringInterpreter) begin() {
+	if ci.moved || !ci.seenPoint() {
+		ci.bounds.add(ci.x, ci.y)
+		ci.moved = false
+	}
+}
+
+// seenPoint returns true if the bounds have a point.
+func (ci *charstringInterpreter) seenPoint() bool {
+	return ci.bounds.xMin <= ci.bounds.xMax
+}
+
+// cubicAt returns the coordinate of the cubic Bézier curve at t.
+func cubicAt(p0, p1, p2, p3, t float64) float64 {
+	s := 1 - t
+	return s*s*s*p0 + 3*s*s*t*p1 + 3*s*t*t*p2 + t*t*t*p3
+}
+
+// cubicExtrema returns the parameters in (0, 1) where the derivative of the cubic Bézier curve is zero.
+func cubicExtrema(p0, p1, p2, p3 float64) []float64 {
+	// the derivative is a t^2 + b t + c.
+	a := 3 * (-p0 + 3*p1 - 3*p2 + p3)
+	b := 6 * (p0 - 2*p1 + p2)
+	c := 3 * (p1 - p0)
+	var ts []float64
+	if math.Abs(a) < 1e-12 {
+		if 0 != b {
+			ts = append(ts, -c/b)
+		}
+	} else if d := b*b - 4*a*c; d >= 0 {
+		sq := math.Sqrt(d)
+		ts = append(ts, (-b+sq)/(2*a), (-b-sq)/(2*a))
+	}
+	var inside []float64
+	for _, t := range ts {
+		if 0 < t && t < 1 {
+			inside = append(inside, t)
+		}
+	}
+	return inside
+}
+
+// run interprets the charstring, that is a subroutine at the depth if it is not zero.
+func (ci *charstringInterpreter) run(cs []byte, depth int) error {
+	if depth > cffSubrsLimit {
+		return fmt.Errorf("subroutines are nested too deeply")
+	}
+	for i := 0; i < len(cs) && !ci.ended; {
+		b0 := cs[i]
+		if 28 == b0 || 32 <= b0 {
+			v, n, err := parseCffInteger(cs[i:], false)
+			if err != nil {
+				return err
+			}
+			ci.stack = append(ci.stack, v)
+			i += n
+			continue
+		}
+		op := int(b0)
+		i++
+		if 12 == b0 {
+			if i >= len(cs) {
+				return fmt.Errorf("operator is truncated")
+			}
+			op = 1200 + int(cs[i])
+			i++
+		}
+		s := ci.stack
+		switch op {
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			ci.width(1 == len(s)%2)
+			ci.nStems += len(ci.stack) / 2
+		case 19, 20: // hintmask, cntrmask
+			ci.width(1 == len(s)%2)
+			ci.nStems += len(ci.stack) / 2
+			i += (ci.nStems + 7) / 8
+		case 21: // rmoveto
+			ci.width(2 < len(s))
+			if len(ci.stack) < 2 {
+				return fmt.Errorf("rmoveto needs 2 operands")
+			}
+			ci.moveTo(ci.stack[0], ci.stack[1])
+		case 22: // hmoveto
+			ci.width(1 < len(s))
+			if len(ci.stack) < 1 {
+				return fmt.Errorf("hmoveto needs 1 operand")
+			}
+			ci.moveTo(ci.stack[0], 0)
+		case 4: // vmoveto
+			ci.width(1 < len(s))
+			if len(ci.stack) < 1 {
+				return fmt.Errorf("vmoveto needs 1 operand")
+			}
+			ci.moveTo(0, ci.stack[0])
+		case 5: // rlineto
+			for k := 0; k+1 < len(s); k += 2 {
+				ci.lineTo(s[k], s[k+1])
+			}
+		case 6, 7: // hlineto, vlineto
+			horizontal := 6 == op
+			for _, d := range s {
+				if horizontal {
+					ci.lineTo(d, 0)
+				} else {
+					ci.lineTo(0, d)
+				}
+				horizontal = !horizontal
+			}
+		case 8: // rrcurveto
+			for k := 0; k+5 < len(s); k += 6 {
+				ci.curveTo(s[k], s[k+1], s[k+2], s[k+3], s[k+4], s[k+5])
+			}
+		case 24: // rcurveline
+			k := 0
+			for ; k+5 < len(s)-2; k += 6 {
+				ci.curveTo(s[k], s[k+1], s[k+2], s[k+3], s[k+4], s[k+5])
+			}
+			if k+1 < len(s) {
+				ci.lineTo(s[k], s[k+1])
+			}
+		case 25: // rlinecurve
+			k := 0
+			for ; k+1 < len(s)-6; k += 2 {
+				ci.lineTo(s[k], s[k+1])
+			}
+			if k+5 < len(s) {
+				ci.curveTo(s[k], s[k+1], s[k+2], s[k+3], s[k+4], s[k+5])
+			}
+		case 26: // vvcurveto
+			k, dx1 := 0, 0.0
+			if 1 == len(s)%2 {
+				dx1, k = s[0], 1
+			}
+			for ; k+3 < len(s); k += 4 {
+				ci.curveTo(dx1, s[k], s[k+1], s[k+2], 0, s[k+3])
+				dx1 = 0
+			}
+		case 27: // hhcurveto
+			k, dy1 := 0, 0.0
+			if 1 == len(s)%2 {
+				dy1, k = s[0], 1
+			}
+			for ; k+3 < len(s); k += 4 {
+				ci.curveTo(s[k], dy1, s[k+1], s[k+2], s[k+3], 0)
+				dy1 = 0
+			}
+		case 30, 31: // vhcurveto, hvcurveto
+			horizontal := 31 == op
+			for k := 0; k+3 < len(s); k += 4 {
+				last := 0.0
+				if k+5 == len(s) {
+					last = s[k+4]
+				}
+				if horizontal {
+					ci.curveTo(s[k], 0, s[k+1], s[k+2], last, s[k+3])
+				} else {
+					ci.curveTo(0, s[k], s[k+1], s[k+2], s[k+3], last)
+				}
+				horizontal = !horizontal
+			}
+		case 1235: // flex
+			if len(s) < 13 {
+				return fmt.Errorf("flex needs 13 operands")
+			}
+			ci.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			ci.curveTo(s[6], s[7], s[8], s[9], s[10], s[11])
+		case 1234: // hflex
+			if len(s) < 7 {
+				return fmt.Errorf("hflex needs 7 operands")
+			}
+			ci.curveTo(s[0], 0, s[1], s[2], s[3], 0)
+			ci.curveTo(s[4], 0, s[5], -s[2], s[6], 0)
+		case 1236: // hflex1
+			if len(s) < 9 {
+				return fmt.Errorf("hflex1 needs 9 operands")
+			}
+			y := ci.y
+			ci.curveTo(s[0], s[1], s[2], s[3], s[4], 0)
+			ci.curveTo(s[5], 0, s[6], s[7], s[8], y-(ci.y+s[7]))
+		case 1237: // flex1
+			if len(s) < 11 {
+				return fmt.Errorf("flex1 needs 11 operands")
+			}
+			dx := s[0] + s[2] + s[4] + s[6] + s[8]
+			dy := s[1] + s[3] + s[5] + s[7] + s[9]
+			x, y := ci.x, ci.y
+			ci.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			if math.Abs(dx) > math.Abs(dy) {
+				ci.curveTo(s[6], s[7], s[8], s[9], s[10], y-(ci.y+s[7]+s[9]))
+			} else {
+				ci.curveTo(s[6], s[7], s[8], s[9], x-(ci.x+s[6]+s[8]), s[10])
+			}
+		case 10, 29: // callsubr, callgsubr
+			subrs := ci.localSubrs
+			if 29 == op {
+				subrs = ci.globalSubrs
+			}
+			if 0 == len(s) {
+				return fmt.Errorf("subroutine call needs an operand")
+			}
+			n := int(s[len(s)-1]) + cffSubrBias(subrs)
+			if n < 0 || n >= len(subrs) {
+				return fmt.Errorf("subroutine %d does not exist", n)
+			}
+			ci.stack = s[:len(s)-1]
+			err := ci.run(subrs[n], depth+1)
+			if err != nil {
+				return err
+			}
+			continue
+		case 11: // return
+			return nil
+		case 14: // endchar
+			ci.width(1 == len(s)%2)
+			if 4 <= len(ci.stack) {
+				return fmt.Errorf("endchar of an accented character is not supported")
+			}
+			ci.ended = true
+		default:
+			return fmt.Errorf("operator %d is not supported", op)
+		}
+		ci.stack = ci.stack[:0]
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package opentype
+
+import (
+	"testing"
+)
+
+// testCffIndex returns the CFF index of the objects, whose offsets are 2 bytes.
+func testCffIndex(objects ...[]byte) []byte {
+	if 0 == len(objects) {
+		return []byte{0, 0}
+	}
+	b := []byte{byte(len(objects) >> 8), byte(len(objects)), 2}
+	offset := 1
+	for i := 0; i <= len(objects); i++ {
+		b = append(b, byte(offset>>8), byte(offset))
+		if i < len(objects) {
+			offset += len(objects[i])
+		}
+	}
+	for _, o := range objects {
+		b = append(b, o...)
+	}
+	return b
+}
+
+// testCffNumber returns the operand of the number in 2 bytes, that is valid in both dicts and charstrings.
+func testCffNumber(v int) []byte {
+	return []byte{28, byte(v >> 8), byte(v)}
+}
+
+// testCffOp returns the numbers followed by the operator.
+func testCffOp(op byte, values ...int) []byte {
+	var b []byte
+	for _, v := range values {
+		b = append(b, testCffNumber(v)...)
+	}
+	return append(b, op)
+}
+
+// newTestCff returns CFF without CID of the charstrings, that have a local and a global subroutine:
+// glyph 1 is a box of a line and a curve from (50, 0) to (150, 60), whose control points reach 80,
+// glyph 2 is the box from (10, 20) to (40, 60) drawn by the local subroutine,
+// and glyph 3 has hints and is the line from (-5, 0) to (0, 5) drawn by the global subroutine.
+func newTestCff(t *testing.T) *Cff {
+	charStrings := [][]byte{
+		{14},
+		concatBytes(testCffOp(21, 600, 50, 0), testCffOp(6, 100), testCffOp(8, 0, 80, -100, 0, 0, -80), []byte{14}),
+		concatBytes(testCffOp(21, 10, 20), []byte{32, 10, 14}),
+		concatBytes(testCffOp(18, 0, 10, 20, 10), []byte{19, 0xC0}, testCffOp(21, -5, 0), []byte{32, 29, 14}),
+	}
+	localSubrs := testCffIndex(concatBytes(testCffOp(6, 30), testCffOp(7, 40), []byte{11}))
+	globalSubrs := testCffIndex(concatBytes(testCffOp(5, 5, 5), []byte{11}))
+	header := []byte{1, 0, 4, 2}
+	name := testCffIndex([]byte("T"))
+	// the top dict has the offsets of the fixed size.
+	topSize := len(testCffIndex(make([]byte, 11)))
+	charStringsOffset := len(header) + len(name) + topSize + len(testCffIndex()) + len(globalSubrs)
+	charStringsIndex := testCffIndex(charStrings...)
+	private := testCffOp(19, 4)
+	privateOffset := charStringsOffset + len(charStringsIndex)
+	top := concatBytes(testCffOp(17, charStringsOffset), testCffOp(18, len(private), privateOffset))
+	d := concatBytes(header, name, testCffIndex(top), testCffIndex(), globalSubrs, charStringsIndex, private, localSubrs)
+	c := &Cff{data: d}
+	err := c.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func concatBytes(bs ...[]byte) []byte {
+	var b []byte
+	for _, v := range bs {
+		b = append(b, v...)
+	}
+	return b
+}
+
+func TestCffBounds(t *testing.T) {
+	c := newTestCff(t)
+	if 4 != c.Len() {
+		t.Fatalf("CFF has %d glyphs, want 4", c.Len())
+	}
+	tests := []struct {
+		gid  uint16
+		want [4]int16
+	}{
+		{0, [4]int16{0, 0, 0, 0}},
+		{1, [4]int16{50, 0, 150, 60}},
+		{2, [4]int16{10, 20, 40, 60}},
+		{3, [4]int16{-5, 0, 0, 5}},
+	}
+	for _, tt := range tests {
+		xMin, yMin, xMax, yMax, err := c.Bounds(tt.gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := [4]int16{xMin, yMin, xMax, yMax}; got != tt.want {
+			t.Errorf("bounds of glyph %d are %v, want %v", tt.gid, got, tt.want)
+		}
+	}
+	if _, _, _, _, err := c.Bounds(4); err == nil {
+		t.Errorf("bounds of a glyph that does not exist are available")
+	}
+}
+
+func TestCffFDSelect(t *testing.T) {
+	d := []byte{3, 0, 2, 0, 0, 1, 0, 2, 0, 0, 4}
+	fds, err := parseCffFDSelect(d, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint8{1, 1, 0, 0}; string(want) != string(fds) {
+		t.Errorf("font dicts are %v, want %v", fds, want)
+	}
+	if _, err := parseCffFDSelect(d[:5], 0, 4); err == nil {
+		t.Errorf("truncated font dict select is parsed")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"sort"
 )
 
 // CMap is a "cmap" table.
@@ -54,10 +55,17 @@ func (cm *CMap) Tag() Tag {
 }
 
 // store writes binary expression of this table.
+// The encoding records are followed by their subtables, whose offsets are recalculated.
 func (cm *CMap) store(w *errWriter) {
-	w.write(&(cm.Header))
+	w.write(cm.Header.Version)
+	w.write(uint16(len(cm.EncodingRecords)))
+	offset := 4 + 8*uint32(len(cm.EncodingRecords))
 	for _, er := range cm.EncodingRecords {
-		er.store(w)
+		er.store(w, offset)
+		offset += er.Subtable.GetLength()
+	}
+	for _, er := range cm.EncodingRecords {
+		er.Subtable.store(w)
 	}
 	padSpace(w, cm.Length())
 }
@@ -81,6 +89,101 @@ func (cm *CMap) Exists() bool {
 	return cm != nil
 }
 
+// clone returns a deep copy of this table.
+func (cm *CMap) clone() *CMap {
+	if cm == nil {
+		return nil
+	}
+	header := *cm.Header
+	c := &CMap{
+		Header:          &header,
+		EncodingRecords: make([]*EncodingRecord, len(cm.EncodingRecords)),
+	}
+	for i, er := range cm.EncodingRecords {
+		r := *er
+		r.Subtable = er.Subtable.clone()
+		c.EncodingRecords[i] = &r
+	}
+	return c
+}
+
+// filter returns the cmap whose glyph IDs are mapped by m, and the characters of the glyphs not in m are removed.
+// The subtables of format 2, 4 and 6 are rebuilt in format 4, and the ones of format 12 are rebuilt in format 12.
+func (cm *CMap) filter(m map[uint16]uint16) *CMap {
+	if cm == nil {
+		return nil
+	}
+	header := *cm.Header
+	c := &CMap{
+		Header:          &header,
+		EncodingRecords: make([]*EncodingRecord, len(cm.EncodingRecords)),
+	}
+	for i, er := range cm.EncodingRecords {
+		r := *er
+		r.Subtable = filterEncodingRecordSubtable(er.Subtable, m)
+		c.EncodingRecords[i] = &r
+	}
+	return c
+}
+
+func filterEncodingRecordSubtable(st EncodingRecordSubtable, m map[uint16]uint16) EncodingRecordSubtable {
+	cmap := make(map[int32]uint16)
+	for c, gid := range st.GetCMap() {
+		if n, ok := m[gid]; ok && 0 != n {
+			cmap[c] = n
+		}
+	}
+	switch st := st.(type) {
+	case *EncodingRecordSubtableFormat0:
+		return newEncodingRecordSubtableFormat0(st.Language, cmap)
+	case *EncodingRecordSubtableFormat2:
+		return newEncodingRecordSubtableFormat4(st.Language, cmap)
+	case *EncodingRecordSubtableFormat4:
+		return newEncodingRecordSubtableFormat4(st.Language, cmap)
+	case *EncodingRecordSubtableFormat6:
+		return newEncodingRecordSubtableFormat4(st.Language, cmap)
+	case *EncodingRecordSubtableFormat12:
+		return newEncodingRecordSubtableFormat12(st.Language, cmap)
+	}
+	return st.clone()
+}
+
+// sortedCharCodes returns the character codes of the cmap in increasing order.
+func sortedCharCodes(cmap map[int32]uint16) []int32 {
+	codes := make([]int32, 0, len(cmap))
+	for c := range cmap {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// UnicodeCMap returns the resolved cmap of the Unicode encoding record, or nil if there is no such record.
+// The encoding records for the full Unicode repertoire are preferred to the BMP-only ones.
+func (cm *CMap) UnicodeCMap() map[int32]uint16 {
+	priorities := []struct {
+		p PlatformID
+		e EncodingID
+	}{
+		{PlatformIDWindows, EncodingIDWindowsUnicodeUCS4},
+		{PlatformIDUnicode, EncodingIDUnicodeFull},
+		{PlatformIDUnicode, EncodingIDUnicode2Full},
+		{PlatformIDWindows, EncodingIDWindowsUnicodeBMP},
+		{PlatformIDUnicode, EncodingIDUnicode2BMP},
+		{PlatformIDUnicode, EncodingIDUnicodeUCS},
+		{PlatformIDUnicode, EncodingIDUnicode11},
+		{PlatformIDUnicode, EncodingIDUnicode10},
+	}
+	for _, p := range priorities {
+		for _, er := range cm.EncodingRecords {
+			if er.PlatformID == p.p && er.EncodingID == p.e && er.Subtable != nil {
+				return er.CMap()
+			}
+		}
+	}
+	return nil
+}
+
 // CMapHeader is a header block of a "cmap" table.
 type CMapHeader struct {
 	Version   uint16
@@ -105,12 +208,11 @@ func (er *EncodingRecord) Length() uint32 {
 	return uint32(8) + er.Subtable.GetLength()
 }
 
-// store writes binary expression of this EncodingRecord.
-func (er *EncodingRecord) store(w *errWriter) {
+// store writes binary expression of this EncodingRecord with the offset of the subtable.
+func (er *EncodingRecord) store(w *errWriter, offset uint32) {
 	w.write(&(er.PlatformID))
 	w.write(&(er.EncodingID))
-	w.write(&(er.Offset))
-	er.Subtable.store(w)
+	w.write(offset)
 }
 
 // EncodingRecordSubtable is a character-to-glyph-index mapping table.
@@ -119,6 +221,7 @@ type EncodingRecordSubtable interface {
 	GetCMap() map[int32]uint16
 	GetLength() uint32
 	store(w *errWriter)
+	clone() EncodingRecordSubtable
 }
 
 // EncodingRecordSubtableFormatNumber is a format specifier of encoding record subtables.
@@ -193,6 +296,23 @@ func parseEncodingRecordSubtableFormat0(f *os.File) (st *EncodingRecordSubtableF
 	return
 }
 
+// newEncodingRecordSubtableFormat0 creates the subtable of format 0 from the cmap.
+// The characters out of a single byte and the glyph IDs larger than 255 can not be represented, and are mapped to glyph 0.
+func newEncodingRecordSubtableFormat0(language uint16, cmap map[int32]uint16) *EncodingRecordSubtableFormat0 {
+	st := &EncodingRecordSubtableFormat0{
+		Length:   262,
+		Language: language,
+		cmap:     make(map[int32]uint16),
+	}
+	for i := 0; i < 256; i++ {
+		if gid, ok := cmap[int32(i)]; ok && gid <= math.MaxUint8 {
+			st.GlyphIDArray[i] = uint8(gid)
+		}
+		st.cmap[int32(i)] = uint16(st.GlyphIDArray[i])
+	}
+	return st
+}
+
 func (st *EncodingRecordSubtableFormat0) store(w *errWriter) {
 	writeEncodingRecordSubtableFormatNumber(w, st.GetFormatNumber())
 	w.write(&(st.Length))
@@ -201,6 +321,12 @@ func (st *EncodingRecordSubtableFormat0) store(w *errWriter) {
 	return
 }
 
+func (st *EncodingRecordSubtableFormat0) clone() EncodingRecordSubtable {
+	c := *st
+	c.cmap = copyCMap(st.cmap)
+	return &c
+}
+
 // GetFormatNumber returns the the format number of the encoding record subtable.
 func (st *EncodingRecordSubtableFormat0) GetFormatNumber() EncodingRecordSubtableFormatNumber {
 	return EncodingRecordSubtableFormatNumber0
@@ -213,7 +339,7 @@ func (st *EncodingRecordSubtableFormat0) GetCMap() map[int32]uint16 {
 
 // GetLength returns the length of this subtable.
 func (st *EncodingRecordSubtableFormat0) GetLength() uint32 {
-	return uint32(st.Length)
+	return 262
 }
 
 // EncodingRecordSubtableFormat2 is useful for the national character code standards used for Japanese, Chinese, and Korean characters.
@@ -226,6 +352,7 @@ type EncodingRecordSubtableFormat2 struct {
 	IDDelta              []int16
 	IDRangeOffset        []uint16
 	idRangeOffsetAddress []int64
+	glyphIndexArray      []uint16
 	cmap                 map[int32]uint16
 }
 
@@ -276,6 +403,15 @@ func parseEncodingRecordSubtableFormat2(f *os.File) (st *EncodingRecordSubtableF
 			return
 		}
 	}
+	// the rest of the subtable is glyphIndexArray.
+	headerLength := 518 + 8*int(subHeaderNum)
+	if int(st.Length) > headerLength {
+		st.glyphIndexArray = make([]uint16, (int(st.Length)-headerLength)/2)
+		err = binary.Read(f, binary.BigEndian, st.glyphIndexArray)
+		if err != nil {
+			return
+		}
+	}
 	st.cmap, err = st.createCMap(f)
 	return
 }
@@ -323,12 +459,29 @@ func (st *EncodingRecordSubtableFormat2) getGID(f *os.File, addr int64, offset u
 }
 
 func (st *EncodingRecordSubtableFormat2) store(w *errWriter) {
-	// w.writeEncodingRecordSubtableFormatNumber(st.GetFormatNumber())
-	// w.write(&(st.Length))
-	// w.write(&(st.Language))
-	// w.write(st.SubHeaderKeys)
-	// for i, fc := range
-	// return
+	writeEncodingRecordSubtableFormatNumber(w, st.GetFormatNumber())
+	w.write(uint16(st.GetLength()))
+	w.write(&(st.Language))
+	w.write(st.SubHeaderKeys)
+	for i := range st.FirstCode {
+		w.write(st.FirstCode[i])
+		w.write(st.EntryCount[i])
+		w.write(st.IDDelta[i])
+		w.write(st.IDRangeOffset[i])
+	}
+	w.write(st.glyphIndexArray)
+}
+
+func (st *EncodingRecordSubtableFormat2) clone() EncodingRecordSubtable {
+	c := *st
+	c.FirstCode = append([]uint16{}, st.FirstCode...)
+	c.EntryCount = append([]uint16{}, st.EntryCount...)
+	c.IDDelta = append([]int16{}, st.IDDelta...)
+	c.IDRangeOffset = append([]uint16{}, st.IDRangeOffset...)
+	c.idRangeOffsetAddress = append([]int64{}, st.idRangeOffsetAddress...)
+	c.glyphIndexArray = append([]uint16{}, st.glyphIndexArray...)
+	c.cmap = copyCMap(st.cmap)
+	return &c
 }
 
 // GetFormatNumber returns the the format number of the encoding record subtable.
@@ -343,7 +496,7 @@ func (st *EncodingRecordSubtableFormat2) GetCMap() map[int32]uint16 {
 
 // GetLength returns the length of this subtable.
 func (st *EncodingRecordSubtableFormat2) GetLength() uint32 {
-	return uint32(st.Length)
+	return 518 + 8*uint32(len(st.FirstCode)) + 2*uint32(len(st.glyphIndexArray))
 }
 
 // EncodingRecordSubtableFormat4 is the Microsoft standard character-to-glyph-index mapping table for fonts that support Unicode BMP characters.
@@ -360,9 +513,77 @@ type EncodingRecordSubtableFormat4 struct {
 	IDDelta              []int16
 	IDRangeOffset        []uint16
 	IDRangeOffsetAddress []int64
+	glyphIDArray         []uint16
 	cmap                 map[int32]uint16
 }
 
+// newEncodingRecordSubtableFormat4 creates the subtable of format 4 from the cmap, whose characters out of the BMP are ignored.
+// Each range of the consecutive characters is a segment, that uses idDelta if the glyph IDs are consecutive as well, or glyphIdArray otherwise.
+func newEncodingRecordSubtableFormat4(language uint16, cmap map[int32]uint16) *EncodingRecordSubtableFormat4 {
+	st := &EncodingRecordSubtableFormat4{
+		Language: language,
+		cmap:     make(map[int32]uint16),
+	}
+	codes := make([]int32, 0, len(cmap))
+	for _, c := range sortedCharCodes(cmap) {
+		// the last segment for 0xFFFF is added below.
+		if 0 <= c && c < math.MaxUint16 {
+			codes = append(codes, c)
+			st.cmap[c] = cmap[c]
+		}
+	}
+	// the index of glyphIdArray where each segment starts, or -1 for the segments using idDelta.
+	arrayStarts := make([]int, 0)
+	for i := 0; i < len(codes); {
+		j := i + 1
+		consecutive := true
+		for j < len(codes) && codes[j] == codes[j-1]+1 {
+			if cmap[codes[j]] != cmap[codes[j-1]]+1 {
+				consecutive = false
+			}
+			j++
+		}
+		st.StartCount = append(st.StartCount, uint16(codes[i]))
+		st.EndCount = append(st.EndCount, uint16(codes[j-1]))
+		if consecutive {
+			st.IDDelta = append(st.IDDelta, int16(int32(cmap[codes[i]])-codes[i]))
+			arrayStarts = append(arrayStarts, -1)
+		} else {
+			st.IDDelta = append(st.IDDelta, 0)
+			arrayStarts = append(arrayStarts, len(st.glyphIDArray))
+			for _, c := range codes[i:j] {
+				st.glyphIDArray = append(st.glyphIDArray, cmap[c])
+			}
+		}
+		i = j
+	}
+	st.StartCount = append(st.StartCount, math.MaxUint16)
+	st.EndCount = append(st.EndCount, math.MaxUint16)
+	st.IDDelta = append(st.IDDelta, 1)
+	arrayStarts = append(arrayStarts, -1)
+	st.SegCount = uint16(len(st.StartCount))
+	st.IDRangeOffset = make([]uint16, st.SegCount)
+	for i, a := range arrayStarts {
+		if 0 <= a {
+			// the offset from the idRangeOffset itself to the first glyph ID of the segment.
+			st.IDRangeOffset[i] = uint16(2 * (int(st.SegCount) - i + a))
+		}
+	}
+	st.EntrySelector = 0
+	for 1<<(st.EntrySelector+1) <= st.SegCount {
+		st.EntrySelector++
+	}
+	st.SearchRange = 2 << st.EntrySelector
+	st.RangeShift = 2*st.SegCount - st.SearchRange
+	l := st.GetLength()
+	if l > math.MaxUint16 {
+		// the length field can not hold it, but it is not needed to find the arrays.
+		l = math.MaxUint16
+	}
+	st.Length = uint16(l)
+	return st
+}
+
 func parseEncodingRecordSubtableFormat4(f *os.File) (st *EncodingRecordSubtableFormat4, err error) {
 	st = &EncodingRecordSubtableFormat4{}
 	err = binary.Read(f, binary.BigEndian, &(st.Length))
@@ -427,6 +648,23 @@ func parseEncodingRecordSubtableFormat4(f *os.File) (st *EncodingRecordSubtableF
 			return
 		}
 	}
+	// the rest of the subtable is glyphIdArray, whose length may exceed the length field that overflows.
+	n := (int(st.Length) - 16 - 8*int(st.SegCount)) / 2
+	for i := 0; i < int(st.SegCount); i++ {
+		if 0 != st.IDRangeOffset[i] && st.StartCount[i] <= st.EndCount[i] {
+			end := int(st.IDRangeOffset[i])/2 - (int(st.SegCount) - i) + int(st.EndCount[i]-st.StartCount[i]) + 1
+			if n < end {
+				n = end
+			}
+		}
+	}
+	if 0 < n {
+		st.glyphIDArray = make([]uint16, n)
+		err = binary.Read(f, binary.BigEndian, st.glyphIDArray)
+		if err != nil {
+			return
+		}
+	}
 	st.cmap, err = st.createCMap(f)
 	return
 }
@@ -471,6 +709,31 @@ func (st *EncodingRecordSubtableFormat4) getGID(f *os.File, i uint16, c uint16)
 }
 
 func (st *EncodingRecordSubtableFormat4) store(w *errWriter) {
+	writeEncodingRecordSubtableFormatNumber(w, st.GetFormatNumber())
+	w.write(&(st.Length))
+	w.write(&(st.Language))
+	w.write(2 * st.SegCount)
+	w.write(&(st.SearchRange))
+	w.write(&(st.EntrySelector))
+	w.write(&(st.RangeShift))
+	w.write(st.EndCount)
+	w.write(&(st.ReservedPad))
+	w.write(st.StartCount)
+	w.write(st.IDDelta)
+	w.write(st.IDRangeOffset)
+	w.write(st.glyphIDArray)
+}
+
+func (st *EncodingRecordSubtableFormat4) clone() EncodingRecordSubtable {
+	c := *st
+	c.EndCount = append([]uint16{}, st.EndCount...)
+	c.StartCount = append([]uint16{}, st.StartCount...)
+	c.IDDelta = append([]int16{}, st.IDDelta...)
+	c.IDRangeOffset = append([]uint16{}, st.IDRangeOffset...)
+	c.IDRangeOffsetAddress = append([]int64{}, st.IDRangeOffsetAddress...)
+	c.glyphIDArray = append([]uint16{}, st.glyphIDArray...)
+	c.cmap = copyCMap(st.cmap)
+	return &c
 }
 
 // GetFormatNumber returns the the format number of the encoding record subtable.
@@ -484,8 +747,9 @@ func (st *EncodingRecordSubtableFormat4) GetCMap() map[int32]uint16 {
 }
 
 // GetLength returns the length of this subtable.
+// It is calculated from the arrays, because the length field may overflow for large subtables.
 func (st *EncodingRecordSubtableFormat4) GetLength() uint32 {
-	return uint32(st.Length)
+	return 16 + 8*uint32(st.SegCount) + 2*uint32(len(st.glyphIDArray))
 }
 
 // EncodingRecordSubtableFormat6 is Trimmed table mapping
@@ -532,6 +796,19 @@ func parseEncodingRecordSubtableFormat6(f *os.File) (st *EncodingRecordSubtableF
 }
 
 func (st *EncodingRecordSubtableFormat6) store(w *errWriter) {
+	writeEncodingRecordSubtableFormatNumber(w, st.GetFormatNumber())
+	w.write(uint16(st.GetLength()))
+	w.write(&(st.Language))
+	w.write(&(st.firstCode))
+	w.write(&(st.entryCount))
+	w.write(st.glyphIDArray)
+}
+
+func (st *EncodingRecordSubtableFormat6) clone() EncodingRecordSubtable {
+	c := *st
+	c.glyphIDArray = append([]uint16{}, st.glyphIDArray...)
+	c.cmap = copyCMap(st.cmap)
+	return &c
 }
 
 // GetFormatNumber returns the the format number of the encoding record subtable.
@@ -546,7 +823,7 @@ func (st *EncodingRecordSubtableFormat6) GetCMap() map[int32]uint16 {
 
 // GetLength returns the length of this subtable.
 func (st *EncodingRecordSubtableFormat6) GetLength() uint32 {
-	return uint32(st.Length)
+	return 10 + 2*uint32(len(st.glyphIDArray))
 }
 
 // EncodingRecordSubtableFormat12 is the Microsoft standard character-to-glyph-index mapping table for fonts supporting Unicode supplementary-plane characters (U+10000 to U+10FFFF).
@@ -599,7 +876,7 @@ func parseEncodingRecordSubtableFormat12(f *os.File) (st *EncodingRecordSubtable
 func (st *EncodingRecordSubtableFormat12) createCMap() (cmap map[int32]uint16) {
 	cmap = make(map[int32]uint16)
 	for i := uint32(0); i < st.NumGroups; i++ {
-		for j := st.startCharCode[i]; j < st.endCharCode[i]; j++ {
+		for j := st.startCharCode[i]; j <= st.endCharCode[i]; j++ {
 			d := j - st.startCharCode[i]
 			gid := st.startGlyphID[i] + d
 			cmap[int32(j)] = uint16(gid)
@@ -608,7 +885,52 @@ func (st *EncodingRecordSubtableFormat12) createCMap() (cmap map[int32]uint16) {
 	return
 }
 
+// newEncodingRecordSubtableFormat12 creates the subtable of format 12 from the cmap.
+// Each range of the consecutive characters mapped to the consecutive glyph IDs is a group.
+func newEncodingRecordSubtableFormat12(language uint32, cmap map[int32]uint16) *EncodingRecordSubtableFormat12 {
+	st := &EncodingRecordSubtableFormat12{
+		Language: language,
+		cmap:     make(map[int32]uint16),
+	}
+	for _, c := range sortedCharCodes(cmap) {
+		if c < 0 {
+			continue
+		}
+		gid := cmap[c]
+		st.cmap[c] = gid
+		n := len(st.endCharCode)
+		if 0 < n && st.endCharCode[n-1]+1 == uint32(c) && st.startGlyphID[n-1]+uint32(c)-st.startCharCode[n-1] == uint32(gid) {
+			st.endCharCode[n-1] = uint32(c)
+			continue
+		}
+		st.startCharCode = append(st.startCharCode, uint32(c))
+		st.endCharCode = append(st.endCharCode, uint32(c))
+		st.startGlyphID = append(st.startGlyphID, uint32(gid))
+	}
+	st.NumGroups = uint32(len(st.startCharCode))
+	st.Length = 16 + 12*st.NumGroups
+	return st
+}
+
 func (st *EncodingRecordSubtableFormat12) store(w *errWriter) {
+	writeEncodingRecordSubtableFormatNumber(w, st.GetFormatNumber())
+	// reserved
+	w.write(uint16(0))
+	w.write(st.GetLength())
+	w.write(&(st.Language))
+	w.write(&(st.NumGroups))
+	for i := uint32(0); i < st.NumGroups; i++ {
+		w.write([]uint32{st.startCharCode[i], st.endCharCode[i], st.startGlyphID[i]})
+	}
+}
+
+func (st *EncodingRecordSubtableFormat12) clone() EncodingRecordSubtable {
+	c := *st
+	c.startCharCode = append([]uint32{}, st.startCharCode...)
+	c.endCharCode = append([]uint32{}, st.endCharCode...)
+	c.startGlyphID = append([]uint32{}, st.startGlyphID...)
+	c.cmap = copyCMap(st.cmap)
+	return &c
 }
 
 // GetFormatNumber returns the the format number of the encoding record subtable.
@@ -623,10 +945,18 @@ func (st *EncodingRecordSubtableFormat12) GetCMap() map[int32]uint16 {
 
 // GetLength returns the length of this subtable.
 func (st *EncodingRecordSubtableFormat12) GetLength() uint32 {
-	return uint32(st.Length)
+	return 16 + 12*st.NumGroups
 }
 
 func writeEncodingRecordSubtableFormatNumber(e *errWriter, n EncodingRecordSubtableFormatNumber) {
 	b := []byte{byte(n / 256), byte(n % 256)}
 	e.writeBin(b)
 }
+
+func copyCMap(cmap map[int32]uint16) map[int32]uint16 {
+	c := make(map[int32]uint16, len(cmap))
+	for k, v := range cmap {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,52 @@
+package opentype
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// parseTestSubtable writes the subtable into a file, and parses it again.
+func parseTestSubtable(t *testing.T, st EncodingRecordSubtable) EncodingRecordSubtable {
+	f, err := ioutil.TempFile("", "cmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	w := newErrWriter(f)
+	st.store(w)
+	if w.hasErr() {
+		t.Fatal(w.err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint32(info.Size()) != st.GetLength() {
+		t.Errorf("%s subtable has %d bytes, want %d", st.GetFormatNumber(), info.Size(), st.GetLength())
+	}
+	_, err = f.Seek(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseEncodingRecordSubtable(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestEncodingRecordSubtableRoundTrip(t *testing.T) {
+	cmap := map[int32]uint16{0x20: 1, 0x41: 2, 0x42: 3, 0x43: 4, 0x61: 7, 0x62: 5, 0x63: 9, 0xFFFD: 10}
+	for _, st := range []EncodingRecordSubtable{
+		newEncodingRecordSubtableFormat4(0, cmap),
+		newEncodingRecordSubtableFormat12(0, cmap),
+	} {
+		parsed := parseTestSubtable(t, st)
+		if !reflect.DeepEqual(cmap, parsed.GetCMap()) {
+			t.Errorf("%s cmap is %v, want %v", st.GetFormatNumber(), parsed.GetCMap(), cmap)
+		}
+	}
+}
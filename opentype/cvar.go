@@ -0,0 +1,166 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// Cvar is a "cvar" table.
+// The CVT variations table has the deltas of the control values of cvt for the regions of the variation space.
+type Cvar struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Tuple variations of the control values, whose point numbers are the indices of cvt.
+	// The peak tuples are always embedded, because cvar has no shared tuples.
+	Variations []*TupleVariation
+}
+
+// cvarHeaderSize is the size of the version, that the tuple variation headers follow.
+const cvarHeaderSize = 4
+
+func parseCvar(f *os.File, offset, length uint32, axisCount int) (c *Cvar, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	b := make([]byte, length)
+	r.read(b)
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse cvar: %s")
+	}
+	c = &Cvar{}
+	r.seek(0)
+	c.MajorVersion = r.uint16()
+	c.MinorVersion = r.uint16()
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	c.Variations, err = parseTupleVariations(b, cvarHeaderSize, axisCount, nil, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cvar: %s", err)
+	}
+	return c, nil
+}
+
+// Tag is table name.
+func (c *Cvar) Tag() Tag {
+	return String2Tag("cvar")
+}
+
+// store writes binary expression of this table.
+func (c *Cvar) store(w *errWriter) {
+	b, err := packOffsetNode(c.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+func (c *Cvar) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(c.MajorVersion)
+	n.uint16(c.MinorVersion)
+	n.bytes(encodeTupleVariations(c.Variations, nil, cvarHeaderSize))
+	return n
+}
+
+// CheckSum for this table.
+func (c *Cvar) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(c)
+}
+
+// Length returns the size(byte) of this table.
+func (c *Cvar) Length() uint32 {
+	b, err := packOffsetNode(c.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (c *Cvar) Exists() bool {
+	return c != nil
+}
+
+// clone returns a deep copy of this table.
+func (c *Cvar) clone() *Cvar {
+	if c == nil {
+		return nil
+	}
+	cc := *c
+	cc.Variations = cloneTupleVariations(c.Variations)
+	return &cc
+}
+
+// valueDeltas returns the deltas of the tuple variation for all n control values.
+// Unlike the points of gvar, the values without the deltas are not interpolated, and stay unchanged.
+// ok is false if the tuple variation for all values does not match the number of the values.
+func (tv *TupleVariation) valueDeltas(n int) (d []float64, ok bool) {
+	d = make([]float64, n)
+	if tv.PointNumbers == nil {
+		if len(tv.Deltas) != n {
+			return nil, false
+		}
+		for i, v := range tv.Deltas {
+			d[i] = float64(v)
+		}
+		return d, true
+	}
+	for k, p := range tv.PointNumbers {
+		if int(p) >= n || k >= len(tv.Deltas) {
+			continue
+		}
+		d[p] = float64(tv.Deltas[k])
+	}
+	return d, true
+}
+
+// cvtAt returns the control values of cvt varied by cvar at the normalized coordinates.
+// The deltas are accumulated before they are rounded, and the values are clamped to int16.
+func (font *Font) cvtAt(coords []float64) []int16 {
+	values := append([]int16{}, font.Cvt.Values...)
+	if !font.Cvar.Exists() {
+		return values
+	}
+	n := len(values)
+	deltas := make([]float64, n)
+	for _, tv := range font.Cvar.Variations {
+		scalar := tv.scalar(coords)
+		if 0 == scalar {
+			continue
+		}
+		d, ok := tv.valueDeltas(n)
+		if !ok {
+			continue
+		}
+		for i := range deltas {
+			deltas[i] += scalar * d[i]
+		}
+	}
+	for i, d := range deltas {
+		v := math.Floor(float64(values[i]) + d + 0.5)
+		values[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+	}
+	return values
+}
+
+// CvtAt returns the control values of cvt at the location in the user space of the variation axes.
+// The values are varied by cvar, that the hinter created by NewHinterAt uses as well.
+// The axes missing in the location are at their default values, and the values are the default ones if the font has no cvar.
+func (font *Font) CvtAt(location map[Tag]float64) ([]int16, error) {
+	err := tableRequired(font.Cvt)
+	if err != nil {
+		return nil, fmt.Errorf("control values are not available: %s", err)
+	}
+	c, err := font.NormalizeCoordinates(location)
+	if err != nil {
+		return nil, err
+	}
+	return font.cvtAt(coordinatesFloat(c)), nil
+}
@@ -0,0 +1,138 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestCvar returns the control values and their variations on a single axis:
+// every value varies at the peak 1, and the first one at the intermediate peak 0.5 from 0 to 1 as well.
+func newTestCvar() (*Cvt, *Cvar) {
+	return &Cvt{Values: []int16{100, -32760, 32750}},
+		&Cvar{
+			MajorVersion: 1,
+			Variations: []*TupleVariation{
+				{PeakTuple: []F2Dot14{0x4000}, Deltas: []int32{40, -20, 20}},
+				{
+					PeakTuple:              []F2Dot14{0x2000},
+					IntermediateStartTuple: []F2Dot14{0},
+					IntermediateEndTuple:   []F2Dot14{0x4000},
+					PointNumbers:           []uint16{0},
+					Deltas:                 []int32{10},
+				},
+			},
+		}
+}
+
+// newTestCvarFont creates a variable font of the weight from 100 to 900, whose default is 400, with the control values of newTestCvar.
+func newTestCvarFont(t *testing.T) *Font {
+	font := newTestSubsetFont(t)
+	font.Fvar = &Fvar{
+		MajorVersion: 1,
+		Axes: []*VariationAxisRecord{
+			{AxisTag: String2Tag("wght"), MinValue: 100 << 16, DefaultValue: 400 << 16, MaxValue: 900 << 16},
+		},
+	}
+	font.Cvt, font.Cvar = newTestCvar()
+	return font
+}
+
+func TestCvtAt(t *testing.T) {
+	font := &Font{}
+	font.Cvt, font.Cvar = newTestCvar()
+	tests := []struct {
+		name  string
+		coord float64
+		want  []int16
+	}{
+		{"default", 0, []int16{100, -32760, 32750}},
+		{"peak", 1, []int16{140, -32768, 32767}},
+		{"intermediate peak", 0.5, []int16{130, -32768, 32760}},
+		{"intermediate", 0.25, []int16{115, -32765, 32755}},
+		{"outside", -0.5, []int16{100, -32760, 32750}},
+	}
+	for _, tt := range tests {
+		if got := font.cvtAt([]float64{tt.coord}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: control values are %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !reflect.DeepEqual(font.Cvt.Values, []int16{100, -32760, 32750}) {
+		t.Errorf("cvt is modified: %v", font.Cvt.Values)
+	}
+	// the deltas of the tuple variations are rounded after they are summed.
+	font.Cvar.Variations[1] = &TupleVariation{PeakTuple: []F2Dot14{0x4000}, Deltas: []int32{1, 0, 0}}
+	font.Cvar.Variations[0].Deltas = []int32{1, 0, 0}
+	if got := font.cvtAt([]float64{0.5}); 101 != got[0] {
+		t.Errorf("control value is %d, want 101", got[0])
+	}
+}
+
+func TestCvtAtLocation(t *testing.T) {
+	font := newTestCvarFont(t)
+	wght := String2Tag("wght")
+	tests := []struct {
+		weight float64
+		want   []int16
+	}{
+		{400, []int16{100, -32760, 32750}},
+		{900, []int16{140, -32768, 32767}},
+		{525, []int16{115, -32765, 32755}},
+		{250, []int16{100, -32760, 32750}},
+	}
+	for _, tt := range tests {
+		got, err := font.CvtAt(map[Tag]float64{wght: tt.weight})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("control values at %g are %v, want %v", tt.weight, got, tt.want)
+		}
+	}
+	got, err := font.CvtAt(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, font.Cvt.Values) {
+		t.Errorf("control values at the default are %v, want %v", got, font.Cvt.Values)
+	}
+	font.Cvt = nil
+	if _, err := font.CvtAt(nil); err == nil {
+		t.Errorf("control values are available without cvt")
+	}
+}
+
+func TestNewHinterAt(t *testing.T) {
+	font := newTestCvarFont(t)
+	wght := String2Tag("wght")
+	for _, w := range []float64{400, 650, 900} {
+		h, err := font.NewHinterAt(12, map[Tag]float64{wght: w})
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := font.CvtAt(map[Tag]float64{wght: w})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]int32, len(values))
+		for i, v := range values {
+			want[i] = mulFix(int32(v), h.scale)
+		}
+		if !reflect.DeepEqual(h.cvt, want) {
+			t.Errorf("control values of the hinter at %g are %v, want %v", w, h.cvt, want)
+		}
+	}
+	h, err := font.NewHinter(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at, err := font.NewHinterAt(12, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.cvt, at.cvt) {
+		t.Errorf("control values of the hinter at the default are %v, want %v", at.cvt, h.cvt)
+	}
+	if _, err := font.NewHinterAt(12, map[Tag]float64{String2Tag("wdth"): 100}); err == nil {
+		t.Errorf("hinter is created at an unknown axis")
+	}
+}
@@ -54,3 +54,13 @@ func (c *Cvt) Length() uint32 {
 func (c *Cvt) Exists() bool {
 	return c != nil
 }
+
+// clone returns a deep copy of this table.
+func (c *Cvt) clone() *Cvt {
+	if c == nil {
+		return nil
+	}
+	return &Cvt{
+		Values: append([]int16{}, c.Values...),
+	}
+}
@@ -13,12 +13,31 @@ type Font struct {
 	Head        *Head
 	Hhea        *Hhea
 	Maxp        *Maxp
+	OS2         *OS2
+	Post        *Post
+	Gasp        *Gasp
 	Hmtx        *Hmtx
+	Vhea        *Vhea
+	Vmtx        *Vmtx
+	Vorg        *Vorg
+	Gdef        *Gdef
+	Gsub        *Gsub
+	Gpos        *Gpos
+	Kern        *Kern
+	Fvar        *Fvar
+	Avar        *Avar
+	Gvar        *Gvar
+	Hvar        *Hvar
+	Vvar        *Vvar
+	Mvar        *Mvar
+	Stat        *Stat
 	Cvt         *Cvt
+	Cvar        *Cvar
 	Fpgm        *Fpgm
 	Prep        *Prep
 	Loca        *Loca
 	Glyf        *Glyf
+	Cff         *Cff
 }
 
 // ParseFont returns the Font instance from the font file.
@@ -49,6 +68,14 @@ func parseTrueTypeFont(f *os.File) (*Font, error) {
 			font.Cvt, err = parseCvt(f, tr.Offset, tr.Length)
 			return err
 		})
+		p.parse("cvar", true, func(tr *TableRecord) (err error) {
+			err = tableRequired(font.Fvar)
+			if err != nil {
+				return err
+			}
+			font.Cvar, err = parseCvar(f, tr.Offset, tr.Length, len(font.Fvar.Axes))
+			return err
+		})
 		p.parse("fpgm", true, func(tr *TableRecord) (err error) {
 			font.Fpgm, err = parseFpgm(f, tr.Offset, tr.Length)
 			return err
@@ -78,7 +105,10 @@ func parseTrueTypeFont(f *os.File) (*Font, error) {
 
 func parseCFFFont(f *os.File) (*Font, error) {
 	return parseOpenTypeTable(f, func(font *Font, p *optionalFontParser) {
-		// TODO CFF specific
+		p.parse("CFF ", true, func(tr *TableRecord) (err error) {
+			font.Cff, err = parseCff(f, tr.Offset, tr.Length)
+			return err
+		})
 	})
 }
 
@@ -110,6 +140,18 @@ func parseOpenTypeTable(f *os.File, parser func(font *Font, p *optionalFontParse
 		font.Maxp, err = parseMaxp(f, tr.Offset)
 		return err
 	})
+	p.parse("OS/2", true, func(tr *TableRecord) error {
+		font.OS2, err = parseOS2(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("post", true, func(tr *TableRecord) error {
+		font.Post, err = parsePost(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("gasp", true, func(tr *TableRecord) error {
+		font.Gasp, err = parseGasp(f, tr.Offset)
+		return err
+	})
 	p.parse("hmtx", false, func(tr *TableRecord) error {
 		err = tableRequired(font.Maxp, font.Hhea)
 		if err != nil {
@@ -118,6 +160,76 @@ func parseOpenTypeTable(f *os.File, parser func(font *Font, p *optionalFontParse
 		font.Hmtx, err = parseHmtx(f, tr.Offset, font.Maxp.NumGlyphs, font.Hhea.NumberOfHMetrics)
 		return err
 	})
+	p.parse("vhea", true, func(tr *TableRecord) error {
+		font.Vhea, err = parseVhea(f, tr.Offset)
+		return err
+	})
+	p.parse("vmtx", true, func(tr *TableRecord) error {
+		err = tableRequired(font.Maxp, font.Vhea)
+		if err != nil {
+			return err
+		}
+		font.Vmtx, err = parseVmtx(f, tr.Offset, font.Maxp.NumGlyphs, font.Vhea.NumOfLongVerMetrics)
+		return err
+	})
+	p.parse("VORG", true, func(tr *TableRecord) error {
+		font.Vorg, err = parseVorg(f, tr.Offset)
+		return err
+	})
+	p.parse("GDEF", true, func(tr *TableRecord) error {
+		font.Gdef, err = parseGdef(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("GSUB", true, func(tr *TableRecord) error {
+		err = tableRequired(font.Maxp)
+		if err != nil {
+			return err
+		}
+		// GSUB that can not be parsed, such as the one with an unknown format, is dropped instead of failing the font.
+		font.Gsub, _ = parseGsub(f, tr.Offset, tr.Length, font.Maxp.NumGlyphs)
+		return nil
+	})
+	p.parse("GPOS", true, func(tr *TableRecord) error {
+		err = tableRequired(font.Maxp)
+		if err != nil {
+			return err
+		}
+		// GPOS that can not be parsed, such as the one with an unknown format, is dropped instead of failing the font.
+		font.Gpos, _ = parseGpos(f, tr.Offset, tr.Length, font.Maxp.NumGlyphs)
+		return nil
+	})
+	p.parse("kern", true, func(tr *TableRecord) error {
+		font.Kern, err = parseKern(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("fvar", true, func(tr *TableRecord) error {
+		font.Fvar, err = parseFvar(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("avar", true, func(tr *TableRecord) error {
+		font.Avar, err = parseAvar(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("gvar", true, func(tr *TableRecord) error {
+		font.Gvar, err = parseGvar(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("HVAR", true, func(tr *TableRecord) error {
+		font.Hvar, err = parseHvar(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("VVAR", true, func(tr *TableRecord) error {
+		font.Vvar, err = parseVvar(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("MVAR", true, func(tr *TableRecord) error {
+		font.Mvar, err = parseMvar(f, tr.Offset, tr.Length)
+		return err
+	})
+	p.parse("STAT", true, func(tr *TableRecord) error {
+		font.Stat, err = parseStat(f, tr.Offset, tr.Length)
+		return err
+	})
 	p.parse("cmap", true, func(tr *TableRecord) error {
 		font.CMap, err = parseCMap(f, tr.Offset)
 		return err
@@ -131,14 +243,34 @@ func (font *Font) Tables() []Table {
 	tables := []Table{
 		font.Head,
 		font.Name,
+		font.CMap,
 		font.Hhea,
 		font.Maxp,
+		font.OS2,
+		font.Post,
+		font.Gasp,
 		font.Hmtx,
+		font.Vhea,
+		font.Vmtx,
+		font.Vorg,
+		font.Gdef,
+		font.Gsub,
+		font.Gpos,
+		font.Kern,
+		font.Fvar,
+		font.Avar,
+		font.Gvar,
+		font.Hvar,
+		font.Vvar,
+		font.Mvar,
+		font.Stat,
 		font.Cvt,
+		font.Cvar,
 		font.Fpgm,
 		font.Prep,
 		font.Loca,
 		font.Glyf,
+		font.Cff,
 	}
 	ret := make([]Table, 0, len(tables))
 	for _, t := range tables {
@@ -149,26 +281,60 @@ func (font *Font) Tables() []Table {
 	return ret
 }
 
+// Clone returns a deep copy of the font.
+// The copy shares no table with the original, so that either of them can be edited independently.
+func (font *Font) Clone() *Font {
+	return &Font{
+		SfntVersion: font.SfntVersion,
+		Name:        font.Name.clone(),
+		CMap:        font.CMap.clone(),
+		Head:        font.Head.clone(),
+		Hhea:        font.Hhea.clone(),
+		Maxp:        font.Maxp.clone(),
+		OS2:         font.OS2.clone(),
+		Post:        font.Post.clone(),
+		Gasp:        font.Gasp.clone(),
+		Hmtx:        font.Hmtx.clone(),
+		Vhea:        font.Vhea.clone(),
+		Vmtx:        font.Vmtx.clone(),
+		Vorg:        font.Vorg.clone(),
+		Gdef:        font.Gdef.clone(),
+		Gsub:        font.Gsub.clone(),
+		Gpos:        font.Gpos.clone(),
+		Kern:        font.Kern.clone(),
+		Fvar:        font.Fvar.clone(),
+		Avar:        font.Avar.clone(),
+		Gvar:        font.Gvar.clone(),
+		Hvar:        font.Hvar.clone(),
+		Vvar:        font.Vvar.clone(),
+		Mvar:        font.Mvar.clone(),
+		Stat:        font.Stat.clone(),
+		Cvt:         font.Cvt.clone(),
+		Cvar:        font.Cvar.clone(),
+		Fpgm:        font.Fpgm.clone(),
+		Prep:        font.Prep.clone(),
+		Loca:        font.Loca.clone(),
+		Glyf:        font.Glyf.clone(),
+		Cff:         font.Cff.clone(),
+	}
+}
+
 // FilterGlyf creates new Font with filtered glyf.
 // You should set filter[0] = 0, that points to the “missing character”, or this method inserts it.
+// The glyphs that GSUB can substitute for the filtered glyphs are appended after them,
+// and the components of the composite glyphs are appended after them as well.
+// cmap, GSUB, GPOS, GDEF and kern are pruned and remapped to the new glyph IDs.
+// The per-glyph vertical origins of VORG and the glyph names of post are remapped as well.
+// The receiver is never modified, so that it is safe to create multiple subsets from the same font concurrently.
 func (font *Font) FilterGlyf(filter []uint16) (*Font, error) {
-	new := &Font{
-		Name: font.Name,
-		CMap: font.CMap,
-		Head: font.Head,
-		Hhea: font.Hhea,
-		Maxp: font.Maxp,
-		Hmtx: font.Hmtx,
-		Cvt:  font.Cvt,
-		Fpgm: font.Fpgm,
-		Prep: font.Prep,
-		Loca: font.Loca,
-		Glyf: font.Glyf,
+	err := tableRequired(font.Maxp, font.Hhea, font.Head, font.Hmtx, font.Glyf)
+	if err != nil {
+		return nil, fmt.Errorf("filtering glyph failed: %s", err)
 	}
 	f := make([]uint16, 0, len(filter)+1)
 	maxGID := uint16(0)
-	if 0 != filter[0] {
-		f = append(f, filter[0])
+	if 0 == len(filter) || 0 != filter[0] {
+		f = append(f, 0)
 	}
 	for _, gid := range filter {
 		f = append(f, gid)
@@ -179,11 +345,262 @@ func (font *Font) FilterGlyf(filter []uint16) (*Font, error) {
 	if maxGID > font.Maxp.NumGlyphs-1 {
 		return nil, fmt.Errorf("filtering glyph failed: request(%d) exceeds maximum glyph id(%d)", maxGID, font.Maxp.NumGlyphs-1)
 	}
-	new.Glyf = new.Glyf.filter(f)
-	new.Loca = new.Glyf.generateLoca()
-	new.Hmtx = new.Hmtx.filter(f)
+	if font.Gsub.Exists() {
+		// the glyphs produced by substitutions follow the requested glyphs.
+		f = append(f, font.Gsub.closure(f, font.Maxp.NumGlyphs)...)
+	}
+	// the components of the composite glyphs follow them.
+	f = append(f, font.Glyf.closure(f, font.Maxp.NumGlyphs)...)
+	m := make(map[uint16]uint16, len(f))
+	for i, gid := range f {
+		if _, ok := m[gid]; !ok {
+			m[gid] = uint16(i)
+		}
+	}
+	new := &Font{
+		SfntVersion: font.SfntVersion,
+		Name:        font.Name.clone(),
+		CMap:        font.CMap.filter(m),
+		Head:        font.Head.clone(),
+		Hhea:        font.Hhea.clone(),
+		Maxp:        font.Maxp.clone(),
+		OS2:         font.OS2.clone(),
+		Gasp:        font.Gasp.clone(),
+		Vhea:        font.Vhea.clone(),
+		Fvar:        font.Fvar.clone(),
+		Avar:        font.Avar.clone(),
+		Mvar:        font.Mvar.clone(),
+		Stat:        font.Stat.clone(),
+		Cvt:         font.Cvt.clone(),
+		Cvar:        font.Cvar.clone(),
+		Fpgm:        font.Fpgm.clone(),
+		Prep:        font.Prep.clone(),
+	}
+	new.Glyf = font.Glyf.filter(f, m)
+	new.Hmtx = font.Hmtx.filter(f)
+	new.Hmtx.Optimize(new.Hhea)
+	if font.Vmtx.Exists() {
+		new.Vmtx = font.Vmtx.filter(f)
+		new.Vmtx.Optimize(new.Vhea)
+	}
+	if font.Post.Exists() {
+		new.Post = font.Post.filter(f)
+	}
+	if font.Vorg.Exists() {
+		new.Vorg = font.Vorg.filter(f)
+	}
+	if font.Gvar.Exists() {
+		new.Gvar = font.Gvar.filter(f)
+	}
+	if font.Hvar.Exists() {
+		new.Hvar = font.Hvar.filter(f)
+	}
+	if font.Vvar.Exists() {
+		new.Vvar = font.Vvar.filter(f)
+	}
+	new.Gdef = font.Gdef.filter(m)
+	new.Gsub = font.Gsub.filter(m)
+	new.Gpos = font.Gpos.filter(m)
+	if font.Kern.Exists() {
+		new.Kern = font.Kern.filter(m)
+	}
 	new.Maxp.NumGlyphs = uint16(len(f))
-	new.Hhea.NumberOfHMetrics = uint16(len(new.Hmtx.HMetrics))
-	new.Head.IndexToLocFormat = new.Loca.indexToLocFormat
+	err = new.UpdateLoca()
+	if err != nil {
+		return nil, fmt.Errorf("filtering glyph failed: %s", err)
+	}
 	return new, nil
 }
+
+// UpdateLoca regenerates loca from glyf, and sets the format of it to head.
+// This should be called before building the font, if glyphs are edited by Glyf.SetGlyph.
+// The short format is used whenever all offsets fit in it.
+func (font *Font) UpdateLoca() error {
+	err := tableRequired(font.Head, font.Glyf)
+	if err != nil {
+		return fmt.Errorf("updating loca failed: %s", err)
+	}
+	font.Loca = font.Glyf.generateLoca()
+	font.Head.IndexToLocFormat = font.Loca.indexToLocFormat
+	return nil
+}
+
+// RecalculateMetrics updates the bounding box of each glyph and the aggregate values of head, hhea and maxp from the glyph outlines.
+// The left side bearing of each glyph in hmtx is set to the minimum x of its bounding box, that the side bearings and the extent of hhea are calculated from.
+// This should be called before building the font, if glyphs are filtered or edited.
+func (font *Font) RecalculateMetrics() error {
+	err := tableRequired(font.Glyf, font.Head, font.Hhea, font.Maxp, font.Hmtx)
+	if err != nil {
+		return fmt.Errorf("recalculating metrics failed: %s", err)
+	}
+	m := &Maxp{}
+	first := true
+	var xMin, yMin, xMax, yMax int16
+	advanceWidthMax := uint16(0)
+	var minLeftSideBearing, minRightSideBearing, xMaxExtent int16
+	for i := 0; i < font.Glyf.Len(); i++ {
+		gid := uint16(i)
+		advanceWidth, _ := font.Hmtx.get(gid)
+		if advanceWidthMax < advanceWidth {
+			advanceWidthMax = advanceWidth
+		}
+		glyph, err := font.Glyf.Glyph(gid)
+		if err != nil {
+			return fmt.Errorf("recalculating metrics failed: %s", err)
+		}
+		if glyph.IsEmpty() {
+			continue
+		}
+		if glyph.IsComposite() {
+			o, depth, err := font.Glyf.resolve(gid, 0)
+			if err != nil {
+				return fmt.Errorf("recalculating metrics failed: %s", err)
+			}
+			o.calcBounds()
+			glyph.XMin, glyph.YMin, glyph.XMax, glyph.YMax = o.XMin, o.YMin, o.XMax, o.YMax
+			m.MaxCompositePoints = maxUint16(m.MaxCompositePoints, uint16(len(o.Points)))
+			m.MaxCompositeContours = maxUint16(m.MaxCompositeContours, uint16(len(o.EndPtsOfContours)))
+			m.MaxComponentElements = maxUint16(m.MaxComponentElements, uint16(len(glyph.Components)))
+			m.MaxComponentDepth = maxUint16(m.MaxComponentDepth, depth)
+		} else {
+			glyph.calcBounds()
+			m.MaxPoints = maxUint16(m.MaxPoints, uint16(len(glyph.Points)))
+			m.MaxContours = maxUint16(m.MaxContours, uint16(len(glyph.EndPtsOfContours)))
+		}
+		font.Glyf.setBounds(gid, glyph.XMin, glyph.YMin, glyph.XMax, glyph.YMax)
+		lsb := glyph.XMin
+		font.Hmtx.setLsb(gid, lsb)
+		rsb := int16(int32(advanceWidth) - int32(lsb) - int32(glyph.XMax-glyph.XMin))
+		extent := lsb + glyph.XMax - glyph.XMin
+		if first {
+			xMin, yMin, xMax, yMax = glyph.XMin, glyph.YMin, glyph.XMax, glyph.YMax
+			minLeftSideBearing, minRightSideBearing, xMaxExtent = lsb, rsb, extent
+			first = false
+			continue
+		}
+		xMin, yMin = minInt16(xMin, glyph.XMin), minInt16(yMin, glyph.YMin)
+		xMax, yMax = maxInt16(xMax, glyph.XMax), maxInt16(yMax, glyph.YMax)
+		minLeftSideBearing = minInt16(minLeftSideBearing, lsb)
+		minRightSideBearing = minInt16(minRightSideBearing, rsb)
+		xMaxExtent = maxInt16(xMaxExtent, extent)
+	}
+	font.Head.XMin, font.Head.YMin, font.Head.XMax, font.Head.YMax = xMin, yMin, xMax, yMax
+	font.Hhea.AdvanceWidthMax = advanceWidthMax
+	font.Hhea.MinLeftSideBearing = minLeftSideBearing
+	font.Hhea.MinRightSideBearing = minRightSideBearing
+	font.Hhea.XMaxExtent = xMaxExtent
+	if 0x00005000 != font.Maxp.Version {
+		font.Maxp.MaxPoints = m.MaxPoints
+		font.Maxp.MaxContours = m.MaxContours
+		font.Maxp.MaxCompositePoints = m.MaxCompositePoints
+		font.Maxp.MaxCompositeContours = m.MaxCompositeContours
+		font.Maxp.MaxComponentElements = m.MaxComponentElements
+		font.Maxp.MaxComponentDepth = m.MaxComponentDepth
+	}
+	return nil
+}
+
+// Dehint removes the TrueType hinting from the font: fpgm, prep, cvt and cvar are dropped, the instructions of the glyphs are cleared,
+// and the fields of maxp for the instructions are reset.
+// gasp is replaced with a single range that smooths all sizes without gridfitting,
+// and hdmx, LTSH and VDMX, that are only meaningful for the hinted glyphs, are not kept by this package, so they are never written.
+func (font *Font) Dehint() error {
+	font.Fpgm = nil
+	font.Prep = nil
+	font.Cvt = nil
+	font.Cvar = nil
+	if font.Glyf.Exists() {
+		err := font.Glyf.clearInstructions()
+		if err != nil {
+			return fmt.Errorf("dehinting failed: %s", err)
+		}
+		err = font.UpdateLoca()
+		if err != nil {
+			return fmt.Errorf("dehinting failed: %s", err)
+		}
+	}
+	if font.Gasp.Exists() {
+		font.Gasp.Version = 1
+		font.Gasp.GaspRanges = []*GaspRange{{RangeMaxPPEM: 0xFFFF, RangeGaspBehavior: GaspDogray | GaspSymmetricSmoothing}}
+	}
+	if font.Maxp.Exists() {
+		font.Maxp.MaxTwilightPoints = 0
+		font.Maxp.MaxStorage = 0
+		font.Maxp.MaxFunctionDefs = 0
+		font.Maxp.MaxInstructionDefs = 0
+		font.Maxp.MaxStackElements = 0
+		font.Maxp.MaxSizeOfInstructions = 0
+	}
+	return nil
+}
+
+// VerticalOrigin returns the y coordinate of the vertical origin of the glyph, in font design units.
+// If VORG is absent, it is calculated from the top side bearing of vmtx and the maximum y of the bounding box of the glyph,
+// that is the one of glyf, or the one of the charstring of CFF.
+// If neither is available, the ascender of hhea is used.
+func (font *Font) VerticalOrigin(gid uint16) (int16, error) {
+	if font.Vorg.Exists() {
+		return font.Vorg.Get(gid), nil
+	}
+	if font.Vmtx.Exists() && (font.Glyf.Exists() || font.Cff.Exists()) {
+		var yMax int16
+		if font.Glyf.Exists() {
+			glyph, err := font.Glyf.Glyph(gid)
+			if err != nil {
+				return 0, err
+			}
+			yMax = glyph.YMax
+		} else {
+			var err error
+			_, _, _, yMax, err = font.Cff.Bounds(gid)
+			if err != nil {
+				return 0, err
+			}
+		}
+		_, tsb := font.Vmtx.get(gid)
+		return tsb + yMax, nil
+	}
+	err := tableRequired(font.Hhea)
+	if err != nil {
+		return 0, fmt.Errorf("vertical origin is not available: %s", err)
+	}
+	return font.Hhea.Ascender, nil
+}
+
+// Features returns the tags of the GSUB and GPOS features of the language system of the script and the language, without duplication.
+// If the script or the language is not supported, the default one is used.
+func (font *Font) Features(script, lang Tag) []Tag {
+	tags := make([]Tag, 0)
+	found := make(map[Tag]bool)
+	layouts := make([]*LayoutTable, 0, 2)
+	if font.Gsub.Exists() {
+		layouts = append(layouts, &font.Gsub.LayoutTable)
+	}
+	if font.Gpos.Exists() {
+		layouts = append(layouts, &font.Gpos.LayoutTable)
+	}
+	for _, t := range layouts {
+		for _, fr := range t.Features(script, lang) {
+			if !found[fr.Tag] {
+				found[fr.Tag] = true
+				tags = append(tags, fr.Tag)
+			}
+		}
+	}
+	return tags
+}
+
+// Kerning returns the horizontal kerning value of the pair of glyphs, in font design units.
+// If GPOS has the "kern" feature, it is used and the "kern" table is ignored, as the shaping engines do,
+// so the "kern" table is used only if GPOS is absent or has no "kern" feature.
+func (font *Font) Kerning(left, right uint16) int16 {
+	if font.Gpos.Exists() {
+		if v, ok := font.Gpos.Kerning(left, right); ok {
+			return v
+		}
+	}
+	if font.Kern.Exists() {
+		return font.Kern.Kerning(left, right)
+	}
+	return 0
+}
@@ -0,0 +1,424 @@
+package opentype
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// testSquare returns a simple glyph of a square of the size at (x, y).
+func testSquare(x, y, size int16) *Glyph {
+	return &Glyph{
+		NumberOfContours: 1,
+		EndPtsOfContours: []uint16{3},
+		Points: []GlyphPoint{
+			{X: x, Y: y, OnCurve: true},
+			{X: x, Y: y + size, OnCurve: true},
+			{X: x + size, Y: y + size, OnCurve: true},
+			{X: x + size, Y: y, OnCurve: true},
+		},
+	}
+}
+
+// newTestFont creates a TrueType font of the glyphs and the advance widths.
+// The font has the long loca format and no trailing advance widths to be optimized, so that subsetting changes them.
+func newTestFont(t *testing.T, glyphs []*Glyph, advances []uint16) *Font {
+	n := len(glyphs)
+	font := &Font{
+		SfntVersion: SfntVersionTrueTypeOpenType,
+		Name:        &Name{},
+		Head: &Head{
+			MajorVersion: 1,
+			MagicNumber:  0x5F0F3CF5,
+			UnitsPerEm:   1000,
+		},
+		Hhea: &Hhea{
+			MajorVersion:     1,
+			Ascender:         800,
+			Descender:        -200,
+			NumberOfHMetrics: uint16(n),
+		},
+		Maxp: &Maxp{
+			Version:          0x00010000,
+			NumGlyphs:        uint16(n),
+			MaxZones:         2,
+			MaxStackElements: 64,
+		},
+		Hmtx: &Hmtx{},
+		Glyf: &Glyf{
+			data: make([][]byte, n),
+		},
+	}
+	for i, g := range glyphs {
+		font.Hmtx.HMetrics = append(font.Hmtx.HMetrics, &LongHorMetric{AdvanceWidth: advances[i]})
+		err := font.Glyf.SetGlyph(uint16(i), g)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := font.RecalculateMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = font.UpdateLoca()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := font.Loca
+	font.Loca = &Loca{indexToLocFormat: 1}
+	for i := 0; i < l.Len(); i++ {
+		font.Loca.offsetsLong = append(font.Loca.offsetsLong, l.Get(i))
+	}
+	font.Head.IndexToLocFormat = 1
+	return writeTestFont(t, font)
+}
+
+// writeTestFont builds the font with the extra tables into a file, and parses it again.
+func writeTestFont(t *testing.T, font *Font, extra ...Table) *Font {
+	dir, err := ioutil.TempDir("", "opentype")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "font.ttf")
+	out, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewBuilder(font.SfntVersion).WithTables(font.Tables()).WithTables(extra).Build(out)
+	out.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	parsed, err := ParseFont(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// newTestSubsetFont creates a font of 8 squares with distinct advance widths.
+func newTestSubsetFont(t *testing.T) *Font {
+	glyphs := []*Glyph{{}}
+	advances := []uint16{500}
+	for i := 1; i < 8; i++ {
+		glyphs = append(glyphs, testSquare(int16(10*i), 0, int16(100+i)))
+		advances = append(advances, uint16(500+10*i))
+	}
+	return newTestFont(t, glyphs, advances)
+}
+
+// assertSameSource fails if the tables that subsetting rebuilds differ from the snapshot.
+func assertSameSource(t *testing.T, font, snapshot *Font) {
+	if font.Maxp.NumGlyphs != snapshot.Maxp.NumGlyphs {
+		t.Errorf("maxp.numGlyphs changed: %d, want %d", font.Maxp.NumGlyphs, snapshot.Maxp.NumGlyphs)
+	}
+	if font.Hhea.NumberOfHMetrics != snapshot.Hhea.NumberOfHMetrics {
+		t.Errorf("hhea.numberOfHMetrics changed: %d, want %d", font.Hhea.NumberOfHMetrics, snapshot.Hhea.NumberOfHMetrics)
+	}
+	if font.Head.IndexToLocFormat != snapshot.Head.IndexToLocFormat {
+		t.Errorf("head.indexToLocFormat changed: %d, want %d", font.Head.IndexToLocFormat, snapshot.Head.IndexToLocFormat)
+	}
+	if !reflect.DeepEqual(font.Hmtx, snapshot.Hmtx) {
+		t.Errorf("hmtx changed")
+	}
+	if font.Loca.IsShort() != snapshot.Loca.IsShort() || font.Loca.Len() != snapshot.Loca.Len() {
+		t.Errorf("loca changed")
+	} else {
+		for i := 0; i < font.Loca.Len(); i++ {
+			if font.Loca.Get(i) != snapshot.Loca.Get(i) {
+				t.Errorf("offset %d of loca changed", i)
+			}
+		}
+	}
+	if len(font.Glyf.data) != len(snapshot.Glyf.data) {
+		t.Fatalf("glyf has %d glyphs, want %d", len(font.Glyf.data), len(snapshot.Glyf.data))
+	}
+	for i, d := range font.Glyf.data {
+		if !bytes.Equal(d, snapshot.Glyf.data[i]) {
+			t.Errorf("glyph %d changed", i)
+		}
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	font := newTestSubsetFont(t)
+	snapshot := font.Clone()
+	c := font.Clone()
+	c.Maxp.NumGlyphs = 1
+	c.Hhea.NumberOfHMetrics = 1
+	c.Head.IndexToLocFormat = 0
+	c.Hmtx.HMetrics[1].AdvanceWidth = 1
+	c.Loca.offsetsLong[1] = 1
+	c.Glyf.data[1][2] = 0xFF
+	assertSameSource(t, font, snapshot)
+}
+
+func TestFilterGlyfDoesNotModifySource(t *testing.T) {
+	font := newTestSubsetFont(t)
+	snapshot := font.Clone()
+	subset, err := font.FilterGlyf([]uint16{0, 3, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameSource(t, font, snapshot)
+	if 3 != subset.Maxp.NumGlyphs {
+		t.Errorf("subset has %d glyphs, want 3", subset.Maxp.NumGlyphs)
+	}
+	if 0 != subset.Head.IndexToLocFormat || !subset.Loca.IsShort() {
+		t.Errorf("subset does not use the short loca format")
+	}
+	if advanceWidth, _ := subset.Hmtx.get(2); 550 != advanceWidth {
+		t.Errorf("advance width of glyph 2 is %d, want 550", advanceWidth)
+	}
+}
+
+func TestFilterGlyfConcurrently(t *testing.T) {
+	font := newTestSubsetFont(t)
+	snapshot := font.Clone()
+	filters := [][]uint16{{0, 1}, {0, 2, 4, 6}, {7, 6, 5}, {0, 1, 2, 3, 4, 5, 6, 7}, {3}}
+	var wg sync.WaitGroup
+	errs := make([]error, len(filters)*4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			subset, err := font.FilterGlyf(filters[i%len(filters)])
+			if err == nil {
+				err = subset.RecalculateMetrics()
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("subset %d: %s", i, err)
+		}
+	}
+	assertSameSource(t, font, snapshot)
+}
+
+func TestFilterGlyfKeepsComponents(t *testing.T) {
+	glyphs := []*Glyph{{}}
+	advances := []uint16{500}
+	for i := 1; i < 5; i++ {
+		glyphs = append(glyphs, testSquare(int16(10*i), 0, int16(100+i)))
+		advances = append(advances, uint16(500+10*i))
+	}
+	glyphs = append(glyphs, &Glyph{
+		NumberOfContours: -1,
+		Components: []*GlyphComponent{
+			{Flags: ComponentFlagArgsAreXYValues, GlyphIndex: 3, XScale: 0x2000, YScale: 0x2000},
+			{Flags: ComponentFlagArgsAreXYValues, GlyphIndex: 4, Arg1: 300, XScale: 0x4000, YScale: 0x4000},
+		},
+	}, &Glyph{
+		NumberOfContours: -1,
+		Components: []*GlyphComponent{
+			{Flags: ComponentFlagArgsAreXYValues, GlyphIndex: 5, Arg2: 20, XScale: 0x4000, YScale: 0x4000},
+		},
+	})
+	advances = append(advances, 600, 700)
+	font := newTestFont(t, glyphs, advances)
+	subset, err := font.FilterGlyf([]uint16{0, 6, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// glyph 5 is added as the component of glyph 6, and glyphs 3 and 4 as the ones of glyph 5.
+	if 6 != subset.Maxp.NumGlyphs {
+		t.Fatalf("subset has %d glyphs, want 6", subset.Maxp.NumGlyphs)
+	}
+	components := map[uint16][]uint16{1: {3}, 3: {4, 5}}
+	for gid, want := range components {
+		g, err := subset.Glyf.Glyph(gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]uint16, 0)
+		for _, c := range g.Components {
+			got = append(got, c.GlyphIndex)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("components of glyph %d are %v, want %v", gid, got, want)
+		}
+	}
+	want, err := font.Glyf.Outline(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := subset.Glyf.Outline(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want.Points, got.Points) {
+		t.Errorf("outline of the composite glyph changed: %v, want %v", got.Points, want.Points)
+	}
+	err = subset.RecalculateMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, _ := font.Glyf.Glyph(6); 5 != g.Components[0].GlyphIndex {
+		t.Errorf("source glyph 6 is modified")
+	}
+}
+
+func TestFilterGlyfRemapsCMap(t *testing.T) {
+	font := newTestSubsetFont(t)
+	bmp := map[int32]uint16{}
+	full := map[int32]uint16{0x1F600: 6}
+	for i := int32(0); i < 7; i++ {
+		bmp['A'+i] = uint16(i + 1)
+		full['A'+i] = uint16(i + 1)
+	}
+	font.CMap = &CMap{
+		Header: &CMapHeader{NumTables: 2},
+		EncodingRecords: []*EncodingRecord{
+			{PlatformID: PlatformIDWindows, EncodingID: EncodingIDWindowsUnicodeBMP, Subtable: newEncodingRecordSubtableFormat4(0, bmp)},
+			{PlatformID: PlatformIDWindows, EncodingID: EncodingIDWindowsUnicodeUCS4, Subtable: newEncodingRecordSubtableFormat12(0, full)},
+		},
+	}
+	subset, err := font.FilterGlyf([]uint16{0, 6, 1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int32]uint16{'A': 2, 'C': 3, 'F': 1}
+	if got := subset.CMap.EncodingRecords[0].CMap(); !reflect.DeepEqual(want, got) {
+		t.Errorf("format 4 cmap is %v, want %v", got, want)
+	}
+	want[0x1F600] = 1
+	if got := subset.CMap.UnicodeCMap(); !reflect.DeepEqual(want, got) {
+		t.Errorf("format 12 cmap is %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(bmp, font.CMap.EncodingRecords[0].CMap()) {
+		t.Errorf("source cmap is modified")
+	}
+}
+
+func TestTablesWriteCMap(t *testing.T) {
+	font := newTestSubsetFont(t)
+	bmp := map[int32]uint16{0x20: 1, 0x41: 2, 0x42: 3, 0x61: 7, 0x62: 5}
+	full := map[int32]uint16{0x20: 1, 0x41: 2, 0x1F600: 4}
+	font.CMap = &CMap{
+		Header: &CMapHeader{NumTables: 2},
+		EncodingRecords: []*EncodingRecord{
+			{PlatformID: PlatformIDWindows, EncodingID: EncodingIDWindowsUnicodeBMP, Subtable: newEncodingRecordSubtableFormat4(0, bmp)},
+			{PlatformID: PlatformIDWindows, EncodingID: EncodingIDWindowsUnicodeUCS4, Subtable: newEncodingRecordSubtableFormat12(0, full)},
+		},
+	}
+	parsed := writeTestFont(t, font)
+	if !parsed.CMap.Exists() || 2 != len(parsed.CMap.EncodingRecords) {
+		t.Fatalf("cmap is not written")
+	}
+	for i, want := range []map[int32]uint16{bmp, full} {
+		if got := parsed.CMap.EncodingRecords[i].CMap(); !reflect.DeepEqual(want, got) {
+			t.Errorf("cmap of encoding record %d is %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestRecalculateMetricsUpdatesLsb(t *testing.T) {
+	font := newTestSubsetFont(t)
+	err := font.Glyf.SetGlyph(2, testSquare(-30, 0, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = font.RecalculateMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, lsb := font.Hmtx.get(2); -30 != lsb {
+		t.Errorf("lsb of glyph 2 is %d, want -30", lsb)
+	}
+	if -30 != font.Hhea.MinLeftSideBearing {
+		t.Errorf("minLeftSideBearing is %d, want -30", font.Hhea.MinLeftSideBearing)
+	}
+	// glyph 7 is the square of 107 at 70 with the advance width 570.
+	if 393 != font.Hhea.MinRightSideBearing {
+		t.Errorf("minRightSideBearing is %d, want 393", font.Hhea.MinRightSideBearing)
+	}
+	if 177 != font.Hhea.XMaxExtent {
+		t.Errorf("xMaxExtent is %d, want 177", font.Hhea.XMaxExtent)
+	}
+}
+
+func TestDehint(t *testing.T) {
+	glyphs := []*Glyph{{}, testSquare(0, 0, 100), {
+		NumberOfContours: -1,
+		Components: []*GlyphComponent{
+			{Flags: ComponentFlagArgsAreXYValues, GlyphIndex: 1, Arg1: 50, XScale: 0x4000, YScale: 0x4000},
+		},
+	}}
+	glyphs[1].Instructions = []uint8{0xB0, 0, 0x2E}
+	glyphs[2].Instructions = []uint8{0xB0, 1, 0x2E}
+	font := newTestFont(t, glyphs, []uint16{500, 600, 700})
+	font.Fpgm = &Fpgm{Values: []uint8{0xB0, 0, 0x2C, 0x2D}}
+	font.Prep = &Prep{Values: []uint8{0xB0, 0, 0x2B}}
+	font.Cvt, font.Cvar = newTestCvar()
+	font.Fvar = newTestCvarFont(t).Fvar
+	font.Gasp = &Gasp{Version: 0, GaspRanges: []*GaspRange{{RangeMaxPPEM: 8, RangeGaspBehavior: GaspDogray}, {RangeMaxPPEM: 0xFFFF, RangeGaspBehavior: GaspGridfit | GaspDogray}}}
+	font.Maxp.MaxStorage, font.Maxp.MaxFunctionDefs, font.Maxp.MaxSizeOfInstructions = 4, 1, 3
+	font = writeTestFont(t, font)
+	for gid := uint16(1); gid < 3; gid++ {
+		if g, err := font.Glyf.Glyph(gid); err != nil || 3 != len(g.Instructions) {
+			t.Fatalf("instructions of glyph %d are not written", gid)
+		}
+	}
+	before := []*Glyph{}
+	for gid := uint16(0); gid < 3; gid++ {
+		o, err := font.Glyf.Outline(gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before = append(before, o)
+	}
+	size := font.Glyf.Length()
+	err := font.Dehint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	font = writeTestFont(t, font)
+	if font.Fpgm.Exists() || font.Prep.Exists() || font.Cvt.Exists() || font.Cvar.Exists() {
+		t.Errorf("hinting tables remain")
+	}
+	if !font.Fvar.Exists() {
+		t.Errorf("fvar is dropped")
+	}
+	// gasp smooths all sizes without gridfitting.
+	gasp := &Gasp{Version: 1, GaspRanges: []*GaspRange{{RangeMaxPPEM: 0xFFFF, RangeGaspBehavior: GaspDogray | GaspSymmetricSmoothing}}}
+	if !reflect.DeepEqual(gasp, font.Gasp) {
+		t.Errorf("gasp is %+v, want %+v", font.Gasp, gasp)
+	}
+	m := font.Maxp
+	if 0 != m.MaxStorage || 0 != m.MaxFunctionDefs || 0 != m.MaxStackElements || 0 != m.MaxSizeOfInstructions || 0 != m.MaxTwilightPoints {
+		t.Errorf("maxp for the instructions is %+v", *m)
+	}
+	// 3 bytes of the instructions and a byte of the padding are removed from each glyph.
+	if want := size - 8; want != font.Glyf.Length() {
+		t.Errorf("glyf has %d bytes, want %d", font.Glyf.Length(), want)
+	}
+	for gid := uint16(0); gid < 3; gid++ {
+		g, err := font.Glyf.Glyph(gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if 0 != len(g.Instructions) {
+			t.Errorf("glyph %d has the instructions %v", gid, g.Instructions)
+		}
+		o, err := font.Glyf.Outline(gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(before[gid].Points, o.Points) || before[gid].XMax != o.XMax {
+			t.Errorf("outline of glyph %d changed: %v, want %v", gid, o.Points, before[gid].Points)
+		}
+	}
+}
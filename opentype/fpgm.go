@@ -53,3 +53,13 @@ func (fpgm *Fpgm) Length() uint32 {
 func (fpgm *Fpgm) Exists() bool {
 	return fpgm != nil
 }
+
+// clone returns a deep copy of this table.
+func (fpgm *Fpgm) clone() *Fpgm {
+	if fpgm == nil {
+		return nil
+	}
+	return &Fpgm{
+		Values: append([]uint8{}, fpgm.Values...),
+	}
+}
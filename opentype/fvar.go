@@ -0,0 +1,259 @@
+package opentype
+
+import (
+	"os"
+)
+
+// Fvar is a "fvar" table.
+// The font variations table specifies the variation axes of a variable font, and the named instances at the locations of the axes.
+type Fvar struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Variation axes, in the order of the coordinates of the variation tables.
+	Axes []*VariationAxisRecord
+	// Named instances.
+	Instances []*InstanceRecord
+}
+
+// VariationAxisRecord is a variation axis.
+type VariationAxisRecord struct {
+	// Tag identifying the design variation for the axis, such as "wght".
+	AxisTag Tag
+	// The minimum coordinate value for the axis.
+	MinValue Fixed
+	// The default coordinate value for the axis.
+	DefaultValue Fixed
+	// The maximum coordinate value for the axis.
+	MaxValue Fixed
+	// Axis qualifiers, see VariationAxisFlag* constants.
+	Flags uint16
+	// The name ID for entries in the "name" table that provide a display name for this axis.
+	AxisNameID NameID
+}
+
+// InstanceRecord is a named instance, that is a location of the variation axes with a name.
+type InstanceRecord struct {
+	// The name ID for entries in the "name" table that provide subfamily names for this instance.
+	SubfamilyNameID NameID
+	// Reserved for future use, set to 0.
+	Flags uint16
+	// The coordinates of the axes in the user space.
+	Coordinates []Fixed
+	// The name ID for entries in the "name" table that provide PostScript names for this instance, or NameIDNone.
+	PostScriptNameID NameID
+}
+
+const (
+	// VariationAxisFlagHidden : the axis should not be exposed directly in user interfaces.
+	VariationAxisFlagHidden = uint16(0x0001)
+)
+
+// NameIDNone is the name ID that refers no name.
+const NameIDNone = NameID(0xFFFF)
+
+func parseFvar(f *os.File, offset, length uint32) (fv *Fvar, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	fv = &Fvar{}
+	fv.MajorVersion = r.uint16()
+	fv.MinorVersion = r.uint16()
+	axesArrayOffset := r.uint16()
+	// reserved
+	r.uint16()
+	axisCount := int(r.uint16())
+	axisSize := int(r.uint16())
+	instanceCount := int(r.uint16())
+	instanceSize := int(r.uint16())
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	r.seek(int64(axesArrayOffset))
+	if !r.available(axisCount, axisSize) {
+		return nil, r.errorf("failed to parse fvar: %s")
+	}
+	fv.Axes = make([]*VariationAxisRecord, axisCount)
+	for i := range fv.Axes {
+		r.seek(int64(axesArrayOffset) + int64(i*axisSize))
+		a := &VariationAxisRecord{}
+		r.read(a)
+		fv.Axes[i] = a
+	}
+	instancesOffset := int64(axesArrayOffset) + int64(axisCount*axisSize)
+	r.seek(instancesOffset)
+	if !r.available(instanceCount, instanceSize) {
+		return nil, r.errorf("failed to parse fvar: %s")
+	}
+	fv.Instances = make([]*InstanceRecord, instanceCount)
+	for i := range fv.Instances {
+		r.seek(instancesOffset + int64(i*instanceSize))
+		ir := &InstanceRecord{
+			SubfamilyNameID:  NameID(r.uint16()),
+			Flags:            r.uint16(),
+			Coordinates:      make([]Fixed, axisCount),
+			PostScriptNameID: NameIDNone,
+		}
+		r.read(ir.Coordinates)
+		// the postScriptNameID is present if the size of the record has the room for it.
+		if instanceSize >= 4*axisCount+6 {
+			ir.PostScriptNameID = NameID(r.uint16())
+		}
+		fv.Instances[i] = ir
+	}
+	return fv, r.errorf("failed to parse fvar: %s")
+}
+
+// Tag is table name.
+func (fv *Fvar) Tag() Tag {
+	return String2Tag("fvar")
+}
+
+// hasPostScriptNameIDs returns true if any instance has the PostScript name ID, then all instance records have the field.
+func (fv *Fvar) hasPostScriptNameIDs() bool {
+	for _, ir := range fv.Instances {
+		if NameIDNone != ir.PostScriptNameID {
+			return true
+		}
+	}
+	return false
+}
+
+func (fv *Fvar) instanceSize() uint16 {
+	size := 4 + 4*len(fv.Axes)
+	if fv.hasPostScriptNameIDs() {
+		size += 2
+	}
+	return uint16(size)
+}
+
+// store writes binary expression of this table.
+func (fv *Fvar) store(w *errWriter) {
+	w.write(&(fv.MajorVersion))
+	w.write(&(fv.MinorVersion))
+	w.write([]uint16{16, 2, uint16(len(fv.Axes)), 20, uint16(len(fv.Instances)), fv.instanceSize()})
+	for _, a := range fv.Axes {
+		w.write(a)
+	}
+	ps := fv.hasPostScriptNameIDs()
+	for _, ir := range fv.Instances {
+		w.write(&(ir.SubfamilyNameID))
+		w.write(&(ir.Flags))
+		for i := range fv.Axes {
+			// the missing coordinates are the default values.
+			c := fv.Axes[i].DefaultValue
+			if i < len(ir.Coordinates) {
+				c = ir.Coordinates[i]
+			}
+			w.write(&c)
+		}
+		if ps {
+			w.write(&(ir.PostScriptNameID))
+		}
+	}
+	padSpace(w, fv.Length())
+}
+
+// CheckSum for this table.
+func (fv *Fvar) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(fv)
+}
+
+// Length returns the size(byte) of this table.
+func (fv *Fvar) Length() uint32 {
+	return uint32(16 + 20*len(fv.Axes) + int(fv.instanceSize())*len(fv.Instances))
+}
+
+// Exists returns true if this is not nil.
+func (fv *Fvar) Exists() bool {
+	return fv != nil
+}
+
+// clone returns a deep copy of this table.
+func (fv *Fvar) clone() *Fvar {
+	if fv == nil {
+		return nil
+	}
+	c := *fv
+	c.Axes = make([]*VariationAxisRecord, len(fv.Axes))
+	for i, a := range fv.Axes {
+		ca := *a
+		c.Axes[i] = &ca
+	}
+	c.Instances = make([]*InstanceRecord, len(fv.Instances))
+	for i, ir := range fv.Instances {
+		ci := *ir
+		ci.Coordinates = append([]Fixed{}, ir.Coordinates...)
+		c.Instances[i] = &ci
+	}
+	return &c
+}
+
+// Axis is a variation axis of a variable font, with the values in the user space.
+type Axis struct {
+	Tag     Tag
+	Min     float64
+	Default float64
+	Max     float64
+	// Whether the axis should not be exposed in user interfaces.
+	Hidden bool
+	// Display name of the axis.
+	Name string
+}
+
+// NamedInstance is a named instance of a variable font.
+type NamedInstance struct {
+	// Subfamily name of the instance, such as "Bold".
+	Name string
+	// PostScript name of the instance, or empty if the font does not have it.
+	PostScriptName string
+	// Coordinates of the axes in the user space.
+	Location map[Tag]float64
+}
+
+// Axes returns the variation axes of the font, or nil if the font is not variable.
+// The names are resolved by the "name" table.
+func (font *Font) Axes() []*Axis {
+	if !font.Fvar.Exists() {
+		return nil
+	}
+	axes := make([]*Axis, len(font.Fvar.Axes))
+	for i, a := range font.Fvar.Axes {
+		axes[i] = &Axis{
+			Tag:     a.AxisTag,
+			Min:     a.MinValue.Float(),
+			Default: a.DefaultValue.Float(),
+			Max:     a.MaxValue.Float(),
+			Hidden:  0 != a.Flags&VariationAxisFlagHidden,
+			Name:    font.Name.Find(a.AxisNameID),
+		}
+	}
+	return axes
+}
+
+// NamedInstances returns the named instances of the font, or nil if the font is not variable.
+// The names are resolved by the "name" table.
+func (font *Font) NamedInstances() []*NamedInstance {
+	if !font.Fvar.Exists() {
+		return nil
+	}
+	instances := make([]*NamedInstance, len(font.Fvar.Instances))
+	for i, ir := range font.Fvar.Instances {
+		ni := &NamedInstance{
+			Name:     font.Name.Find(ir.SubfamilyNameID),
+			Location: make(map[Tag]float64, len(font.Fvar.Axes)),
+		}
+		if NameIDNone != ir.PostScriptNameID {
+			ni.PostScriptName = font.Name.Find(ir.PostScriptNameID)
+		}
+		for j, a := range font.Fvar.Axes {
+			v := a.DefaultValue
+			if j < len(ir.Coordinates) {
+				v = ir.Coordinates[j]
+			}
+			ni.Location[a.AxisTag] = v.Float()
+		}
+		instances[i] = ni
+	}
+	return instances
+}
@@ -0,0 +1,60 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestFvarFont creates a font of newTestSubsetFont with the axes of the weight from 100 to 900 and the hidden width from 50 to 200,
+// and the named instances of Bold, that has the PostScript name, and Condensed, that does not.
+func newTestFvarFont(t *testing.T) *Font {
+	font := newTestSubsetFont(t)
+	for id, value := range map[NameID]string{256: "Weight", 257: "Width", 258: "Bold", 259: "Test-Bold", 260: "Condensed"} {
+		font.Name.set(id, value)
+	}
+	font.Fvar = &Fvar{
+		MajorVersion: 1,
+		Axes: []*VariationAxisRecord{
+			{AxisTag: String2Tag("wght"), MinValue: 100 << 16, DefaultValue: 400 << 16, MaxValue: 900 << 16, AxisNameID: 256},
+			{AxisTag: String2Tag("wdth"), MinValue: 50 << 16, DefaultValue: 100 << 16, MaxValue: 200 << 16, Flags: VariationAxisFlagHidden, AxisNameID: 257},
+		},
+		Instances: []*InstanceRecord{
+			{SubfamilyNameID: 258, Coordinates: []Fixed{700 << 16, 100 << 16}, PostScriptNameID: 259},
+			{SubfamilyNameID: 260, Coordinates: []Fixed{400 << 16, 75<<16 + 0x8000}, PostScriptNameID: NameIDNone},
+		},
+	}
+	return font
+}
+
+func TestFvarRoundTrip(t *testing.T) {
+	font := newTestFvarFont(t)
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Fvar, parsed.Fvar) {
+		t.Errorf("fvar is %+v, want %+v", *parsed.Fvar, *font.Fvar)
+	}
+	wght, wdth := String2Tag("wght"), String2Tag("wdth")
+	axes := []*Axis{
+		{Tag: wght, Min: 100, Default: 400, Max: 900, Name: "Weight"},
+		{Tag: wdth, Min: 50, Default: 100, Max: 200, Hidden: true, Name: "Width"},
+	}
+	if got := parsed.Axes(); !reflect.DeepEqual(axes, got) {
+		t.Errorf("axes are %+v, %+v, want %+v, %+v", *got[0], *got[1], *axes[0], *axes[1])
+	}
+	instances := []*NamedInstance{
+		{Name: "Bold", PostScriptName: "Test-Bold", Location: map[Tag]float64{wght: 700, wdth: 100}},
+		{Name: "Condensed", Location: map[Tag]float64{wght: 400, wdth: 75.5}},
+	}
+	if got := parsed.NamedInstances(); !reflect.DeepEqual(instances, got) {
+		t.Errorf("named instances are %+v, %+v, want %+v, %+v", *got[0], *got[1], *instances[0], *instances[1])
+	}
+	// the instances without the PostScript names are shorter.
+	font.Fvar.Instances[0].PostScriptNameID = NameIDNone
+	parsed = writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Fvar, parsed.Fvar) || font.Fvar.Length() >= newTestFvarFont(t).Fvar.Length() {
+		t.Errorf("fvar without the PostScript names is %+v", *parsed.Fvar)
+	}
+	font.Fvar = nil
+	if nil != font.Axes() || nil != font.NamedInstances() {
+		t.Errorf("font without fvar has the axes")
+	}
+}
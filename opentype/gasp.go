@@ -0,0 +1,94 @@
+package opentype
+
+import (
+	"os"
+)
+
+// Gasp is a "gasp" table.
+// This table contains information which describes the preferred rasterization techniques for the typeface when it is rendered on grayscale-capable devices.
+type Gasp struct {
+	// Version number (set to 1).
+	Version uint16
+	// Sorted by ppem.
+	GaspRanges []*GaspRange
+}
+
+// GaspRange is the rasterization behavior of the sizes up to RangeMaxPPEM.
+type GaspRange struct {
+	// Upper limit of range, in PPEM. The last range should be 0xFFFF.
+	RangeMaxPPEM uint16
+	// Flags describing desired rasterizer behavior.
+	RangeGaspBehavior uint16
+}
+
+const (
+	// GaspGridfit : use gridfitting.
+	GaspGridfit = uint16(0x0001)
+	// GaspDogray : use grayscale rendering.
+	GaspDogray = uint16(0x0002)
+	// GaspSymmetricGridfit : use gridfitting with ClearType symmetric smoothing, only supported in version 1.
+	GaspSymmetricGridfit = uint16(0x0004)
+	// GaspSymmetricSmoothing : use smoothing along multiple axes with ClearType, only supported in version 1.
+	GaspSymmetricSmoothing = uint16(0x0008)
+)
+
+func parseGasp(f *os.File, offset uint32) (g *Gasp, err error) {
+	g = &Gasp{}
+	f.Seek(int64(offset), 0)
+	r := newErrReader(f)
+	r.read(&(g.Version))
+	var numRanges uint16
+	r.read(&numRanges)
+	g.GaspRanges = make([]*GaspRange, numRanges)
+	for i := range g.GaspRanges {
+		gr := &GaspRange{}
+		r.read(gr)
+		g.GaspRanges[i] = gr
+	}
+	return g, r.errorf("failed to parse gasp: %s")
+}
+
+// Tag is table name.
+func (g *Gasp) Tag() Tag {
+	return String2Tag("gasp")
+}
+
+// store writes binary expression of this table.
+func (g *Gasp) store(w *errWriter) {
+	w.write(&(g.Version))
+	numRanges := uint16(len(g.GaspRanges))
+	w.write(&numRanges)
+	for _, gr := range g.GaspRanges {
+		w.write(gr)
+	}
+	padSpace(w, g.Length())
+}
+
+// CheckSum for this table.
+func (g *Gasp) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(g)
+}
+
+// Length returns the size(byte) of this table.
+func (g *Gasp) Length() uint32 {
+	return uint32(4 + 4*len(g.GaspRanges))
+}
+
+// Exists returns true if this is not nil.
+func (g *Gasp) Exists() bool {
+	return g != nil
+}
+
+// clone returns a deep copy of this table.
+func (g *Gasp) clone() *Gasp {
+	if g == nil {
+		return nil
+	}
+	c := *g
+	c.GaspRanges = make([]*GaspRange, len(g.GaspRanges))
+	for i, gr := range g.GaspRanges {
+		cr := *gr
+		c.GaspRanges[i] = &cr
+	}
+	return &c
+}
@@ -0,0 +1,28 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGaspRoundTrip(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Gasp = &Gasp{
+		Version: 1,
+		GaspRanges: []*GaspRange{
+			{RangeMaxPPEM: 8, RangeGaspBehavior: GaspDogray},
+			{RangeMaxPPEM: 0xFFFF, RangeGaspBehavior: GaspGridfit | GaspDogray | GaspSymmetricGridfit | GaspSymmetricSmoothing},
+		},
+	}
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Gasp, parsed.Gasp) {
+		t.Errorf("gasp is %+v, want %+v", *parsed.Gasp, *font.Gasp)
+	}
+	subset, err := parsed.FilterGlyf([]uint16{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(font.Gasp, writeTestFont(t, subset).Gasp) {
+		t.Errorf("gasp of the subset is %+v, want %+v", *subset.Gasp, *font.Gasp)
+	}
+}
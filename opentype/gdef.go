@@ -0,0 +1,379 @@
+package opentype
+
+import (
+	"os"
+)
+
+// Gdef is a "GDEF" table.
+// The Glyph Definition table provides various glyph properties used in OpenType Layout processing.
+type Gdef struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Glyph class definitions, see GlyphClass* constants.
+	GlyphClassDef *ClassDef
+	// Attachment point indices of the glyphs, keyed by glyph ID.
+	AttachPoints map[uint16][]uint16
+	// Ligature caret values of the ligature glyphs, keyed by glyph ID.
+	LigCarets map[uint16][]*CaretValue
+	// Mark attachment class definitions.
+	MarkAttachClassDef *ClassDef
+	// Mark glyph sets referenced by the lookups with LookupFlagUseMarkFilteringSet, only in version 1.2 or later.
+	MarkGlyphSets []*Coverage
+	// Item variation store for the variable fonts, only in version 1.3 or later.
+	ItemVarStore *ItemVariationStore
+}
+
+const (
+	// GlyphClassBase : base glyph (single character, spacing glyph).
+	GlyphClassBase = uint16(1)
+	// GlyphClassLigature : ligature glyph (multiple character, spacing glyph).
+	GlyphClassLigature = uint16(2)
+	// GlyphClassMark : mark glyph (non-spacing combining glyph).
+	GlyphClassMark = uint16(3)
+	// GlyphClassComponent : component glyph (part of single character, spacing glyph).
+	GlyphClassComponent = uint16(4)
+)
+
+// CaretValue is a caret position of a ligature.
+type CaretValue struct {
+	// Format identifier: 1 for design units only, 2 for contour point, 3 for design units plus device or VariationIndex table.
+	Format uint16
+	// X or Y value, in design units, for format 1 and 3.
+	Coordinate int16
+	// Contour point index on glyph, only for format 2.
+	CaretValuePointIndex uint16
+	// Device table (non-variable font) / VariationIndex table (variable font) for X or Y value, only for format 3.
+	Device *Device
+}
+
+func parseGdef(f *os.File, offset, length uint32) (g *Gdef, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	g = &Gdef{}
+	g.MajorVersion = r.uint16()
+	g.MinorVersion = r.uint16()
+	glyphClassDefOffset := r.uint16()
+	attachListOffset := r.uint16()
+	ligCaretListOffset := r.uint16()
+	markAttachClassDefOffset := r.uint16()
+	markGlyphSetsDefOffset := uint16(0)
+	if 2 <= g.MinorVersion {
+		markGlyphSetsDefOffset = r.uint16()
+	}
+	itemVarStoreOffset := uint32(0)
+	if 3 <= g.MinorVersion {
+		itemVarStoreOffset = r.uint32()
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	if 0 != glyphClassDefOffset {
+		g.GlyphClassDef = parseClassDef(r, int64(glyphClassDefOffset))
+	}
+	if 0 != attachListOffset {
+		g.AttachPoints = parseAttachList(r, int64(attachListOffset))
+	}
+	if 0 != ligCaretListOffset {
+		g.LigCarets = parseLigCaretList(r, int64(ligCaretListOffset))
+	}
+	if 0 != markAttachClassDefOffset {
+		g.MarkAttachClassDef = parseClassDef(r, int64(markAttachClassDefOffset))
+	}
+	if 0 != markGlyphSetsDefOffset {
+		r.seek(int64(markGlyphSetsDefOffset))
+		// format of MarkGlyphSets table
+		r.uint16()
+		offsets := r.uint32s(int(r.uint16()))
+		g.MarkGlyphSets = make([]*Coverage, len(offsets))
+		for i, o := range offsets {
+			g.MarkGlyphSets[i] = parseCoverage(r, int64(markGlyphSetsDefOffset)+int64(o))
+		}
+	}
+	if 0 != itemVarStoreOffset {
+		g.ItemVarStore = parseItemVariationStore(r, int64(itemVarStoreOffset))
+	}
+	return g, r.errorf("failed to parse GDEF table: %s")
+}
+
+func parseAttachList(r *tableReader, offset int64) map[uint16][]uint16 {
+	r.seek(offset)
+	coverageOffset := r.uint16()
+	offsets := r.uint16s(int(r.uint16()))
+	coverage := parseCoverage(r, offset+int64(coverageOffset))
+	points := make(map[uint16][]uint16)
+	for i, o := range offsets {
+		if i >= len(coverage.Glyphs) {
+			break
+		}
+		r.seek(offset + int64(o))
+		points[coverage.Glyphs[i]] = r.uint16s(int(r.uint16()))
+	}
+	return points
+}
+
+func parseLigCaretList(r *tableReader, offset int64) map[uint16][]*CaretValue {
+	r.seek(offset)
+	coverageOffset := r.uint16()
+	offsets := r.uint16s(int(r.uint16()))
+	coverage := parseCoverage(r, offset+int64(coverageOffset))
+	carets := make(map[uint16][]*CaretValue)
+	for i, o := range offsets {
+		if i >= len(coverage.Glyphs) {
+			break
+		}
+		ligGlyphOffset := offset + int64(o)
+		r.seek(ligGlyphOffset)
+		caretOffsets := r.uint16s(int(r.uint16()))
+		values := make([]*CaretValue, len(caretOffsets))
+		for j, co := range caretOffsets {
+			values[j] = parseCaretValue(r, ligGlyphOffset+int64(co))
+		}
+		carets[coverage.Glyphs[i]] = values
+	}
+	return carets
+}
+
+func parseCaretValue(r *tableReader, offset int64) *CaretValue {
+	c := &CaretValue{}
+	r.seek(offset)
+	c.Format = r.uint16()
+	switch c.Format {
+	case 2:
+		c.CaretValuePointIndex = r.uint16()
+	case 3:
+		c.Coordinate = r.int16()
+		deviceOffset := r.uint16()
+		if 0 != deviceOffset {
+			c.Device = parseDevice(r, offset+int64(deviceOffset))
+		}
+	default:
+		c.Coordinate = r.int16()
+	}
+	return c
+}
+
+// GlyphClass returns the glyph class of the glyph, or 0 if the glyph is not classified.
+func (g *Gdef) GlyphClass(gid uint16) uint16 {
+	if g == nil || g.GlyphClassDef == nil {
+		return 0
+	}
+	return g.GlyphClassDef.Class(gid)
+}
+
+// MarkAttachClass returns the mark attachment class of the glyph.
+func (g *Gdef) MarkAttachClass(gid uint16) uint16 {
+	if g == nil || g.MarkAttachClassDef == nil {
+		return 0
+	}
+	return g.MarkAttachClassDef.Class(gid)
+}
+
+// InMarkGlyphSet returns true if the glyph is in the mark glyph set.
+func (g *Gdef) InMarkGlyphSet(set uint16, gid uint16) bool {
+	if g == nil || int(set) >= len(g.MarkGlyphSets) {
+		return false
+	}
+	return g.MarkGlyphSets[set].Contains(gid)
+}
+
+// Tag is table name.
+func (g *Gdef) Tag() Tag {
+	return String2Tag("GDEF")
+}
+
+// store writes binary expression of this table.
+func (g *Gdef) store(w *errWriter) {
+	b, err := packOffsetNode(g.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+func (g *Gdef) node() *offsetNode {
+	minorVersion := g.MinorVersion
+	if g.MarkGlyphSets != nil && minorVersion < 2 {
+		minorVersion = 2
+	}
+	if g.ItemVarStore != nil && minorVersion < 3 {
+		minorVersion = 3
+	}
+	n := &offsetNode{}
+	n.uint16(1)
+	n.uint16(minorVersion)
+	n.offset16(classDefNode(g.GlyphClassDef))
+	n.offset16(attachListNode(g.AttachPoints))
+	n.offset16(ligCaretListNode(g.LigCarets))
+	n.offset16(classDefNode(g.MarkAttachClassDef))
+	if 2 <= minorVersion {
+		if g.MarkGlyphSets == nil {
+			n.uint16(0)
+		} else {
+			ms := &offsetNode{}
+			ms.uint16(1)
+			ms.uint16(uint16(len(g.MarkGlyphSets)))
+			for _, c := range g.MarkGlyphSets {
+				ms.offset32(c.node())
+			}
+			n.offset16(ms)
+		}
+	}
+	if 3 <= minorVersion {
+		if g.ItemVarStore == nil {
+			n.uint32(0)
+		} else {
+			n.offset32(g.ItemVarStore.node())
+		}
+	}
+	return n
+}
+
+func attachListNode(points map[uint16][]uint16) *offsetNode {
+	if points == nil {
+		return nil
+	}
+	glyphs := make([]uint16, 0, len(points))
+	for gid := range points {
+		glyphs = append(glyphs, gid)
+	}
+	sortGlyphs(glyphs)
+	n := &offsetNode{}
+	n.offset16((&Coverage{Glyphs: glyphs}).node())
+	n.uint16(uint16(len(glyphs)))
+	for _, gid := range glyphs {
+		p := &offsetNode{}
+		p.uint16(uint16(len(points[gid])))
+		p.uint16s(points[gid])
+		n.offset16(p)
+	}
+	return n
+}
+
+func ligCaretListNode(carets map[uint16][]*CaretValue) *offsetNode {
+	if carets == nil {
+		return nil
+	}
+	glyphs := make([]uint16, 0, len(carets))
+	for gid := range carets {
+		glyphs = append(glyphs, gid)
+	}
+	sortGlyphs(glyphs)
+	n := &offsetNode{}
+	n.offset16((&Coverage{Glyphs: glyphs}).node())
+	n.uint16(uint16(len(glyphs)))
+	for _, gid := range glyphs {
+		lg := &offsetNode{}
+		lg.uint16(uint16(len(carets[gid])))
+		for _, c := range carets[gid] {
+			lg.offset16(c.node())
+		}
+		n.offset16(lg)
+	}
+	return n
+}
+
+func (c *CaretValue) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(c.Format)
+	switch c.Format {
+	case 2:
+		n.uint16(c.CaretValuePointIndex)
+	case 3:
+		n.int16(c.Coordinate)
+		n.offset16(deviceNode(c.Device))
+	default:
+		n.int16(c.Coordinate)
+	}
+	return n
+}
+
+// CheckSum for this table.
+func (g *Gdef) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(g)
+}
+
+// Length returns the size(byte) of this table.
+func (g *Gdef) Length() uint32 {
+	b, err := packOffsetNode(g.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (g *Gdef) Exists() bool {
+	return g != nil
+}
+
+// clone returns a deep copy of this table.
+func (g *Gdef) clone() *Gdef {
+	if g == nil {
+		return nil
+	}
+	c := *g
+	c.GlyphClassDef = g.GlyphClassDef.clone()
+	if g.AttachPoints != nil {
+		c.AttachPoints = make(map[uint16][]uint16, len(g.AttachPoints))
+		for gid, points := range g.AttachPoints {
+			c.AttachPoints[gid] = append([]uint16{}, points...)
+		}
+	}
+	if g.LigCarets != nil {
+		c.LigCarets = make(map[uint16][]*CaretValue, len(g.LigCarets))
+		for gid, values := range g.LigCarets {
+			cv := make([]*CaretValue, len(values))
+			for i, v := range values {
+				vv := *v
+				vv.Device = v.Device.clone()
+				cv[i] = &vv
+			}
+			c.LigCarets[gid] = cv
+		}
+	}
+	c.MarkAttachClassDef = g.MarkAttachClassDef.clone()
+	if g.MarkGlyphSets != nil {
+		c.MarkGlyphSets = cloneCoverages(g.MarkGlyphSets)
+	}
+	c.ItemVarStore = g.ItemVarStore.clone()
+	return &c
+}
+
+// filter returns the table for the glyphs of a subset.
+// m maps the glyph IDs of the original font to the ones of the subset.
+// The mark glyph sets are kept even if they become empty, because they are referenced by index.
+func (g *Gdef) filter(m map[uint16]uint16) *Gdef {
+	if g == nil {
+		return nil
+	}
+	c := g.clone()
+	c.GlyphClassDef = g.GlyphClassDef.filter(m)
+	c.MarkAttachClassDef = g.MarkAttachClassDef.filter(m)
+	if g.AttachPoints != nil {
+		c.AttachPoints = make(map[uint16][]uint16)
+		for gid, points := range g.AttachPoints {
+			if n, ok := m[gid]; ok {
+				c.AttachPoints[n] = append([]uint16{}, points...)
+			}
+		}
+	}
+	if g.LigCarets != nil {
+		carets := c.LigCarets
+		c.LigCarets = make(map[uint16][]*CaretValue)
+		for gid, values := range carets {
+			if n, ok := m[gid]; ok {
+				c.LigCarets[n] = values
+			}
+		}
+	}
+	for i, set := range g.MarkGlyphSets {
+		c.MarkGlyphSets[i], _ = set.filter(m)
+	}
+	return c
+}
@@ -0,0 +1,85 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestGdef returns GDEF version 1.3 for the font of newTestSubsetFont:
+// glyph 1 and 2 are bases, glyph 3 is a ligature with the carets of all formats, and glyph 4 and 5 are marks.
+func newTestGdef() *Gdef {
+	return &Gdef{
+		MajorVersion: 1,
+		MinorVersion: 3,
+		GlyphClassDef: &ClassDef{Classes: map[uint16]uint16{
+			1: GlyphClassBase, 2: GlyphClassBase, 3: GlyphClassLigature, 4: GlyphClassMark, 5: GlyphClassMark,
+		}},
+		AttachPoints: map[uint16][]uint16{1: {0, 2}, 2: {1}},
+		LigCarets: map[uint16][]*CaretValue{3: {
+			{Format: 1, Coordinate: 50},
+			{Format: 2, CaretValuePointIndex: 3},
+			{Format: 3, Coordinate: 80, Device: &Device{DeltaFormat: DeltaFormatVariationIndex, DeltaSetOuterIndex: 0, DeltaSetInnerIndex: 1}},
+		}},
+		MarkAttachClassDef: &ClassDef{Classes: map[uint16]uint16{4: 1, 5: 2}},
+		MarkGlyphSets:      []*Coverage{{Glyphs: []uint16{4}}, {Glyphs: []uint16{4, 5}}},
+		ItemVarStore: &ItemVariationStore{
+			Format:           1,
+			AxisCount:        1,
+			VariationRegions: [][]*RegionAxisCoordinates{{{StartCoord: 0, PeakCoord: 0x4000, EndCoord: 0x4000}}},
+			ItemVariationData: []*ItemVariationData{
+				{RegionIndexes: []uint16{0}, DeltaSets: [][]int32{{5}, {-300}}},
+			},
+		},
+	}
+}
+
+func TestGdefRoundTrip(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Gdef = newTestGdef()
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Gdef, parsed.Gdef) {
+		t.Errorf("GDEF is %+v, want %+v", *parsed.Gdef, *font.Gdef)
+	}
+	g := parsed.Gdef
+	if GlyphClassLigature != g.GlyphClass(3) || 0 != g.GlyphClass(7) {
+		t.Errorf("glyph classes of glyph 3 and 7 are %d and %d", g.GlyphClass(3), g.GlyphClass(7))
+	}
+	if 2 != g.MarkAttachClass(5) || 0 != g.MarkAttachClass(1) {
+		t.Errorf("mark attachment classes of glyph 5 and 1 are %d and %d", g.MarkAttachClass(5), g.MarkAttachClass(1))
+	}
+	if g.InMarkGlyphSet(0, 5) || !g.InMarkGlyphSet(1, 5) || g.InMarkGlyphSet(2, 5) {
+		t.Errorf("mark glyph sets of glyph 5 are wrong")
+	}
+	var none *Gdef
+	if 0 != none.GlyphClass(1) || none.InMarkGlyphSet(0, 4) {
+		t.Errorf("GDEF that does not exist classifies the glyphs")
+	}
+}
+
+func TestGdefFilter(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Gdef = newTestGdef()
+	subset, err := font.FilterGlyf([]uint16{0, 5, 3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset = writeTestFont(t, subset)
+	g := subset.Gdef
+	want := &ClassDef{Classes: map[uint16]uint16{1: GlyphClassMark, 2: GlyphClassLigature, 3: GlyphClassBase}}
+	if !reflect.DeepEqual(want, g.GlyphClassDef) {
+		t.Errorf("glyph classes are %v, want %v", g.GlyphClassDef.Classes, want.Classes)
+	}
+	if !reflect.DeepEqual(map[uint16][]uint16{3: {0, 2}}, g.AttachPoints) {
+		t.Errorf("attachment points are %v", g.AttachPoints)
+	}
+	if 3 != len(g.LigCarets[2]) {
+		t.Errorf("ligature carets are %v", g.LigCarets)
+	}
+	// the mark glyph sets are kept by index even if they become empty.
+	if 2 != len(g.MarkGlyphSets) || 0 != len(g.MarkGlyphSets[0].Glyphs) || !reflect.DeepEqual([]uint16{1}, g.MarkGlyphSets[1].Glyphs) {
+		t.Errorf("mark glyph sets are %+v, %+v", *g.MarkGlyphSets[0], *g.MarkGlyphSets[1])
+	}
+	if !reflect.DeepEqual(newTestGdef(), font.Gdef) {
+		t.Errorf("GDEF of the source is modified")
+	}
+}
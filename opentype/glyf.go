@@ -1,7 +1,10 @@
 package opentype
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
+	"math"
 	"os"
 )
 
@@ -12,20 +15,23 @@ type Glyf struct {
 }
 
 func parseGlyf(f *os.File, offset, length uint32, l *Loca) (g *Glyf, err error) {
-	_, err = f.Seek(int64(offset), 0)
-	if err != nil {
-		return
-	}
+	// loca has an extra entry after the last valid index.
+	numGlyphs := l.Len() - 1
 	g = &Glyf{
-		data: make([][]byte, l.Len()),
+		data: make([][]byte, numGlyphs),
 	}
-	for i := 0; i < l.Len(); i++ {
+	for i := 0; i < numGlyphs; i++ {
 		first := l.Get(i)
-		var last uint32
-		if i >= l.Len()-1 {
+		last := l.Get(i + 1)
+		if last > length {
 			last = length
-		} else {
-			last = l.Get(i + 1)
+		}
+		if first > last {
+			return nil, fmt.Errorf("glyph %d has invalid offset", i)
+		}
+		_, err = f.Seek(int64(offset+first), 0)
+		if err != nil {
+			return
 		}
 		g.data[i] = make([]byte, last-first)
 		err = binary.Read(f, binary.BigEndian, g.data[i])
@@ -36,28 +42,114 @@ func parseGlyf(f *os.File, offset, length uint32, l *Loca) (g *Glyf, err error)
 	return
 }
 
-func (g *Glyf) filter(f []uint16) (new *Glyf) {
+// filter returns the glyphs of f, and m maps the old glyph IDs to the new ones.
+// The glyph indices of the components of composite glyphs are rewritten by m, so m should contain the component closure of f.
+func (g *Glyf) filter(f []uint16, m map[uint16]uint16) (new *Glyf) {
 	new = &Glyf{
 		data: make([][]byte, len(f)),
 	}
 	for i, gid := range f {
-		new.data[i] = g.data[gid]
+		d := g.data[gid]
+		offsets := componentIndexOffsets(d)
+		if 0 == len(offsets) {
+			new.data[i] = d
+			continue
+		}
+		// copy the data, because it is shared with the source table.
+		d = append([]byte{}, d...)
+		for _, o := range offsets {
+			binary.BigEndian.PutUint16(d[o:], m[binary.BigEndian.Uint16(d[o:])])
+		}
+		new.data[i] = d
 	}
 	return
 }
 
-func (g *Glyf) generateLoca() (l *Loca) {
-	l = &Loca{
-		indexToLocFormat: 1,
-		offsetsLong:      make([]uint32, len(g.data)+1),
+// closure returns the component glyphs of the composite glyphs in f, including the nested ones, that are not in f.
+// The glyph IDs of numGlyphs or larger are ignored.
+func (g *Glyf) closure(f []uint16, numGlyphs uint16) []uint16 {
+	set := make(map[uint16]bool, len(f))
+	for _, gid := range f {
+		set[gid] = true
+	}
+	added := make([]uint16, 0)
+	queue := append([]uint16{}, f...)
+	for 0 < len(queue) {
+		gid := queue[0]
+		queue = queue[1:]
+		if int(gid) >= len(g.data) {
+			continue
+		}
+		d := g.data[gid]
+		for _, o := range componentIndexOffsets(d) {
+			c := binary.BigEndian.Uint16(d[o:])
+			if c < numGlyphs && !set[c] {
+				set[c] = true
+				added = append(added, c)
+				queue = append(queue, c)
+			}
+		}
+	}
+	return added
+}
+
+// componentIndexOffsets returns the byte offsets of the glyph indices of the components in the glyph data.
+// It returns nil if the glyph is not composite, and stops at the truncated component.
+func componentIndexOffsets(d []byte) (offsets []int) {
+	if len(d) < 10 || int16(binary.BigEndian.Uint16(d)) >= 0 {
+		return nil
+	}
+	for p := 10; p+4 <= len(d); {
+		flags := binary.BigEndian.Uint16(d[p:])
+		offsets = append(offsets, p+2)
+		p += 4
+		if flags&ComponentFlagArg1And2AreWords != 0 {
+			p += 4
+		} else {
+			p += 2
+		}
+		if flags&ComponentFlagWeHaveAScale != 0 {
+			p += 2
+		} else if flags&ComponentFlagWeHaveAnXAndYScale != 0 {
+			p += 4
+		} else if flags&ComponentFlagWeHaveATwoByTwo != 0 {
+			p += 8
+		}
+		if flags&ComponentFlagMoreComponents == 0 {
+			break
+		}
 	}
+	return
+}
+
+// generateLoca creates the "loca" table that points the glyph data of this table.
+// Each glyph data is padded to 2-byte alignment, and the short format is chosen if all offsets fit in it.
+func (g *Glyf) generateLoca() (l *Loca) {
+	offsets := make([]uint32, len(g.data)+1)
 	offset := uint32(0)
 	for i, d := range g.data {
-		l.offsetsLong[i] = offset
-		offset += uint32(len(d))
+		if 0 != len(d)%2 {
+			// copy the data, because it may be shared with other Glyf tables.
+			g.data[i] = append(d[:len(d):len(d)], 0)
+		}
+		offsets[i] = offset
+		offset += uint32(len(g.data[i]))
 	}
 	// extra entry
-	l.offsetsLong[len(g.data)] = offset
+	offsets[len(g.data)] = offset
+	if offset/2 > math.MaxUint16 {
+		return &Loca{
+			indexToLocFormat: 1,
+			offsetsLong:      offsets,
+		}
+	}
+	l = &Loca{
+		indexToLocFormat: 0,
+		offsetsShort:     make([]uint16, len(offsets)),
+	}
+	for i, o := range offsets {
+		l.offsetsShort[i] = uint16(o / 2)
+	}
 	return
 }
 
@@ -92,3 +184,553 @@ func (g *Glyf) Length() uint32 {
 func (g *Glyf) Exists() bool {
 	return g != nil
 }
+
+// clone returns a deep copy of this table.
+func (g *Glyf) clone() *Glyf {
+	if g == nil {
+		return nil
+	}
+	c := &Glyf{
+		data: make([][]byte, len(g.data)),
+	}
+	for i, d := range g.data {
+		c.data[i] = append([]byte{}, d...)
+	}
+	return c
+}
+
+// Len returns the number of glyphs.
+func (g *Glyf) Len() int {
+	return len(g.data)
+}
+
+// Glyph returns the parsed glyph description of the glyph id.
+func (g *Glyf) Glyph(gid uint16) (*Glyph, error) {
+	if int(gid) >= len(g.data) {
+		return nil, fmt.Errorf("glyph id(%d) exceeds maximum glyph id(%d)", gid, len(g.data)-1)
+	}
+	return parseGlyph(g.data[gid])
+}
+
+// SetGlyph replaces the glyph description of the glyph id.
+func (g *Glyf) SetGlyph(gid uint16, glyph *Glyph) error {
+	if int(gid) >= len(g.data) {
+		return fmt.Errorf("glyph id(%d) exceeds maximum glyph id(%d)", gid, len(g.data)-1)
+	}
+	b := bytes.NewBuffer([]byte{})
+	w := newErrWriter(b)
+	glyph.store(w)
+	if w.hasErr() {
+		return w.errorf("failed to encode glyph: %s")
+	}
+	g.data[gid] = b.Bytes()
+	return nil
+}
+
+// clearInstructions removes the instructions of all glyphs.
+func (g *Glyf) clearInstructions() error {
+	for i, d := range g.data {
+		gid := uint16(i)
+		glyph, err := parseGlyph(d)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		if 0 == len(glyph.Instructions) {
+			continue
+		}
+		glyph.Instructions = nil
+		err = g.SetGlyph(gid, glyph)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+	}
+	return nil
+}
+
+// setBounds rewrites the bounding box in the header of the glyph data.
+// The data is copied, because it may be shared with other Glyf tables.
+func (g *Glyf) setBounds(gid uint16, xMin, yMin, xMax, yMax int16) {
+	d := g.data[gid]
+	if len(d) < 10 {
+		return
+	}
+	b := make([]byte, len(d))
+	copy(b, d)
+	binary.BigEndian.PutUint16(b[2:], uint16(xMin))
+	binary.BigEndian.PutUint16(b[4:], uint16(yMin))
+	binary.BigEndian.PutUint16(b[6:], uint16(xMax))
+	binary.BigEndian.PutUint16(b[8:], uint16(yMax))
+	g.data[gid] = b
+}
+
+// Outline returns the simple glyph description of the glyph id.
+// Components of a composite glyph are resolved into its points and contours.
+func (g *Glyf) Outline(gid uint16) (*Glyph, error) {
+	o, _, err := g.resolve(gid, 0)
+	if err != nil {
+		return nil, err
+	}
+	o.calcBounds()
+	return o, nil
+}
+
+// maxComponentDepth is the limit of nested components, that prevents infinite recursion of broken fonts.
+const maxComponentDepth = 64
+
+// resolve returns the outline of the glyph with the nesting depth of its components.
+func (g *Glyf) resolve(gid uint16, depth int) (o *Glyph, componentDepth uint16, err error) {
+	return resolveComponents(gid, depth, g.Glyph)
+}
+
+// resolveComponents returns the outline of the glyph loaded by the function, with the nesting depth of its components.
+func resolveComponents(gid uint16, depth int, load func(gid uint16) (*Glyph, error)) (o *Glyph, componentDepth uint16, err error) {
+	if depth > maxComponentDepth {
+		return nil, 0, fmt.Errorf("glyph %d: components are nested too deeply", gid)
+	}
+	glyph, err := load(gid)
+	if err != nil {
+		return
+	}
+	if !glyph.IsComposite() {
+		return glyph, 0, nil
+	}
+	o = &Glyph{
+		EndPtsOfContours: make([]uint16, 0),
+		Points:           make([]GlyphPoint, 0),
+	}
+	for _, c := range glyph.Components {
+		child, d, e := resolveComponents(c.GlyphIndex, depth+1, load)
+		if e != nil {
+			return nil, 0, e
+		}
+		if componentDepth < d+1 {
+			componentDepth = d + 1
+		}
+		points := c.transform(child.Points)
+		dx, dy := float64(c.Arg1), float64(c.Arg2)
+		if !c.ArgsAreXYValues() {
+			if int(c.Arg1) >= len(o.Points) || int(c.Arg2) >= len(points) {
+				return nil, 0, fmt.Errorf("glyph %d: component refers to a point that does not exist", gid)
+			}
+			dx = float64(o.Points[c.Arg1].X) - float64(points[c.Arg2].X)
+			dy = float64(o.Points[c.Arg1].Y) - float64(points[c.Arg2].Y)
+		} else if c.Flags&ComponentFlagScaledComponentOffset != 0 && c.Flags&ComponentFlagUnscaledComponentOffset == 0 {
+			dx, dy = c.XScale.Float()*dx+c.Scale10.Float()*dy, c.Scale01.Float()*dx+c.YScale.Float()*dy
+		}
+		if c.Flags&ComponentFlagRoundXYToGrid != 0 {
+			dx, dy = math.Floor(dx+0.5), math.Floor(dy+0.5)
+		}
+		base := uint16(len(o.Points))
+		for _, p := range points {
+			o.Points = append(o.Points, GlyphPoint{
+				X:       int16(math.Floor(float64(p.X) + dx + 0.5)),
+				Y:       int16(math.Floor(float64(p.Y) + dy + 0.5)),
+				OnCurve: p.OnCurve,
+			})
+		}
+		for _, e := range child.EndPtsOfContours {
+			o.EndPtsOfContours = append(o.EndPtsOfContours, base+e)
+		}
+	}
+	o.NumberOfContours = int16(len(o.EndPtsOfContours))
+	return
+}
+
+// Glyph is a glyph description of the "glyf" table.
+// A simple glyph has points and contours, and a composite glyph has components.
+type Glyph struct {
+	// If the number of contours is greater than or equal to zero, this is a simple glyph. If negative, this is a composite glyph.
+	NumberOfContours int16
+	// Minimum x for coordinate data.
+	XMin int16
+	// Minimum y for coordinate data.
+	YMin int16
+	// Maximum x for coordinate data.
+	XMax int16
+	// Maximum y for coordinate data.
+	YMax int16
+	// Array of point indices for the last point of each contour, in increasing numeric order.
+	EndPtsOfContours []uint16
+	// Points of the simple glyph, in absolute font design units.
+	Points []GlyphPoint
+	// True if contours of the simple glyph may overlap.
+	OverlapSimple bool
+	// Components of the composite glyph.
+	Components []*GlyphComponent
+	// Array of instruction byte code for the glyph.
+	Instructions []uint8
+}
+
+// GlyphPoint is a point of the glyph outline.
+type GlyphPoint struct {
+	X       int16
+	Y       int16
+	OnCurve bool
+}
+
+const (
+	glyphFlagOnCurvePoint                     = uint8(0x01)
+	glyphFlagXShortVector                     = uint8(0x02)
+	glyphFlagYShortVector                     = uint8(0x04)
+	glyphFlagRepeat                           = uint8(0x08)
+	glyphFlagXIsSameOrPositiveXShortVector    = uint8(0x10)
+	glyphFlagYIsSameOrPositiveYShortVector    = uint8(0x20)
+	glyphFlagOverlapSimple                    = uint8(0x40)
+	glyphFlagXYShortVector                    = glyphFlagXShortVector | glyphFlagYShortVector
+	glyphFlagXYIsSameOrPositiveXYShortVectors = glyphFlagXIsSameOrPositiveXShortVector | glyphFlagYIsSameOrPositiveYShortVector
+)
+
+// IsComposite returns true if this is a composite glyph.
+func (g *Glyph) IsComposite() bool {
+	return g.NumberOfContours < 0
+}
+
+// IsEmpty returns true if this glyph has no outline, like a space.
+func (g *Glyph) IsEmpty() bool {
+	return 0 == len(g.Points) && 0 == len(g.Components)
+}
+
+func parseGlyph(data []byte) (g *Glyph, err error) {
+	g = &Glyph{}
+	if 0 == len(data) {
+		return
+	}
+	r := newErrReader(bytes.NewReader(data))
+	r.read(&(g.NumberOfContours))
+	r.read(&(g.XMin))
+	r.read(&(g.YMin))
+	r.read(&(g.XMax))
+	r.read(&(g.YMax))
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse glyph header: %s")
+	}
+	if g.IsComposite() {
+		err = g.parseComposite(r)
+	} else {
+		err = g.parseSimple(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (g *Glyph) parseSimple(r *errReader) error {
+	g.EndPtsOfContours = make([]uint16, g.NumberOfContours)
+	r.read(g.EndPtsOfContours)
+	var instructionLength uint16
+	r.read(&instructionLength)
+	g.Instructions = make([]uint8, instructionLength)
+	r.read(g.Instructions)
+	if r.hasErr() {
+		return r.errorf("failed to parse simple glyph: %s")
+	}
+	numPoints := 0
+	if g.NumberOfContours > 0 {
+		numPoints = int(g.EndPtsOfContours[g.NumberOfContours-1]) + 1
+	}
+	flags := make([]uint8, 0, numPoints)
+	for len(flags) < numPoints && !r.hasErr() {
+		var flag uint8
+		r.read(&flag)
+		flags = append(flags, flag)
+		if flag&glyphFlagRepeat != 0 {
+			var repeat uint8
+			r.read(&repeat)
+			for i := uint8(0); i < repeat; i++ {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	if numPoints > 0 && !r.hasErr() {
+		g.OverlapSimple = flags[0]&glyphFlagOverlapSimple != 0
+	}
+	g.Points = make([]GlyphPoint, numPoints)
+	x := int16(0)
+	for i := 0; i < numPoints && !r.hasErr(); i++ {
+		x += readGlyphCoordinate(r, flags[i], glyphFlagXShortVector, glyphFlagXIsSameOrPositiveXShortVector)
+		g.Points[i].X = x
+		g.Points[i].OnCurve = flags[i]&glyphFlagOnCurvePoint != 0
+	}
+	y := int16(0)
+	for i := 0; i < numPoints && !r.hasErr(); i++ {
+		y += readGlyphCoordinate(r, flags[i], glyphFlagYShortVector, glyphFlagYIsSameOrPositiveYShortVector)
+		g.Points[i].Y = y
+	}
+	return r.errorf("failed to parse simple glyph: %s")
+}
+
+func readGlyphCoordinate(r *errReader, flag, short, sameOrPositive uint8) int16 {
+	if flag&short != 0 {
+		var d uint8
+		r.read(&d)
+		if flag&sameOrPositive != 0 {
+			return int16(d)
+		}
+		return -int16(d)
+	}
+	if flag&sameOrPositive != 0 {
+		return 0
+	}
+	var d int16
+	r.read(&d)
+	return d
+}
+
+func (g *Glyph) parseComposite(r *errReader) error {
+	g.Components = make([]*GlyphComponent, 0)
+	hasInstructions := false
+	for {
+		c := &GlyphComponent{
+			XScale: F2Dot14(0x4000),
+			YScale: F2Dot14(0x4000),
+		}
+		r.read(&(c.Flags))
+		r.read(&(c.GlyphIndex))
+		if c.Flags&ComponentFlagArg1And2AreWords != 0 {
+			var args [2]int16
+			r.read(&args)
+			c.Arg1, c.Arg2 = int32(args[0]), int32(args[1])
+			if !c.ArgsAreXYValues() {
+				c.Arg1, c.Arg2 = int32(uint16(args[0])), int32(uint16(args[1]))
+			}
+		} else {
+			var args [2]uint8
+			r.read(&args)
+			c.Arg1, c.Arg2 = int32(args[0]), int32(args[1])
+			if c.ArgsAreXYValues() {
+				c.Arg1, c.Arg2 = int32(int8(args[0])), int32(int8(args[1]))
+			}
+		}
+		if c.Flags&ComponentFlagWeHaveAScale != 0 {
+			r.read(&(c.XScale))
+			c.YScale = c.XScale
+		} else if c.Flags&ComponentFlagWeHaveAnXAndYScale != 0 {
+			r.read(&(c.XScale))
+			r.read(&(c.YScale))
+		} else if c.Flags&ComponentFlagWeHaveATwoByTwo != 0 {
+			r.read(&(c.XScale))
+			r.read(&(c.Scale01))
+			r.read(&(c.Scale10))
+			r.read(&(c.YScale))
+		}
+		if r.hasErr() {
+			return r.errorf("failed to parse composite glyph: %s")
+		}
+		g.Components = append(g.Components, c)
+		if c.Flags&ComponentFlagWeHaveInstructions != 0 {
+			hasInstructions = true
+		}
+		if c.Flags&ComponentFlagMoreComponents == 0 {
+			break
+		}
+	}
+	if hasInstructions {
+		var instructionLength uint16
+		r.read(&instructionLength)
+		g.Instructions = make([]uint8, instructionLength)
+		r.read(g.Instructions)
+	}
+	return r.errorf("failed to parse composite glyph: %s")
+}
+
+// calcBounds updates the bounding box of the simple glyph from its points.
+func (g *Glyph) calcBounds() {
+	g.XMin, g.YMin, g.XMax, g.YMax = 0, 0, 0, 0
+	for i, p := range g.Points {
+		if 0 == i || p.X < g.XMin {
+			g.XMin = p.X
+		}
+		if 0 == i || p.Y < g.YMin {
+			g.YMin = p.Y
+		}
+		if 0 == i || p.X > g.XMax {
+			g.XMax = p.X
+		}
+		if 0 == i || p.Y > g.YMax {
+			g.YMax = p.Y
+		}
+	}
+}
+
+// store writes binary expression of this glyph.
+func (g *Glyph) store(w *errWriter) {
+	if g.IsEmpty() {
+		return
+	}
+	w.write(&(g.NumberOfContours))
+	w.write(&(g.XMin))
+	w.write(&(g.YMin))
+	w.write(&(g.XMax))
+	w.write(&(g.YMax))
+	if g.IsComposite() {
+		g.storeComposite(w)
+	} else {
+		g.storeSimple(w)
+	}
+}
+
+func (g *Glyph) storeSimple(w *errWriter) {
+	w.write(g.EndPtsOfContours)
+	instructionLength := uint16(len(g.Instructions))
+	w.write(&instructionLength)
+	w.write(g.Instructions)
+	flags := make([]uint8, len(g.Points))
+	xs := make([]byte, 0, 2*len(g.Points))
+	ys := make([]byte, 0, 2*len(g.Points))
+	var x, y int16
+	for i, p := range g.Points {
+		if p.OnCurve {
+			flags[i] |= glyphFlagOnCurvePoint
+		}
+		var f uint8
+		f, xs = appendGlyphCoordinate(xs, p.X-x, glyphFlagXShortVector, glyphFlagXIsSameOrPositiveXShortVector)
+		flags[i] |= f
+		f, ys = appendGlyphCoordinate(ys, p.Y-y, glyphFlagYShortVector, glyphFlagYIsSameOrPositiveYShortVector)
+		flags[i] |= f
+		x, y = p.X, p.Y
+	}
+	if g.OverlapSimple && len(flags) > 0 {
+		flags[0] |= glyphFlagOverlapSimple
+	}
+	for i := 0; i < len(flags); {
+		repeat := 0
+		for i+repeat+1 < len(flags) && flags[i+repeat+1] == flags[i] && repeat < 255 {
+			repeat++
+		}
+		if repeat > 0 {
+			w.writeBin([]byte{flags[i] | glyphFlagRepeat, uint8(repeat)})
+		} else {
+			w.writeBin([]byte{flags[i]})
+		}
+		i += repeat + 1
+	}
+	w.writeBin(xs)
+	w.writeBin(ys)
+}
+
+func appendGlyphCoordinate(b []byte, d int16, short, sameOrPositive uint8) (uint8, []byte) {
+	switch {
+	case 0 == d:
+		return sameOrPositive, b
+	case 0 < d && d <= 255:
+		return short | sameOrPositive, append(b, uint8(d))
+	case -255 <= d && d < 0:
+		return short, append(b, uint8(-d))
+	default:
+		return 0, append(b, uint8(uint16(d)>>8), uint8(d))
+	}
+}
+
+func (g *Glyph) storeComposite(w *errWriter) {
+	for i, c := range g.Components {
+		flags := c.Flags &^ (ComponentFlagArg1And2AreWords | ComponentFlagWeHaveAScale | ComponentFlagWeHaveAnXAndYScale | ComponentFlagWeHaveATwoByTwo | ComponentFlagMoreComponents | ComponentFlagWeHaveInstructions)
+		if !c.argsFitInBytes() {
+			flags |= ComponentFlagArg1And2AreWords
+		}
+		switch {
+		case 0 != c.Scale01 || 0 != c.Scale10:
+			flags |= ComponentFlagWeHaveATwoByTwo
+		case c.XScale != c.YScale:
+			flags |= ComponentFlagWeHaveAnXAndYScale
+		case 0x4000 != c.XScale:
+			flags |= ComponentFlagWeHaveAScale
+		}
+		if i < len(g.Components)-1 {
+			flags |= ComponentFlagMoreComponents
+		} else if len(g.Instructions) > 0 {
+			flags |= ComponentFlagWeHaveInstructions
+		}
+		w.write(&flags)
+		w.write(&(c.GlyphIndex))
+		if flags&ComponentFlagArg1And2AreWords != 0 {
+			w.write([]uint16{uint16(c.Arg1), uint16(c.Arg2)})
+		} else {
+			w.writeBin([]byte{uint8(c.Arg1), uint8(c.Arg2)})
+		}
+		switch {
+		case flags&ComponentFlagWeHaveATwoByTwo != 0:
+			w.write([]F2Dot14{c.XScale, c.Scale01, c.Scale10, c.YScale})
+		case flags&ComponentFlagWeHaveAnXAndYScale != 0:
+			w.write([]F2Dot14{c.XScale, c.YScale})
+		case flags&ComponentFlagWeHaveAScale != 0:
+			w.write(&(c.XScale))
+		}
+	}
+	if len(g.Instructions) > 0 {
+		instructionLength := uint16(len(g.Instructions))
+		w.write(&instructionLength)
+		w.write(g.Instructions)
+	}
+}
+
+// GlyphComponent is a component of the composite glyph.
+type GlyphComponent struct {
+	// Component flag.
+	Flags uint16
+	// Glyph index of component.
+	GlyphIndex uint16
+	// X offset, or the point number of the parent glyph if ARGS_ARE_XY_VALUES is not set.
+	Arg1 int32
+	// Y offset, or the point number of the component glyph if ARGS_ARE_XY_VALUES is not set.
+	Arg2 int32
+	// Transformation matrix of the component, x' = XScale*x + Scale10*y, y' = Scale01*x + YScale*y.
+	XScale  F2Dot14
+	Scale01 F2Dot14
+	Scale10 F2Dot14
+	YScale  F2Dot14
+}
+
+const (
+	// ComponentFlagArg1And2AreWords : the arguments are 16-bit.
+	ComponentFlagArg1And2AreWords = uint16(0x0001)
+	// ComponentFlagArgsAreXYValues : the arguments are signed xy values, otherwise unsigned point numbers.
+	ComponentFlagArgsAreXYValues = uint16(0x0002)
+	// ComponentFlagRoundXYToGrid : round the xy values to the grid.
+	ComponentFlagRoundXYToGrid = uint16(0x0004)
+	// ComponentFlagWeHaveAScale : there is a simple scale for the component.
+	ComponentFlagWeHaveAScale = uint16(0x0008)
+	// ComponentFlagMoreComponents : at least one more glyph after this one.
+	ComponentFlagMoreComponents = uint16(0x0020)
+	// ComponentFlagWeHaveAnXAndYScale : the x direction will use a different scale from the y direction.
+	ComponentFlagWeHaveAnXAndYScale = uint16(0x0040)
+	// ComponentFlagWeHaveATwoByTwo : there is a 2 by 2 transformation that will be used to scale the component.
+	ComponentFlagWeHaveATwoByTwo = uint16(0x0080)
+	// ComponentFlagWeHaveInstructions : following the last component are instructions for the composite character.
+	ComponentFlagWeHaveInstructions = uint16(0x0100)
+	// ComponentFlagUseMyMetrics : use metrics from this component for the composite glyph.
+	ComponentFlagUseMyMetrics = uint16(0x0200)
+	// ComponentFlagOverlapCompound : the components of the compound glyph overlap.
+	ComponentFlagOverlapCompound = uint16(0x0400)
+	// ComponentFlagScaledComponentOffset : the component's offset should be scaled.
+	ComponentFlagScaledComponentOffset = uint16(0x0800)
+	// ComponentFlagUnscaledComponentOffset : the component's offset should not be scaled.
+	ComponentFlagUnscaledComponentOffset = uint16(0x1000)
+)
+
+// ArgsAreXYValues returns true if the arguments are xy offsets.
+func (c *GlyphComponent) ArgsAreXYValues() bool {
+	return c.Flags&ComponentFlagArgsAreXYValues != 0
+}
+
+func (c *GlyphComponent) argsFitInBytes() bool {
+	if c.ArgsAreXYValues() {
+		return -128 <= c.Arg1 && c.Arg1 <= 127 && -128 <= c.Arg2 && c.Arg2 <= 127
+	}
+	return 0 <= c.Arg1 && c.Arg1 <= 255 && 0 <= c.Arg2 && c.Arg2 <= 255
+}
+
+// transform returns the points transformed by the matrix of this component.
+func (c *GlyphComponent) transform(points []GlyphPoint) []GlyphPoint {
+	ret := make([]GlyphPoint, len(points))
+	copy(ret, points)
+	if 0x4000 == c.XScale && 0x4000 == c.YScale && 0 == c.Scale01 && 0 == c.Scale10 {
+		return ret
+	}
+	for i, p := range points {
+		x, y := float64(p.X), float64(p.Y)
+		ret[i].X = int16(math.Floor(c.XScale.Float()*x + c.Scale10.Float()*y + 0.5))
+		ret[i].Y = int16(math.Floor(c.Scale01.Float()*x + c.YScale.Float()*y + 0.5))
+	}
+	return ret
+}
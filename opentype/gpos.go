@@ -0,0 +1,1188 @@
+package opentype
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+)
+
+// Gpos is a "GPOS" table.
+// The Glyph Positioning table provides precise control over glyph placement for sophisticated text layout and rendering.
+type Gpos struct {
+	LayoutTable
+}
+
+const (
+	// GposLookupTypeSingle : adjust position of a single glyph.
+	GposLookupTypeSingle = uint16(1)
+	// GposLookupTypePair : adjust position of a pair of glyphs.
+	GposLookupTypePair = uint16(2)
+	// GposLookupTypeCursive : attach cursive glyphs.
+	GposLookupTypeCursive = uint16(3)
+	// GposLookupTypeMarkToBase : attach a combining mark to a base glyph.
+	GposLookupTypeMarkToBase = uint16(4)
+	// GposLookupTypeMarkToLigature : attach a combining mark to a ligature.
+	GposLookupTypeMarkToLigature = uint16(5)
+	// GposLookupTypeMarkToMark : attach a combining mark to another mark.
+	GposLookupTypeMarkToMark = uint16(6)
+	// GposLookupTypeContext : position one or more glyphs in context.
+	GposLookupTypeContext = uint16(7)
+	// GposLookupTypeChainedContext : position one or more glyphs in chained context.
+	GposLookupTypeChainedContext = uint16(8)
+	// GposLookupTypeExtension : extension mechanism for other positionings.
+	GposLookupTypeExtension = uint16(9)
+)
+
+func parseGpos(f *os.File, offset, length uint32, numGlyphs uint16) (g *Gpos, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	r.numGlyphs = int(numGlyphs)
+	t, err := parseLayoutTable(r, GposLookupTypeExtension, parseGposSubtable)
+	if err != nil {
+		return
+	}
+	return &Gpos{
+		LayoutTable: *t,
+	}, nil
+}
+
+func parseGposSubtable(r *tableReader, lookupType uint16, offset int64) (LookupSubtable, error) {
+	switch lookupType {
+	case GposLookupTypeSingle:
+		return parseSinglePos(r, offset)
+	case GposLookupTypePair:
+		return parsePairPos(r, offset)
+	case GposLookupTypeCursive:
+		return parseCursivePos(r, offset)
+	case GposLookupTypeMarkToBase:
+		return parseMarkBasePos(r, offset)
+	case GposLookupTypeMarkToLigature:
+		return parseMarkLigPos(r, offset)
+	case GposLookupTypeMarkToMark:
+		return parseMarkMarkPos(r, offset)
+	case GposLookupTypeContext:
+		return parseSequenceContext(r, offset, false)
+	case GposLookupTypeChainedContext:
+		return parseSequenceContext(r, offset, true)
+	default:
+		return nil, fmt.Errorf("GPOS lookup type %d is not supported", lookupType)
+	}
+}
+
+// Tag is table name.
+func (g *Gpos) Tag() Tag {
+	return String2Tag("GPOS")
+}
+
+// store writes binary expression of this table.
+func (g *Gpos) store(w *errWriter) {
+	b, err := g.pack(GposLookupTypeExtension)
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+// CheckSum for this table.
+func (g *Gpos) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(g)
+}
+
+// Length returns the size(byte) of this table.
+func (g *Gpos) Length() uint32 {
+	b, err := g.pack(GposLookupTypeExtension)
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (g *Gpos) Exists() bool {
+	return g != nil
+}
+
+// clone returns a deep copy of this table.
+func (g *Gpos) clone() *Gpos {
+	if g == nil {
+		return nil
+	}
+	return &Gpos{
+		LayoutTable: *g.LayoutTable.clone(),
+	}
+}
+
+// filter returns the table for the glyphs of a subset.
+// m maps the glyph IDs of the original font to the ones of the subset.
+func (g *Gpos) filter(m map[uint16]uint16) *Gpos {
+	if g == nil {
+		return nil
+	}
+	return &Gpos{
+		LayoutTable: *g.LayoutTable.filter(m),
+	}
+}
+
+// Kerning returns the sum of the X advance adjustments of the first glyph by the pair adjustment lookups of the "kern" feature,
+// and false if GPOS has no "kern" feature, whose value is zero even if the feature has no pair adjustment lookup.
+func (g *Gpos) Kerning(left, right uint16) (int16, bool) {
+	kern := String2Tag("kern")
+	found := false
+	lookups := make(map[uint16]bool)
+	for _, fr := range g.FeatureList {
+		if kern == fr.Tag {
+			found = true
+			for _, i := range fr.Feature.LookupListIndices {
+				lookups[i] = true
+			}
+		}
+	}
+	value := int16(0)
+	for i, l := range g.LookupList {
+		if !lookups[uint16(i)] || GposLookupTypePair != l.LookupType {
+			continue
+		}
+		for _, st := range l.Subtables {
+			p, ok := st.(*PairPos)
+			if !ok {
+				continue
+			}
+			if v1, _, ok := p.Pair(left, right); ok {
+				if v1 != nil {
+					value += v1.XAdvance
+				}
+				break
+			}
+		}
+	}
+	return value, found
+}
+
+const (
+	// ValueFormatXPlacement : includes horizontal adjustment for placement.
+	ValueFormatXPlacement = uint16(0x0001)
+	// ValueFormatYPlacement : includes vertical adjustment for placement.
+	ValueFormatYPlacement = uint16(0x0002)
+	// ValueFormatXAdvance : includes horizontal adjustment for advance.
+	ValueFormatXAdvance = uint16(0x0004)
+	// ValueFormatYAdvance : includes vertical adjustment for advance.
+	ValueFormatYAdvance = uint16(0x0008)
+	// ValueFormatXPlacementDevice : includes Device table (non-variable font) / VariationIndex table (variable font) for horizontal placement.
+	ValueFormatXPlacementDevice = uint16(0x0010)
+	// ValueFormatYPlacementDevice : includes Device table (non-variable font) / VariationIndex table (variable font) for vertical placement.
+	ValueFormatYPlacementDevice = uint16(0x0020)
+	// ValueFormatXAdvanceDevice : includes Device table (non-variable font) / VariationIndex table (variable font) for horizontal advance.
+	ValueFormatXAdvanceDevice = uint16(0x0040)
+	// ValueFormatYAdvanceDevice : includes Device table (non-variable font) / VariationIndex table (variable font) for vertical advance.
+	ValueFormatYAdvanceDevice = uint16(0x0080)
+)
+
+// ValueRecord describes all the variables and values used to adjust the position of a glyph or set of glyphs.
+// Only the values specified by the ValueFormat of the subtable are stored.
+type ValueRecord struct {
+	// Horizontal adjustment for placement, in design units.
+	XPlacement int16
+	// Vertical adjustment for placement, in design units.
+	YPlacement int16
+	// Horizontal adjustment for advance, in design units — only used for horizontal layout.
+	XAdvance int16
+	// Vertical adjustment for advance, in design units — only used for vertical layout.
+	YAdvance int16
+	// Device table (non-variable font) / VariationIndex table (variable font) for horizontal placement.
+	XPlaDevice *Device
+	// Device table (non-variable font) / VariationIndex table (variable font) for vertical placement.
+	YPlaDevice *Device
+	// Device table (non-variable font) / VariationIndex table (variable font) for horizontal advance.
+	XAdvDevice *Device
+	// Device table (non-variable font) / VariationIndex table (variable font) for vertical advance.
+	YAdvDevice *Device
+}
+
+// parseValueRecord reads the value record at the current position.
+// Offsets to device tables are relative to base, that is the beginning of the parent table.
+func parseValueRecord(r *tableReader, valueFormat uint16, base int64) *ValueRecord {
+	v := &ValueRecord{}
+	if valueFormat&ValueFormatXPlacement != 0 {
+		v.XPlacement = r.int16()
+	}
+	if valueFormat&ValueFormatYPlacement != 0 {
+		v.YPlacement = r.int16()
+	}
+	if valueFormat&ValueFormatXAdvance != 0 {
+		v.XAdvance = r.int16()
+	}
+	if valueFormat&ValueFormatYAdvance != 0 {
+		v.YAdvance = r.int16()
+	}
+	devices := []**Device{&(v.XPlaDevice), &(v.YPlaDevice), &(v.XAdvDevice), &(v.YAdvDevice)}
+	offsets := make([]uint16, len(devices))
+	for i := range devices {
+		if valueFormat&(ValueFormatXPlacementDevice<<uint(i)) != 0 {
+			offsets[i] = r.uint16()
+		}
+	}
+	cur := r.tell()
+	for i, d := range devices {
+		if 0 != offsets[i] {
+			*d = parseDevice(r, base+int64(offsets[i]))
+		}
+	}
+	r.seek(cur)
+	return v
+}
+
+// valueRecordNode writes the value record to n, that is the parent table of the device tables.
+func valueRecordNode(n *offsetNode, v *ValueRecord, valueFormat uint16) {
+	if v == nil {
+		v = &ValueRecord{}
+	}
+	values := []int16{v.XPlacement, v.YPlacement, v.XAdvance, v.YAdvance}
+	for i, value := range values {
+		if valueFormat&(ValueFormatXPlacement<<uint(i)) != 0 {
+			n.int16(value)
+		}
+	}
+	devices := []*Device{v.XPlaDevice, v.YPlaDevice, v.XAdvDevice, v.YAdvDevice}
+	for i, d := range devices {
+		if valueFormat&(ValueFormatXPlacementDevice<<uint(i)) != 0 {
+			n.offset16(deviceNode(d))
+		}
+	}
+}
+
+// Format returns the minimum value format that represents this record.
+func (v *ValueRecord) Format() uint16 {
+	if v == nil {
+		return 0
+	}
+	format := uint16(0)
+	values := []int16{v.XPlacement, v.YPlacement, v.XAdvance, v.YAdvance}
+	for i, value := range values {
+		if 0 != value {
+			format |= ValueFormatXPlacement << uint(i)
+		}
+	}
+	devices := []*Device{v.XPlaDevice, v.YPlaDevice, v.XAdvDevice, v.YAdvDevice}
+	for i, d := range devices {
+		if d != nil {
+			format |= ValueFormatXPlacementDevice << uint(i)
+		}
+	}
+	return format
+}
+
+func (v *ValueRecord) clone() *ValueRecord {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	c.XPlaDevice = v.XPlaDevice.clone()
+	c.YPlaDevice = v.YPlaDevice.clone()
+	c.XAdvDevice = v.XAdvDevice.clone()
+	c.YAdvDevice = v.YAdvDevice.clone()
+	return &c
+}
+
+// Anchor is an anchor table that specifies the position of an attachment point.
+type Anchor struct {
+	// Format identifier: 1 for design units only, 2 for design units plus contour point, 3 for design units plus device or VariationIndex tables.
+	Format uint16
+	// Horizontal value, in design units.
+	XCoordinate int16
+	// Vertical value, in design units.
+	YCoordinate int16
+	// Index to glyph contour point, only for format 2.
+	AnchorPoint uint16
+	// Device table (non-variable font) / VariationIndex table (variable font) for X coordinate, only for format 3.
+	XDevice *Device
+	// Device table (non-variable font) / VariationIndex table (variable font) for Y coordinate, only for format 3.
+	YDevice *Device
+}
+
+func parseAnchor(r *tableReader, offset int64) *Anchor {
+	a := &Anchor{}
+	r.seek(offset)
+	a.Format = r.uint16()
+	a.XCoordinate = r.int16()
+	a.YCoordinate = r.int16()
+	switch a.Format {
+	case 2:
+		a.AnchorPoint = r.uint16()
+	case 3:
+		xDeviceOffset := r.uint16()
+		yDeviceOffset := r.uint16()
+		if 0 != xDeviceOffset {
+			a.XDevice = parseDevice(r, offset+int64(xDeviceOffset))
+		}
+		if 0 != yDeviceOffset {
+			a.YDevice = parseDevice(r, offset+int64(yDeviceOffset))
+		}
+	}
+	return a
+}
+
+func anchorNode(a *Anchor) *offsetNode {
+	if a == nil {
+		return nil
+	}
+	n := &offsetNode{}
+	n.uint16(a.Format)
+	n.int16(a.XCoordinate)
+	n.int16(a.YCoordinate)
+	switch a.Format {
+	case 2:
+		n.uint16(a.AnchorPoint)
+	case 3:
+		n.offset16(deviceNode(a.XDevice))
+		n.offset16(deviceNode(a.YDevice))
+	}
+	return n
+}
+
+func (a *Anchor) clone() *Anchor {
+	if a == nil {
+		return nil
+	}
+	c := *a
+	c.XDevice = a.XDevice.clone()
+	c.YDevice = a.YDevice.clone()
+	return &c
+}
+
+func deviceNode(d *Device) *offsetNode {
+	if d == nil {
+		return nil
+	}
+	return d.node()
+}
+
+// parseAnchors reads the offsets to anchor tables at the current position.
+func parseAnchors(r *tableReader, count int, base int64) []*Anchor {
+	offsets := r.uint16s(count)
+	cur := r.tell()
+	anchors := make([]*Anchor, count)
+	for i, o := range offsets {
+		if 0 != o {
+			anchors[i] = parseAnchor(r, base+int64(o))
+		}
+	}
+	r.seek(cur)
+	return anchors
+}
+
+func cloneAnchors(anchors []*Anchor) []*Anchor {
+	c := make([]*Anchor, len(anchors))
+	for i, a := range anchors {
+		c[i] = a.clone()
+	}
+	return c
+}
+
+// SinglePos is a single adjustment positioning subtable.
+type SinglePos struct {
+	// Format identifier: 1 for a single value record for all covered glyphs, 2 for a value record for each covered glyph.
+	Format uint16
+	// Coverage of the glyphs to be adjusted.
+	Coverage *Coverage
+	// Defines the types of data in the value records.
+	ValueFormat uint16
+	// Value records, only one for format 1, or ordered by coverage index for format 2.
+	ValueRecords []*ValueRecord
+}
+
+func parseSinglePos(r *tableReader, offset int64) (*SinglePos, error) {
+	st := &SinglePos{}
+	r.seek(offset)
+	st.Format = r.uint16()
+	coverageOffset := r.uint16()
+	st.ValueFormat = r.uint16()
+	switch st.Format {
+	case 1:
+		st.ValueRecords = []*ValueRecord{parseValueRecord(r, st.ValueFormat, offset)}
+	case 2:
+		count := r.uint16()
+		st.ValueRecords = make([]*ValueRecord, count)
+		for i := range st.ValueRecords {
+			st.ValueRecords[i] = parseValueRecord(r, st.ValueFormat, offset)
+		}
+	default:
+		return nil, fmt.Errorf("single adjustment positioning format %d is not supported", st.Format)
+	}
+	st.Coverage = parseCoverage(r, offset+int64(coverageOffset))
+	return st, nil
+}
+
+// ValueRecord returns the value record of the glyph.
+func (st *SinglePos) ValueRecord(gid uint16) (*ValueRecord, bool) {
+	i, ok := st.Coverage.Index(gid)
+	if !ok {
+		return nil, false
+	}
+	if 1 == st.Format {
+		i = 0
+	}
+	if i >= len(st.ValueRecords) {
+		return nil, false
+	}
+	return st.ValueRecords[i], true
+}
+
+func (st *SinglePos) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(st.Format)
+	n.offset16(st.Coverage.node())
+	n.uint16(st.ValueFormat)
+	if 2 == st.Format {
+		n.uint16(uint16(len(st.ValueRecords)))
+	}
+	for _, v := range st.ValueRecords {
+		valueRecordNode(n, v, st.ValueFormat)
+	}
+	return n
+}
+
+func (st *SinglePos) cloneSubtable() LookupSubtable {
+	c := *st
+	c.Coverage = st.Coverage.clone()
+	c.ValueRecords = make([]*ValueRecord, len(st.ValueRecords))
+	for i, v := range st.ValueRecords {
+		c.ValueRecords[i] = v.clone()
+	}
+	return &c
+}
+
+func (st *SinglePos) filter(m map[uint16]uint16) LookupSubtable {
+	coverage, indices := st.Coverage.filter(m)
+	if 0 == len(coverage.Glyphs) {
+		return nil
+	}
+	n := &SinglePos{
+		Format:      st.Format,
+		Coverage:    coverage,
+		ValueFormat: st.ValueFormat,
+	}
+	if 1 == st.Format {
+		n.ValueRecords = []*ValueRecord{st.ValueRecords[0].clone()}
+		return n
+	}
+	n.ValueRecords = make([]*ValueRecord, len(indices))
+	for i, index := range indices {
+		if index < len(st.ValueRecords) {
+			n.ValueRecords[i] = st.ValueRecords[index].clone()
+		}
+	}
+	return n
+}
+
+// PairPos is a pair adjustment positioning subtable.
+type PairPos struct {
+	// Format identifier: 1 for adjustments for glyph pairs, 2 for class pair adjustments.
+	Format uint16
+	// Coverage of the first glyph of the pairs.
+	Coverage *Coverage
+	// Defines the types of data in Value1 — for the first glyph in the pair.
+	ValueFormat1 uint16
+	// Defines the types of data in Value2 — for the second glyph in the pair.
+	ValueFormat2 uint16
+	// Pair sets ordered by coverage index, only for format 1.
+	PairSets [][]*PairValueRecord
+	// Class definition of the first glyph of the pairs, only for format 2.
+	ClassDef1 *ClassDef
+	// Class definition of the second glyph of the pairs, only for format 2.
+	ClassDef2 *ClassDef
+	// Records indexed by the class of the first glyph and the class of the second glyph, only for format 2.
+	Class1Records [][]*Class2Record
+}
+
+// PairValueRecord is an adjustment for a pair of glyphs.
+type PairValueRecord struct {
+	// Glyph ID of second glyph in the pair.
+	SecondGlyph uint16
+	// Positioning data for the first glyph in the pair.
+	Value1 *ValueRecord
+	// Positioning data for the second glyph in the pair.
+	Value2 *ValueRecord
+}
+
+// Class2Record is an adjustment for a pair of classes.
+type Class2Record struct {
+	// Positioning for first glyph.
+	Value1 *ValueRecord
+	// Positioning for second glyph.
+	Value2 *ValueRecord
+}
+
+func parsePairPos(r *tableReader, offset int64) (*PairPos, error) {
+	st := &PairPos{}
+	r.seek(offset)
+	st.Format = r.uint16()
+	coverageOffset := r.uint16()
+	st.ValueFormat1 = r.uint16()
+	st.ValueFormat2 = r.uint16()
+	size := 2 * (bits.OnesCount16(st.ValueFormat1) + bits.OnesCount16(st.ValueFormat2))
+	switch st.Format {
+	case 1:
+		offsets := r.uint16s(int(r.uint16()))
+		st.PairSets = make([][]*PairValueRecord, len(offsets))
+		for i, o := range offsets {
+			setOffset := offset + int64(o)
+			r.seek(setOffset)
+			count := int(r.uint16())
+			if !r.available(count, 2+size) {
+				return nil, r.errorf("failed to parse pair adjustment positioning: %s")
+			}
+			set := make([]*PairValueRecord, count)
+			for j := range set {
+				set[j] = &PairValueRecord{
+					SecondGlyph: r.uint16(),
+					Value1:      parseValueRecord(r, st.ValueFormat1, setOffset),
+					Value2:      parseValueRecord(r, st.ValueFormat2, setOffset),
+				}
+			}
+			st.PairSets[i] = set
+		}
+	case 2:
+		classDef1Offset := r.uint16()
+		classDef2Offset := r.uint16()
+		class1Count := r.uint16()
+		class2Count := r.uint16()
+		if size == 0 {
+			size = 1
+		}
+		if !r.available(int(class1Count)*int(class2Count), size) {
+			return nil, r.errorf("failed to parse pair adjustment positioning: %s")
+		}
+		st.Class1Records = make([][]*Class2Record, class1Count)
+		for i := range st.Class1Records {
+			st.Class1Records[i] = make([]*Class2Record, class2Count)
+			for j := range st.Class1Records[i] {
+				st.Class1Records[i][j] = &Class2Record{
+					Value1: parseValueRecord(r, st.ValueFormat1, offset),
+					Value2: parseValueRecord(r, st.ValueFormat2, offset),
+				}
+			}
+		}
+		st.ClassDef1 = parseClassDef(r, offset+int64(classDef1Offset))
+		st.ClassDef2 = parseClassDef(r, offset+int64(classDef2Offset))
+	default:
+		return nil, fmt.Errorf("pair adjustment positioning format %d is not supported", st.Format)
+	}
+	st.Coverage = parseCoverage(r, offset+int64(coverageOffset))
+	return st, nil
+}
+
+// Pair returns the value records of the pair of glyphs.
+func (st *PairPos) Pair(first, second uint16) (value1, value2 *ValueRecord, ok bool) {
+	i, ok := st.Coverage.Index(first)
+	if !ok {
+		return nil, nil, false
+	}
+	switch st.Format {
+	case 1:
+		if i >= len(st.PairSets) {
+			return nil, nil, false
+		}
+		for _, pvr := range st.PairSets[i] {
+			if pvr.SecondGlyph == second {
+				return pvr.Value1, pvr.Value2, true
+			}
+		}
+	case 2:
+		class1 := int(st.ClassDef1.Class(first))
+		class2 := int(st.ClassDef2.Class(second))
+		if class1 < len(st.Class1Records) && class2 < len(st.Class1Records[class1]) {
+			record := st.Class1Records[class1][class2]
+			return record.Value1, record.Value2, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (st *PairPos) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(st.Format)
+	n.offset16(st.Coverage.node())
+	n.uint16(st.ValueFormat1)
+	n.uint16(st.ValueFormat2)
+	switch st.Format {
+	case 1:
+		n.uint16(uint16(len(st.PairSets)))
+		for _, set := range st.PairSets {
+			sn := &offsetNode{}
+			sn.uint16(uint16(len(set)))
+			for _, pvr := range set {
+				sn.uint16(pvr.SecondGlyph)
+				valueRecordNode(sn, pvr.Value1, st.ValueFormat1)
+				valueRecordNode(sn, pvr.Value2, st.ValueFormat2)
+			}
+			n.offset16(sn)
+		}
+	case 2:
+		n.offset16(st.ClassDef1.node())
+		n.offset16(st.ClassDef2.node())
+		class2Count := 0
+		if len(st.Class1Records) > 0 {
+			class2Count = len(st.Class1Records[0])
+		}
+		n.uint16(uint16(len(st.Class1Records)))
+		n.uint16(uint16(class2Count))
+		for _, records := range st.Class1Records {
+			for _, record := range records {
+				valueRecordNode(n, record.Value1, st.ValueFormat1)
+				valueRecordNode(n, record.Value2, st.ValueFormat2)
+			}
+		}
+	}
+	return n
+}
+
+func (st *PairPos) cloneSubtable() LookupSubtable {
+	c := &PairPos{
+		Format:       st.Format,
+		Coverage:     st.Coverage.clone(),
+		ValueFormat1: st.ValueFormat1,
+		ValueFormat2: st.ValueFormat2,
+		ClassDef1:    st.ClassDef1.clone(),
+		ClassDef2:    st.ClassDef2.clone(),
+	}
+	if st.PairSets != nil {
+		c.PairSets = make([][]*PairValueRecord, len(st.PairSets))
+		for i, set := range st.PairSets {
+			c.PairSets[i] = make([]*PairValueRecord, len(set))
+			for j, pvr := range set {
+				c.PairSets[i][j] = &PairValueRecord{
+					SecondGlyph: pvr.SecondGlyph,
+					Value1:      pvr.Value1.clone(),
+					Value2:      pvr.Value2.clone(),
+				}
+			}
+		}
+	}
+	if st.Class1Records != nil {
+		c.Class1Records = make([][]*Class2Record, len(st.Class1Records))
+		for i, records := range st.Class1Records {
+			c.Class1Records[i] = make([]*Class2Record, len(records))
+			for j, record := range records {
+				c.Class1Records[i][j] = &Class2Record{
+					Value1: record.Value1.clone(),
+					Value2: record.Value2.clone(),
+				}
+			}
+		}
+	}
+	return c
+}
+
+func (st *PairPos) filter(m map[uint16]uint16) LookupSubtable {
+	coverage, indices := st.Coverage.filter(m)
+	n := &PairPos{
+		Format:       st.Format,
+		Coverage:     coverage,
+		ValueFormat1: st.ValueFormat1,
+		ValueFormat2: st.ValueFormat2,
+	}
+	switch st.Format {
+	case 1:
+		n.Coverage = &Coverage{}
+		n.PairSets = make([][]*PairValueRecord, 0, len(indices))
+		for i, gid := range coverage.Glyphs {
+			if indices[i] >= len(st.PairSets) {
+				continue
+			}
+			set := make([]*PairValueRecord, 0)
+			for _, pvr := range st.PairSets[indices[i]] {
+				if second, ok := m[pvr.SecondGlyph]; ok {
+					set = append(set, &PairValueRecord{
+						SecondGlyph: second,
+						Value1:      pvr.Value1.clone(),
+						Value2:      pvr.Value2.clone(),
+					})
+				}
+			}
+			if 0 == len(set) {
+				continue
+			}
+			sort.Slice(set, func(i, j int) bool {
+				return set[i].SecondGlyph < set[j].SecondGlyph
+			})
+			n.Coverage.Glyphs = append(n.Coverage.Glyphs, gid)
+			n.PairSets = append(n.PairSets, set)
+		}
+	case 2:
+		// classes that no longer have glyphs are removed, and the rest are renumbered in the original order.
+		classDef1 := st.ClassDef1.filter(m)
+		classDef2 := st.ClassDef2.filter(m)
+		used1 := map[uint16]bool{0: true}
+		for _, gid := range coverage.Glyphs {
+			used1[classDef1.Class(gid)] = true
+		}
+		used2 := map[uint16]bool{0: true}
+		for _, class := range classDef2.Classes {
+			used2[class] = true
+		}
+		classes1 := compactClasses(used1, len(st.Class1Records))
+		class2Count := 0
+		if 0 < len(st.Class1Records) {
+			class2Count = len(st.Class1Records[0])
+		}
+		classes2 := compactClasses(used2, class2Count)
+		n.ClassDef1 = &ClassDef{
+			Classes: make(map[uint16]uint16),
+		}
+		for gid, class := range classDef1.Classes {
+			if nc, ok := classes1[class]; ok && coverage.Contains(gid) {
+				n.ClassDef1.Classes[gid] = nc
+			}
+		}
+		n.ClassDef2 = &ClassDef{
+			Classes: make(map[uint16]uint16),
+		}
+		for gid, class := range classDef2.Classes {
+			if nc, ok := classes2[class]; ok {
+				n.ClassDef2.Classes[gid] = nc
+			}
+		}
+		n.Class1Records = make([][]*Class2Record, len(classes1))
+		for class1, nc1 := range classes1 {
+			records := make([]*Class2Record, len(classes2))
+			for class2, nc2 := range classes2 {
+				record := st.Class1Records[class1][class2]
+				records[nc2] = &Class2Record{
+					Value1: record.Value1.clone(),
+					Value2: record.Value2.clone(),
+				}
+			}
+			n.Class1Records[nc1] = records
+		}
+	}
+	if 0 == len(n.Coverage.Glyphs) {
+		return nil
+	}
+	return n
+}
+
+// compactClasses returns the map from the used classes less than count to the new classes, numbered in the original order.
+func compactClasses(used map[uint16]bool, count int) map[uint16]uint16 {
+	classes := make(map[uint16]uint16)
+	for class := 0; class < count; class++ {
+		if used[uint16(class)] {
+			classes[uint16(class)] = uint16(len(classes))
+		}
+	}
+	return classes
+}
+
+// CursivePos is a cursive attachment positioning subtable.
+type CursivePos struct {
+	// Coverage of the glyphs to be attached.
+	Coverage *Coverage
+	// Entry and exit anchors ordered by coverage index.
+	EntryExitRecords []*EntryExitRecord
+}
+
+// EntryExitRecord is a pair of the entry and exit anchors of a glyph.
+type EntryExitRecord struct {
+	// Entry anchor, may be nil.
+	EntryAnchor *Anchor
+	// Exit anchor, may be nil.
+	ExitAnchor *Anchor
+}
+
+func parseCursivePos(r *tableReader, offset int64) (*CursivePos, error) {
+	st := &CursivePos{}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("cursive attachment positioning format %d is not supported", format)
+	}
+	coverageOffset := r.uint16()
+	count := r.uint16()
+	st.EntryExitRecords = make([]*EntryExitRecord, count)
+	for i := range st.EntryExitRecords {
+		anchors := parseAnchors(r, 2, offset)
+		st.EntryExitRecords[i] = &EntryExitRecord{
+			EntryAnchor: anchors[0],
+			ExitAnchor:  anchors[1],
+		}
+	}
+	st.Coverage = parseCoverage(r, offset+int64(coverageOffset))
+	return st, nil
+}
+
+func (st *CursivePos) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(1)
+	n.offset16(st.Coverage.node())
+	n.uint16(uint16(len(st.EntryExitRecords)))
+	for _, record := range st.EntryExitRecords {
+		n.offset16(anchorNode(record.EntryAnchor))
+		n.offset16(anchorNode(record.ExitAnchor))
+	}
+	return n
+}
+
+func (st *CursivePos) cloneSubtable() LookupSubtable {
+	c := &CursivePos{
+		Coverage:         st.Coverage.clone(),
+		EntryExitRecords: make([]*EntryExitRecord, len(st.EntryExitRecords)),
+	}
+	for i, record := range st.EntryExitRecords {
+		c.EntryExitRecords[i] = &EntryExitRecord{
+			EntryAnchor: record.EntryAnchor.clone(),
+			ExitAnchor:  record.ExitAnchor.clone(),
+		}
+	}
+	return c
+}
+
+func (st *CursivePos) filter(m map[uint16]uint16) LookupSubtable {
+	coverage, indices := st.Coverage.filter(m)
+	if 0 == len(coverage.Glyphs) {
+		return nil
+	}
+	c := st.cloneSubtable().(*CursivePos)
+	n := &CursivePos{
+		Coverage:         coverage,
+		EntryExitRecords: make([]*EntryExitRecord, len(indices)),
+	}
+	for i, index := range indices {
+		if index < len(c.EntryExitRecords) {
+			n.EntryExitRecords[i] = c.EntryExitRecords[index]
+		} else {
+			n.EntryExitRecords[i] = &EntryExitRecord{}
+		}
+	}
+	return n
+}
+
+// MarkRecord is a mark class and an anchor of a mark glyph.
+type MarkRecord struct {
+	// Class defined for the associated mark.
+	MarkClass uint16
+	// Anchor of the mark glyph.
+	MarkAnchor *Anchor
+}
+
+func parseMarkArray(r *tableReader, offset int64) []*MarkRecord {
+	r.seek(offset)
+	records := make([]*MarkRecord, r.uint16())
+	for i := range records {
+		records[i] = &MarkRecord{
+			MarkClass: r.uint16(),
+		}
+		records[i].MarkAnchor = parseAnchors(r, 1, offset)[0]
+	}
+	return records
+}
+
+func markArrayNode(records []*MarkRecord) *offsetNode {
+	n := &offsetNode{}
+	n.uint16(uint16(len(records)))
+	for _, record := range records {
+		n.uint16(record.MarkClass)
+		n.offset16(anchorNode(record.MarkAnchor))
+	}
+	return n
+}
+
+func cloneMarkArray(records []*MarkRecord) []*MarkRecord {
+	c := make([]*MarkRecord, len(records))
+	for i, record := range records {
+		c[i] = &MarkRecord{
+			MarkClass:  record.MarkClass,
+			MarkAnchor: record.MarkAnchor.clone(),
+		}
+	}
+	return c
+}
+
+// parseAnchorMatrix reads the array of records that have anchors for each mark class, like BaseArray and Mark2Array.
+func parseAnchorMatrix(r *tableReader, offset int64, markClassCount uint16) [][]*Anchor {
+	r.seek(offset)
+	count := r.uint16()
+	if !r.available(int(count)*int(markClassCount), 2) {
+		return [][]*Anchor{}
+	}
+	matrix := make([][]*Anchor, count)
+	for i := range matrix {
+		matrix[i] = parseAnchors(r, int(markClassCount), offset)
+	}
+	return matrix
+}
+
+func anchorMatrixNode(matrix [][]*Anchor) *offsetNode {
+	n := &offsetNode{}
+	n.uint16(uint16(len(matrix)))
+	for _, anchors := range matrix {
+		for _, a := range anchors {
+			n.offset16(anchorNode(a))
+		}
+	}
+	return n
+}
+
+func cloneAnchorMatrix(matrix [][]*Anchor) [][]*Anchor {
+	c := make([][]*Anchor, len(matrix))
+	for i, anchors := range matrix {
+		c[i] = cloneAnchors(anchors)
+	}
+	return c
+}
+
+// filterMarks returns the coverage of the marks of a subset and their mark records.
+func filterMarks(coverage *Coverage, records []*MarkRecord, m map[uint16]uint16) (*Coverage, []*MarkRecord) {
+	covered, indices := coverage.filter(m)
+	nc := &Coverage{}
+	nr := make([]*MarkRecord, 0, len(indices))
+	for i, index := range indices {
+		if index < len(records) {
+			nc.Glyphs = append(nc.Glyphs, covered.Glyphs[i])
+			nr = append(nr, &MarkRecord{
+				MarkClass:  records[index].MarkClass,
+				MarkAnchor: records[index].MarkAnchor.clone(),
+			})
+		}
+	}
+	return nc, nr
+}
+
+// filterAnchorMatrix returns the coverage of the glyphs of a subset and their anchors.
+func filterAnchorMatrix(coverage *Coverage, matrix [][]*Anchor, m map[uint16]uint16) (*Coverage, [][]*Anchor) {
+	covered, indices := coverage.filter(m)
+	nc := &Coverage{}
+	nm := make([][]*Anchor, 0, len(indices))
+	for i, index := range indices {
+		if index < len(matrix) {
+			nc.Glyphs = append(nc.Glyphs, covered.Glyphs[i])
+			nm = append(nm, cloneAnchors(matrix[index]))
+		}
+	}
+	return nc, nm
+}
+
+// MarkBasePos is a mark-to-base attachment positioning subtable.
+type MarkBasePos struct {
+	// Coverage of the mark glyphs.
+	MarkCoverage *Coverage
+	// Coverage of the base glyphs.
+	BaseCoverage *Coverage
+	// Number of classes defined for marks.
+	MarkClassCount uint16
+	// Mark records ordered by the coverage index of the mark glyphs.
+	MarkArray []*MarkRecord
+	// Anchors indexed by the coverage index of the base glyphs and the mark class, may contain nil.
+	BaseArray [][]*Anchor
+}
+
+func parseMarkBasePos(r *tableReader, offset int64) (*MarkBasePos, error) {
+	st := &MarkBasePos{}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("mark-to-base attachment positioning format %d is not supported", format)
+	}
+	markCoverageOffset := r.uint16()
+	baseCoverageOffset := r.uint16()
+	st.MarkClassCount = r.uint16()
+	markArrayOffset := r.uint16()
+	baseArrayOffset := r.uint16()
+	st.MarkCoverage = parseCoverage(r, offset+int64(markCoverageOffset))
+	st.BaseCoverage = parseCoverage(r, offset+int64(baseCoverageOffset))
+	st.MarkArray = parseMarkArray(r, offset+int64(markArrayOffset))
+	st.BaseArray = parseAnchorMatrix(r, offset+int64(baseArrayOffset), st.MarkClassCount)
+	return st, nil
+}
+
+func (st *MarkBasePos) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(1)
+	n.offset16(st.MarkCoverage.node())
+	n.offset16(st.BaseCoverage.node())
+	n.uint16(st.MarkClassCount)
+	n.offset16(markArrayNode(st.MarkArray))
+	n.offset16(anchorMatrixNode(st.BaseArray))
+	return n
+}
+
+func (st *MarkBasePos) cloneSubtable() LookupSubtable {
+	return &MarkBasePos{
+		MarkCoverage:   st.MarkCoverage.clone(),
+		BaseCoverage:   st.BaseCoverage.clone(),
+		MarkClassCount: st.MarkClassCount,
+		MarkArray:      cloneMarkArray(st.MarkArray),
+		BaseArray:      cloneAnchorMatrix(st.BaseArray),
+	}
+}
+
+func (st *MarkBasePos) filter(m map[uint16]uint16) LookupSubtable {
+	n := &MarkBasePos{
+		MarkClassCount: st.MarkClassCount,
+	}
+	n.MarkCoverage, n.MarkArray = filterMarks(st.MarkCoverage, st.MarkArray, m)
+	n.BaseCoverage, n.BaseArray = filterAnchorMatrix(st.BaseCoverage, st.BaseArray, m)
+	if 0 == len(n.MarkArray) || 0 == len(n.BaseArray) {
+		return nil
+	}
+	return n
+}
+
+// MarkLigPos is a mark-to-ligature attachment positioning subtable.
+type MarkLigPos struct {
+	// Coverage of the mark glyphs.
+	MarkCoverage *Coverage
+	// Coverage of the ligature glyphs.
+	LigatureCoverage *Coverage
+	// Number of defined mark classes.
+	MarkClassCount uint16
+	// Mark records ordered by the coverage index of the mark glyphs.
+	MarkArray []*MarkRecord
+	// Anchors indexed by the coverage index of the ligature glyphs, the ligature component and the mark class, may contain nil.
+	LigatureArray [][][]*Anchor
+}
+
+func parseMarkLigPos(r *tableReader, offset int64) (*MarkLigPos, error) {
+	st := &MarkLigPos{}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("mark-to-ligature attachment positioning format %d is not supported", format)
+	}
+	markCoverageOffset := r.uint16()
+	ligatureCoverageOffset := r.uint16()
+	st.MarkClassCount = r.uint16()
+	markArrayOffset := r.uint16()
+	ligatureArrayOffset := r.uint16()
+	st.MarkCoverage = parseCoverage(r, offset+int64(markCoverageOffset))
+	st.LigatureCoverage = parseCoverage(r, offset+int64(ligatureCoverageOffset))
+	st.MarkArray = parseMarkArray(r, offset+int64(markArrayOffset))
+	ligatureArrayStart := offset + int64(ligatureArrayOffset)
+	r.seek(ligatureArrayStart)
+	offsets := r.uint16s(int(r.uint16()))
+	st.LigatureArray = make([][][]*Anchor, len(offsets))
+	for i, o := range offsets {
+		st.LigatureArray[i] = parseAnchorMatrix(r, ligatureArrayStart+int64(o), st.MarkClassCount)
+	}
+	return st, nil
+}
+
+func (st *MarkLigPos) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(1)
+	n.offset16(st.MarkCoverage.node())
+	n.offset16(st.LigatureCoverage.node())
+	n.uint16(st.MarkClassCount)
+	n.offset16(markArrayNode(st.MarkArray))
+	la := &offsetNode{}
+	la.uint16(uint16(len(st.LigatureArray)))
+	for _, attach := range st.LigatureArray {
+		la.offset16(anchorMatrixNode(attach))
+	}
+	n.offset16(la)
+	return n
+}
+
+func (st *MarkLigPos) cloneSubtable() LookupSubtable {
+	c := &MarkLigPos{
+		MarkCoverage:     st.MarkCoverage.clone(),
+		LigatureCoverage: st.LigatureCoverage.clone(),
+		MarkClassCount:   st.MarkClassCount,
+		MarkArray:        cloneMarkArray(st.MarkArray),
+		LigatureArray:    make([][][]*Anchor, len(st.LigatureArray)),
+	}
+	for i, attach := range st.LigatureArray {
+		c.LigatureArray[i] = cloneAnchorMatrix(attach)
+	}
+	return c
+}
+
+func (st *MarkLigPos) filter(m map[uint16]uint16) LookupSubtable {
+	n := &MarkLigPos{
+		MarkClassCount: st.MarkClassCount,
+	}
+	n.MarkCoverage, n.MarkArray = filterMarks(st.MarkCoverage, st.MarkArray, m)
+	ligatureCoverage, indices := st.LigatureCoverage.filter(m)
+	n.LigatureCoverage = &Coverage{}
+	for i, index := range indices {
+		if index < len(st.LigatureArray) {
+			n.LigatureCoverage.Glyphs = append(n.LigatureCoverage.Glyphs, ligatureCoverage.Glyphs[i])
+			n.LigatureArray = append(n.LigatureArray, cloneAnchorMatrix(st.LigatureArray[index]))
+		}
+	}
+	if 0 == len(n.MarkArray) || 0 == len(n.LigatureArray) {
+		return nil
+	}
+	return n
+}
+
+// MarkMarkPos is a mark-to-mark attachment positioning subtable.
+type MarkMarkPos struct {
+	// Coverage of the combining mark glyphs.
+	Mark1Coverage *Coverage
+	// Coverage of the base mark glyphs.
+	Mark2Coverage *Coverage
+	// Number of combining mark classes defined.
+	MarkClassCount uint16
+	// Mark records ordered by the coverage index of the combining mark glyphs.
+	Mark1Array []*MarkRecord
+	// Anchors indexed by the coverage index of the base mark glyphs and the mark class, may contain nil.
+	Mark2Array [][]*Anchor
+}
+
+func parseMarkMarkPos(r *tableReader, offset int64) (*MarkMarkPos, error) {
+	st := &MarkMarkPos{}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("mark-to-mark attachment positioning format %d is not supported", format)
+	}
+	mark1CoverageOffset := r.uint16()
+	mark2CoverageOffset := r.uint16()
+	st.MarkClassCount = r.uint16()
+	mark1ArrayOffset := r.uint16()
+	mark2ArrayOffset := r.uint16()
+	st.Mark1Coverage = parseCoverage(r, offset+int64(mark1CoverageOffset))
+	st.Mark2Coverage = parseCoverage(r, offset+int64(mark2CoverageOffset))
+	st.Mark1Array = parseMarkArray(r, offset+int64(mark1ArrayOffset))
+	st.Mark2Array = parseAnchorMatrix(r, offset+int64(mark2ArrayOffset), st.MarkClassCount)
+	return st, nil
+}
+
+func (st *MarkMarkPos) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(1)
+	n.offset16(st.Mark1Coverage.node())
+	n.offset16(st.Mark2Coverage.node())
+	n.uint16(st.MarkClassCount)
+	n.offset16(markArrayNode(st.Mark1Array))
+	n.offset16(anchorMatrixNode(st.Mark2Array))
+	return n
+}
+
+func (st *MarkMarkPos) cloneSubtable() LookupSubtable {
+	return &MarkMarkPos{
+		Mark1Coverage:  st.Mark1Coverage.clone(),
+		Mark2Coverage:  st.Mark2Coverage.clone(),
+		MarkClassCount: st.MarkClassCount,
+		Mark1Array:     cloneMarkArray(st.Mark1Array),
+		Mark2Array:     cloneAnchorMatrix(st.Mark2Array),
+	}
+}
+
+func (st *MarkMarkPos) filter(m map[uint16]uint16) LookupSubtable {
+	n := &MarkMarkPos{
+		MarkClassCount: st.MarkClassCount,
+	}
+	n.Mark1Coverage, n.Mark1Array = filterMarks(st.Mark1Coverage, st.Mark1Array, m)
+	n.Mark2Coverage, n.Mark2Array = filterAnchorMatrix(st.Mark2Coverage, st.Mark2Array, m)
+	if 0 == len(n.Mark1Array) || 0 == len(n.Mark2Array) {
+		return nil
+	}
+	return n
+}
@@ -0,0 +1,666 @@
+package opentype
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Gsub is a "GSUB" table.
+// The Glyph Substitution table provides data for substitution of glyphs for appropriate rendering of scripts.
+type Gsub struct {
+	LayoutTable
+}
+
+const (
+	// GsubLookupTypeSingle : replace one glyph with one glyph.
+	GsubLookupTypeSingle = uint16(1)
+	// GsubLookupTypeMultiple : replace one glyph with more than one glyph.
+	GsubLookupTypeMultiple = uint16(2)
+	// GsubLookupTypeAlternate : replace one glyph with one of many glyphs.
+	GsubLookupTypeAlternate = uint16(3)
+	// GsubLookupTypeLigature : replace multiple glyphs with one glyph.
+	GsubLookupTypeLigature = uint16(4)
+	// GsubLookupTypeContext : replace one or more glyphs in context.
+	GsubLookupTypeContext = uint16(5)
+	// GsubLookupTypeChainingContext : replace one or more glyphs in chained context.
+	GsubLookupTypeChainingContext = uint16(6)
+	// GsubLookupTypeExtension : extension mechanism for other substitutions.
+	GsubLookupTypeExtension = uint16(7)
+	// GsubLookupTypeReverseChainingSingle : applied in reverse order, replace single glyph in chaining context.
+	GsubLookupTypeReverseChainingSingle = uint16(8)
+)
+
+func parseGsub(f *os.File, offset, length uint32, numGlyphs uint16) (g *Gsub, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	r.numGlyphs = int(numGlyphs)
+	t, err := parseLayoutTable(r, GsubLookupTypeExtension, parseGsubSubtable)
+	if err != nil {
+		return
+	}
+	return &Gsub{
+		LayoutTable: *t,
+	}, nil
+}
+
+func parseGsubSubtable(r *tableReader, lookupType uint16, offset int64) (LookupSubtable, error) {
+	switch lookupType {
+	case GsubLookupTypeSingle:
+		return parseSingleSubst(r, offset)
+	case GsubLookupTypeMultiple:
+		return parseMultipleSubst(r, offset)
+	case GsubLookupTypeAlternate:
+		return parseAlternateSubst(r, offset)
+	case GsubLookupTypeLigature:
+		return parseLigatureSubst(r, offset)
+	case GsubLookupTypeContext:
+		return parseSequenceContext(r, offset, false)
+	case GsubLookupTypeChainingContext:
+		return parseSequenceContext(r, offset, true)
+	case GsubLookupTypeReverseChainingSingle:
+		return parseReverseChainSingleSubst(r, offset)
+	default:
+		return nil, fmt.Errorf("GSUB lookup type %d is not supported", lookupType)
+	}
+}
+
+// Tag is table name.
+func (g *Gsub) Tag() Tag {
+	return String2Tag("GSUB")
+}
+
+// store writes binary expression of this table.
+func (g *Gsub) store(w *errWriter) {
+	b, err := g.pack(GsubLookupTypeExtension)
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+// CheckSum for this table.
+func (g *Gsub) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(g)
+}
+
+// Length returns the size(byte) of this table.
+func (g *Gsub) Length() uint32 {
+	b, err := g.pack(GsubLookupTypeExtension)
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (g *Gsub) Exists() bool {
+	return g != nil
+}
+
+// clone returns a deep copy of this table.
+func (g *Gsub) clone() *Gsub {
+	if g == nil {
+		return nil
+	}
+	return &Gsub{
+		LayoutTable: *g.LayoutTable.clone(),
+	}
+}
+
+// filter returns the table for the glyphs of a subset.
+// m maps the glyph IDs of the original font to the ones of the subset.
+func (g *Gsub) filter(m map[uint16]uint16) *Gsub {
+	if g == nil {
+		return nil
+	}
+	return &Gsub{
+		LayoutTable: *g.LayoutTable.filter(m),
+	}
+}
+
+// closure returns the glyphs that can be produced from the glyphs by the lookups of the features, in numerical order.
+// The given glyphs are not included, and the glyph IDs of numGlyphs or larger are ignored.
+// Contextual lookups are assumed to be applicable, so the result may contain more glyphs than actually reachable.
+func (g *Gsub) closure(glyphs []uint16, numGlyphs uint16) []uint16 {
+	set := make(map[uint16]bool, len(glyphs))
+	for _, gid := range glyphs {
+		set[gid] = true
+	}
+	added := make([]uint16, 0)
+	changed := true
+	add := func(gid uint16) {
+		if gid < numGlyphs && !set[gid] {
+			set[gid] = true
+			added = append(added, gid)
+			changed = true
+		}
+	}
+	reachable := reachableLookups(g.LookupList, g.featureLookups())
+	for changed {
+		changed = false
+		for i, l := range g.LookupList {
+			if !reachable[i] {
+				continue
+			}
+			for _, st := range l.Subtables {
+				switch st := st.(type) {
+				case *SingleSubst:
+					for in, out := range st.Substitutes {
+						if set[in] {
+							add(out)
+						}
+					}
+				case *MultipleSubst:
+					for in, seq := range st.Sequences {
+						if set[in] {
+							for _, out := range seq {
+								add(out)
+							}
+						}
+					}
+				case *AlternateSubst:
+					for in, alternates := range st.Alternates {
+						if set[in] {
+							for _, out := range alternates {
+								add(out)
+							}
+						}
+					}
+				case *LigatureSubst:
+					for first, ligatures := range st.LigatureSets {
+						if !set[first] {
+							continue
+						}
+						for _, l := range ligatures {
+							if containsGlyphs(set, l.ComponentGlyphIDs) {
+								add(l.LigatureGlyph)
+							}
+						}
+					}
+				case *ReverseChainSingleSubst:
+					for j, in := range st.Coverage.Glyphs {
+						if set[in] && j < len(st.SubstituteGlyphIDs) {
+							add(st.SubstituteGlyphIDs[j])
+						}
+					}
+				}
+			}
+		}
+	}
+	sortGlyphs(added)
+	return added
+}
+
+// SingleSubst is a single substitution subtable, that replaces a single glyph with another.
+type SingleSubst struct {
+	// Substitute glyph IDs indexed by input glyph IDs.
+	Substitutes map[uint16]uint16
+}
+
+func parseSingleSubst(r *tableReader, offset int64) (*SingleSubst, error) {
+	st := &SingleSubst{
+		Substitutes: make(map[uint16]uint16),
+	}
+	r.seek(offset)
+	format := r.uint16()
+	coverageOffset := r.uint16()
+	switch format {
+	case 1:
+		delta := r.int16()
+		c := parseCoverage(r, offset+int64(coverageOffset))
+		for _, gid := range c.Glyphs {
+			st.Substitutes[gid] = uint16(int(gid) + int(delta))
+		}
+	case 2:
+		substitutes := r.uint16s(int(r.uint16()))
+		c := parseCoverage(r, offset+int64(coverageOffset))
+		for i, gid := range c.Glyphs {
+			if i < len(substitutes) {
+				st.Substitutes[gid] = substitutes[i]
+			}
+		}
+	default:
+		return nil, fmt.Errorf("single substitution format %d is not supported", format)
+	}
+	return st, nil
+}
+
+func (st *SingleSubst) node() *offsetNode {
+	n := &offsetNode{}
+	c := &Coverage{
+		Glyphs: sortedGlyphs(st.Substitutes),
+	}
+	delta := 0
+	for i, gid := range c.Glyphs {
+		d := int(st.Substitutes[gid]) - int(gid)
+		if 0 == i {
+			delta = d
+		} else if d != delta {
+			delta = 0x10000
+			break
+		}
+	}
+	if delta != 0x10000 {
+		n.uint16(1)
+		n.offset16(c.node())
+		n.int16(int16(delta))
+		return n
+	}
+	n.uint16(2)
+	n.offset16(c.node())
+	n.uint16(uint16(len(c.Glyphs)))
+	for _, gid := range c.Glyphs {
+		n.uint16(st.Substitutes[gid])
+	}
+	return n
+}
+
+func (st *SingleSubst) cloneSubtable() LookupSubtable {
+	c := &SingleSubst{
+		Substitutes: make(map[uint16]uint16, len(st.Substitutes)),
+	}
+	for k, v := range st.Substitutes {
+		c.Substitutes[k] = v
+	}
+	return c
+}
+
+func (st *SingleSubst) filter(m map[uint16]uint16) LookupSubtable {
+	n := &SingleSubst{
+		Substitutes: make(map[uint16]uint16),
+	}
+	for in, out := range st.Substitutes {
+		newIn, ok1 := m[in]
+		newOut, ok2 := m[out]
+		if ok1 && ok2 {
+			n.Substitutes[newIn] = newOut
+		}
+	}
+	if 0 == len(n.Substitutes) {
+		return nil
+	}
+	return n
+}
+
+// MultipleSubst is a multiple substitution subtable, that replaces a single glyph with more than one glyph.
+type MultipleSubst struct {
+	// Sequences of substitute glyph IDs indexed by input glyph IDs.
+	Sequences map[uint16][]uint16
+}
+
+func parseMultipleSubst(r *tableReader, offset int64) (*MultipleSubst, error) {
+	st := &MultipleSubst{
+		Sequences: make(map[uint16][]uint16),
+	}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("multiple substitution format %d is not supported", format)
+	}
+	coverageOffset := r.uint16()
+	offsets := r.uint16s(int(r.uint16()))
+	c := parseCoverage(r, offset+int64(coverageOffset))
+	for i, gid := range c.Glyphs {
+		if i < len(offsets) {
+			r.seek(offset + int64(offsets[i]))
+			st.Sequences[gid] = r.uint16s(int(r.uint16()))
+		}
+	}
+	return st, nil
+}
+
+func (st *MultipleSubst) node() *offsetNode {
+	return glyphArraysNode(st.Sequences)
+}
+
+func (st *MultipleSubst) cloneSubtable() LookupSubtable {
+	return &MultipleSubst{
+		Sequences: cloneGlyphArrays(st.Sequences),
+	}
+}
+
+func (st *MultipleSubst) filter(m map[uint16]uint16) LookupSubtable {
+	sequences := filterGlyphArrays(st.Sequences, m, true)
+	if 0 == len(sequences) {
+		return nil
+	}
+	return &MultipleSubst{
+		Sequences: sequences,
+	}
+}
+
+// AlternateSubst is an alternate substitution subtable, that replaces a single glyph with one of alternative glyphs.
+type AlternateSubst struct {
+	// Alternative glyph IDs in arbitrary order, indexed by input glyph IDs.
+	Alternates map[uint16][]uint16
+}
+
+func parseAlternateSubst(r *tableReader, offset int64) (*AlternateSubst, error) {
+	st := &AlternateSubst{
+		Alternates: make(map[uint16][]uint16),
+	}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("alternate substitution format %d is not supported", format)
+	}
+	coverageOffset := r.uint16()
+	offsets := r.uint16s(int(r.uint16()))
+	c := parseCoverage(r, offset+int64(coverageOffset))
+	for i, gid := range c.Glyphs {
+		if i < len(offsets) {
+			r.seek(offset + int64(offsets[i]))
+			st.Alternates[gid] = r.uint16s(int(r.uint16()))
+		}
+	}
+	return st, nil
+}
+
+func (st *AlternateSubst) node() *offsetNode {
+	return glyphArraysNode(st.Alternates)
+}
+
+func (st *AlternateSubst) cloneSubtable() LookupSubtable {
+	return &AlternateSubst{
+		Alternates: cloneGlyphArrays(st.Alternates),
+	}
+}
+
+func (st *AlternateSubst) filter(m map[uint16]uint16) LookupSubtable {
+	alternates := filterGlyphArrays(st.Alternates, m, false)
+	if 0 == len(alternates) {
+		return nil
+	}
+	return &AlternateSubst{
+		Alternates: alternates,
+	}
+}
+
+// glyphArraysNode creates the format 1 subtable of multiple and alternate substitutions, that have the same structure.
+func glyphArraysNode(arrays map[uint16][]uint16) *offsetNode {
+	n := &offsetNode{}
+	c := &Coverage{
+		Glyphs: make([]uint16, 0, len(arrays)),
+	}
+	for gid := range arrays {
+		c.Glyphs = append(c.Glyphs, gid)
+	}
+	sortGlyphs(c.Glyphs)
+	n.uint16(1)
+	n.offset16(c.node())
+	n.uint16(uint16(len(c.Glyphs)))
+	for _, gid := range c.Glyphs {
+		a := &offsetNode{}
+		a.uint16(uint16(len(arrays[gid])))
+		a.uint16s(arrays[gid])
+		n.offset16(a)
+	}
+	return n
+}
+
+func cloneGlyphArrays(arrays map[uint16][]uint16) map[uint16][]uint16 {
+	c := make(map[uint16][]uint16, len(arrays))
+	for k, v := range arrays {
+		c[k] = append([]uint16{}, v...)
+	}
+	return c
+}
+
+// filterGlyphArrays returns the glyph arrays for the glyphs of a subset.
+// If all is true, the arrays that contain any glyph not in the subset are removed,
+// otherwise such glyphs are removed from the arrays, and only empty arrays are removed.
+func filterGlyphArrays(arrays map[uint16][]uint16, m map[uint16]uint16, all bool) map[uint16][]uint16 {
+	ret := make(map[uint16][]uint16)
+	for in, gids := range arrays {
+		newIn, ok := m[in]
+		if !ok {
+			continue
+		}
+		out := make([]uint16, 0, len(gids))
+		for _, gid := range gids {
+			if n, ok := m[gid]; ok {
+				out = append(out, n)
+			} else if all {
+				out = nil
+				break
+			}
+		}
+		if 0 < len(out) {
+			ret[newIn] = out
+		}
+	}
+	return ret
+}
+
+// LigatureSubst is a ligature substitution subtable, that replaces a sequence of glyphs with a single glyph.
+type LigatureSubst struct {
+	// Ligatures in preference order, indexed by the first glyph ID of components.
+	LigatureSets map[uint16][]*Ligature
+}
+
+// Ligature is a ligature glyph and its components.
+type Ligature struct {
+	// Glyph ID of ligature to substitute.
+	LigatureGlyph uint16
+	// Component glyph IDs, beginning with the second component.
+	ComponentGlyphIDs []uint16
+}
+
+func parseLigatureSubst(r *tableReader, offset int64) (*LigatureSubst, error) {
+	st := &LigatureSubst{
+		LigatureSets: make(map[uint16][]*Ligature),
+	}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("ligature substitution format %d is not supported", format)
+	}
+	coverageOffset := r.uint16()
+	offsets := r.uint16s(int(r.uint16()))
+	c := parseCoverage(r, offset+int64(coverageOffset))
+	for i, gid := range c.Glyphs {
+		if i >= len(offsets) {
+			break
+		}
+		setOffset := offset + int64(offsets[i])
+		r.seek(setOffset)
+		ligatureOffsets := r.uint16s(int(r.uint16()))
+		ligatures := make([]*Ligature, len(ligatureOffsets))
+		for j, o := range ligatureOffsets {
+			r.seek(setOffset + int64(o))
+			l := &Ligature{
+				LigatureGlyph: r.uint16(),
+			}
+			l.ComponentGlyphIDs = r.uint16s(inputCount(r.uint16()))
+			ligatures[j] = l
+		}
+		st.LigatureSets[gid] = ligatures
+	}
+	return st, nil
+}
+
+func (st *LigatureSubst) node() *offsetNode {
+	n := &offsetNode{}
+	c := &Coverage{
+		Glyphs: make([]uint16, 0, len(st.LigatureSets)),
+	}
+	for gid := range st.LigatureSets {
+		c.Glyphs = append(c.Glyphs, gid)
+	}
+	sortGlyphs(c.Glyphs)
+	n.uint16(1)
+	n.offset16(c.node())
+	n.uint16(uint16(len(c.Glyphs)))
+	for _, gid := range c.Glyphs {
+		set := &offsetNode{}
+		set.uint16(uint16(len(st.LigatureSets[gid])))
+		for _, l := range st.LigatureSets[gid] {
+			ln := &offsetNode{}
+			ln.uint16(l.LigatureGlyph)
+			ln.uint16(uint16(len(l.ComponentGlyphIDs) + 1))
+			ln.uint16s(l.ComponentGlyphIDs)
+			set.offset16(ln)
+		}
+		n.offset16(set)
+	}
+	return n
+}
+
+func (st *LigatureSubst) cloneSubtable() LookupSubtable {
+	c := &LigatureSubst{
+		LigatureSets: make(map[uint16][]*Ligature, len(st.LigatureSets)),
+	}
+	for gid, ligatures := range st.LigatureSets {
+		ls := make([]*Ligature, len(ligatures))
+		for i, l := range ligatures {
+			ls[i] = &Ligature{
+				LigatureGlyph:     l.LigatureGlyph,
+				ComponentGlyphIDs: append([]uint16{}, l.ComponentGlyphIDs...),
+			}
+		}
+		c.LigatureSets[gid] = ls
+	}
+	return c
+}
+
+func (st *LigatureSubst) filter(m map[uint16]uint16) LookupSubtable {
+	n := &LigatureSubst{
+		LigatureSets: make(map[uint16][]*Ligature),
+	}
+	for first, ligatures := range st.LigatureSets {
+		newFirst, ok := m[first]
+		if !ok {
+			continue
+		}
+		ls := make([]*Ligature, 0, len(ligatures))
+		for _, l := range ligatures {
+			gid, ok := m[l.LigatureGlyph]
+			if !ok {
+				continue
+			}
+			components, ok := filterGlyphs(l.ComponentGlyphIDs, m)
+			if !ok {
+				continue
+			}
+			ls = append(ls, &Ligature{
+				LigatureGlyph:     gid,
+				ComponentGlyphIDs: components,
+			})
+		}
+		if 0 < len(ls) {
+			n.LigatureSets[newFirst] = ls
+		}
+	}
+	if 0 == len(n.LigatureSets) {
+		return nil
+	}
+	return n
+}
+
+// ReverseChainSingleSubst is a reverse chaining contextual single substitution subtable, that is applied from the end of the glyph sequence.
+type ReverseChainSingleSubst struct {
+	// Coverage of the input glyph.
+	Coverage *Coverage
+	// Coverages of the backtrack sequence, in the order of the distance from the input glyph.
+	BacktrackCoverages []*Coverage
+	// Coverages of the lookahead sequence.
+	LookaheadCoverages []*Coverage
+	// Substitute glyph IDs, ordered by coverage index.
+	SubstituteGlyphIDs []uint16
+}
+
+func parseReverseChainSingleSubst(r *tableReader, offset int64) (*ReverseChainSingleSubst, error) {
+	st := &ReverseChainSingleSubst{}
+	r.seek(offset)
+	format := r.uint16()
+	if 1 != format {
+		return nil, fmt.Errorf("reverse chaining contextual single substitution format %d is not supported", format)
+	}
+	coverageOffset := r.uint16()
+	backtrackOffsets := r.uint16s(int(r.uint16()))
+	lookaheadOffsets := r.uint16s(int(r.uint16()))
+	st.SubstituteGlyphIDs = r.uint16s(int(r.uint16()))
+	st.Coverage = parseCoverage(r, offset+int64(coverageOffset))
+	st.BacktrackCoverages = parseCoverages(r, offset, backtrackOffsets)
+	st.LookaheadCoverages = parseCoverages(r, offset, lookaheadOffsets)
+	return st, nil
+}
+
+func (st *ReverseChainSingleSubst) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(1)
+	n.offset16(st.Coverage.node())
+	coveragesNode(n, st.BacktrackCoverages)
+	coveragesNode(n, st.LookaheadCoverages)
+	n.uint16(uint16(len(st.SubstituteGlyphIDs)))
+	n.uint16s(st.SubstituteGlyphIDs)
+	return n
+}
+
+func (st *ReverseChainSingleSubst) cloneSubtable() LookupSubtable {
+	return &ReverseChainSingleSubst{
+		Coverage:           st.Coverage.clone(),
+		BacktrackCoverages: cloneCoverages(st.BacktrackCoverages),
+		LookaheadCoverages: cloneCoverages(st.LookaheadCoverages),
+		SubstituteGlyphIDs: append([]uint16{}, st.SubstituteGlyphIDs...),
+	}
+}
+
+func (st *ReverseChainSingleSubst) filter(m map[uint16]uint16) LookupSubtable {
+	n := &ReverseChainSingleSubst{
+		Coverage: &Coverage{},
+	}
+	var ok bool
+	if n.BacktrackCoverages, ok = filterCoverages(st.BacktrackCoverages, m); !ok {
+		return nil
+	}
+	if n.LookaheadCoverages, ok = filterCoverages(st.LookaheadCoverages, m); !ok {
+		return nil
+	}
+	coverage, indices := st.Coverage.filter(m)
+	for i, gid := range coverage.Glyphs {
+		if indices[i] >= len(st.SubstituteGlyphIDs) {
+			continue
+		}
+		if out, ok := m[st.SubstituteGlyphIDs[indices[i]]]; ok {
+			n.Coverage.Glyphs = append(n.Coverage.Glyphs, gid)
+			n.SubstituteGlyphIDs = append(n.SubstituteGlyphIDs, out)
+		}
+	}
+	if 0 == len(n.Coverage.Glyphs) {
+		return nil
+	}
+	return n
+}
+
+func sortedGlyphs(m map[uint16]uint16) []uint16 {
+	gids := make([]uint16, 0, len(m))
+	for gid := range m {
+		gids = append(gids, gid)
+	}
+	sortGlyphs(gids)
+	return gids
+}
+
+// containsGlyphs returns true if all of the glyphs are in the set.
+func containsGlyphs(set map[uint16]bool, gids []uint16) bool {
+	for _, gid := range gids {
+		if !set[gid] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortGlyphs(gids []uint16) {
+	sort.Slice(gids, func(i, j int) bool {
+		return gids[i] < gids[j]
+	})
+}
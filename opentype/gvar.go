@@ -0,0 +1,468 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// Gvar is a "gvar" table.
+// The glyph variations table has the deltas of the points of the TrueType glyphs for the regions of the variation space.
+type Gvar struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// The number of the variation axes, that must be the same as fvar.
+	AxisCount uint16
+	// Tuple variations of the glyphs, indexed by the glyph ID.
+	// The points of a tuple variation are the points of a simple glyph or the components of a composite glyph, followed by the four phantom points.
+	// The shared tuples of the table are resolved into the peak tuples when parsed, and are chosen again when written.
+	Variations [][]*TupleVariation
+}
+
+const (
+	// gvarLongOffsets : the offsets to the glyph variation data are 32-bit.
+	gvarLongOffsets = uint16(0x0001)
+	// maxSharedTuples is the number of the shared tuples that the tuple index can refer.
+	maxSharedTuples = int(tupleIndexMask) + 1
+)
+
+func parseGvar(f *os.File, offset, length uint32) (gv *Gvar, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	gv = &Gvar{}
+	gv.MajorVersion = r.uint16()
+	gv.MinorVersion = r.uint16()
+	gv.AxisCount = r.uint16()
+	sharedTupleCount := int(r.uint16())
+	sharedTuplesOffset := r.uint32()
+	glyphCount := int(r.uint16())
+	flags := r.uint16()
+	dataArrayOffset := r.uint32()
+	offsets := make([]uint32, 0, glyphCount+1)
+	if 0 != flags&gvarLongOffsets {
+		offsets = append(offsets, r.uint32s(glyphCount+1)...)
+	} else {
+		for _, o := range r.uint16s(glyphCount + 1) {
+			offsets = append(offsets, uint32(o)*2)
+		}
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	axisCount := int(gv.AxisCount)
+	r.seek(int64(sharedTuplesOffset))
+	if !r.available(sharedTupleCount, 2*axisCount) {
+		return nil, r.errorf("failed to parse shared tuples: %s")
+	}
+	sharedTuples := make([][]F2Dot14, sharedTupleCount)
+	for i := range sharedTuples {
+		sharedTuples[i] = make([]F2Dot14, axisCount)
+		r.read(sharedTuples[i])
+	}
+	gv.Variations = make([][]*TupleVariation, glyphCount)
+	for i := range gv.Variations {
+		first, last := offsets[i], offsets[i+1]
+		if last <= first {
+			continue
+		}
+		r.seek(int64(dataArrayOffset) + int64(first))
+		if !r.available(int(last-first), 1) {
+			break
+		}
+		b := make([]byte, last-first)
+		r.read(b)
+		gv.Variations[i], err = parseTupleVariations(b, 0, axisCount, sharedTuples, 2)
+		if err != nil {
+			return nil, fmt.Errorf("glyph %d: %s", i, err)
+		}
+	}
+	return gv, r.errorf("failed to parse gvar: %s")
+}
+
+// Tag is table name.
+func (gv *Gvar) Tag() Tag {
+	return String2Tag("gvar")
+}
+
+// store writes binary expression of this table.
+func (gv *Gvar) store(w *errWriter) {
+	b, err := packOffsetNode(gv.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+// node writes the table.
+// The peak tuples used by more than one tuple variation are shared, in descending order of the frequency.
+func (gv *Gvar) node() *offsetNode {
+	counts := make(map[string]int)
+	tuples := make(map[string][]F2Dot14)
+	for _, tvs := range gv.Variations {
+		for _, tv := range tvs {
+			k := tupleKey(tv.PeakTuple)
+			counts[k]++
+			tuples[k] = tv.PeakTuple
+		}
+	}
+	keys := make([]string, 0, len(counts))
+	for k, c := range counts {
+		if 1 < c {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > maxSharedTuples {
+		keys = keys[:maxSharedTuples]
+	}
+	shared := make(map[string]int, len(keys))
+	for i, k := range keys {
+		shared[k] = i
+	}
+	data := make([][]byte, len(gv.Variations))
+	offsets := make([]uint32, len(gv.Variations)+1)
+	for i, tvs := range gv.Variations {
+		offsets[i+1] = offsets[i]
+		if 0 == len(tvs) {
+			continue
+		}
+		d := encodeTupleVariations(tvs, shared, 0)
+		// the glyph variation data is aligned for the short offsets.
+		if 0 != len(d)%2 {
+			d = append(d, 0)
+		}
+		data[i] = d
+		offsets[i+1] += uint32(len(d))
+	}
+	long := offsets[len(gv.Variations)]/2 > math.MaxUint16
+	flags, offsetSize := uint16(0), 2
+	if long {
+		flags, offsetSize = gvarLongOffsets, 4
+	}
+	sharedTuplesOffset := 20 + offsetSize*len(offsets)
+	dataArrayOffset := sharedTuplesOffset + 2*int(gv.AxisCount)*len(keys)
+	n := &offsetNode{}
+	n.uint16(gv.MajorVersion)
+	n.uint16(gv.MinorVersion)
+	n.uint16(gv.AxisCount)
+	n.uint16(uint16(len(keys)))
+	n.uint32(uint32(sharedTuplesOffset))
+	n.uint16(uint16(len(gv.Variations)))
+	n.uint16(flags)
+	n.uint32(uint32(dataArrayOffset))
+	for _, o := range offsets {
+		if long {
+			n.uint32(o)
+		} else {
+			n.uint16(uint16(o / 2))
+		}
+	}
+	for _, k := range keys {
+		for i := 0; i < int(gv.AxisCount); i++ {
+			v := F2Dot14(0)
+			if i < len(tuples[k]) {
+				v = tuples[k][i]
+			}
+			n.int16(int16(v))
+		}
+	}
+	for _, d := range data {
+		n.bytes(d)
+	}
+	return n
+}
+
+// CheckSum for this table.
+func (gv *Gvar) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(gv)
+}
+
+// Length returns the size(byte) of this table.
+func (gv *Gvar) Length() uint32 {
+	b, err := packOffsetNode(gv.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (gv *Gvar) Exists() bool {
+	return gv != nil
+}
+
+// clone returns a deep copy of this table.
+func (gv *Gvar) clone() *Gvar {
+	if gv == nil {
+		return nil
+	}
+	c := *gv
+	c.Variations = make([][]*TupleVariation, len(gv.Variations))
+	for i, tvs := range gv.Variations {
+		c.Variations[i] = cloneTupleVariations(tvs)
+	}
+	return &c
+}
+
+func (gv *Gvar) filter(f []uint16) *Gvar {
+	c := *gv
+	c.Variations = make([][]*TupleVariation, len(f))
+	for i, gid := range f {
+		if int(gid) < len(gv.Variations) {
+			c.Variations[i] = cloneTupleVariations(gv.Variations[gid])
+		}
+	}
+	return &c
+}
+
+// deltas returns the deltas of the points of the glyph at the normalized coordinates.
+// The points are the original positions of the points that the tuple variations refer.
+// If ends is not nil, the deltas of the points not referred by a tuple variation are interpolated in each contour.
+func (gv *Gvar) deltas(gid uint16, points []GlyphPoint, ends []uint16, coords []float64) (dx, dy []float64) {
+	n := len(points)
+	dx, dy = make([]float64, n), make([]float64, n)
+	if int(gid) >= len(gv.Variations) {
+		return
+	}
+	for _, tv := range gv.Variations[gid] {
+		scalar := tv.scalar(coords)
+		if 0 == scalar {
+			continue
+		}
+		tx, ty, ok := tv.pointDeltas(points, ends)
+		if !ok {
+			continue
+		}
+		for i := 0; i < n; i++ {
+			dx[i] += scalar * tx[i]
+			dy[i] += scalar * ty[i]
+		}
+	}
+	return
+}
+
+// pointDeltas returns the deltas of the tuple variation for all points, that are interpolated for the points without the deltas if ends is not nil.
+// ok is false if the tuple variation for all points does not match the number of the points.
+func (tv *TupleVariation) pointDeltas(points []GlyphPoint, ends []uint16) (tx, ty []float64, ok bool) {
+	n := len(points)
+	tx, ty = make([]float64, n), make([]float64, n)
+	if tv.PointNumbers == nil {
+		if len(tv.Deltas) != n || len(tv.DeltasY) != n {
+			return nil, nil, false
+		}
+		for i := 0; i < n; i++ {
+			tx[i], ty[i] = float64(tv.Deltas[i]), float64(tv.DeltasY[i])
+		}
+		return tx, ty, true
+	}
+	touched := make([]bool, n)
+	for k, p := range tv.PointNumbers {
+		if int(p) >= n || k >= len(tv.Deltas) || k >= len(tv.DeltasY) {
+			continue
+		}
+		tx[p], ty[p] = float64(tv.Deltas[k]), float64(tv.DeltasY[k])
+		touched[p] = true
+	}
+	if ends != nil {
+		interpolateUntouched(tx, ty, touched, points, ends)
+	}
+	return tx, ty, true
+}
+
+// interpolateUntouched infers the deltas of the untouched points in each contour from the touched points before and after them,
+// in the same way as IUP instruction.
+func interpolateUntouched(dx, dy []float64, touched []bool, points []GlyphPoint, ends []uint16) {
+	start := 0
+	for _, e := range ends {
+		end := int(e)
+		if end >= len(points) {
+			return
+		}
+		refs := make([]int, 0)
+		for i := start; i <= end; i++ {
+			if touched[i] {
+				refs = append(refs, i)
+			}
+		}
+		if 0 < len(refs) && len(refs) < end-start+1 {
+			for k, r1 := range refs {
+				r2 := refs[(k+1)%len(refs)]
+				for i := r1 + 1; ; i++ {
+					if i > end {
+						i = start
+					}
+					if i == r2 {
+						break
+					}
+					dx[i] = interpolateDelta(float64(points[i].X), float64(points[r1].X), float64(points[r2].X), dx[r1], dx[r2])
+					dy[i] = interpolateDelta(float64(points[i].Y), float64(points[r1].Y), float64(points[r2].Y), dy[r1], dy[r2])
+				}
+			}
+		}
+		start = end + 1
+	}
+}
+
+// interpolateDelta returns the delta of the coordinate v between the reference coordinates v1 and v2 that have the deltas d1 and d2.
+// The coordinate outside of the references has the delta of the nearer one.
+func interpolateDelta(v, v1, v2, d1, d2 float64) float64 {
+	if v1 == v2 {
+		if d1 == d2 {
+			return d1
+		}
+		return 0
+	}
+	if v1 > v2 {
+		v1, v2, d1, d2 = v2, v1, d2, d1
+	}
+	switch {
+	case v <= v1:
+		return d1
+	case v >= v2:
+		return d2
+	default:
+		return d1 + (v-v1)*(d2-d1)/(v2-v1)
+	}
+}
+
+// PhantomPoints are the points of the metrics of a glyph, that are varied by gvar as well as the points of the outline.
+type PhantomPoints struct {
+	// The origin and the advance point of the horizontal metrics.
+	Left  GlyphPoint
+	Right GlyphPoint
+	// The top and the bottom of the vertical metrics.
+	Top    GlyphPoint
+	Bottom GlyphPoint
+}
+
+// AdvanceWidth returns the horizontal advance of the glyph.
+func (p *PhantomPoints) AdvanceWidth() int {
+	return int(p.Right.X) - int(p.Left.X)
+}
+
+// AdvanceHeight returns the vertical advance of the glyph.
+func (p *PhantomPoints) AdvanceHeight() int {
+	return int(p.Top.Y) - int(p.Bottom.Y)
+}
+
+// phantomPoints returns the phantom points of the glyph in font design units:
+// the origin and the advance point of the horizontal metrics, and the top and the bottom of the vertical metrics.
+// If vmtx is absent, the vertical metrics are the ascender and the descender of hhea.
+func (font *Font) phantomPoints(gid uint16, g *Glyph) []GlyphPoint {
+	advanceWidth, lsb := font.Hmtx.get(gid)
+	x := int32(g.XMin) - int32(lsb)
+	var top, advanceHeight int32
+	if font.Vmtx.Exists() {
+		a, tsb := font.Vmtx.get(gid)
+		top, advanceHeight = int32(g.YMax)+int32(tsb), int32(a)
+	} else if font.Hhea.Exists() {
+		top, advanceHeight = int32(font.Hhea.Ascender), int32(font.Hhea.Ascender)-int32(font.Hhea.Descender)
+	}
+	return []GlyphPoint{
+		{X: int16(x), Y: 0},
+		{X: int16(x + int32(advanceWidth)), Y: 0},
+		{X: 0, Y: int16(top)},
+		{X: 0, Y: int16(top - advanceHeight)},
+	}
+}
+
+// variationPoints returns the points of the glyph that gvar varies, that are the points of a simple glyph or the offsets of the components of a composite glyph,
+// followed by the phantom points.
+// ends are the contours of a simple glyph, or nil for a composite glyph, whose points are not interpolated.
+func (font *Font) variationPoints(gid uint16, g *Glyph) (points []GlyphPoint, ends []uint16) {
+	if g.IsComposite() {
+		for _, c := range g.Components {
+			p := GlyphPoint{}
+			if c.ArgsAreXYValues() {
+				p.X, p.Y = int16(c.Arg1), int16(c.Arg2)
+			}
+			points = append(points, p)
+		}
+	} else {
+		points = append(points, g.Points...)
+		ends = g.EndPtsOfContours
+		if ends == nil {
+			ends = []uint16{}
+		}
+	}
+	return append(points, font.phantomPoints(gid, g)...), ends
+}
+
+// glyphAt returns the glyph description of the glyph id varied at the normalized coordinates, and its phantom points.
+// The points of a simple glyph and the offsets of the components of a composite glyph are moved by the deltas of gvar, and rounded.
+// The bounding box of a composite glyph is not updated, because it depends on the components.
+func (font *Font) glyphAt(gid uint16, coords []float64) (*Glyph, []GlyphPoint, error) {
+	g, err := font.Glyf.Glyph(gid)
+	if err != nil {
+		return nil, nil, err
+	}
+	points, ends := font.variationPoints(gid, g)
+	if font.Gvar.Exists() {
+		dx, dy := font.Gvar.deltas(gid, points, ends, coords)
+		for i := range points {
+			points[i].X = int16(math.Floor(float64(points[i].X) + dx[i] + 0.5))
+			points[i].Y = int16(math.Floor(float64(points[i].Y) + dy[i] + 0.5))
+		}
+	}
+	n := len(points) - 4
+	if g.IsComposite() {
+		for i, c := range g.Components {
+			if c.ArgsAreXYValues() {
+				c.Arg1, c.Arg2 = int32(points[i].X), int32(points[i].Y)
+			}
+		}
+	} else if !g.IsEmpty() {
+		g.Points = points[:n:n]
+		g.calcBounds()
+	}
+	return g, points[n:], nil
+}
+
+// GlyphOutlineAt returns the outline of the glyph at the location in the user space of the variation axes, and the phantom points varied as well.
+// The components of a composite glyph are varied before they are resolved into the points and the contours like Glyf.Outline.
+// The axes missing in the location are at their default values, and the outline is the default one if the font has no gvar.
+func (font *Font) GlyphOutlineAt(gid uint16, location map[Tag]float64) (*Glyph, *PhantomPoints, error) {
+	err := tableRequired(font.Glyf, font.Hmtx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("glyph outline is not available: %s", err)
+	}
+	c, err := font.NormalizeCoordinates(location)
+	if err != nil {
+		return nil, nil, err
+	}
+	coords := coordinatesFloat(c)
+	glyphs := make(map[uint16]*Glyph)
+	phantoms := make(map[uint16][]GlyphPoint)
+	o, _, err := resolveComponents(gid, 0, func(id uint16) (*Glyph, error) {
+		g, pp, err := font.glyphAt(id, coords)
+		if err != nil {
+			return nil, err
+		}
+		glyphs[id], phantoms[id] = g, pp
+		return g, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	o.calcBounds()
+	pp := phantoms[gid]
+	for _, c := range glyphs[gid].Components {
+		if 0 != c.Flags&ComponentFlagUseMyMetrics {
+			// the metrics of the component are used.
+			pp = phantoms[c.GlyphIndex]
+		}
+	}
+	return o, &PhantomPoints{Left: pp[0], Right: pp[1], Top: pp[2], Bottom: pp[3]}, nil
+}
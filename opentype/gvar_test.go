@@ -0,0 +1,118 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestGvarFont creates a variable font of the weight from 100 to 900, whose default is 400.
+// Glyph 1 has a contour of 6 points, whose points 0 and 3 and the advance point are moved at the maximum weight,
+// and an untouched square. Glyph 2 is a square of an intermediate region that peaks at the weight 650, and a region that touches its point 1 only.
+func newTestGvarFont(t *testing.T) *Font {
+	contour := &Glyph{
+		NumberOfContours: 2,
+		EndPtsOfContours: []uint16{5, 9},
+		Points: []GlyphPoint{
+			{X: 0, Y: 0, OnCurve: true}, {X: 0, Y: 100, OnCurve: true}, {X: 50, Y: 100, OnCurve: true},
+			{X: 100, Y: 100, OnCurve: true}, {X: 100, Y: 0, OnCurve: true}, {X: 50, Y: 0, OnCurve: true},
+		},
+	}
+	contour.Points = append(contour.Points, testSquare(200, 0, 50).Points...)
+	font := newTestFont(t, []*Glyph{{}, contour, testSquare(0, 0, 100)}, []uint16{500, 600, 700})
+	font.Fvar = &Fvar{
+		MajorVersion: 1,
+		Axes: []*VariationAxisRecord{
+			{AxisTag: String2Tag("wght"), MinValue: 100 << 16, DefaultValue: 400 << 16, MaxValue: 900 << 16},
+		},
+	}
+	font.Gvar = &Gvar{
+		MajorVersion: 1,
+		AxisCount:    1,
+		Variations: [][]*TupleVariation{nil, {
+			{PeakTuple: []F2Dot14{0x4000}, PointNumbers: []uint16{0, 3, 11}, Deltas: []int32{10, 30, 40}, DeltasY: []int32{0, 20, 0}},
+		}, {
+			{
+				PeakTuple:              []F2Dot14{0x2000},
+				IntermediateStartTuple: []F2Dot14{0},
+				IntermediateEndTuple:   []F2Dot14{0x4000},
+				Deltas:                 []int32{-1, -1, 1, 1, 0, 0, 0, 0},
+				DeltasY:                []int32{0, 2, 2, 0, 0, 0, 0, 0},
+			},
+			{PeakTuple: []F2Dot14{0x4000}, PointNumbers: []uint16{1}, Deltas: []int32{5}, DeltasY: []int32{5}},
+		}},
+	}
+	return font
+}
+
+func TestGvarRoundTrip(t *testing.T) {
+	font := newTestGvarFont(t)
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Gvar, parsed.Gvar) {
+		for gid, tvs := range parsed.Gvar.Variations {
+			for _, tv := range tvs {
+				t.Errorf("tuple variation of glyph %d is %+v", gid, *tv)
+			}
+		}
+		t.Fatalf("gvar is not written as it is")
+	}
+	subset, err := parsed.FilterGlyf([]uint16{0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(font.Gvar.Variations[2], subset.Gvar.Variations[1]) || nil != subset.Gvar.Variations[0] {
+		t.Errorf("gvar of the subset is %+v", subset.Gvar.Variations)
+	}
+}
+
+func TestGlyphOutlineAtInterpolatesUntouchedPoints(t *testing.T) {
+	font := writeTestFont(t, newTestGvarFont(t))
+	wght := String2Tag("wght")
+	square := testSquare(200, 0, 50).Points
+	tests := []struct {
+		weight  float64
+		points  []GlyphPoint
+		advance int
+	}{
+		{400, nil, 600},
+		// the points between the touched points 0 and 3 are interpolated, or shifted like the nearer one outside of them.
+		{900, []GlyphPoint{{X: 10, Y: 0}, {X: 10, Y: 120}, {X: 70, Y: 120}, {X: 130, Y: 120}, {X: 130, Y: 0}, {X: 70, Y: 0}}, 640},
+		{650, []GlyphPoint{{X: 5, Y: 0}, {X: 5, Y: 110}, {X: 60, Y: 110}, {X: 115, Y: 110}, {X: 115, Y: 0}, {X: 60, Y: 0}}, 620},
+	}
+	for _, tt := range tests {
+		g, pp, err := font.GlyphOutlineAt(1, map[Tag]float64{wght: tt.weight})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := tt.points
+		if want == nil {
+			o, err := font.Glyf.Outline(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want = o.Points[:6]
+		}
+		for i := range want {
+			want[i].OnCurve = true
+		}
+		want = append(append([]GlyphPoint{}, want...), square...)
+		if !reflect.DeepEqual(want, g.Points) {
+			t.Errorf("points at %g are %v, want %v", tt.weight, g.Points, want)
+		}
+		if tt.advance != pp.AdvanceWidth() {
+			t.Errorf("advance at %g is %d, want %d", tt.weight, pp.AdvanceWidth(), tt.advance)
+		}
+	}
+	// the intermediate region peaks at 650, and the only touched point of the other region shifts the whole contour by the half of (5, 5).
+	// the deltas are rounded after they are summed.
+	g, _, err := font.GlyphOutlineAt(2, map[Tag]float64{wght: 650})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GlyphPoint{{X: 2, Y: 3}, {X: 2, Y: 105}, {X: 104, Y: 105}, {X: 104, Y: 3}}
+	for i := range want {
+		want[i].OnCurve = true
+	}
+	if !reflect.DeepEqual(want, g.Points) {
+		t.Errorf("points of the intermediate region are %v, want %v", g.Points, want)
+	}
+}
@@ -74,3 +74,12 @@ func (h *Head) Length() uint32 {
 func (h *Head) Exists() bool {
 	return h != nil
 }
+
+// clone returns a copy of this table.
+func (h *Head) clone() *Head {
+	if h == nil {
+		return nil
+	}
+	c := *h
+	return &c
+}
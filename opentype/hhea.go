@@ -60,3 +60,12 @@ func (h *Hhea) Length() uint32 {
 func (h *Hhea) Exists() bool {
 	return h != nil
 }
+
+// clone returns a copy of this table.
+func (h *Hhea) clone() *Hhea {
+	if h == nil {
+		return nil
+	}
+	c := *h
+	return &c
+}
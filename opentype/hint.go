@@ -0,0 +1,1668 @@
+package opentype
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// HintedGlyph is a glyph outline grid-fitted by the TrueType instructions.
+type HintedGlyph struct {
+	// Array of point indices for the last point of each contour, in increasing numeric order.
+	EndPtsOfContours []uint16
+	// Points of the outline in pixels, where y grows upward, relative to the origin of the glyph.
+	Points []HintedPoint
+	// Advance width of the glyph in pixels, that is rounded to the grid.
+	AdvanceWidth float64
+}
+
+// HintedPoint is a point of the grid-fitted outline.
+type HintedPoint struct {
+	X       float64
+	Y       float64
+	OnCurve bool
+}
+
+// Hinter executes the TrueType instructions of a font at a size, like the version 35 interpreter of FreeType.
+// "fpgm" and "prep" are executed when the Hinter is created, and the glyph program is executed for each glyph.
+// The coordinates of the interpreter are 26.6 fixed-point numbers in pixels.
+type Hinter struct {
+	font *Font
+	ppem uint16
+	// the normalized coordinates of the variation axes, or nil for a font without fvar.
+	coords []F2Dot14
+	// the scale from font design units to 26.6 pixels, in 16.16 fixed-point.
+	scale int32
+	// the functions defined by FDEF, and the instructions defined by IDEF.
+	functions map[int32][]byte
+	idefs     map[uint8][]byte
+	// the state of the interpreter.
+	gs       hintGraphicsState
+	stack    []int32
+	maxStack int
+	cvt      []int32
+	storage  []int32
+	zones    [2]*hintZone
+	executed int
+	err      error
+	// the state after "prep", that each glyph program starts with.
+	prepGS       hintGraphicsState
+	prepCvt      []int32
+	prepStorage  []int32
+	prepTwilight *hintZone
+}
+
+// hintPoint is a point of a zone, in 26.6 fixed-point or in font design units.
+type hintPoint struct {
+	x int32
+	y int32
+}
+
+const (
+	hintTouchedX = uint8(0x01)
+	hintTouchedY = uint8(0x02)
+)
+
+// hintZone is a set of points that the instructions refer, the twilight zone (0) or the glyph zone (1).
+type hintZone struct {
+	// current, grid-fitted positions.
+	cur []hintPoint
+	// original, scaled positions.
+	org []hintPoint
+	// original positions in font design units, or the scaled positions for a composite glyph.
+	orus    []hintPoint
+	onCurve []bool
+	touched []uint8
+	endPts  []uint16
+	// whether orus are the scaled positions.
+	scaled bool
+}
+
+func newHintZone(n int) *hintZone {
+	return &hintZone{
+		cur:     make([]hintPoint, n),
+		org:     make([]hintPoint, n),
+		orus:    make([]hintPoint, n),
+		onCurve: make([]bool, n),
+		touched: make([]uint8, n),
+		endPts:  []uint16{},
+	}
+}
+
+func (z *hintZone) clone() *hintZone {
+	return &hintZone{
+		cur:     append([]hintPoint{}, z.cur...),
+		org:     append([]hintPoint{}, z.org...),
+		orus:    append([]hintPoint{}, z.orus...),
+		onCurve: append([]bool{}, z.onCurve...),
+		touched: append([]uint8{}, z.touched...),
+		endPts:  append([]uint16{}, z.endPts...),
+	}
+}
+
+// hintGraphicsState is the graphics state of the interpreter.
+type hintGraphicsState struct {
+	// projection, freedom and dual projection vectors in 2.14 fixed-point.
+	pv [2]int32
+	fv [2]int32
+	dv [2]int32
+	// dot product of fv and pv, that is never too small.
+	fdotp int32
+	// reference points and zone pointers.
+	rp                [3]int32
+	zp                [3]int32
+	loop              int32
+	minDist           int32
+	controlValueCutIn int32
+	singleWidthCutIn  int32
+	singleWidthValue  int32
+	deltaBase         int32
+	deltaShift        int32
+	roundPeriod       int32
+	roundPhase        int32
+	roundThreshold    int32
+	roundSuper45      bool
+	autoFlip          bool
+	instructControl   int32
+}
+
+var defaultHintGraphicsState = hintGraphicsState{
+	pv:                [2]int32{0x4000, 0},
+	fv:                [2]int32{0x4000, 0},
+	dv:                [2]int32{0x4000, 0},
+	fdotp:             0x4000,
+	zp:                [3]int32{1, 1, 1},
+	loop:              1,
+	minDist:           64,
+	controlValueCutIn: 68,
+	deltaBase:         9,
+	deltaShift:        3,
+	roundPeriod:       64,
+	roundThreshold:    32,
+	autoFlip:          true,
+}
+
+const (
+	// hintInterpreterVersion is the version that GETINFO returns.
+	hintInterpreterVersion = 35
+	// maxHintInstructions limits the instructions executed by a program, that may loop infinitely in broken fonts.
+	maxHintInstructions = 1 << 22
+	// maxHintCallDepth limits nested function calls.
+	maxHintCallDepth = 64
+	// hintStackMargin is added to maxStackElements of maxp, that some fonts underestimate.
+	hintStackMargin = 32
+)
+
+// NewHinter executes "fpgm" and "prep" at the size in pixels per em, and returns the Hinter for the size.
+// The glyphs of a variable font are hinted at the default values of the axes.
+func (font *Font) NewHinter(ppem uint16) (*Hinter, error) {
+	var coords []F2Dot14
+	if font.Fvar.Exists() {
+		coords = make([]F2Dot14, len(font.Fvar.Axes))
+	}
+	return font.newHinter(ppem, coords)
+}
+
+// NewHinterAt returns the Hinter for the size at the location in the user space of the variation axes.
+// The outlines and the phantom points are varied by gvar, and the control values by cvar, before they are hinted.
+func (font *Font) NewHinterAt(ppem uint16, location map[Tag]float64) (*Hinter, error) {
+	coords, err := font.NormalizeCoordinates(location)
+	if err != nil {
+		return nil, fmt.Errorf("hinting failed: %s", err)
+	}
+	return font.newHinter(ppem, coords)
+}
+
+func (font *Font) newHinter(ppem uint16, coords []F2Dot14) (*Hinter, error) {
+	err := tableRequired(font.Head, font.Maxp, font.Hmtx, font.Glyf)
+	if err != nil {
+		return nil, fmt.Errorf("hinting failed: %s", err)
+	}
+	if 0 == font.Head.UnitsPerEm {
+		return nil, fmt.Errorf("hinting failed: unitsPerEm is zero")
+	}
+	h := &Hinter{
+		font:      font,
+		ppem:      ppem,
+		coords:    coords,
+		scale:     int32((int64(ppem)<<22 + int64(font.Head.UnitsPerEm)/2) / int64(font.Head.UnitsPerEm)),
+		functions: make(map[int32][]byte),
+		idefs:     make(map[uint8][]byte),
+		storage:   make([]int32, font.Maxp.MaxStorage),
+		stack:     make([]int32, 0, int(font.Maxp.MaxStackElements)+hintStackMargin),
+		maxStack:  int(font.Maxp.MaxStackElements) + hintStackMargin,
+	}
+	h.zones[0] = newHintZone(int(font.Maxp.MaxTwilightPoints))
+	h.zones[1] = newHintZone(0)
+	if font.Cvt.Exists() {
+		values := font.cvtAt(coordinatesFloat(coords))
+		h.cvt = make([]int32, len(values))
+		for i, v := range values {
+			h.cvt[i] = mulFix(int32(v), h.scale)
+		}
+	}
+	h.gs = defaultHintGraphicsState
+	if font.Fpgm.Exists() {
+		err = h.run(font.Fpgm.Values)
+		if err != nil {
+			return nil, fmt.Errorf("hinting failed: fpgm: %s", err)
+		}
+	}
+	h.gs = defaultHintGraphicsState
+	if font.Prep.Exists() {
+		err = h.run(font.Prep.Values)
+		if err != nil {
+			return nil, fmt.Errorf("hinting failed: prep: %s", err)
+		}
+	}
+	h.prepGS = h.gs
+	if 0 != h.prepGS.instructControl&2 {
+		h.prepGS = defaultHintGraphicsState
+		h.prepGS.instructControl = h.gs.instructControl
+	}
+	h.prepCvt = append([]int32{}, h.cvt...)
+	h.prepStorage = append([]int32{}, h.storage...)
+	h.prepTwilight = h.zones[0].clone()
+	return h, nil
+}
+
+// HintedGlyph returns the outline of the glyph grid-fitted by the glyph program.
+// As FreeType does by default, an error of the glyph program is ignored, and the outline is returned as hinted until the error.
+func (h *Hinter) HintedGlyph(gid uint16) (*HintedGlyph, error) {
+	z, err := h.loadGlyph(gid, 0)
+	if err != nil {
+		return nil, fmt.Errorf("hinting failed: %s", err)
+	}
+	n := len(z.cur) - 4
+	pp1, pp2 := z.cur[n], z.cur[n+1]
+	g := &HintedGlyph{
+		EndPtsOfContours: z.endPts,
+		Points:           make([]HintedPoint, n),
+		AdvanceWidth:     float64(roundPixel(pp2.x-pp1.x)) / 64,
+	}
+	for i, p := range z.cur[:n] {
+		g.Points[i] = HintedPoint{
+			X:       float64(p.x-pp1.x) / 64,
+			Y:       float64(p.y) / 64,
+			OnCurve: z.onCurve[i],
+		}
+	}
+	return g, nil
+}
+
+// GlyphImage renders the hinted glyph with the origin of the glyph at (x, y) of the pixel grid.
+// The bounds of the returned image are the pixels that the glyph covers, which are empty for a glyph without outline.
+func (h *Hinter) GlyphImage(gid uint16, x, y float64) (*image.Alpha, error) {
+	g, err := h.HintedGlyph(gid)
+	if err != nil {
+		return nil, err
+	}
+	if 0 == len(g.Points) {
+		return image.NewAlpha(image.Rectangle{}), nil
+	}
+	xMin, yMin, xMax, yMax := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range g.Points {
+		xMin, xMax = math.Min(xMin, p.X), math.Max(xMax, p.X)
+		yMin, yMax = math.Min(yMin, p.Y), math.Max(yMax, p.Y)
+	}
+	bounds := image.Rect(
+		int(math.Floor(x+xMin)),
+		int(math.Floor(y-yMax)),
+		int(math.Ceil(x+xMax)),
+		int(math.Ceil(y-yMin)),
+	)
+	z := NewRasterizer(bounds.Dx(), bounds.Dy())
+	z.AddHintedGlyph(g, x-float64(bounds.Min.X), y-float64(bounds.Min.Y))
+	dst := image.NewAlpha(bounds)
+	z.Draw(dst)
+	return dst, nil
+}
+
+// loadGlyph loads the glyph into a new glyph zone with the phantom points at the end, and executes its instructions.
+func (h *Hinter) loadGlyph(gid uint16, depth int) (*hintZone, error) {
+	if depth > maxComponentDepth {
+		return nil, fmt.Errorf("glyph %d: components are nested too deeply", gid)
+	}
+	g, pp, err := h.glyph(gid)
+	if err != nil {
+		return nil, err
+	}
+	var z *hintZone
+	var phantoms []hintPoint
+	if !g.IsComposite() {
+		n := len(g.Points)
+		z = newHintZone(n + 4)
+		for i, p := range g.Points {
+			z.orus[i] = hintPoint{x: int32(p.X), y: int32(p.Y)}
+			z.onCurve[i] = p.OnCurve
+		}
+		z.endPts = append(z.endPts, g.EndPtsOfContours...)
+		copy(z.orus[n:], pp)
+		for i, p := range z.orus {
+			z.org[i] = hintPoint{x: mulFix(p.x, h.scale), y: mulFix(p.y, h.scale)}
+		}
+		copy(z.cur, z.org)
+		h.hint(z, g.Instructions)
+		return z, nil
+	}
+	z = newHintZone(0)
+	for _, c := range g.Components {
+		child, err := h.loadGlyph(c.GlyphIndex, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		n := len(child.cur) - 4
+		if 0 != c.Flags&ComponentFlagUseMyMetrics {
+			// the phantom points hinted by the component are used.
+			phantoms = child.cur[n:]
+		}
+		cur := c.transformHinted(child.cur[:n])
+		var dx, dy int32
+		if c.ArgsAreXYValues() {
+			ux, uy := c.Arg1, c.Arg2
+			if c.Flags&ComponentFlagScaledComponentOffset != 0 && c.Flags&ComponentFlagUnscaledComponentOffset == 0 {
+				o := c.transformHinted([]hintPoint{{x: ux, y: uy}})
+				ux, uy = o[0].x, o[0].y
+			}
+			dx, dy = mulFix(ux, h.scale), mulFix(uy, h.scale)
+			if c.Flags&ComponentFlagRoundXYToGrid != 0 {
+				dx, dy = roundPixel(dx), roundPixel(dy)
+			}
+		} else {
+			if int(c.Arg1) >= len(z.cur) || c.Arg1 < 0 || int(c.Arg2) >= n || c.Arg2 < 0 {
+				return nil, fmt.Errorf("glyph %d: component refers to a point that does not exist", gid)
+			}
+			dx, dy = z.cur[c.Arg1].x-cur[c.Arg2].x, z.cur[c.Arg1].y-cur[c.Arg2].y
+		}
+		base := uint16(len(z.cur))
+		for i := range cur {
+			z.cur = append(z.cur, hintPoint{x: cur[i].x + dx, y: cur[i].y + dy})
+		}
+		z.onCurve = append(z.onCurve, child.onCurve[:n]...)
+		for _, e := range child.endPts {
+			z.endPts = append(z.endPts, base+e)
+		}
+	}
+	if phantoms == nil {
+		for _, p := range pp {
+			phantoms = append(phantoms, hintPoint{x: mulFix(p.x, h.scale), y: mulFix(p.y, h.scale)})
+		}
+	}
+	z.cur = append(z.cur, phantoms...)
+	z.onCurve = append(z.onCurve, false, false, false, false)
+	// as FreeType does, the positions hinted by the components are the original positions of the composite glyph.
+	z.org = append([]hintPoint{}, z.cur...)
+	z.orus = append([]hintPoint{}, z.cur...)
+	z.scaled = true
+	z.touched = make([]uint8, len(z.cur))
+	h.hint(z, g.Instructions)
+	return z, nil
+}
+
+// glyph returns the glyph description varied at the coordinates of the Hinter, and its phantom points in font design units.
+func (h *Hinter) glyph(gid uint16) (*Glyph, []hintPoint, error) {
+	var g *Glyph
+	var pp []GlyphPoint
+	var err error
+	if h.font.Gvar.Exists() {
+		g, pp, err = h.font.glyphAt(gid, coordinatesFloat(h.coords))
+	} else {
+		g, err = h.font.Glyf.Glyph(gid)
+		if err == nil {
+			pp = h.font.phantomPoints(gid, g)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	ret := make([]hintPoint, len(pp))
+	for i, p := range pp {
+		ret[i] = hintPoint{x: int32(p.X), y: int32(p.Y)}
+	}
+	return g, ret, nil
+}
+
+// transformHinted returns the points transformed by the matrix of the component.
+func (c *GlyphComponent) transformHinted(points []hintPoint) []hintPoint {
+	ret := make([]hintPoint, len(points))
+	copy(ret, points)
+	if 0x4000 == c.XScale && 0x4000 == c.YScale && 0 == c.Scale01 && 0 == c.Scale10 {
+		return ret
+	}
+	for i, p := range points {
+		ret[i].x = dot14(p.x, p.y, [2]int32{int32(c.XScale), int32(c.Scale10)})
+		ret[i].y = dot14(p.x, p.y, [2]int32{int32(c.Scale01), int32(c.YScale)})
+	}
+	return ret
+}
+
+// hint rounds the phantom points, and executes the instructions on the glyph zone.
+func (h *Hinter) hint(z *hintZone, instructions []uint8) {
+	n := len(z.cur) - 4
+	z.cur[n].x = roundPixel(z.cur[n].x)
+	z.cur[n+1].x = roundPixel(z.cur[n+1].x)
+	z.cur[n+2].y = roundPixel(z.cur[n+2].y)
+	z.cur[n+3].y = roundPixel(z.cur[n+3].y)
+	if 0 == len(instructions) || 0 != h.prepGS.instructControl&1 {
+		return
+	}
+	for i := range z.touched {
+		z.touched[i] = 0
+	}
+	h.zones[0] = h.prepTwilight.clone()
+	h.zones[1] = z
+	copy(h.cvt, h.prepCvt)
+	copy(h.storage, h.prepStorage)
+	h.gs = h.prepGS
+	h.gs.pv = [2]int32{0x4000, 0}
+	h.gs.fv = h.gs.pv
+	h.gs.dv = h.gs.pv
+	h.gs.fdotp = 0x4000
+	h.gs.zp = [3]int32{1, 1, 1}
+	h.gs.loop = 1
+	h.setRound(64, 0, 32, false)
+	h.run(instructions)
+}
+
+// run executes the program with the empty stack.
+func (h *Hinter) run(program []byte) error {
+	h.stack = h.stack[:0]
+	h.executed = 0
+	h.err = nil
+	return h.execute(program)
+}
+
+// hintFrame is a frame of the function call.
+type hintFrame struct {
+	program []byte
+	pc      int
+	body    []byte
+	count   int32
+}
+
+// execute executes the instructions of the program.
+func (h *Hinter) execute(program []byte) error {
+	calls := make([]hintFrame, 0)
+	pc := 0
+	for {
+		if pc >= len(program) {
+			if 0 == len(calls) {
+				return nil
+			}
+			return fmt.Errorf("function without ENDF")
+		}
+		h.executed++
+		if h.executed > maxHintInstructions {
+			return fmt.Errorf("too many instructions")
+		}
+		op := program[pc]
+		next := pc + 1
+		switch {
+		case 0x00 == op || 0x01 == op: // SVTCA
+			v := axisVector(op)
+			h.gs.pv, h.gs.fv, h.gs.dv = v, v, v
+			h.updateFdotp()
+		case 0x02 == op || 0x03 == op: // SPVTCA
+			h.gs.pv = axisVector(op)
+			h.gs.dv = h.gs.pv
+			h.updateFdotp()
+		case 0x04 == op || 0x05 == op: // SFVTCA
+			h.gs.fv = axisVector(op)
+			h.updateFdotp()
+		case 0x06 <= op && op <= 0x09: // SPVTL, SFVTL
+			p2, p1 := h.pop(), h.pop()
+			z1, z2 := h.zone(1), h.zone(2)
+			if h.valid(z1, p1) && h.valid(z2, p2) {
+				v := lineVector(z1.cur[p1], z2.cur[p2], 0 != op&1)
+				if op < 0x08 {
+					h.gs.pv, h.gs.dv = v, v
+				} else {
+					h.gs.fv = v
+				}
+				h.updateFdotp()
+			}
+		case 0x0A == op || 0x0B == op: // SPVFS, SFVFS
+			y, x := h.pop(), h.pop()
+			v := normalize(float64(x), float64(y))
+			if 0x0A == op {
+				h.gs.pv, h.gs.dv = v, v
+			} else {
+				h.gs.fv = v
+			}
+			h.updateFdotp()
+		case 0x0C == op: // GPV
+			h.push(h.gs.pv[0])
+			h.push(h.gs.pv[1])
+		case 0x0D == op: // GFV
+			h.push(h.gs.fv[0])
+			h.push(h.gs.fv[1])
+		case 0x0E == op: // SFVTPV
+			h.gs.fv = h.gs.pv
+			h.updateFdotp()
+		case 0x0F == op: // ISECT
+			h.isect()
+		case 0x10 <= op && op <= 0x12: // SRP0, SRP1, SRP2
+			h.gs.rp[op-0x10] = h.pop()
+		case 0x13 <= op && op <= 0x15: // SZP0, SZP1, SZP2
+			v := h.pop()
+			if v < 0 || v > 1 {
+				return fmt.Errorf("invalid zone %d", v)
+			}
+			h.gs.zp[op-0x13] = v
+		case 0x16 == op: // SZPS
+			v := h.pop()
+			if v < 0 || v > 1 {
+				return fmt.Errorf("invalid zone %d", v)
+			}
+			h.gs.zp = [3]int32{v, v, v}
+		case 0x17 == op: // SLOOP
+			v := h.pop()
+			if v < 0 {
+				return fmt.Errorf("invalid loop %d", v)
+			}
+			h.gs.loop = v
+		case 0x18 == op: // RTG
+			h.setRound(64, 0, 32, false)
+		case 0x19 == op: // RTHG
+			h.setRound(64, 32, 32, false)
+		case 0x1A == op: // SMD
+			h.gs.minDist = h.pop()
+		case 0x1B == op: // ELSE
+			var err error
+			next, err = skipBranch(program, next, false)
+			if err != nil {
+				return err
+			}
+		case 0x1C == op: // JMPR
+			offset := h.pop()
+			// the jump to itself executes the instruction again, that loops forever if the stack is empty.
+			if 0 == offset && 0 == len(h.stack) {
+				return fmt.Errorf("jump to itself")
+			}
+			next = pc + int(offset)
+		case 0x1D == op: // SCVTCI
+			h.gs.controlValueCutIn = h.pop()
+		case 0x1E == op: // SSWCI
+			h.gs.singleWidthCutIn = h.pop()
+		case 0x1F == op: // SSW
+			h.gs.singleWidthValue = mulFix(h.pop(), h.scale)
+		case 0x20 == op: // DUP
+			v := h.pop()
+			h.push(v)
+			h.push(v)
+		case 0x21 == op: // POP
+			h.pop()
+		case 0x22 == op: // CLEAR
+			h.stack = h.stack[:0]
+		case 0x23 == op: // SWAP
+			b, a := h.pop(), h.pop()
+			h.push(b)
+			h.push(a)
+		case 0x24 == op: // DEPTH
+			h.push(int32(len(h.stack)))
+		case 0x25 == op || 0x26 == op: // CINDEX, MINDEX
+			k := h.pop()
+			if k <= 0 || int(k) > len(h.stack) {
+				return fmt.Errorf("invalid stack index %d", k)
+			}
+			i := len(h.stack) - int(k)
+			v := h.stack[i]
+			if 0x26 == op {
+				h.stack = append(h.stack[:i], h.stack[i+1:]...)
+			}
+			h.push(v)
+		case 0x27 == op: // ALIGNPTS
+			p2, p1 := h.pop(), h.pop()
+			z0, z1 := h.zone(0), h.zone(1)
+			if h.valid(z1, p1) && h.valid(z0, p2) {
+				d := h.project(z0.cur[p2], z1.cur[p1]) / 2
+				h.move(z1, p1, d, true)
+				h.move(z0, p2, -d, true)
+			}
+		case 0x29 == op: // UTP
+			p := h.pop()
+			z := h.zone(0)
+			if h.valid(z, p) {
+				if 0 != h.gs.fv[0] {
+					z.touched[p] &^= hintTouchedX
+				}
+				if 0 != h.gs.fv[1] {
+					z.touched[p] &^= hintTouchedY
+				}
+			}
+		case 0x2A == op || 0x2B == op: // LOOPCALL, CALL
+			f := h.pop()
+			count := int32(1)
+			if 0x2A == op {
+				count = h.pop()
+			}
+			body, ok := h.functions[f]
+			if !ok {
+				return fmt.Errorf("undefined function %d", f)
+			}
+			if count > 0 {
+				if len(calls) >= maxHintCallDepth {
+					return fmt.Errorf("functions are nested too deeply")
+				}
+				calls = append(calls, hintFrame{program: program, pc: next, body: body, count: count})
+				program, next = body, 0
+			}
+		case 0x2C == op: // FDEF
+			f := h.pop()
+			end, err := skipFunction(program, next)
+			if err != nil {
+				return err
+			}
+			h.functions[f] = program[next:end]
+			next = end
+		case 0x2D == op: // ENDF
+			if 0 == len(calls) {
+				return fmt.Errorf("ENDF without function")
+			}
+			c := &calls[len(calls)-1]
+			c.count--
+			if c.count > 0 {
+				next = 0
+			} else {
+				program, next = c.program, c.pc
+				calls = calls[:len(calls)-1]
+			}
+		case 0x2E == op || 0x2F == op: // MDAP
+			p := h.pop()
+			z := h.zone(0)
+			if h.valid(z, p) {
+				d := int32(0)
+				if 0x2F == op {
+					c := h.project(z.cur[p], hintPoint{})
+					d = h.round(c) - c
+				}
+				h.move(z, p, d, true)
+			}
+			h.gs.rp[0], h.gs.rp[1] = p, p
+		case 0x30 == op || 0x31 == op: // IUP
+			h.iup(0x31 == op)
+		case 0x32 == op || 0x33 == op: // SHP
+			_, dx, dy, ok := h.displacement(op)
+			for ; 0 < h.gs.loop; h.gs.loop-- {
+				p := h.pop()
+				if ok && h.valid(h.zone(2), p) {
+					h.shift(h.zone(2), p, dx, dy, true)
+				}
+			}
+			h.gs.loop = 1
+		case 0x34 == op || 0x35 == op: // SHC
+			c := h.pop()
+			z, dx, dy, ok := h.displacement(op)
+			z2 := h.zone(2)
+			if ok && 0 <= c && int(c) < len(z2.endPts) {
+				start := 0
+				if 0 < c {
+					start = int(z2.endPts[c-1]) + 1
+				}
+				ref := h.gs.rp[2]
+				if 0 != op&1 {
+					ref = h.gs.rp[1]
+				}
+				for p := start; p <= int(z2.endPts[c]) && p < len(z2.cur); p++ {
+					if z != z2 || int32(p) != ref {
+						h.shift(z2, int32(p), dx, dy, true)
+					}
+				}
+			}
+		case 0x36 == op || 0x37 == op: // SHZ
+			// the argument is only checked, and the points of zp2 are shifted.
+			e := h.pop()
+			if e < 0 || e > 1 {
+				return fmt.Errorf("invalid zone %d", e)
+			}
+			z, dx, dy, ok := h.displacement(op)
+			ze := h.zone(2)
+			ref := h.gs.rp[2]
+			if 0 != op&1 {
+				ref = h.gs.rp[1]
+			}
+			if ok {
+				// the phantom points of the glyph zone are not shifted.
+				n := len(ze.cur)
+				if 1 == h.gs.zp[2] {
+					n = 0
+					if 0 < len(ze.endPts) {
+						n = int(ze.endPts[len(ze.endPts)-1]) + 1
+					}
+				}
+				for p := 0; p < n; p++ {
+					if z != ze || int32(p) != ref {
+						h.shift(ze, int32(p), dx, dy, false)
+					}
+				}
+			}
+		case 0x38 == op: // SHPIX
+			d := h.pop()
+			dx, dy := mulF2Dot14(d, h.gs.fv[0]), mulF2Dot14(d, h.gs.fv[1])
+			for ; 0 < h.gs.loop; h.gs.loop-- {
+				p := h.pop()
+				if h.valid(h.zone(2), p) {
+					h.shift(h.zone(2), p, dx, dy, true)
+				}
+			}
+			h.gs.loop = 1
+		case 0x39 == op: // IP
+			h.ip()
+		case 0x3A == op || 0x3B == op: // MSIRP
+			d, p := h.pop(), h.pop()
+			z0, z1 := h.zone(0), h.zone(1)
+			rp0 := h.gs.rp[0]
+			if h.valid(z1, p) && h.valid(z0, rp0) {
+				if 0 == h.gs.zp[1] {
+					z1.org[p] = z0.org[rp0]
+					h.moveOrig(z1, p, d)
+					z1.cur[p] = z1.org[p]
+				}
+				h.move(z1, p, d-h.project(z1.cur[p], z0.cur[rp0]), true)
+			}
+			h.gs.rp[1], h.gs.rp[2] = rp0, p
+			if 0x3B == op {
+				h.gs.rp[0] = p
+			}
+		case 0x3C == op: // ALIGNRP
+			z0, z1 := h.zone(0), h.zone(1)
+			rp0 := h.gs.rp[0]
+			for ; 0 < h.gs.loop; h.gs.loop-- {
+				p := h.pop()
+				if h.valid(z1, p) && h.valid(z0, rp0) {
+					h.move(z1, p, -h.project(z1.cur[p], z0.cur[rp0]), true)
+				}
+			}
+			h.gs.loop = 1
+		case 0x3D == op: // RTDG
+			h.setRound(32, 0, 16, false)
+		case 0x3E == op || 0x3F == op: // MIAP
+			n, p := h.pop(), h.pop()
+			z := h.zone(0)
+			if h.valid(z, p) && 0 <= n && int(n) < len(h.cvt) {
+				d := h.cvt[n]
+				if 0 == h.gs.zp[0] {
+					z.org[p] = hintPoint{x: mulF2Dot14(d, h.gs.fv[0]), y: mulF2Dot14(d, h.gs.fv[1])}
+					z.cur[p] = z.org[p]
+				}
+				c := h.project(z.cur[p], hintPoint{})
+				if 0x3F == op {
+					if abs32(d-c) > h.gs.controlValueCutIn {
+						d = c
+					}
+					d = h.round(d)
+				}
+				h.move(z, p, d-c, true)
+			}
+			h.gs.rp[0], h.gs.rp[1] = p, p
+		case 0x40 == op || 0x41 == op || 0xB0 <= op && op <= 0xBF: // NPUSHB, NPUSHW, PUSHB, PUSHW
+			l := instructionLength(program, pc)
+			if pc+l > len(program) {
+				return fmt.Errorf("push data exceeds the program")
+			}
+			h.pushData(program[pc:pc+l], op)
+			next = pc + l
+		case 0x42 == op: // WS
+			v, l := h.pop(), h.pop()
+			if 0 <= l && int(l) < len(h.storage) {
+				h.storage[l] = v
+			}
+		case 0x43 == op: // RS
+			l := h.pop()
+			v := int32(0)
+			if 0 <= l && int(l) < len(h.storage) {
+				v = h.storage[l]
+			}
+			h.push(v)
+		case 0x44 == op || 0x70 == op: // WCVTP, WCVTF
+			v, l := h.pop(), h.pop()
+			if 0x70 == op {
+				v = mulFix(v, h.scale)
+			}
+			if 0 <= l && int(l) < len(h.cvt) {
+				h.cvt[l] = v
+			}
+		case 0x45 == op: // RCVT
+			l := h.pop()
+			v := int32(0)
+			if 0 <= l && int(l) < len(h.cvt) {
+				v = h.cvt[l]
+			}
+			h.push(v)
+		case 0x46 == op || 0x47 == op: // GC
+			p := h.pop()
+			z := h.zone(2)
+			v := int32(0)
+			if h.valid(z, p) {
+				if 0x47 == op {
+					v = h.dualProject(z.org[p], hintPoint{})
+				} else {
+					v = h.project(z.cur[p], hintPoint{})
+				}
+			}
+			h.push(v)
+		case 0x48 == op: // SCFS
+			k, p := h.pop(), h.pop()
+			z := h.zone(2)
+			if h.valid(z, p) {
+				h.move(z, p, k-h.project(z.cur[p], hintPoint{}), true)
+				if 0 == h.gs.zp[2] {
+					z.org[p] = z.cur[p]
+				}
+			}
+		case 0x49 == op || 0x4A == op: // MD
+			k, l := h.pop(), h.pop()
+			z0, z1 := h.zone(0), h.zone(1)
+			v := int32(0)
+			if h.valid(z0, l) && h.valid(z1, k) {
+				if 0x49 == op {
+					v = h.project(z0.cur[l], z1.cur[k])
+				} else {
+					v = h.originalDistance(z0, l, z1, k)
+				}
+			}
+			h.push(v)
+		case 0x4B == op || 0x4C == op: // MPPEM, MPS
+			h.push(int32(h.ppem))
+		case 0x4D == op: // FLIPON
+			h.gs.autoFlip = true
+		case 0x4E == op: // FLIPOFF
+			h.gs.autoFlip = false
+		case 0x4F == op: // DEBUG
+			h.pop()
+		case 0x50 <= op && op <= 0x55: // LT, LTEQ, GT, GTEQ, EQ, NEQ
+			b, a := h.pop(), h.pop()
+			h.pushBool([]bool{a < b, a <= b, a > b, a >= b, a == b, a != b}[op-0x50])
+		case 0x56 == op: // ODD
+			h.pushBool(64 == h.round(h.pop())&127)
+		case 0x57 == op: // EVEN
+			h.pushBool(0 == h.round(h.pop())&127)
+		case 0x58 == op: // IF
+			if 0 == h.pop() {
+				var err error
+				next, err = skipBranch(program, next, true)
+				if err != nil {
+					return err
+				}
+			}
+		case 0x59 == op: // EIF
+		case 0x5A == op: // AND
+			b, a := h.pop(), h.pop()
+			h.pushBool(0 != a && 0 != b)
+		case 0x5B == op: // OR
+			b, a := h.pop(), h.pop()
+			h.pushBool(0 != a || 0 != b)
+		case 0x5C == op: // NOT
+			h.pushBool(0 == h.pop())
+		case 0x5D == op || 0x71 == op || 0x72 == op: // DELTAP1, DELTAP2, DELTAP3
+			h.deltaP(op)
+		case 0x5E == op: // SDB
+			h.gs.deltaBase = h.pop()
+		case 0x5F == op: // SDS
+			h.gs.deltaShift = h.pop()
+		case 0x60 == op: // ADD
+			b, a := h.pop(), h.pop()
+			h.push(a + b)
+		case 0x61 == op: // SUB
+			b, a := h.pop(), h.pop()
+			h.push(a - b)
+		case 0x62 == op: // DIV
+			b, a := h.pop(), h.pop()
+			if 0 == b {
+				return fmt.Errorf("division by zero")
+			}
+			h.push(int32(int64(a) * 64 / int64(b)))
+		case 0x63 == op: // MUL
+			b, a := h.pop(), h.pop()
+			h.push(mulDiv(a, b, 64))
+		case 0x64 == op: // ABS
+			h.push(abs32(h.pop()))
+		case 0x65 == op: // NEG
+			h.push(-h.pop())
+		case 0x66 == op: // FLOOR
+			h.push(h.pop() &^ 63)
+		case 0x67 == op: // CEILING
+			h.push((h.pop() + 63) &^ 63)
+		case 0x68 <= op && op <= 0x6B: // ROUND
+			h.push(h.round(h.pop()))
+		case 0x6C <= op && op <= 0x6F: // NROUND
+		case 0x73 <= op && op <= 0x75: // DELTAC1, DELTAC2, DELTAC3
+			h.deltaC(op)
+		case 0x76 == op || 0x77 == op: // SROUND, S45ROUND
+			h.superRound(h.pop(), 0x77 == op)
+		case 0x78 == op || 0x79 == op: // JROT, JROF
+			e, offset := h.pop(), h.pop()
+			if (0 != e) == (0x78 == op) {
+				if 0 == offset && 0 == len(h.stack) {
+					return fmt.Errorf("jump to itself")
+				}
+				next = pc + int(offset)
+			}
+		case 0x7A == op: // ROFF
+			h.setRound(0, 0, 0, false)
+		case 0x7C == op: // RUTG
+			h.setRound(64, 0, 63, false)
+		case 0x7D == op: // RDTG
+			h.setRound(64, 0, 0, false)
+		case 0x7E == op || 0x7F == op: // SANGW, AA
+			h.pop()
+		case 0x80 == op: // FLIPPT
+			z := h.zone(0)
+			for ; 0 < h.gs.loop; h.gs.loop-- {
+				p := h.pop()
+				if h.valid(z, p) {
+					z.onCurve[p] = !z.onCurve[p]
+				}
+			}
+			h.gs.loop = 1
+		case 0x81 == op || 0x82 == op: // FLIPRGON, FLIPRGOFF
+			hi, lo := h.pop(), h.pop()
+			z := h.zone(0)
+			if h.valid(z, lo) && h.valid(z, hi) {
+				for p := lo; p <= hi; p++ {
+					z.onCurve[p] = 0x81 == op
+				}
+			}
+		case 0x85 == op: // SCANCTRL
+			h.pop()
+		case 0x86 == op || 0x87 == op: // SDPVTL
+			p2, p1 := h.pop(), h.pop()
+			z1, z2 := h.zone(1), h.zone(2)
+			if h.valid(z1, p1) && h.valid(z2, p2) {
+				h.gs.pv = lineVector(z1.cur[p1], z2.cur[p2], 0 != op&1)
+				h.gs.dv = lineVector(z1.org[p1], z2.org[p2], 0 != op&1)
+				h.updateFdotp()
+			}
+		case 0x88 == op: // GETINFO
+			h.push(h.info(h.pop()))
+		case 0x89 == op: // IDEF
+			f := h.pop()
+			end, err := skipFunction(program, next)
+			if err != nil {
+				return err
+			}
+			h.idefs[uint8(f)] = program[next:end]
+			next = end
+		case 0x8A == op: // ROLL
+			c, b, a := h.pop(), h.pop(), h.pop()
+			h.push(b)
+			h.push(c)
+			h.push(a)
+		case 0x8B == op: // MAX
+			b, a := h.pop(), h.pop()
+			if a < b {
+				a = b
+			}
+			h.push(a)
+		case 0x8C == op: // MIN
+			b, a := h.pop(), h.pop()
+			if a > b {
+				a = b
+			}
+			h.push(a)
+		case 0x8D == op: // SCANTYPE
+			h.pop()
+		case 0x8E == op: // INSTCTRL
+			s, v := h.pop(), h.pop()
+			if s < 1 || s > 3 {
+				return fmt.Errorf("invalid selector of INSTCTRL %d", s)
+			}
+			if 0 != v {
+				h.gs.instructControl |= 1 << uint(s-1)
+			} else {
+				h.gs.instructControl &^= 1 << uint(s-1)
+			}
+		case 0x91 == op && h.coords != nil: // GETVARIATION
+			for _, c := range h.coords {
+				h.push(int32(c))
+			}
+		case 0xC0 <= op && op <= 0xDF: // MDRP
+			h.mdrp(op)
+		case 0xE0 <= op: // MIRP
+			h.mirp(op)
+		default:
+			body, ok := h.idefs[op]
+			if !ok {
+				return fmt.Errorf("invalid instruction 0x%02X", op)
+			}
+			if len(calls) >= maxHintCallDepth {
+				return fmt.Errorf("functions are nested too deeply")
+			}
+			calls = append(calls, hintFrame{program: program, pc: next, body: body, count: 1})
+			program, next = body, 0
+		}
+		if h.err != nil {
+			return h.err
+		}
+		if next < 0 {
+			return fmt.Errorf("jump before the program")
+		}
+		pc = next
+	}
+}
+
+func (h *Hinter) pop() int32 {
+	if 0 == len(h.stack) {
+		if h.err == nil {
+			h.err = fmt.Errorf("stack underflow")
+		}
+		return 0
+	}
+	v := h.stack[len(h.stack)-1]
+	h.stack = h.stack[:len(h.stack)-1]
+	return v
+}
+
+func (h *Hinter) push(v int32) {
+	if len(h.stack) >= h.maxStack {
+		if h.err == nil {
+			h.err = fmt.Errorf("stack overflow")
+		}
+		return
+	}
+	h.stack = append(h.stack, v)
+}
+
+func (h *Hinter) pushBool(b bool) {
+	if b {
+		h.push(1)
+	} else {
+		h.push(0)
+	}
+}
+
+// pushData pushes the data of the push instruction, whose bytes are given including the opcode.
+func (h *Hinter) pushData(b []byte, op uint8) {
+	words := 0x41 == op || 0xB8 <= op
+	data := b[1:]
+	if 0x40 == op || 0x41 == op {
+		data = b[2:]
+	}
+	if words {
+		for i := 0; i+1 < len(data); i += 2 {
+			h.push(int32(int16(uint16(data[i])<<8 | uint16(data[i+1]))))
+		}
+		return
+	}
+	for _, v := range data {
+		h.push(int32(v))
+	}
+}
+
+// zone returns the zone that the zone pointer points.
+func (h *Hinter) zone(i int) *hintZone {
+	return h.zones[h.gs.zp[i]]
+}
+
+// valid returns true if the point exists in the zone.
+// As FreeType does, the instructions that refer a point that does not exist are ignored.
+func (h *Hinter) valid(z *hintZone, p int32) bool {
+	return 0 <= p && int(p) < len(z.cur)
+}
+
+func axisVector(op uint8) [2]int32 {
+	if 0 != op&1 {
+		return [2]int32{0x4000, 0}
+	}
+	return [2]int32{0, 0x4000}
+}
+
+// lineVector returns the unit vector from b to a, or that rotated counter-clockwise if perpendicular is true.
+func lineVector(a, b hintPoint, perpendicular bool) [2]int32 {
+	dx, dy := float64(a.x-b.x), float64(a.y-b.y)
+	if 0 == dx && 0 == dy {
+		dx, perpendicular = 1, false
+	}
+	if perpendicular {
+		dx, dy = -dy, dx
+	}
+	return normalize(dx, dy)
+}
+
+// normalize returns the unit vector in 2.14 fixed-point.
+func normalize(x, y float64) [2]int32 {
+	l := math.Hypot(x, y)
+	if 0 == l {
+		return [2]int32{0x4000, 0}
+	}
+	return [2]int32{int32(math.Floor(x/l*0x4000 + 0.5)), int32(math.Floor(y/l*0x4000 + 0.5))}
+}
+
+func (h *Hinter) updateFdotp() {
+	d := dot14(h.gs.fv[0], h.gs.fv[1], h.gs.pv)
+	if abs32(d) < 0x400 {
+		d = 0x4000
+	}
+	h.gs.fdotp = d
+}
+
+// project returns the distance between the points along the projection vector.
+func (h *Hinter) project(a, b hintPoint) int32 {
+	return dot14(a.x-b.x, a.y-b.y, h.gs.pv)
+}
+
+// dualProject returns the distance between the points along the dual projection vector.
+func (h *Hinter) dualProject(a, b hintPoint) int32 {
+	return dot14(a.x-b.x, a.y-b.y, h.gs.dv)
+}
+
+// move moves the point along the freedom vector, so that its projection moves by the distance.
+func (h *Hinter) move(z *hintZone, p, d int32, touch bool) {
+	fv, pv := h.gs.fv, h.gs.pv
+	if 0x4000 == fv[0] && 0x4000 == pv[0] {
+		z.cur[p].x += d
+		if touch {
+			z.touched[p] |= hintTouchedX
+		}
+		return
+	}
+	if 0x4000 == fv[1] && 0x4000 == pv[1] {
+		z.cur[p].y += d
+		if touch {
+			z.touched[p] |= hintTouchedY
+		}
+		return
+	}
+	if 0 != fv[0] {
+		z.cur[p].x += mulDiv(d, fv[0], h.gs.fdotp)
+		if touch {
+			z.touched[p] |= hintTouchedX
+		}
+	}
+	if 0 != fv[1] {
+		z.cur[p].y += mulDiv(d, fv[1], h.gs.fdotp)
+		if touch {
+			z.touched[p] |= hintTouchedY
+		}
+	}
+}
+
+// moveOrig moves the original position of the point, like move.
+func (h *Hinter) moveOrig(z *hintZone, p, d int32) {
+	fv := h.gs.fv
+	if 0 != fv[0] {
+		z.org[p].x += mulDiv(d, fv[0], h.gs.fdotp)
+	}
+	if 0 != fv[1] {
+		z.org[p].y += mulDiv(d, fv[1], h.gs.fdotp)
+	}
+}
+
+// displacement returns the displacement of the reference point along the freedom vector, used by SHP, SHC and SHZ.
+func (h *Hinter) displacement(op uint8) (z *hintZone, dx, dy int32, ok bool) {
+	z, ref := h.zone(1), h.gs.rp[2]
+	if 0 != op&1 {
+		z, ref = h.zone(0), h.gs.rp[1]
+	}
+	if !h.valid(z, ref) {
+		return z, 0, 0, false
+	}
+	d := h.project(z.cur[ref], z.org[ref])
+	return z, mulDiv(d, h.gs.fv[0], h.gs.fdotp), mulDiv(d, h.gs.fv[1], h.gs.fdotp), true
+}
+
+// shift moves the point by the displacement.
+func (h *Hinter) shift(z *hintZone, p, dx, dy int32, touch bool) {
+	if 0 != h.gs.fv[0] {
+		z.cur[p].x += dx
+		if touch {
+			z.touched[p] |= hintTouchedX
+		}
+	}
+	if 0 != h.gs.fv[1] {
+		z.cur[p].y += dy
+		if touch {
+			z.touched[p] |= hintTouchedY
+		}
+	}
+}
+
+func (h *Hinter) setRound(period, phase, threshold int32, super45 bool) {
+	h.gs.roundPeriod, h.gs.roundPhase, h.gs.roundThreshold, h.gs.roundSuper45 = period, phase, threshold, super45
+}
+
+// superRound sets the round state of SROUND or S45ROUND.
+func (h *Hinter) superRound(n int32, super45 bool) {
+	period := int32(64)
+	if super45 {
+		period = 45
+	}
+	switch (n >> 6) & 3 {
+	case 0:
+		period /= 2
+	case 2:
+		period *= 2
+	}
+	phase := period * ((n >> 4) & 3) / 4
+	threshold := period - 1
+	if 0 != n&15 {
+		threshold = (n&15 - 4) * period / 8
+	}
+	h.setRound(period, phase, threshold, super45)
+}
+
+// round rounds the distance by the round state, keeping its sign.
+func (h *Hinter) round(x int32) int32 {
+	period, phase, threshold := h.gs.roundPeriod, h.gs.roundPhase, h.gs.roundThreshold
+	if 0 == period {
+		return x
+	}
+	floor := func(v int32) int32 {
+		if h.gs.roundSuper45 {
+			return v / period * period
+		}
+		return v &^ (period - 1)
+	}
+	if x >= 0 {
+		v := floor(x-phase+threshold) + phase
+		if v < 0 {
+			v = phase
+		}
+		return v
+	}
+	v := -floor(threshold-phase-x) - phase
+	if v > 0 {
+		v = -phase
+	}
+	return v
+}
+
+// isect moves the point to the intersection of the lines.
+func (h *Hinter) isect() {
+	b1, b0, a1, a0, p := h.pop(), h.pop(), h.pop(), h.pop(), h.pop()
+	za, zb, z := h.zone(1), h.zone(0), h.zone(2)
+	if !h.valid(za, a0) || !h.valid(za, a1) || !h.valid(zb, b0) || !h.valid(zb, b1) || !h.valid(z, p) {
+		return
+	}
+	pa0, pa1, pb0, pb1 := za.cur[a0], za.cur[a1], zb.cur[b0], zb.cur[b1]
+	dax, day := int64(pa1.x-pa0.x), int64(pa1.y-pa0.y)
+	dbx, dby := int64(pb1.x-pb0.x), int64(pb1.y-pb0.y)
+	dx, dy := int64(pb0.x-pa0.x), int64(pb0.y-pa0.y)
+	discriminant := dax*dby - day*dbx
+	dotProduct := dax*dbx + day*dby
+	// the lines are regarded as parallel if the angle is less than about 1 degree.
+	if 19*abs64(discriminant) > abs64(dotProduct) {
+		v := dx*dby - dy*dbx
+		z.cur[p].x = pa0.x + int32(v*dax/discriminant)
+		z.cur[p].y = pa0.y + int32(v*day/discriminant)
+	} else {
+		z.cur[p].x = (pa0.x + pa1.x + pb0.x + pb1.x) / 4
+		z.cur[p].y = (pa0.y + pa1.y + pb0.y + pb1.y) / 4
+	}
+	z.touched[p] |= hintTouchedX | hintTouchedY
+}
+
+// ip interpolates the points between rp1 and rp2, keeping the relation of the original positions.
+// The original positions in font design units are used unless the twilight zone is involved, as FreeType does.
+func (h *Hinter) ip() {
+	z0, z1, z2 := h.zone(0), h.zone(1), h.zone(2)
+	rp1, rp2 := h.gs.rp[1], h.gs.rp[2]
+	twilight := 0 == h.gs.zp[0] || 0 == h.gs.zp[1] || 0 == h.gs.zp[2]
+	original := func(z *hintZone, p int32) hintPoint {
+		if twilight {
+			return z.org[p]
+		}
+		return z.orus[p]
+	}
+	ok := h.valid(z0, rp1) && h.valid(z1, rp2)
+	var orgBase, curBase hintPoint
+	var orgRange, curRange int32
+	if ok {
+		orgBase, curBase = original(z0, rp1), z0.cur[rp1]
+		orgRange = h.dualProject(original(z1, rp2), orgBase)
+		curRange = h.project(z1.cur[rp2], curBase)
+	}
+	for ; 0 < h.gs.loop; h.gs.loop-- {
+		p := h.pop()
+		if !ok || !h.valid(z2, p) {
+			continue
+		}
+		orgDist := h.dualProject(original(z2, p), orgBase)
+		curDist := h.project(z2.cur[p], curBase)
+		newDist := int32(0)
+		if 0 != orgDist {
+			newDist = curDist
+			if 0 != orgRange {
+				newDist = mulDiv(orgDist, curRange, orgRange)
+			}
+		}
+		h.move(z2, p, newDist-curDist, true)
+	}
+	h.gs.loop = 1
+}
+
+// originalDistance returns the distance between the original positions along the dual projection vector.
+// The positions in font design units are used unless the twilight zone is involved, as FreeType does.
+func (h *Hinter) originalDistance(za *hintZone, a int32, zb *hintZone, b int32) int32 {
+	if za == h.zones[0] || zb == h.zones[0] || za.scaled || zb.scaled {
+		return h.dualProject(za.org[a], zb.org[b])
+	}
+	return mulFix(h.dualProject(za.orus[a], zb.orus[b]), h.scale)
+}
+
+// iup interpolates the untouched points of the glyph zone in the direction.
+func (h *Hinter) iup(x bool) {
+	z := h.zones[1]
+	flag := hintTouchedY
+	coord := func(p hintPoint) int32 { return p.y }
+	if x {
+		flag = hintTouchedX
+		coord = func(p hintPoint) int32 { return p.x }
+	}
+	set := func(i int, v int32) {
+		if x {
+			z.cur[i].x = v
+		} else {
+			z.cur[i].y = v
+		}
+	}
+	interpolate := func(p1, p2, ref1, ref2 int) {
+		if p1 > p2 {
+			return
+		}
+		if coord(z.orus[ref1]) > coord(z.orus[ref2]) {
+			ref1, ref2 = ref2, ref1
+		}
+		orus1, orus2 := coord(z.orus[ref1]), coord(z.orus[ref2])
+		org1, org2 := coord(z.org[ref1]), coord(z.org[ref2])
+		cur1, cur2 := coord(z.cur[ref1]), coord(z.cur[ref2])
+		delta1, delta2 := cur1-org1, cur2-org2
+		var scale int32
+		trivial := cur1 == cur2 || orus1 == orus2
+		if !trivial {
+			scale = divFix(cur2-cur1, orus2-orus1)
+		}
+		for i := p1; i <= p2; i++ {
+			v := coord(z.org[i])
+			switch {
+			case v <= org1:
+				v += delta1
+			case v >= org2:
+				v += delta2
+			case trivial:
+				v = cur1
+			default:
+				v = cur1 + mulFix(coord(z.orus[i])-orus1, scale)
+			}
+			set(i, v)
+		}
+	}
+	start := 0
+	for _, e := range z.endPts {
+		end := int(e)
+		if end >= len(z.cur) || end < start {
+			break
+		}
+		first := -1
+		for i := start; i <= end; i++ {
+			if 0 != z.touched[i]&flag {
+				first = i
+				break
+			}
+		}
+		if first < 0 {
+			start = end + 1
+			continue
+		}
+		cur := first
+		for i := first + 1; i <= end; i++ {
+			if 0 != z.touched[i]&flag {
+				interpolate(cur+1, i-1, cur, i)
+				cur = i
+			}
+		}
+		if cur == first {
+			// the other points are shifted from their current positions, as the single touched point is.
+			d := coord(z.cur[cur]) - coord(z.org[cur])
+			for i := start; i <= end; i++ {
+				if i != cur {
+					set(i, coord(z.cur[i])+d)
+				}
+			}
+		} else {
+			interpolate(cur+1, end, cur, first)
+			interpolate(start, first-1, cur, first)
+		}
+		start = end + 1
+	}
+}
+
+// deltaP moves the points at the size that the exceptions specify.
+func (h *Hinter) deltaP(op uint8) {
+	n := h.pop()
+	base := h.gs.deltaBase
+	switch op {
+	case 0x71:
+		base += 16
+	case 0x72:
+		base += 32
+	}
+	z := h.zone(0)
+	for k := int32(0); k < n && nil == h.err; k++ {
+		p, arg := h.pop(), h.pop()
+		if !h.valid(z, p) {
+			continue
+		}
+		if d, ok := h.delta(arg, base); ok {
+			h.move(z, p, d, true)
+		}
+	}
+}
+
+// deltaC changes the CVT values at the size that the exceptions specify.
+func (h *Hinter) deltaC(op uint8) {
+	n := h.pop()
+	base := h.gs.deltaBase + 16*int32(op-0x73)
+	for k := int32(0); k < n && nil == h.err; k++ {
+		c, arg := h.pop(), h.pop()
+		if c < 0 || int(c) >= len(h.cvt) {
+			continue
+		}
+		if d, ok := h.delta(arg, base); ok {
+			h.cvt[c] += d
+		}
+	}
+}
+
+// delta decodes the exception, and returns the distance if it applies to the size.
+func (h *Hinter) delta(arg, base int32) (int32, bool) {
+	if base+(arg&0xF0)>>4 != int32(h.ppem) {
+		return 0, false
+	}
+	d := arg&0xF - 8
+	if d >= 0 {
+		d++
+	}
+	return d * 64 / (1 << uint(h.gs.deltaShift&31)), true
+}
+
+// mdrp moves the point relative to rp0, keeping the original distance.
+func (h *Hinter) mdrp(op uint8) {
+	p := h.pop()
+	z0, z1 := h.zone(0), h.zone(1)
+	rp0 := h.gs.rp[0]
+	if h.valid(z0, rp0) && h.valid(z1, p) {
+		orgDist := h.originalDistance(z1, p, z0, rp0)
+		if abs32(orgDist-h.gs.singleWidthValue) < h.gs.singleWidthCutIn {
+			if orgDist >= 0 {
+				orgDist = h.gs.singleWidthValue
+			} else {
+				orgDist = -h.gs.singleWidthValue
+			}
+		}
+		d := orgDist
+		if 0 != op&0x04 {
+			d = h.round(orgDist)
+		}
+		if 0 != op&0x08 {
+			d = h.minDistance(d, orgDist)
+		}
+		h.move(z1, p, d-h.project(z1.cur[p], z0.cur[rp0]), true)
+	}
+	h.gs.rp[1], h.gs.rp[2] = rp0, p
+	if 0 != op&0x10 {
+		h.gs.rp[0] = p
+	}
+}
+
+// mirp moves the point relative to rp0, by the distance of the CVT entry.
+func (h *Hinter) mirp(op uint8) {
+	n, p := h.pop(), h.pop()
+	z0, z1 := h.zone(0), h.zone(1)
+	rp0 := h.gs.rp[0]
+	if h.valid(z0, rp0) && h.valid(z1, p) && -1 <= n && int(n) < len(h.cvt) {
+		cvtDist := int32(0)
+		if 0 <= n {
+			cvtDist = h.cvt[n]
+		}
+		if abs32(cvtDist-h.gs.singleWidthValue) < h.gs.singleWidthCutIn {
+			if cvtDist >= 0 {
+				cvtDist = h.gs.singleWidthValue
+			} else {
+				cvtDist = -h.gs.singleWidthValue
+			}
+		}
+		if 0 == h.gs.zp[1] {
+			z1.org[p] = hintPoint{
+				x: z0.org[rp0].x + mulF2Dot14(cvtDist, h.gs.fv[0]),
+				y: z0.org[rp0].y + mulF2Dot14(cvtDist, h.gs.fv[1]),
+			}
+			z1.cur[p] = z1.org[p]
+		}
+		orgDist := h.dualProject(z1.org[p], z0.org[rp0])
+		curDist := h.project(z1.cur[p], z0.cur[rp0])
+		if h.gs.autoFlip && (orgDist^cvtDist) < 0 {
+			cvtDist = -cvtDist
+		}
+		d := cvtDist
+		if 0 != op&0x04 {
+			if h.gs.zp[0] == h.gs.zp[1] && abs32(cvtDist-orgDist) > h.gs.controlValueCutIn {
+				cvtDist = orgDist
+			}
+			d = h.round(cvtDist)
+		}
+		if 0 != op&0x08 {
+			d = h.minDistance(d, orgDist)
+		}
+		h.move(z1, p, d-curDist, true)
+	}
+	h.gs.rp[1], h.gs.rp[2] = rp0, p
+	if 0 != op&0x10 {
+		h.gs.rp[0] = p
+	}
+}
+
+// minDistance returns the distance kept at least the minimum distance, in the direction of the original distance.
+func (h *Hinter) minDistance(d, orgDist int32) int32 {
+	if orgDist >= 0 {
+		if d < h.gs.minDist {
+			return h.gs.minDist
+		}
+		return d
+	}
+	if d > -h.gs.minDist {
+		return -h.gs.minDist
+	}
+	return d
+}
+
+// info returns the result of GETINFO.
+func (h *Hinter) info(selector int32) int32 {
+	v := int32(0)
+	if 0 != selector&1 {
+		v |= hintInterpreterVersion
+	}
+	if 0 != selector&8 && h.coords != nil {
+		// the glyphs are varied.
+		v |= 1 << 10
+	}
+	if 0 != selector&32 {
+		// the rasterizer renders in grayscale.
+		v |= 1 << 12
+	}
+	return v
+}
+
+// instructionLength returns the length of the instruction at pc, including the push data.
+func instructionLength(program []byte, pc int) int {
+	op := program[pc]
+	switch {
+	case 0x40 == op: // NPUSHB
+		if pc+1 < len(program) {
+			return 2 + int(program[pc+1])
+		}
+		return 2
+	case 0x41 == op: // NPUSHW
+		if pc+1 < len(program) {
+			return 2 + 2*int(program[pc+1])
+		}
+		return 2
+	case 0xB0 <= op && op <= 0xB7: // PUSHB
+		return 2 + int(op-0xB0)
+	case 0xB8 <= op && op <= 0xBF: // PUSHW
+		return 1 + 2*(1+int(op-0xB8))
+	}
+	return 1
+}
+
+// skipBranch returns the position after the ELSE or the EIF that matches the IF or the ELSE before pc.
+func skipBranch(program []byte, pc int, elseAllowed bool) (int, error) {
+	depth := 0
+	for pc < len(program) {
+		op := program[pc]
+		next := pc + instructionLength(program, pc)
+		switch {
+		case 0x58 == op: // IF
+			depth++
+		case 0x1B == op && 0 == depth && elseAllowed: // ELSE
+			return next, nil
+		case 0x59 == op: // EIF
+			if 0 == depth {
+				return next, nil
+			}
+			depth--
+		}
+		pc = next
+	}
+	return 0, fmt.Errorf("IF without EIF")
+}
+
+// skipFunction returns the position after the ENDF of the definition that begins at pc.
+func skipFunction(program []byte, pc int) (int, error) {
+	for pc < len(program) {
+		op := program[pc]
+		switch op {
+		case 0x2C, 0x89: // FDEF, IDEF
+			return 0, fmt.Errorf("nested function definition")
+		case 0x2D: // ENDF
+			return pc + 1, nil
+		}
+		pc += instructionLength(program, pc)
+	}
+	return 0, fmt.Errorf("FDEF without ENDF")
+}
+
+// roundPixel rounds the 26.6 value to the pixel grid.
+func roundPixel(v int32) int32 {
+	return (v + 32) &^ 63
+}
+
+// dot14 returns the dot product of the vector and the 2.14 vector.
+func dot14(x, y int32, v [2]int32) int32 {
+	return int32((int64(x)*int64(v[0]) + int64(y)*int64(v[1]) + 0x2000) >> 14)
+}
+
+// mulF2Dot14 multiplies the value by the 2.14 value.
+func mulF2Dot14(a, b int32) int32 {
+	return int32((int64(a)*int64(b) + 0x2000) >> 14)
+}
+
+// mulFix multiplies the value by the 16.16 value, rounding half away from zero.
+func mulFix(a, b int32) int32 {
+	v := int64(a) * int64(b)
+	if v < 0 {
+		return -int32((-v + 0x8000) >> 16)
+	}
+	return int32((v + 0x8000) >> 16)
+}
+
+// divFix returns a / b in 16.16 fixed-point.
+func divFix(a, b int32) int32 {
+	return mulDiv(a, 0x10000, b)
+}
+
+// mulDiv returns a * b / c, rounding half away from zero.
+func mulDiv(a, b, c int32) int32 {
+	if 0 == c {
+		if a*b < 0 {
+			return math.MinInt32
+		}
+		return math.MaxInt32
+	}
+	sign := int64(1)
+	x, y, z := int64(a), int64(b), int64(c)
+	if x < 0 {
+		x, sign = -x, -sign
+	}
+	if y < 0 {
+		y, sign = -y, -sign
+	}
+	if z < 0 {
+		z, sign = -z, -sign
+	}
+	return int32(sign * ((x*y + z/2) / z))
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,240 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestHinter returns the Hinter at 12 pixels per em of the font with the control values in 26.6 fixed-point.
+func newTestHinter(t *testing.T, font *Font, cvt ...int32) *Hinter {
+	h, err := font.NewHinter(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.cvt = cvt
+	return h
+}
+
+// testHintZone returns the glyph zone of a contour of the points on the x axis, whose positions are in 26.6 fixed-point.
+func testHintZone(xs ...int32) *hintZone {
+	z := newHintZone(len(xs))
+	for i, x := range xs {
+		z.orus[i] = hintPoint{x: x}
+	}
+	copy(z.org, z.orus)
+	copy(z.cur, z.orus)
+	z.endPts = []uint16{uint16(len(xs) - 1)}
+	z.scaled = true
+	return z
+}
+
+// curX returns the current x coordinates of the points of the zone.
+func curX(z *hintZone) []int32 {
+	xs := make([]int32, len(z.cur))
+	for i, p := range z.cur {
+		xs[i] = p.x
+	}
+	return xs
+}
+
+func TestHintStackInstructions(t *testing.T) {
+	font := newTestSubsetFont(t)
+	tests := []struct {
+		name    string
+		program []byte
+		want    []int32
+	}{
+		{"PUSHB", []byte{0xB2, 1, 2, 3}, []int32{1, 2, 3}},
+		{"PUSHW", []byte{0xB9, 0x01, 0x00, 0xFF, 0xFE}, []int32{256, -2}},
+		{"NPUSHB", []byte{0x40, 2, 7, 8}, []int32{7, 8}},
+		{"NPUSHW", []byte{0x41, 1, 0x80, 0x00}, []int32{-32768}},
+		{"DUP", []byte{0xB1, 1, 2, 0x20}, []int32{1, 2, 2}},
+		{"POP", []byte{0xB1, 1, 2, 0x21}, []int32{1}},
+		{"CLEAR", []byte{0xB1, 1, 2, 0x22}, []int32{}},
+		{"SWAP", []byte{0xB1, 1, 2, 0x23}, []int32{2, 1}},
+		{"DEPTH", []byte{0xB2, 1, 2, 3, 0x24}, []int32{1, 2, 3, 3}},
+		{"CINDEX", []byte{0xB3, 1, 2, 3, 3, 0x25}, []int32{1, 2, 3, 1}},
+		{"MINDEX", []byte{0xB3, 1, 2, 3, 3, 0x26}, []int32{2, 3, 1}},
+		{"ROLL", []byte{0xB2, 1, 2, 3, 0x8A}, []int32{2, 3, 1}},
+		{"ADD SUB", []byte{0xB2, 5, 3, 2, 0x60, 0x61}, []int32{0}},
+		{"DIV", []byte{0xB1, 128, 64, 0x62}, []int32{128}},
+		{"MUL", []byte{0xB1, 128, 96, 0x63}, []int32{192}},
+		{"ABS NEG", []byte{0xB0, 5, 0x65, 0x64, 0xB0, 6, 0x65}, []int32{5, -6}},
+		{"FLOOR CEILING", []byte{0xB1, 100, 100, 0x66, 0x23, 0x67}, []int32{64, 128}},
+		{"MAX MIN", []byte{0xB1, 1, 2, 0x8B, 0xB1, 1, 2, 0x8C}, []int32{2, 1}},
+		{"LT GTEQ EQ NEQ", []byte{0xB1, 1, 2, 0x50, 0xB1, 1, 2, 0x53, 0xB1, 2, 2, 0x54, 0xB1, 2, 2, 0x55}, []int32{1, 0, 1, 0}},
+		{"AND OR NOT", []byte{0xB1, 1, 0, 0x5A, 0xB1, 1, 0, 0x5B, 0xB0, 0, 0x5C}, []int32{0, 1, 1}},
+		{"IF ELSE", []byte{0xB0, 0, 0x58, 0xB0, 1, 0x1B, 0xB0, 2, 0x59, 0xB0, 1, 0x58, 0xB0, 3, 0x1B, 0xB0, 4, 0x59}, []int32{2, 3}},
+		{"JMPR", []byte{0xB0, 3, 0x1C, 0xB0, 9, 0xB0, 7}, []int32{7}},
+		{"JROT", []byte{0xB1, 3, 1, 0x78, 0xB0, 9, 0xB0, 7}, []int32{7}},
+		{"JROF", []byte{0xB1, 3, 1, 0x79, 0xB0, 9, 0xB0, 7}, []int32{9, 7}},
+		{"JROF not taken with offset 0", []byte{0xB1, 0, 1, 0x79}, []int32{}},
+		// the jump to itself pops the next offset.
+		{"JMPR with offset 0", []byte{0xB1, 3, 0, 0x1C, 0xB0, 9, 0xB0, 7}, []int32{7}},
+		{"JROT with offset 0", []byte{0xB3, 3, 1, 0, 1, 0x78, 0xB0, 9, 0xB0, 7}, []int32{7}},
+		{"JROF with offset 0", []byte{0xB3, 3, 0, 0, 0, 0x79, 0xB0, 9, 0xB0, 7}, []int32{7}},
+		{"WS RS", []byte{0xB1, 0, 42, 0x42, 0xB0, 0, 0x43}, []int32{42}},
+		{"WCVTP RCVT", []byte{0xB1, 1, 80, 0x44, 0xB0, 1, 0x45}, []int32{80}},
+		{"MPPEM", []byte{0x4B}, []int32{12}},
+		{"GETINFO", []byte{0xB0, 1, 0x88}, []int32{35}},
+	}
+	for _, test := range tests {
+		h := newTestHinter(t, font, 0, 0)
+		h.storage = make([]int32, 1)
+		err := h.run(test.program)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got := append([]int32{}, h.stack...); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: stack is %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHintProgramErrors(t *testing.T) {
+	font := newTestSubsetFont(t)
+	overflow := append([]byte{0x40, 255}, make([]byte, 255)...)
+	tests := []struct {
+		name    string
+		program []byte
+	}{
+		{"stack underflow", []byte{0x21}},
+		{"stack overflow", overflow},
+		{"JMPR to itself", []byte{0xB0, 0, 0x1C}},
+		{"JROT to itself", []byte{0xB1, 0, 1, 0x78}},
+		{"JROF to itself", []byte{0xB1, 0, 0, 0x79}},
+		{"jump before the program", []byte{0xB8, 0xFF, 0xF6, 0x1C}},
+		{"division by zero", []byte{0xB1, 1, 0, 0x62}},
+		{"undefined function", []byte{0xB0, 1, 0x2B}},
+		{"ENDF without function", []byte{0x2D}},
+		{"recursion", []byte{0xB0, 0, 0x2C, 0xB0, 0, 0x2B, 0x2D, 0xB0, 0, 0x2B}},
+		{"invalid instruction", []byte{0x93}},
+	}
+	for _, test := range tests {
+		h := newTestHinter(t, font)
+		if err := h.run(test.program); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestHintFunctions(t *testing.T) {
+	font := newTestSubsetFont(t)
+	// function 0 increments the top of the stack.
+	fdef := []byte{0xB0, 0, 0x2C, 0xB0, 1, 0x60, 0x2D}
+	tests := []struct {
+		name    string
+		program []byte
+		want    []int32
+	}{
+		{"CALL", []byte{0xB1, 5, 0, 0x2B}, []int32{6}},
+		{"LOOPCALL", []byte{0xB2, 5, 3, 0, 0x2A}, []int32{8}},
+		{"LOOPCALL zero times", []byte{0xB2, 5, 0, 0, 0x2A}, []int32{5}},
+		{"nested CALL", []byte{0xB0, 1, 0x2C, 0xB0, 0, 0x2B, 0xB0, 0, 0x2B, 0x2D, 0xB1, 5, 1, 0x2B}, []int32{7}},
+		{"IDEF", []byte{0xB0, 0x93, 0x89, 0xB0, 7, 0x2D, 0x93, 0x93}, []int32{7, 7}},
+	}
+	for _, test := range tests {
+		h := newTestHinter(t, font)
+		err := h.run(append(append([]byte{}, fdef...), test.program...))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got := append([]int32{}, h.stack...); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: stack is %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHintRounding(t *testing.T) {
+	font := newTestSubsetFont(t)
+	tests := []struct {
+		name  string
+		state []byte
+		value int16
+		want  int32
+	}{
+		{"RTG", []byte{0x18}, 96, 128},
+		{"RTG negative", []byte{0x18}, -96, -128},
+		{"RTG small", []byte{0x18}, 31, 0},
+		{"RTHG", []byte{0x19}, 70, 96},
+		{"RTDG", []byte{0x3D}, 50, 64},
+		{"RTDG down", []byte{0x3D}, 40, 32},
+		{"RDTG", []byte{0x7D}, 127, 64},
+		{"RUTG", []byte{0x7C}, 65, 128},
+		{"RUTG negative", []byte{0x7C}, -65, -128},
+		{"ROFF", []byte{0x7A}, 70, 70},
+		{"SROUND like RTG", []byte{0xB0, 0x48, 0x76}, 96, 128},
+		{"SROUND with phase", []byte{0xB0, 0x68, 0x76}, 40, 32},
+		{"S45ROUND", []byte{0xB0, 0x48, 0x77}, 50, 45},
+	}
+	for _, test := range tests {
+		h := newTestHinter(t, font)
+		program := append(append([]byte{}, test.state...), 0xB8, byte(uint16(test.value)>>8), byte(test.value), 0x68)
+		err := h.run(program)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if 1 != len(h.stack) || test.want != h.stack[0] {
+			t.Errorf("%s: rounded to %v, want %d", test.name, h.stack, test.want)
+		}
+	}
+}
+
+func TestHintMovePoints(t *testing.T) {
+	font := newTestSubsetFont(t)
+	tests := []struct {
+		name    string
+		zone    []int32
+		cvt     []int32
+		program []byte
+		want    []int32
+	}{
+		{"MIAP rounds the CVT value", []int32{90}, []int32{100}, []byte{0xB1, 0, 0, 0x3F}, []int32{128}},
+		{"MIAP without rounding", []int32{90}, []int32{100}, []byte{0xB1, 0, 0, 0x3E}, []int32{100}},
+		{"MIAP out of the cut-in", []int32{0}, []int32{100}, []byte{0xB1, 0, 0, 0x3F}, []int32{0}},
+		{"MDAP", []int32{90}, nil, []byte{0xB0, 0, 0x2F}, []int32{64}},
+		{"MDRP rounds the distance", []int32{0, 70}, nil, []byte{0xB0, 0, 0x10, 0xB0, 1, 0xC4}, []int32{0, 64}},
+		{"MDRP keeps the minimum distance", []int32{0, 20}, nil, []byte{0xB0, 0, 0x10, 0xB0, 1, 0xCC}, []int32{0, 64}},
+		{"MDRP follows rp0", []int32{0, 70}, nil, []byte{0xB1, 0, 10, 0x38, 0xB0, 0, 0x10, 0xB0, 1, 0xC0}, []int32{10, 80}},
+		{"MIRP rounds the CVT value", []int32{0, 90}, []int32{100}, []byte{0xB0, 0, 0x10, 0xB1, 1, 0, 0xE4}, []int32{0, 128}},
+		{"MIRP out of the cut-in", []int32{0, 90}, []int32{300}, []byte{0xB0, 0, 0x10, 0xB1, 1, 0, 0xE4}, []int32{0, 64}},
+		{"MIRP flips the sign", []int32{0, 90}, []int32{-100}, []byte{0xB0, 0, 0x10, 0xB1, 1, 0, 0xE0}, []int32{0, 100}},
+		{"MIRP sets rp0", []int32{0, 90, 180}, []int32{100}, []byte{0xB0, 0, 0x10, 0xB1, 1, 0, 0xF0, 0xB1, 2, 0, 0xE0}, []int32{0, 100, 200}},
+		{"IP", []int32{0, 50, 100}, nil, []byte{0xB1, 2, 100, 0x38, 0xB0, 0, 0x11, 0xB0, 2, 0x12, 0xB0, 1, 0x39}, []int32{0, 100, 200}},
+		{"SHP", []int32{0, 50, 100}, nil, []byte{0xB1, 0, 10, 0x38, 0xB0, 0, 0x12, 0xB0, 2, 0x32}, []int32{10, 50, 110}},
+		{"SHZ", []int32{0, 50, 100}, nil, []byte{0xB1, 0, 10, 0x38, 0xB0, 0, 0x12, 0xB0, 0, 0x36}, []int32{10, 60, 110}},
+		{"IUP interpolates", []int32{0, 50, 100, 150}, nil, []byte{0xB1, 0, 10, 0x38, 0xB1, 2, 200, 0x38, 0x31}, []int32{10, 155, 300, 350}},
+		{"IUP shifts from the current positions", []int32{0, 50, 100}, nil, []byte{0xB1, 2, 5, 0x38, 0xB0, 2, 0x29, 0xB1, 0, 10, 0x38, 0x31}, []int32{10, 60, 115}},
+		{"DELTAP1", []int32{0, 0}, nil, []byte{0xB6, 0x3F, 0, 0x4F, 1, 0x30, 1, 3, 0x5D}, []int32{64, -64}},
+		{"DELTAP2", []int32{0}, nil, []byte{0xB8, 0xFF, 0xFC, 0x5E, 0xB2, 0x0F, 0, 1, 0x71}, []int32{64}},
+	}
+	for _, test := range tests {
+		h := newTestHinter(t, font, test.cvt...)
+		z := testHintZone(test.zone...)
+		h.zones[1] = z
+		err := h.run(test.program)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got := curX(z); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: points are at %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHintDeltaC(t *testing.T) {
+	font := newTestSubsetFont(t)
+	h := newTestHinter(t, font, 100, 200)
+	// the first exception applies to 12 pixels per em, and the second to 13.
+	err := h.run([]byte{0xB4, 0x3F, 0, 0x4F, 1, 2, 0x73})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int32{164, 200}; !reflect.DeepEqual(want, h.cvt) {
+		t.Errorf("cvt is %v, want %v", h.cvt, want)
+	}
+}
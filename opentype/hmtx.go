@@ -47,34 +47,72 @@ func parseHmtx(f *os.File, offset uint32, numGlyphs, numberOfHMetrics uint16) (h
 }
 
 func (h *Hmtx) filter(f []uint16) *Hmtx {
-	th := uint16(len(h.HMetrics))
-	numHM := 0
-	for i, gid := range f {
-		if gid < th {
-			numHM = i + 1
-		}
-	}
 	new := &Hmtx{
-		HMetrics:         make([]*LongHorMetric, 0, numHM),
-		LeftSideBearings: make([]int16, 0, len(f)-numHM),
+		HMetrics:         make([]*LongHorMetric, len(f)),
+		LeftSideBearings: make([]int16, 0),
 	}
 	for i, gid := range f {
-		if i < numHM {
-			if gid < th {
-				new.HMetrics = append(new.HMetrics, h.HMetrics[gid])
-			} else {
-				new.HMetrics = append(new.HMetrics, &LongHorMetric{
-					AdvanceWidth: 0,
-					Lsb:          h.LeftSideBearings[gid-th],
-				})
-			}
-		} else {
-			new.LeftSideBearings = append(new.LeftSideBearings, h.LeftSideBearings[gid-th])
+		advanceWidth, lsb := h.get(gid)
+		new.HMetrics[i] = &LongHorMetric{
+			AdvanceWidth: advanceWidth,
+			Lsb:          lsb,
 		}
 	}
 	return new
 }
 
+// Optimize minimizes numberOfHMetrics.
+// The trailing entries of HMetrics that have the same advance width as the last one are moved into LeftSideBearings,
+// that is common for monospaced fonts.
+// If hhea is not nil, its NumberOfHMetrics is updated.
+func (h *Hmtx) Optimize(hhea *Hhea) {
+	n := len(h.HMetrics)
+	if n > 0 {
+		last := h.HMetrics[n-1].AdvanceWidth
+		k := n - 1
+		for k > 0 && h.HMetrics[k-1].AdvanceWidth == last {
+			k--
+		}
+		lsbs := make([]int16, 0, n-k-1+len(h.LeftSideBearings))
+		for _, hm := range h.HMetrics[k+1:] {
+			lsbs = append(lsbs, hm.Lsb)
+		}
+		h.LeftSideBearings = append(lsbs, h.LeftSideBearings...)
+		h.HMetrics = h.HMetrics[:k+1]
+	}
+	if hhea != nil {
+		hhea.NumberOfHMetrics = uint16(len(h.HMetrics))
+	}
+}
+
+// get returns the advance width and left side bearing of the glyph.
+// Glyphs beyond numberOfHMetrics share the advance width of the last entry of HMetrics.
+func (h *Hmtx) get(gid uint16) (advanceWidth uint16, lsb int16) {
+	th := uint16(len(h.HMetrics))
+	if gid < th {
+		return h.HMetrics[gid].AdvanceWidth, h.HMetrics[gid].Lsb
+	}
+	if th > 0 {
+		advanceWidth = h.HMetrics[th-1].AdvanceWidth
+	}
+	if int(gid-th) < len(h.LeftSideBearings) {
+		lsb = h.LeftSideBearings[gid-th]
+	}
+	return
+}
+
+// setLsb sets the left side bearing of the glyph, whether it is in HMetrics or in LeftSideBearings.
+func (h *Hmtx) setLsb(gid uint16, lsb int16) {
+	th := uint16(len(h.HMetrics))
+	if gid < th {
+		h.HMetrics[gid].Lsb = lsb
+		return
+	}
+	if int(gid-th) < len(h.LeftSideBearings) {
+		h.LeftSideBearings[gid-th] = lsb
+	}
+}
+
 // Tag is table name.
 func (h *Hmtx) Tag() Tag {
 	return String2Tag("hmtx")
@@ -105,3 +143,20 @@ func (h *Hmtx) Length() uint32 {
 func (h *Hmtx) Exists() bool {
 	return h != nil
 }
+
+// clone returns a deep copy of this table.
+func (h *Hmtx) clone() *Hmtx {
+	if h == nil {
+		return nil
+	}
+	c := &Hmtx{
+		HMetrics:         make([]*LongHorMetric, len(h.HMetrics)),
+		LeftSideBearings: make([]int16, len(h.LeftSideBearings)),
+	}
+	for i, hm := range h.HMetrics {
+		m := *hm
+		c.HMetrics[i] = &m
+	}
+	copy(c.LeftSideBearings, h.LeftSideBearings)
+	return c
+}
@@ -0,0 +1,173 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// Hvar is a "HVAR" table.
+// The horizontal metrics variations table has the deltas of the advance widths and the side bearings of the glyphs,
+// so that the metrics are varied without the phantom points of gvar.
+type Hvar struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Item variation store of the deltas.
+	ItemVarStore *ItemVariationStore
+	// Map from the glyph IDs to the delta-sets of the advance widths.
+	// If it is nil, the glyph ID is the inner-level index of the outer-level index 0.
+	AdvanceWidthMapping *DeltaSetIndexMap
+	// Map from the glyph IDs to the delta-sets of the left side bearings, or nil if they have no deltas.
+	LsbMapping *DeltaSetIndexMap
+	// Map from the glyph IDs to the delta-sets of the right side bearings, or nil if they have no deltas.
+	RsbMapping *DeltaSetIndexMap
+}
+
+func parseHvar(f *os.File, offset, length uint32) (h *Hvar, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	h = &Hvar{}
+	h.MajorVersion = r.uint16()
+	h.MinorVersion = r.uint16()
+	itemVariationStoreOffset := r.uint32()
+	advanceWidthMappingOffset := r.uint32()
+	lsbMappingOffset := r.uint32()
+	rsbMappingOffset := r.uint32()
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	if 0 != itemVariationStoreOffset {
+		h.ItemVarStore = parseItemVariationStore(r, int64(itemVariationStoreOffset))
+	}
+	if 0 != advanceWidthMappingOffset {
+		h.AdvanceWidthMapping = parseDeltaSetIndexMap(r, int64(advanceWidthMappingOffset))
+	}
+	if 0 != lsbMappingOffset {
+		h.LsbMapping = parseDeltaSetIndexMap(r, int64(lsbMappingOffset))
+	}
+	if 0 != rsbMappingOffset {
+		h.RsbMapping = parseDeltaSetIndexMap(r, int64(rsbMappingOffset))
+	}
+	return h, r.errorf("failed to parse HVAR: %s")
+}
+
+// Tag is table name.
+func (h *Hvar) Tag() Tag {
+	return String2Tag("HVAR")
+}
+
+// store writes binary expression of this table.
+func (h *Hvar) store(w *errWriter) {
+	b, err := packOffsetNode(h.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+func (h *Hvar) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(h.MajorVersion)
+	n.uint16(h.MinorVersion)
+	if h.ItemVarStore == nil {
+		n.uint32(0)
+	} else {
+		n.offset32(h.ItemVarStore.node())
+	}
+	for _, m := range []*DeltaSetIndexMap{h.AdvanceWidthMapping, h.LsbMapping, h.RsbMapping} {
+		if m == nil {
+			n.uint32(0)
+		} else {
+			n.offset32(m.node())
+		}
+	}
+	return n
+}
+
+// CheckSum for this table.
+func (h *Hvar) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(h)
+}
+
+// Length returns the size(byte) of this table.
+func (h *Hvar) Length() uint32 {
+	b, err := packOffsetNode(h.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (h *Hvar) Exists() bool {
+	return h != nil
+}
+
+// clone returns a deep copy of this table.
+func (h *Hvar) clone() *Hvar {
+	if h == nil {
+		return nil
+	}
+	c := *h
+	c.ItemVarStore = h.ItemVarStore.clone()
+	c.AdvanceWidthMapping = h.AdvanceWidthMapping.clone()
+	c.LsbMapping = h.LsbMapping.clone()
+	c.RsbMapping = h.RsbMapping.clone()
+	return &c
+}
+
+// filter returns the table for the glyphs of a subset.
+// The item variation store is kept as it is, and the maps are rebuilt for the new glyph IDs.
+func (h *Hvar) filter(f []uint16) *Hvar {
+	c := h.clone()
+	c.AdvanceWidthMapping = h.AdvanceWidthMapping.filter(f)
+	if h.LsbMapping != nil {
+		c.LsbMapping = h.LsbMapping.filter(f)
+	}
+	if h.RsbMapping != nil {
+		c.RsbMapping = h.RsbMapping.filter(f)
+	}
+	return c
+}
+
+// advanceDelta returns the delta of the advance width of the glyph at the normalized coordinates.
+func (h *Hvar) advanceDelta(gid uint16, coords []float64) float64 {
+	outer, inner := uint16(0), gid
+	if h.AdvanceWidthMapping != nil {
+		outer, inner = h.AdvanceWidthMapping.Get(int(gid))
+	}
+	return h.ItemVarStore.Delta(outer, inner, coords)
+}
+
+// AdvanceAt returns the advance width of the glyph at the location in the user space of the variation axes, in font design units.
+// The advance is varied by HVAR, or by the phantom points of gvar if the font has no HVAR.
+// The axes missing in the location are at their default values, and the advance is the default one if the font has neither of them.
+func (font *Font) AdvanceAt(gid uint16, location map[Tag]float64) (uint16, error) {
+	err := tableRequired(font.Hmtx)
+	if err != nil {
+		return 0, fmt.Errorf("advance is not available: %s", err)
+	}
+	c, err := font.NormalizeCoordinates(location)
+	if err != nil {
+		return 0, err
+	}
+	advanceWidth, _ := font.Hmtx.get(gid)
+	switch {
+	case font.Hvar.Exists():
+		v := float64(advanceWidth) + font.Hvar.advanceDelta(gid, coordinatesFloat(c))
+		return uint16(math.Max(0, math.Min(0xFFFF, math.Floor(v+0.5)))), nil
+	case font.Gvar.Exists() && font.Glyf.Exists():
+		_, pp, err := font.GlyphOutlineAt(gid, location)
+		if err != nil {
+			return 0, fmt.Errorf("advance is not available: %s", err)
+		}
+		return uint16(math.Max(0, math.Min(0xFFFF, float64(pp.AdvanceWidth())))), nil
+	}
+	return advanceWidth, nil
+}
@@ -0,0 +1,106 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestHvarFont creates a font of newTestSubsetFont with the weight axis from 100 to 900 and HVAR,
+// that varies the advance widths of glyph 1 by 100 at the maximum and -50 at the minimum, glyph 2 by -30 at the maximum,
+// and the glyphs from 3 by 20 at the maximum.
+func newTestHvarFont(t *testing.T) *Font {
+	font := newTestSubsetFont(t)
+	font.Fvar = newTestCvarFont(t).Fvar
+	font.Hvar = &Hvar{
+		MajorVersion: 1,
+		ItemVarStore: &ItemVariationStore{
+			Format:    1,
+			AxisCount: 1,
+			VariationRegions: [][]*RegionAxisCoordinates{
+				{{StartCoord: 0, PeakCoord: 0x4000, EndCoord: 0x4000}},
+				{{StartCoord: -0x4000, PeakCoord: -0x4000, EndCoord: 0}},
+			},
+			ItemVariationData: []*ItemVariationData{
+				{RegionIndexes: []uint16{0, 1}, DeltaSets: [][]int32{{0, 0}, {100, -50}, {20, 0}}},
+				{RegionIndexes: []uint16{0}, DeltaSets: [][]int32{{-30}}},
+			},
+		},
+		// the glyphs after the end of the map use the last entry.
+		AdvanceWidthMapping: &DeltaSetIndexMap{Map: []uint32{0, 1, 1 << 16, 2}},
+		LsbMapping:          &DeltaSetIndexMap{Map: []uint32{0, 2}},
+	}
+	return font
+}
+
+func TestHvarRoundTrip(t *testing.T) {
+	font := newTestHvarFont(t)
+	want := font.Hvar.clone()
+	font = writeTestFont(t, font)
+	if !reflect.DeepEqual(want, font.Hvar) {
+		t.Errorf("HVAR is %+v, want %+v", *font.Hvar, *want)
+	}
+	subset, err := font.FilterGlyf([]uint16{0, 2, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset = writeTestFont(t, subset)
+	if m := []uint32{0, 1 << 16, 2}; !reflect.DeepEqual(m, subset.Hvar.AdvanceWidthMapping.Map) {
+		t.Errorf("advance width mapping of the subset is %v, want %v", subset.Hvar.AdvanceWidthMapping.Map, m)
+	}
+	if m := []uint32{0, 2, 2}; !reflect.DeepEqual(m, subset.Hvar.LsbMapping.Map) {
+		t.Errorf("lsb mapping of the subset is %v, want %v", subset.Hvar.LsbMapping.Map, m)
+	}
+	if nil != subset.Hvar.RsbMapping || !reflect.DeepEqual(want.ItemVarStore, subset.Hvar.ItemVarStore) {
+		t.Errorf("HVAR of the subset is %+v", *subset.Hvar)
+	}
+}
+
+func TestAdvanceAt(t *testing.T) {
+	font := writeTestFont(t, newTestHvarFont(t))
+	subset, err := font.FilterGlyf([]uint16{0, 2, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	implicit := newTestHvarFont(t)
+	implicit.Hvar.AdvanceWidthMapping = nil
+	implicit = writeTestFont(t, implicit)
+	implicitSubset, err := implicit.FilterGlyf([]uint16{0, 2, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		font   *Font
+		gid    uint16
+		weight float64
+		want   uint16
+	}{
+		{"default", font, 1, 400, 510},
+		{"maximum", font, 1, 900, 610},
+		{"intermediate", font, 1, 650, 560},
+		{"minimum", font, 1, 100, 460},
+		{"negative intermediate", font, 1, 250, 485},
+		{"outer index", font, 2, 900, 490},
+		{"outer index at minimum", font, 2, 100, 520},
+		{"after the end of the map", font, 5, 900, 570},
+		{"no deltas", font, 0, 900, 500},
+		{"subset", subset, 1, 900, 490},
+		{"subset after the end of the map", subset, 2, 900, 570},
+		// the glyph ID is the inner index without the map.
+		{"implicit map", implicit, 2, 900, 540},
+		{"implicit map subset", implicitSubset, 1, 900, 540},
+		{"implicit map subset without deltas", implicitSubset, 2, 900, 550},
+	}
+	for _, tt := range tests {
+		aw, err := tt.font.AdvanceAt(tt.gid, map[Tag]float64{String2Tag("wght"): tt.weight})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want != aw {
+			t.Errorf("%s: advance width of glyph %d at %g is %d, want %d", tt.name, tt.gid, tt.weight, aw, tt.want)
+		}
+	}
+	if _, err := font.AdvanceAt(1, map[Tag]float64{String2Tag("wdth"): 100}); err == nil {
+		t.Errorf("advance width is available at the location of the missing axis")
+	}
+}
@@ -0,0 +1,423 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Instantiate creates a static font at the location in the user space of the variation axes.
+// The axes missing in the location are at their default values.
+// The glyphs of glyf and the metrics of hmtx and vmtx are varied by gvar, except that the advance widths are varied by HVAR if the font has it,
+// the deltas of the VariationIndex tables in GDEF and GPOS are applied, and the feature variations of GSUB and GPOS matched at the location are applied.
+// The font-wide metrics of OS/2, hhea, vhea, post and gasp are varied by MVAR, and the control values of cvt by cvar.
+// usWeightClass and usWidthClass of OS/2 follow wght and wdth axes, and the names are updated if the location is a named instance,
+// or by the style name of STAT at the location. The axis values of STAT at the other values of the axes are removed.
+// fvar, avar, gvar, cvar, HVAR, VVAR and MVAR are dropped. Only the fonts with TrueType outlines are supported.
+// The receiver is never modified.
+func (font *Font) Instantiate(location map[Tag]float64) (*Font, error) {
+	err := tableRequired(font.Fvar, font.Head, font.Hhea, font.Maxp, font.Hmtx, font.Glyf)
+	if err != nil {
+		return nil, fmt.Errorf("instantiating failed: %s", err)
+	}
+	coords, err := font.NormalizeCoordinates(location)
+	if err != nil {
+		return nil, fmt.Errorf("instantiating failed: %s", err)
+	}
+	new := font.Clone()
+	err = new.instantiateGlyphs(font, coordinatesFloat(coords))
+	if err != nil {
+		return nil, fmt.Errorf("instantiating failed: %s", err)
+	}
+	new.instantiateLayout(coords)
+	if new.Mvar.Exists() {
+		store, fs := new.Mvar.ItemVarStore, coordinatesFloat(coords)
+		new.applyMetricDeltas(func(outer, inner uint16) float64 {
+			return store.Delta(outer, inner, fs)
+		})
+	}
+	if new.Cvar.Exists() && new.Cvt.Exists() {
+		new.Cvt.Values = font.cvtAt(coordinatesFloat(coords))
+	}
+	new.instantiateOS2(location)
+	new.instantiateNames(font.instanceAt(location))
+	new.instantiateStat(location)
+	new.Fvar = nil
+	new.Avar = nil
+	new.Gvar = nil
+	new.Cvar = nil
+	new.Hvar = nil
+	new.Vvar = nil
+	new.Mvar = nil
+	err = new.UpdateLoca()
+	if err != nil {
+		return nil, fmt.Errorf("instantiating failed: %s", err)
+	}
+	err = new.RecalculateMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("instantiating failed: %s", err)
+	}
+	return new, nil
+}
+
+// instantiateGlyphs replaces the glyphs and the metrics with the ones of the variable font varied at the normalized coordinates.
+// The advances and the side bearings are calculated from the phantom points, after the composite glyphs are resolved with the varied components.
+// If the variable font has HVAR, the advance widths are varied by it instead, because the phantom points of gvar may have no deltas in such a font.
+func (font *Font) instantiateGlyphs(variable *Font, coords []float64) error {
+	n := variable.Glyf.Len()
+	phantoms := make([][]GlyphPoint, n)
+	for i := 0; i < n; i++ {
+		gid := uint16(i)
+		g, pp, err := variable.glyphAt(gid, coords)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		err = font.Glyf.SetGlyph(gid, g)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		phantoms[i] = pp
+	}
+	hmtx := &Hmtx{HMetrics: make([]*LongHorMetric, n)}
+	var vmtx *Vmtx
+	if font.Vmtx.Exists() {
+		vmtx = &Vmtx{VMetrics: make([]*LongVerMetric, n)}
+	}
+	for i, pp := range phantoms {
+		gid := uint16(i)
+		o, err := font.Glyf.Outline(gid)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		advanceWidth := math.Max(0, float64(pp[1].X)-float64(pp[0].X))
+		if variable.Hvar.Exists() {
+			aw, _ := variable.Hmtx.get(gid)
+			advanceWidth = math.Max(0, math.Floor(float64(aw)+variable.Hvar.advanceDelta(gid, coords)+0.5))
+		}
+		hmtx.HMetrics[i] = &LongHorMetric{
+			AdvanceWidth: uint16(math.Min(0xFFFF, advanceWidth)),
+			Lsb:          int16(int32(o.XMin) - int32(pp[0].X)),
+		}
+		if vmtx != nil {
+			vmtx.VMetrics[i] = &LongVerMetric{
+				AdvanceHeight:  uint16(math.Max(0, float64(pp[2].Y)-float64(pp[3].Y))),
+				TopSideBearing: int16(int32(pp[2].Y) - int32(o.YMax)),
+			}
+		}
+	}
+	hmtx.Optimize(font.Hhea)
+	font.Hmtx = hmtx
+	if vmtx != nil {
+		vmtx.Optimize(font.Vhea)
+		font.Vmtx = vmtx
+	}
+	return nil
+}
+
+// instantiateLayout applies the deltas of the VariationIndex tables in GDEF and GPOS, and the feature variations of GSUB and GPOS,
+// at the normalized coordinates.
+// The item variation store of GDEF is removed after that.
+func (font *Font) instantiateLayout(coords []F2Dot14) {
+	var store *ItemVariationStore
+	if font.Gdef.Exists() {
+		store = font.Gdef.ItemVarStore
+	}
+	fs := coordinatesFloat(coords)
+	di := &deviceInstancer{
+		delta: func(outer, inner uint16) float64 {
+			return store.Delta(outer, inner, fs)
+		},
+	}
+	di.layout(font)
+	if font.Gdef.Exists() {
+		font.Gdef.ItemVarStore = nil
+	}
+	if font.Gpos.Exists() {
+		font.Gpos.applyFeatureVariations(coords)
+	}
+	if font.Gsub.Exists() {
+		font.Gsub.applyFeatureVariations(coords)
+	}
+}
+
+// deviceInstancer adds the deltas of the VariationIndex tables to the values of GDEF and GPOS.
+type deviceInstancer struct {
+	// delta returns the delta of the delta-set.
+	delta func(outer, inner uint16) float64
+	// keep is true if the VariationIndex tables remain, as the partial instancing does.
+	keep bool
+}
+
+// layout applies the deltas to the ligature carets of GDEF and the positioning subtables of GPOS.
+func (di *deviceInstancer) layout(font *Font) {
+	if font.Gdef.Exists() {
+		for _, carets := range font.Gdef.LigCarets {
+			for _, c := range carets {
+				c.Coordinate, c.Device = di.device(c.Coordinate, c.Device)
+				if 3 == c.Format && c.Device == nil {
+					c.Format = 1
+				}
+			}
+		}
+	}
+	if font.Gpos.Exists() {
+		for _, l := range font.Gpos.LookupList {
+			for _, st := range l.Subtables {
+				di.positioning(st)
+			}
+		}
+	}
+}
+
+// positioning applies the deltas to the value records and the anchors of the positioning subtable.
+func (di *deviceInstancer) positioning(st LookupSubtable) {
+	switch st := st.(type) {
+	case *SinglePos:
+		st.ValueFormat = di.valueRecords(st.ValueFormat, st.ValueRecords)
+	case *PairPos:
+		var values1, values2 []*ValueRecord
+		for _, set := range st.PairSets {
+			for _, pv := range set {
+				values1, values2 = append(values1, pv.Value1), append(values2, pv.Value2)
+			}
+		}
+		for _, records := range st.Class1Records {
+			for _, r := range records {
+				values1, values2 = append(values1, r.Value1), append(values2, r.Value2)
+			}
+		}
+		st.ValueFormat1 = di.valueRecords(st.ValueFormat1, values1)
+		st.ValueFormat2 = di.valueRecords(st.ValueFormat2, values2)
+	case *CursivePos:
+		for _, r := range st.EntryExitRecords {
+			di.anchor(r.EntryAnchor)
+			di.anchor(r.ExitAnchor)
+		}
+	case *MarkBasePos:
+		di.markArray(st.MarkArray)
+		for _, anchors := range st.BaseArray {
+			di.anchors(anchors)
+		}
+	case *MarkLigPos:
+		di.markArray(st.MarkArray)
+		for _, components := range st.LigatureArray {
+			for _, anchors := range components {
+				di.anchors(anchors)
+			}
+		}
+	case *MarkMarkPos:
+		di.markArray(st.Mark1Array)
+		for _, anchors := range st.Mark2Array {
+			di.anchors(anchors)
+		}
+	}
+}
+
+// valueRecords applies the deltas to the value records, and returns the value format for them.
+// The values that become non-zero are added to the format, and the removed VariationIndex tables are removed from it.
+func (di *deviceInstancer) valueRecords(valueFormat uint16, records []*ValueRecord) uint16 {
+	format := uint16(0)
+	for _, v := range records {
+		if v == nil {
+			continue
+		}
+		v.XPlacement, v.XPlaDevice = di.device(v.XPlacement, v.XPlaDevice)
+		v.YPlacement, v.YPlaDevice = di.device(v.YPlacement, v.YPlaDevice)
+		v.XAdvance, v.XAdvDevice = di.device(v.XAdvance, v.XAdvDevice)
+		v.YAdvance, v.YAdvDevice = di.device(v.YAdvance, v.YAdvDevice)
+		format |= v.Format()
+	}
+	devices := ValueFormatXPlacementDevice | ValueFormatYPlacementDevice | ValueFormatXAdvanceDevice | ValueFormatYAdvanceDevice
+	if di.keep {
+		devices = 0
+	}
+	return valueFormat&^devices | format
+}
+
+func (di *deviceInstancer) markArray(records []*MarkRecord) {
+	for _, r := range records {
+		di.anchor(r.MarkAnchor)
+	}
+}
+
+func (di *deviceInstancer) anchors(anchors []*Anchor) {
+	for _, a := range anchors {
+		di.anchor(a)
+	}
+}
+
+// anchor applies the deltas to the anchor, that becomes format 1 if no device table remains.
+func (di *deviceInstancer) anchor(a *Anchor) {
+	if a == nil {
+		return
+	}
+	a.XCoordinate, a.XDevice = di.device(a.XCoordinate, a.XDevice)
+	a.YCoordinate, a.YDevice = di.device(a.YCoordinate, a.YDevice)
+	if 3 == a.Format && a.XDevice == nil && a.YDevice == nil {
+		a.Format = 1
+	}
+}
+
+// device returns the value with the delta of the VariationIndex table, and the table that remains.
+// The device tables for the sizes are always kept.
+func (di *deviceInstancer) device(v int16, d *Device) (int16, *Device) {
+	if d == nil || !d.IsVariationIndex() {
+		return v, d
+	}
+	v = int16(int32(v) + int32(math.Floor(di.delta(d.DeltaSetOuterIndex, d.DeltaSetInnerIndex)+0.5)))
+	if di.keep {
+		return v, d
+	}
+	return v, nil
+}
+
+// applyFeatureVariations replaces the feature tables with the substitutions of the first feature variation record matched at the normalized coordinates,
+// and removes the feature variations.
+func (t *LayoutTable) applyFeatureVariations(coords []F2Dot14) {
+	if t.FeatureVariations == nil {
+		return
+	}
+	for _, record := range t.FeatureVariations.FeatureVariationRecords {
+		if !matchConditionSet(record.ConditionSet, coords) {
+			continue
+		}
+		for _, s := range record.Substitutions {
+			if int(s.FeatureIndex) < len(t.FeatureList) {
+				t.FeatureList[s.FeatureIndex].Feature = s.Feature
+			}
+		}
+		break
+	}
+	t.FeatureVariations = nil
+}
+
+// matchConditionSet returns true if all conditions are satisfied at the normalized coordinates.
+func matchConditionSet(conditions []*Condition, coords []F2Dot14) bool {
+	for _, c := range conditions {
+		v := F2Dot14(0)
+		if int(c.AxisIndex) < len(coords) {
+			v = coords[c.AxisIndex]
+		}
+		if v < c.FilterRangeMinValue || v > c.FilterRangeMaxValue {
+			return false
+		}
+	}
+	return true
+}
+
+// widthClasses are the percentages of the normal width for usWidthClass from 1 to 9.
+var widthClasses = []float64{50, 62.5, 75, 87.5, 100, 112.5, 125, 150, 200}
+
+// instantiateOS2 sets usWeightClass and usWidthClass to the values of wght and wdth axes at the location.
+// usWidthClass is the class of the nearest percentage.
+func (font *Font) instantiateOS2(location map[Tag]float64) {
+	if !font.OS2.Exists() {
+		return
+	}
+	for _, a := range font.Axes() {
+		v, ok := location[a.Tag]
+		if !ok {
+			v = a.Default
+		}
+		v = math.Max(a.Min, math.Min(a.Max, v))
+		switch a.Tag {
+		case String2Tag("wght"):
+			font.OS2.UsWeightClass = uint16(math.Max(1, math.Min(1000, math.Floor(v+0.5))))
+		case String2Tag("wdth"):
+			class := 0
+			for i, p := range widthClasses {
+				if math.Abs(p-v) < math.Abs(widthClasses[class]-v) {
+					class = i
+				}
+			}
+			font.OS2.UsWidthClass = uint16(class + 1)
+		}
+	}
+}
+
+// namedInstanceAt returns the named instance at the location, or nil if there is no such instance.
+func (font *Font) namedInstanceAt(location map[Tag]float64) *NamedInstance {
+	axes := font.Axes()
+	for _, ni := range font.NamedInstances() {
+		matched := true
+		for _, a := range axes {
+			v, ok := location[a.Tag]
+			if !ok {
+				v = a.Default
+			}
+			if math.Max(a.Min, math.Min(a.Max, v)) != ni.Location[a.Tag] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return ni
+		}
+	}
+	return nil
+}
+
+// instanceAt returns the named instance at the location, or the instance named by STAT if the location is not a named instance.
+// It returns nil if the font has neither of them.
+func (font *Font) instanceAt(location map[Tag]float64) *NamedInstance {
+	if ni := font.namedInstanceAt(location); ni != nil {
+		return ni
+	}
+	if !font.Stat.Exists() {
+		return nil
+	}
+	name, err := font.StyleNameAt(location)
+	if err != nil {
+		return nil
+	}
+	return &NamedInstance{Name: name}
+}
+
+// instantiateStat removes the axis values of STAT other than the ones at the location.
+func (font *Font) instantiateStat(location map[Tag]float64) {
+	if !font.Stat.Exists() {
+		return
+	}
+	limits := make(map[Tag]AxisLimit)
+	for _, a := range font.Axes() {
+		v, ok := location[a.Tag]
+		if !ok {
+			v = a.Default
+		}
+		v = math.Max(a.Min, math.Min(a.Max, v))
+		limits[a.Tag] = AxisLimit{Min: v, Default: v, Max: v}
+	}
+	font.Stat.limitAxes(limits)
+}
+
+// ribbiStyles are the subfamily names that the legacy family of four styles can have.
+var ribbiStyles = map[string]bool{"Regular": true, "Italic": true, "Bold": true, "Bold Italic": true}
+
+// instantiateNames updates the family, the subfamily, the full and the PostScript names to the named instance.
+// The typographic family is the family of the variable font, and the styles other than Regular, Italic, Bold and Bold Italic
+// are moved into the legacy family name.
+func (font *Font) instantiateNames(ni *NamedInstance) {
+	if ni == nil || "" == ni.Name || !font.Name.Exists() {
+		return
+	}
+	family := font.Name.Find(NameIDTypographicFamilyName)
+	if "" == family {
+		family = font.Name.Find(NameIDFontFamilyName)
+	}
+	full := family + " " + ni.Name
+	if ribbiStyles[ni.Name] {
+		font.Name.set(NameIDFontFamilyName, family)
+		font.Name.set(NameIDFontSubfamilyName, ni.Name)
+	} else {
+		font.Name.set(NameIDFontFamilyName, full)
+		font.Name.set(NameIDFontSubfamilyName, "Regular")
+	}
+	font.Name.set(NameIDTypographicFamilyName, family)
+	font.Name.set(NameIDTypographicSubfamilyName, ni.Name)
+	font.Name.set(NameIDFontFullName, full)
+	ps := ni.PostScriptName
+	if "" == ps {
+		ps = strings.Replace(family, " ", "", -1) + "-" + strings.Replace(ni.Name, " ", "", -1)
+	}
+	font.Name.set(NameIDPostScriptName, ps)
+}
@@ -0,0 +1,71 @@
+package opentype
+
+import (
+	"testing"
+)
+
+// newTestMetricsVariationStore returns the item variation store of the deltas at the maximum of the single axis.
+func newTestMetricsVariationStore(deltas ...int32) *ItemVariationStore {
+	s := &ItemVariationStore{
+		Format:            1,
+		AxisCount:         1,
+		VariationRegions:  [][]*RegionAxisCoordinates{{{StartCoord: 0, PeakCoord: 0x4000, EndCoord: 0x4000}}},
+		ItemVariationData: []*ItemVariationData{{RegionIndexes: []uint16{0}}},
+	}
+	for _, d := range deltas {
+		s.ItemVariationData[0].DeltaSets = append(s.ItemVariationData[0].DeltaSets, []int32{d})
+	}
+	return s
+}
+
+func TestInstantiateMetrics(t *testing.T) {
+	font := newTestGvarFont(t)
+	font.Post = &Post{Version: PostVersion3, UnderlinePosition: -100, UnderlineThickness: 50}
+	font.Gasp = &Gasp{Version: 1, GaspRanges: []*GaspRange{{RangeMaxPPEM: 8, RangeGaspBehavior: GaspDogray}, {RangeMaxPPEM: 0xFFFF}}}
+	font.Mvar = &Mvar{
+		MajorVersion: 1,
+		ValueRecords: []*MetricValueRecord{
+			{ValueTag: String2Tag("undo"), DeltaSetInnerIndex: 0},
+			{ValueTag: String2Tag("unds"), DeltaSetInnerIndex: 1},
+			{ValueTag: String2Tag("gsp0"), DeltaSetInnerIndex: 2},
+		},
+		ItemVarStore: newTestMetricsVariationStore(-20, 10, 4),
+	}
+	// the advance of glyph 1 is 640 at the maximum by the phantom points of gvar, and 700 by HVAR.
+	font.Hvar = &Hvar{MajorVersion: 1, ItemVarStore: newTestMetricsVariationStore(0, 100, 0)}
+	font = writeTestFont(t, font)
+	wght := String2Tag("wght")
+	tests := []struct {
+		weight       float64
+		underline    [2]int16
+		maxPPEM      uint16
+		advanceWidth uint16
+	}{
+		{400, [2]int16{-100, 50}, 8, 600},
+		{650, [2]int16{-110, 55}, 10, 650},
+		{900, [2]int16{-120, 60}, 12, 700},
+	}
+	for _, tt := range tests {
+		location := map[Tag]float64{wght: tt.weight}
+		instance, err := font.Instantiate(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance = writeTestFont(t, instance)
+		if p := instance.Post; tt.underline[0] != p.UnderlinePosition || tt.underline[1] != p.UnderlineThickness {
+			t.Errorf("underline at %g is %d, %d, want %v", tt.weight, p.UnderlinePosition, p.UnderlineThickness, tt.underline)
+		}
+		if v := instance.Gasp.GaspRanges[0].RangeMaxPPEM; tt.maxPPEM != v {
+			t.Errorf("rangeMaxPPEM at %g is %d, want %d", tt.weight, v, tt.maxPPEM)
+		}
+		if v, err := font.MetricAt(String2Tag("undo"), location); err != nil || int(tt.underline[0]) != v {
+			t.Errorf("underline position at %g is %d, %v, want %d", tt.weight, v, err, tt.underline[0])
+		}
+		if aw, _ := instance.Hmtx.get(1); tt.advanceWidth != aw {
+			t.Errorf("advance width at %g is %d, want %d", tt.weight, aw, tt.advanceWidth)
+		}
+		if aw, err := font.AdvanceAt(1, location); err != nil || tt.advanceWidth != aw {
+			t.Errorf("advance width of HVAR at %g is %d, %v, want %d", tt.weight, aw, err, tt.advanceWidth)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package opentype
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Instruction is a TrueType instruction of fpgm, prep or a glyph program.
+type Instruction struct {
+	Opcode uint8
+	// Values pushed by NPUSHB, NPUSHW, PUSHB and PUSHW.
+	Values []int32
+}
+
+// instructionName is the mnemonic of the instructions that share the name, and the number of the flag bits in the opcode.
+type instructionName struct {
+	name string
+	bits uint
+}
+
+// instructionNames are the mnemonics of the instructions from the base opcode.
+var instructionNames = map[uint8]instructionName{
+	0x00: {"SVTCA", 1}, 0x02: {"SPVTCA", 1}, 0x04: {"SFVTCA", 1}, 0x06: {"SPVTL", 1},
+	0x08: {"SFVTL", 1}, 0x0A: {"SPVFS", 0}, 0x0B: {"SFVFS", 0}, 0x0C: {"GPV", 0},
+	0x0D: {"GFV", 0}, 0x0E: {"SFVTPV", 0}, 0x0F: {"ISECT", 0}, 0x10: {"SRP0", 0},
+	0x11: {"SRP1", 0}, 0x12: {"SRP2", 0}, 0x13: {"SZP0", 0}, 0x14: {"SZP1", 0},
+	0x15: {"SZP2", 0}, 0x16: {"SZPS", 0}, 0x17: {"SLOOP", 0}, 0x18: {"RTG", 0},
+	0x19: {"RTHG", 0}, 0x1A: {"SMD", 0}, 0x1B: {"ELSE", 0}, 0x1C: {"JMPR", 0},
+	0x1D: {"SCVTCI", 0}, 0x1E: {"SSWCI", 0}, 0x1F: {"SSW", 0}, 0x20: {"DUP", 0},
+	0x21: {"POP", 0}, 0x22: {"CLEAR", 0}, 0x23: {"SWAP", 0}, 0x24: {"DEPTH", 0},
+	0x25: {"CINDEX", 0}, 0x26: {"MINDEX", 0}, 0x27: {"ALIGNPTS", 0}, 0x29: {"UTP", 0},
+	0x2A: {"LOOPCALL", 0}, 0x2B: {"CALL", 0}, 0x2C: {"FDEF", 0}, 0x2D: {"ENDF", 0},
+	0x2E: {"MDAP", 1}, 0x30: {"IUP", 1}, 0x32: {"SHP", 1}, 0x34: {"SHC", 1},
+	0x36: {"SHZ", 1}, 0x38: {"SHPIX", 0}, 0x39: {"IP", 0}, 0x3A: {"MSIRP", 1},
+	0x3C: {"ALIGNRP", 0}, 0x3D: {"RTDG", 0}, 0x3E: {"MIAP", 1}, 0x40: {"NPUSHB", 0},
+	0x41: {"NPUSHW", 0}, 0x42: {"WS", 0}, 0x43: {"RS", 0}, 0x44: {"WCVTP", 0},
+	0x45: {"RCVT", 0}, 0x46: {"GC", 1}, 0x48: {"SCFS", 0}, 0x49: {"MD", 1},
+	0x4B: {"MPPEM", 0}, 0x4C: {"MPS", 0}, 0x4D: {"FLIPON", 0}, 0x4E: {"FLIPOFF", 0},
+	0x4F: {"DEBUG", 0}, 0x50: {"LT", 0}, 0x51: {"LTEQ", 0}, 0x52: {"GT", 0},
+	0x53: {"GTEQ", 0}, 0x54: {"EQ", 0}, 0x55: {"NEQ", 0}, 0x56: {"ODD", 0},
+	0x57: {"EVEN", 0}, 0x58: {"IF", 0}, 0x59: {"EIF", 0}, 0x5A: {"AND", 0},
+	0x5B: {"OR", 0}, 0x5C: {"NOT", 0}, 0x5D: {"DELTAP1", 0}, 0x5E: {"SDB", 0},
+	0x5F: {"SDS", 0}, 0x60: {"ADD", 0}, 0x61: {"SUB", 0}, 0x62: {"DIV", 0},
+	0x63: {"MUL", 0}, 0x64: {"ABS", 0}, 0x65: {"NEG", 0}, 0x66: {"FLOOR", 0},
+	0x67: {"CEILING", 0}, 0x68: {"ROUND", 2}, 0x6C: {"NROUND", 2}, 0x70: {"WCVTF", 0},
+	0x71: {"DELTAP2", 0}, 0x72: {"DELTAP3", 0}, 0x73: {"DELTAC1", 0}, 0x74: {"DELTAC2", 0},
+	0x75: {"DELTAC3", 0}, 0x76: {"SROUND", 0}, 0x77: {"S45ROUND", 0}, 0x78: {"JROT", 0},
+	0x79: {"JROF", 0}, 0x7A: {"ROFF", 0}, 0x7C: {"RUTG", 0}, 0x7D: {"RDTG", 0},
+	0x7E: {"SANGW", 0}, 0x7F: {"AA", 0}, 0x80: {"FLIPPT", 0}, 0x81: {"FLIPRGON", 0},
+	0x82: {"FLIPRGOFF", 0}, 0x85: {"SCANCTRL", 0}, 0x86: {"SDPVTL", 1}, 0x88: {"GETINFO", 0},
+	0x89: {"IDEF", 0}, 0x8A: {"ROLL", 0}, 0x8B: {"MAX", 0}, 0x8C: {"MIN", 0},
+	0x8D: {"SCANTYPE", 0}, 0x8E: {"INSTCTRL", 0}, 0x91: {"GETVARIATION", 0}, 0x92: {"GETDATA", 0},
+	0xB0: {"PUSHB", 3}, 0xB8: {"PUSHW", 3}, 0xC0: {"MDRP", 5}, 0xE0: {"MIRP", 5},
+}
+
+// instructionOpcodes are the base opcodes from the mnemonics.
+var instructionOpcodes = func() map[string]uint8 {
+	m := make(map[string]uint8, len(instructionNames))
+	for op, n := range instructionNames {
+		m[n.name] = op
+	}
+	return m
+}()
+
+// instructionBases are the base opcodes of the defined opcodes.
+var instructionBases = func() map[uint8]uint8 {
+	m := make(map[uint8]uint8, 0x100)
+	for op, n := range instructionNames {
+		for i := 0; i < 1<<n.bits; i++ {
+			m[op+uint8(i)] = op
+		}
+	}
+	return m
+}()
+
+// isPushInstruction returns true if the opcode is NPUSHB, NPUSHW, PUSHB or PUSHW.
+func isPushInstruction(op uint8) bool {
+	return 0x40 == op || 0x41 == op || 0xB0 <= op && op <= 0xBF
+}
+
+// isWordPushInstruction returns true if the opcode is NPUSHW or PUSHW.
+func isWordPushInstruction(op uint8) bool {
+	return 0x41 == op || 0xB8 <= op && op <= 0xBF
+}
+
+// Mnemonic returns the name of the instruction with the flags in brackets, such as MIAP[1] and MDRP[10110].
+// The flags are the bits of the opcode in binary, except that those of PUSHB and PUSHW are the number of the values, such as PUSHB[3].
+// The undefined opcode, that may be defined by IDEF, is shown in hexadecimal such as 0xA0.
+func (ins *Instruction) Mnemonic() string {
+	base, ok := instructionBases[ins.Opcode]
+	n := instructionNames[base]
+	switch {
+	case !ok:
+		return fmt.Sprintf("0x%02X", ins.Opcode)
+	case 0xB0 == base || 0xB8 == base:
+		return fmt.Sprintf("%s[%d]", n.name, ins.Opcode-base+1)
+	case 0 < n.bits:
+		return fmt.Sprintf("%s[%0*b]", n.name, n.bits, ins.Opcode-base)
+	}
+	return n.name
+}
+
+// String returns the mnemonic followed by the pushed values.
+func (ins *Instruction) String() string {
+	s := ins.Mnemonic()
+	for _, v := range ins.Values {
+		s += " " + strconv.Itoa(int(v))
+	}
+	return s
+}
+
+// ParseInstructions decodes the program into the instructions.
+func ParseInstructions(program []uint8) ([]*Instruction, error) {
+	ret := []*Instruction{}
+	for pc := 0; pc < len(program); {
+		op := program[pc]
+		next := pc + instructionLength(program, pc)
+		if next > len(program) {
+			return nil, fmt.Errorf("push data of the instruction at %d exceeds the end of the program", pc)
+		}
+		ins := &Instruction{Opcode: op}
+		if isPushInstruction(op) {
+			data := program[pc+1 : next]
+			if 0x40 == op || 0x41 == op {
+				data = data[1:]
+			}
+			for i := 0; i < pushCount(program, pc); i++ {
+				if isWordPushInstruction(op) {
+					ins.Values = append(ins.Values, int32(int16(uint16(data[2*i])<<8|uint16(data[2*i+1]))))
+				} else {
+					ins.Values = append(ins.Values, int32(data[i]))
+				}
+			}
+		}
+		ret = append(ret, ins)
+		pc = next
+	}
+	return ret, nil
+}
+
+// pushCount returns the number of the values pushed by the push instruction at pc.
+func pushCount(program []byte, pc int) int {
+	op := program[pc]
+	switch {
+	case 0x40 == op || 0x41 == op:
+		return int(program[pc+1])
+	case 0xB0 <= op && op <= 0xB7:
+		return int(op-0xB0) + 1
+	case 0xB8 <= op && op <= 0xBF:
+		return int(op-0xB8) + 1
+	}
+	return 0
+}
+
+// EncodeInstructions encodes the instructions into a program.
+func EncodeInstructions(instructions []*Instruction) ([]uint8, error) {
+	b := []uint8{}
+	for _, ins := range instructions {
+		op := ins.Opcode
+		n := len(ins.Values)
+		switch {
+		case !isPushInstruction(op):
+			if 0 != n {
+				return nil, fmt.Errorf("%s: pushes no value", ins.Mnemonic())
+			}
+			b = append(b, op)
+			continue
+		case 0x40 == op || 0x41 == op:
+			if n > 0xFF {
+				return nil, fmt.Errorf("%s: too many values", ins.Mnemonic())
+			}
+			b = append(b, op, uint8(n))
+		default:
+			if n != pushCount([]uint8{op}, 0) {
+				return nil, fmt.Errorf("%s: requires %d values, but got %d", ins.Mnemonic(), pushCount([]uint8{op}, 0), n)
+			}
+			b = append(b, op)
+		}
+		for _, v := range ins.Values {
+			if isWordPushInstruction(op) {
+				if v < -0x8000 || 0x7FFF < v {
+					return nil, fmt.Errorf("%s: %d is out of range of a word", ins.Mnemonic(), v)
+				}
+				b = append(b, uint8(uint16(v)>>8), uint8(v))
+				continue
+			}
+			if v < 0 || 0xFF < v {
+				return nil, fmt.Errorf("%s: %d is out of range of a byte", ins.Mnemonic(), v)
+			}
+			b = append(b, uint8(v))
+		}
+	}
+	return b, nil
+}
+
+// Disassemble returns the program in the assembly language, that has an instruction per line.
+// The bodies of FDEF, IDEF and IF are indented.
+func Disassemble(program []uint8) (string, error) {
+	instructions, err := ParseInstructions(program)
+	if err != nil {
+		return "", fmt.Errorf("disassembling failed: %s", err)
+	}
+	var sb strings.Builder
+	depth := 0
+	for _, ins := range instructions {
+		switch ins.Opcode {
+		case 0x1B, 0x2D, 0x59: // ELSE, ENDF, EIF
+			if 0 < depth {
+				depth--
+			}
+		}
+		sb.WriteString(strings.Repeat("  ", depth))
+		sb.WriteString(ins.String())
+		sb.WriteString("\n")
+		switch ins.Opcode {
+		case 0x1B, 0x2C, 0x58, 0x89: // ELSE, FDEF, IF, IDEF
+			depth++
+		}
+	}
+	return sb.String(), nil
+}
+
+// Assemble converts the source in the assembly language of Disassemble into a program.
+// Each line has an instruction, and the text after "//" is a comment.
+// The brackets of the flags may be omitted for zero flags, and those of the push instructions for the number of the values.
+// PUSH followed by the values pushes them with the shortest push instructions.
+// The hexadecimal opcode such as 0xA0 is accepted for the instructions defined by IDEF.
+func Assemble(source string) ([]uint8, error) {
+	instructions := []*Instruction{}
+	s := bufio.NewScanner(strings.NewReader(source))
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if i := strings.Index(text, "//"); 0 <= i {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if 0 == len(fields) {
+			continue
+		}
+		ins, err := assembleLine(fields)
+		if err != nil {
+			return nil, fmt.Errorf("assembling failed: line %d: %s", line, err)
+		}
+		instructions = append(instructions, ins...)
+	}
+	b, err := EncodeInstructions(instructions)
+	if err != nil {
+		return nil, fmt.Errorf("assembling failed: %s", err)
+	}
+	return b, nil
+}
+
+// assembleLine returns the instructions of the mnemonic and the values.
+func assembleLine(fields []string) ([]*Instruction, error) {
+	values := make([]int32, 0, len(fields)-1)
+	for _, f := range fields[1:] {
+		v, err := strconv.ParseInt(f, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", f)
+		}
+		values = append(values, int32(v))
+	}
+	mnemonic := fields[0]
+	if "PUSH" == mnemonic {
+		return pushInstructions(values), nil
+	}
+	if strings.HasPrefix(mnemonic, "0x") || strings.HasPrefix(mnemonic, "0X") {
+		op, err := strconv.ParseUint(mnemonic[2:], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid opcode %q", mnemonic)
+		}
+		return []*Instruction{{Opcode: uint8(op)}}, nil
+	}
+	name, flags := mnemonic, ""
+	if i := strings.IndexByte(mnemonic, '['); 0 <= i && strings.HasSuffix(mnemonic, "]") {
+		name, flags = mnemonic[:i], mnemonic[i+1:len(mnemonic)-1]
+	}
+	base, ok := instructionOpcodes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown instruction %q", mnemonic)
+	}
+	n := instructionNames[base]
+	ins := &Instruction{Opcode: base, Values: values}
+	switch {
+	case 0xB0 == base || 0xB8 == base:
+		if "" != flags {
+			count, err := strconv.Atoi(flags)
+			if err != nil || count != len(values) {
+				return nil, fmt.Errorf("%s: the number of the values is %d", mnemonic, len(values))
+			}
+		}
+		if len(values) < 1 || 8 < len(values) {
+			return nil, fmt.Errorf("%s: pushes 1 to 8 values", mnemonic)
+		}
+		ins.Opcode = base + uint8(len(values)-1)
+	case 0x40 == base || 0x41 == base:
+		if "" != flags {
+			count, err := strconv.Atoi(flags)
+			if err != nil || count != len(values) {
+				return nil, fmt.Errorf("%s: the number of the values is %d", mnemonic, len(values))
+			}
+		}
+	case "" != flags:
+		v, err := strconv.ParseUint(flags, 2, 8)
+		if err != nil || uint(len(flags)) > n.bits {
+			return nil, fmt.Errorf("%s: invalid flags", mnemonic)
+		}
+		ins.Opcode = base + uint8(v)
+	}
+	return []*Instruction{ins}, nil
+}
+
+// pushInstructions returns the shortest push instructions of the values.
+func pushInstructions(values []int32) []*Instruction {
+	ret := []*Instruction{}
+	for 0 < len(values) {
+		// the values in a byte and those in a word are pushed separately.
+		words := values[0] < 0 || 0xFF < values[0]
+		n := 1
+		for n < len(values) && n < 0xFF && words == (values[n] < 0 || 0xFF < values[n]) {
+			n++
+		}
+		var op uint8
+		switch {
+		case n <= 8 && words:
+			op = 0xB8 + uint8(n-1)
+		case n <= 8:
+			op = 0xB0 + uint8(n-1)
+		case words:
+			op = 0x41
+		default:
+			op = 0x40
+		}
+		ret = append(ret, &Instruction{Opcode: op, Values: append([]int32{}, values[:n]...)})
+		values = values[n:]
+	}
+	return ret
+}
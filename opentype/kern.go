@@ -0,0 +1,577 @@
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Kern is a "kern" table.
+// The kerning table contains the values that control the inter-character spacing for the glyphs in a font.
+// Both the Microsoft version 0 and the Apple version 1 are supported, with format 0 and 2 subtables.
+type Kern struct {
+	// Table version number: 0 for the Microsoft version, 0x00010000 for the Apple version.
+	Version   uint32
+	Subtables []*KernSubtable
+}
+
+const (
+	// KernVersionMicrosoft : the version of the "kern" table defined by the OpenType specification.
+	KernVersionMicrosoft = uint32(0)
+	// KernVersionApple : the version of the "kern" table defined by the TrueType reference manual.
+	KernVersionApple = uint32(0x00010000)
+)
+
+// KernSubtable is a subtable of the "kern" table.
+type KernSubtable struct {
+	// Format of the subtable: 0 for ordered list of kerning pairs, 2 for class kerning values.
+	Format uint8
+	// True if the table has horizontal data, false if vertical.
+	Horizontal bool
+	// True if the table has minimum values, false if kerning values, only for the Microsoft version.
+	Minimum bool
+	// True if kerning is perpendicular to the flow of the text.
+	CrossStream bool
+	// True if the value in this table should replace the value currently being accumulated, only for the Microsoft version.
+	Override bool
+	// True if the table has variation kerning values, only for the Apple version.
+	Variation bool
+	// The tuple index for variation kerning values, only for the Apple version.
+	TupleIndex uint16
+	// Kerning pairs sorted by the left and the right glyph IDs, only for format 0.
+	Pairs []*KernPair
+	// Classes of the left glyphs, that are the rows of Values, only for format 2.
+	LeftClasses *KernClassTable
+	// Classes of the right glyphs, that are the columns of Values, only for format 2.
+	RightClasses *KernClassTable
+	// Kerning values indexed by the left class and the right class, only for format 2.
+	Values [][]int16
+	// The body of the subtable of the other formats, that is kept as it is.
+	data []byte
+}
+
+// KernPair is a kerning value of a pair of glyphs.
+type KernPair struct {
+	// The glyph index for the left-hand glyph in the kerning pair.
+	Left uint16
+	// The glyph index for the right-hand glyph in the kerning pair.
+	Right uint16
+	// The kerning value for the above pair, in font design units.
+	Value int16
+}
+
+// KernClassTable assigns classes to a range of glyphs.
+type KernClassTable struct {
+	// First glyph in the class range.
+	FirstGlyph uint16
+	// Classes of the glyphs, beginning with FirstGlyph.
+	Classes []uint16
+}
+
+const (
+	kernCoverageHorizontal  = uint16(0x0001)
+	kernCoverageMinimum     = uint16(0x0002)
+	kernCoverageCrossStream = uint16(0x0004)
+	kernCoverageOverride    = uint16(0x0008)
+
+	kernAppleCoverageVertical    = uint16(0x8000)
+	kernAppleCoverageCrossStream = uint16(0x4000)
+	kernAppleCoverageVariation   = uint16(0x2000)
+)
+
+func parseKern(f *os.File, offset, length uint32) (k *Kern, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	k = &Kern{}
+	version := r.uint16()
+	var nTables uint32
+	if 0 == version {
+		k.Version = KernVersionMicrosoft
+		nTables = uint32(r.uint16())
+	} else {
+		k.Version = uint32(version)<<16 | uint32(r.uint16())
+		if KernVersionApple != k.Version {
+			return nil, fmt.Errorf("kern table version %#x is not supported", k.Version)
+		}
+		nTables = r.uint32()
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse kern header: %s")
+	}
+	k.Subtables = make([]*KernSubtable, 0)
+	pos := r.tell()
+	for i := uint32(0); i < nTables && pos < int64(length); i++ {
+		st, size := k.parseSubtable(r, pos)
+		if r.hasErr() {
+			return nil, r.errorf("failed to parse kern subtable: %s")
+		}
+		k.Subtables = append(k.Subtables, st)
+		pos += size
+	}
+	return k, nil
+}
+
+// headerSize returns the size of the header of the subtables.
+func (k *Kern) headerSize() int {
+	if KernVersionApple == k.Version {
+		return 8
+	}
+	return 6
+}
+
+// parseSubtable reads the subtable at the offset, and returns it with its size.
+func (k *Kern) parseSubtable(r *tableReader, offset int64) (st *KernSubtable, size int64) {
+	st = &KernSubtable{}
+	r.seek(offset)
+	if KernVersionApple == k.Version {
+		size = int64(r.uint32())
+		coverage := r.uint16()
+		st.TupleIndex = r.uint16()
+		st.Format = uint8(coverage)
+		st.Horizontal = 0 == coverage&kernAppleCoverageVertical
+		st.CrossStream = 0 != coverage&kernAppleCoverageCrossStream
+		st.Variation = 0 != coverage&kernAppleCoverageVariation
+	} else {
+		// version of the subtable
+		r.uint16()
+		size = int64(r.uint16())
+		coverage := r.uint16()
+		st.Format = uint8(coverage >> 8)
+		st.Horizontal = 0 != coverage&kernCoverageHorizontal
+		st.Minimum = 0 != coverage&kernCoverageMinimum
+		st.CrossStream = 0 != coverage&kernCoverageCrossStream
+		st.Override = 0 != coverage&kernCoverageOverride
+	}
+	header := int64(k.headerSize())
+	switch st.Format {
+	case 0:
+		nPairs := r.uint16()
+		// searchRange, entrySelector, rangeShift
+		r.uint16s(3)
+		if !r.available(int(nPairs), 6) {
+			return
+		}
+		st.Pairs = make([]*KernPair, nPairs)
+		for i := range st.Pairs {
+			p := &KernPair{}
+			r.read(p)
+			st.Pairs[i] = p
+		}
+		// the length of a large subtable overflows in the Microsoft version, so that it is calculated from the number of pairs.
+		size = header + 8 + 6*int64(nPairs)
+	case 2:
+		rowWidth := r.uint16()
+		leftOffset := r.uint16()
+		rightOffset := r.uint16()
+		arrayOffset := r.uint16()
+		left := parseKernClassTable(r, offset+int64(leftOffset))
+		right := parseKernClassTable(r, offset+int64(rightOffset))
+		st.RightClasses = &KernClassTable{
+			FirstGlyph: right.FirstGlyph,
+			Classes:    make([]uint16, len(right.Classes)),
+		}
+		cols := int(rowWidth / 2)
+		for i, v := range right.Classes {
+			st.RightClasses.Classes[i] = v / 2
+		}
+		// the left classes are the offsets of the rows from the beginning of the subtable.
+		rowIndex := make(map[uint16]uint16)
+		rowOffsets := make([]uint16, 0)
+		for _, v := range left.Classes {
+			if _, ok := rowIndex[v]; !ok {
+				rowIndex[v] = 0
+				rowOffsets = append(rowOffsets, v)
+			}
+		}
+		sort.Slice(rowOffsets, func(i, j int) bool {
+			return rowOffsets[i] < rowOffsets[j]
+		})
+		st.Values = make([][]int16, 0, len(rowOffsets))
+		zeroRow := -1
+		for _, o := range rowOffsets {
+			if o < arrayOffset || 0 == rowWidth {
+				// the glyphs that have no kerning value.
+				if zeroRow < 0 {
+					zeroRow = len(st.Values)
+					st.Values = append(st.Values, make([]int16, cols))
+				}
+				rowIndex[o] = uint16(zeroRow)
+				continue
+			}
+			r.seek(offset + int64(o))
+			row := make([]int16, cols)
+			r.read(row)
+			rowIndex[o] = uint16(len(st.Values))
+			st.Values = append(st.Values, row)
+		}
+		st.LeftClasses = &KernClassTable{
+			FirstGlyph: left.FirstGlyph,
+			Classes:    make([]uint16, len(left.Classes)),
+		}
+		for i, v := range left.Classes {
+			st.LeftClasses.Classes[i] = rowIndex[v]
+		}
+	default:
+		r.seek(offset + header)
+		n := int(size - header)
+		if r.available(n, 1) {
+			st.data = make([]byte, n)
+			r.read(st.data)
+		}
+	}
+	if size < header {
+		size = header
+	}
+	return
+}
+
+func parseKernClassTable(r *tableReader, offset int64) *KernClassTable {
+	c := &KernClassTable{}
+	r.seek(offset)
+	c.FirstGlyph = r.uint16()
+	c.Classes = r.uint16s(int(r.uint16()))
+	return c
+}
+
+// class returns the class of the glyph, and false if the glyph is out of the range.
+func (c *KernClassTable) class(gid uint16) (uint16, bool) {
+	if c == nil || gid < c.FirstGlyph || int(gid-c.FirstGlyph) >= len(c.Classes) {
+		return 0, false
+	}
+	return c.Classes[gid-c.FirstGlyph], true
+}
+
+// Value returns the kerning value of the pair of glyphs in this subtable, and false if the pair is not found.
+func (st *KernSubtable) Value(left, right uint16) (int16, bool) {
+	switch st.Format {
+	case 0:
+		key := uint32(left)<<16 | uint32(right)
+		i := sort.Search(len(st.Pairs), func(i int) bool {
+			return uint32(st.Pairs[i].Left)<<16|uint32(st.Pairs[i].Right) >= key
+		})
+		if i < len(st.Pairs) && st.Pairs[i].Left == left && st.Pairs[i].Right == right {
+			return st.Pairs[i].Value, true
+		}
+	case 2:
+		row, ok1 := st.LeftClasses.class(left)
+		col, ok2 := st.RightClasses.class(right)
+		if ok1 && ok2 && int(row) < len(st.Values) && int(col) < len(st.Values[row]) {
+			return st.Values[row][col], true
+		}
+	}
+	return 0, false
+}
+
+// Kerning returns the horizontal kerning value of the pair of glyphs, in font design units.
+// The values of the horizontal subtables are accumulated, except for minimum, cross-stream and variation ones.
+func (k *Kern) Kerning(left, right uint16) int16 {
+	value := int16(0)
+	for _, st := range k.Subtables {
+		if !st.Horizontal || st.Minimum || st.CrossStream || st.Variation {
+			continue
+		}
+		v, ok := st.Value(left, right)
+		if !ok {
+			continue
+		}
+		if st.Override {
+			value = v
+		} else {
+			value += v
+		}
+	}
+	return value
+}
+
+func (k *Kern) filter(m map[uint16]uint16) *Kern {
+	new := &Kern{
+		Version:   k.Version,
+		Subtables: make([]*KernSubtable, 0, len(k.Subtables)),
+	}
+	for _, st := range k.Subtables {
+		c := st.clone()
+		switch st.Format {
+		case 0:
+			c.Pairs = make([]*KernPair, 0)
+			for _, p := range st.Pairs {
+				left, ok1 := m[p.Left]
+				right, ok2 := m[p.Right]
+				if ok1 && ok2 {
+					c.Pairs = append(c.Pairs, &KernPair{
+						Left:  left,
+						Right: right,
+						Value: p.Value,
+					})
+				}
+			}
+			sortKernPairs(c.Pairs)
+		case 2:
+			c.LeftClasses, c.RightClasses = st.LeftClasses.filter(m), st.RightClasses.filter(m)
+			// the glyphs out of the original ranges need a row and a column of zeros.
+			zeroRow, zeroCol := -1, -1
+			for i, row := range c.Values {
+				if 0 == countNonZero(row) {
+					zeroRow = i
+				}
+			}
+			cols := 0
+			if 0 < len(c.Values) {
+				cols = len(c.Values[0])
+			}
+			for j := 0; j < cols && zeroCol < 0; j++ {
+				column := make([]int16, len(c.Values))
+				for i := range c.Values {
+					column[i] = c.Values[i][j]
+				}
+				if 0 == countNonZero(column) {
+					zeroCol = j
+				}
+			}
+			if zeroRow < 0 {
+				zeroRow = len(c.Values)
+				c.Values = append(c.Values, make([]int16, cols))
+			}
+			if zeroCol < 0 {
+				zeroCol = cols
+				for i := range c.Values {
+					c.Values[i] = append(c.Values[i], 0)
+				}
+			}
+			c.LeftClasses.fill(m, st.LeftClasses, uint16(zeroRow))
+			c.RightClasses.fill(m, st.RightClasses, uint16(zeroCol))
+		}
+		new.Subtables = append(new.Subtables, c)
+	}
+	return new
+}
+
+// filter returns the class table that covers the range of the glyphs of a subset.
+// The classes are set by fill.
+func (c *KernClassTable) filter(m map[uint16]uint16) *KernClassTable {
+	first, last := -1, -1
+	for i := range c.Classes {
+		if n, ok := m[c.FirstGlyph+uint16(i)]; ok {
+			if first < 0 || int(n) < first {
+				first = int(n)
+			}
+			if last < int(n) {
+				last = int(n)
+			}
+		}
+	}
+	if first < 0 {
+		return &KernClassTable{}
+	}
+	return &KernClassTable{
+		FirstGlyph: uint16(first),
+		Classes:    make([]uint16, last-first+1),
+	}
+}
+
+// fill sets the classes of the original class table to the glyphs of a subset, and the default class to the others.
+func (c *KernClassTable) fill(m map[uint16]uint16, original *KernClassTable, defaultClass uint16) {
+	for i := range c.Classes {
+		c.Classes[i] = defaultClass
+	}
+	for i, class := range original.Classes {
+		if n, ok := m[original.FirstGlyph+uint16(i)]; ok {
+			c.Classes[n-c.FirstGlyph] = class
+		}
+	}
+}
+
+func countNonZero(values []int16) int {
+	count := 0
+	for _, v := range values {
+		if 0 != v {
+			count++
+		}
+	}
+	return count
+}
+
+func sortKernPairs(pairs []*KernPair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Left != pairs[j].Left {
+			return pairs[i].Left < pairs[j].Left
+		}
+		return pairs[i].Right < pairs[j].Right
+	})
+}
+
+// Tag is table name.
+func (k *Kern) Tag() Tag {
+	return String2Tag("kern")
+}
+
+// store writes binary expression of this table.
+func (k *Kern) store(w *errWriter) {
+	b := k.pack()
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+// pack creates the binary expression of this table.
+func (k *Kern) pack() []byte {
+	buf := &bytes.Buffer{}
+	if KernVersionApple == k.Version {
+		binary.Write(buf, binary.BigEndian, k.Version)
+		binary.Write(buf, binary.BigEndian, uint32(len(k.Subtables)))
+	} else {
+		binary.Write(buf, binary.BigEndian, uint16(0))
+		binary.Write(buf, binary.BigEndian, uint16(len(k.Subtables)))
+	}
+	for _, st := range k.Subtables {
+		body := st.body(k.headerSize())
+		length := k.headerSize() + len(body)
+		if KernVersionApple == k.Version {
+			coverage := uint16(st.Format)
+			if !st.Horizontal {
+				coverage |= kernAppleCoverageVertical
+			}
+			if st.CrossStream {
+				coverage |= kernAppleCoverageCrossStream
+			}
+			if st.Variation {
+				coverage |= kernAppleCoverageVariation
+			}
+			binary.Write(buf, binary.BigEndian, uint32(length))
+			binary.Write(buf, binary.BigEndian, coverage)
+			binary.Write(buf, binary.BigEndian, st.TupleIndex)
+		} else {
+			coverage := uint16(st.Format) << 8
+			if st.Horizontal {
+				coverage |= kernCoverageHorizontal
+			}
+			if st.Minimum {
+				coverage |= kernCoverageMinimum
+			}
+			if st.CrossStream {
+				coverage |= kernCoverageCrossStream
+			}
+			if st.Override {
+				coverage |= kernCoverageOverride
+			}
+			binary.Write(buf, binary.BigEndian, uint16(0))
+			// the length overflows for a large format 0 subtable, as other font tools do.
+			binary.Write(buf, binary.BigEndian, uint16(length))
+			binary.Write(buf, binary.BigEndian, coverage)
+		}
+		buf.Write(body)
+	}
+	return buf.Bytes()
+}
+
+// body creates the binary expression of this subtable except for the header.
+func (st *KernSubtable) body(headerSize int) []byte {
+	buf := &bytes.Buffer{}
+	switch st.Format {
+	case 0:
+		nPairs := uint16(len(st.Pairs))
+		entrySelector := uint16(0)
+		for 1<<(entrySelector+1) <= nPairs {
+			entrySelector++
+		}
+		searchRange := uint16(6) << entrySelector
+		if 0 == nPairs {
+			searchRange = 0
+		}
+		binary.Write(buf, binary.BigEndian, []uint16{nPairs, searchRange, entrySelector, nPairs*6 - searchRange})
+		for _, p := range st.Pairs {
+			binary.Write(buf, binary.BigEndian, p)
+		}
+	case 2:
+		cols := 0
+		if 0 < len(st.Values) {
+			cols = len(st.Values[0])
+		}
+		rowWidth := uint16(2 * cols)
+		leftOffset := uint16(headerSize + 8)
+		rightOffset := leftOffset + uint16(4+2*len(st.LeftClasses.Classes))
+		arrayOffset := rightOffset + uint16(4+2*len(st.RightClasses.Classes))
+		binary.Write(buf, binary.BigEndian, []uint16{rowWidth, leftOffset, rightOffset, arrayOffset})
+		binary.Write(buf, binary.BigEndian, st.LeftClasses.FirstGlyph)
+		binary.Write(buf, binary.BigEndian, uint16(len(st.LeftClasses.Classes)))
+		for _, class := range st.LeftClasses.Classes {
+			binary.Write(buf, binary.BigEndian, arrayOffset+class*rowWidth)
+		}
+		binary.Write(buf, binary.BigEndian, st.RightClasses.FirstGlyph)
+		binary.Write(buf, binary.BigEndian, uint16(len(st.RightClasses.Classes)))
+		for _, class := range st.RightClasses.Classes {
+			binary.Write(buf, binary.BigEndian, class*2)
+		}
+		for _, row := range st.Values {
+			binary.Write(buf, binary.BigEndian, row)
+		}
+	default:
+		buf.Write(st.data)
+	}
+	return buf.Bytes()
+}
+
+// CheckSum for this table.
+func (k *Kern) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(k)
+}
+
+// Length returns the size(byte) of this table.
+func (k *Kern) Length() uint32 {
+	return uint32(len(k.pack()))
+}
+
+// Exists returns true if this is not nil.
+func (k *Kern) Exists() bool {
+	return k != nil
+}
+
+// clone returns a deep copy of this table.
+func (k *Kern) clone() *Kern {
+	if k == nil {
+		return nil
+	}
+	c := &Kern{
+		Version:   k.Version,
+		Subtables: make([]*KernSubtable, len(k.Subtables)),
+	}
+	for i, st := range k.Subtables {
+		c.Subtables[i] = st.clone()
+	}
+	return c
+}
+
+func (st *KernSubtable) clone() *KernSubtable {
+	c := *st
+	if st.Pairs != nil {
+		c.Pairs = make([]*KernPair, len(st.Pairs))
+		for i, p := range st.Pairs {
+			pp := *p
+			c.Pairs[i] = &pp
+		}
+	}
+	if st.LeftClasses != nil {
+		c.LeftClasses = &KernClassTable{
+			FirstGlyph: st.LeftClasses.FirstGlyph,
+			Classes:    append([]uint16{}, st.LeftClasses.Classes...),
+		}
+	}
+	if st.RightClasses != nil {
+		c.RightClasses = &KernClassTable{
+			FirstGlyph: st.RightClasses.FirstGlyph,
+			Classes:    append([]uint16{}, st.RightClasses.Classes...),
+		}
+	}
+	if st.Values != nil {
+		c.Values = make([][]int16, len(st.Values))
+		for i, row := range st.Values {
+			c.Values[i] = append([]int16{}, row...)
+		}
+	}
+	if st.data != nil {
+		c.data = append([]byte{}, st.data...)
+	}
+	return &c
+}
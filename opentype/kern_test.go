@@ -0,0 +1,123 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestKern returns the kern table of a format 0 subtable, that kerns the pairs (1, 2) and (2, 3),
+// and a format 2 subtable, that kerns the glyphs 4 and 5 followed by the glyph 6.
+func newTestKern() *Kern {
+	return &Kern{
+		Version: KernVersionMicrosoft,
+		Subtables: []*KernSubtable{
+			{
+				Format:     0,
+				Horizontal: true,
+				Pairs: []*KernPair{
+					{Left: 1, Right: 2, Value: -50},
+					{Left: 2, Right: 3, Value: -20},
+				},
+			},
+			{
+				Format:       2,
+				Horizontal:   true,
+				LeftClasses:  &KernClassTable{FirstGlyph: 4, Classes: []uint16{0, 0}},
+				RightClasses: &KernClassTable{FirstGlyph: 5, Classes: []uint16{0, 1}},
+				Values:       [][]int16{{0, -30}},
+			},
+		},
+	}
+}
+
+// newTestKernGpos returns GPOS whose feature of the tag has the pair adjustment of the glyphs 1 and 3.
+func newTestKernGpos(feature Tag) *Gpos {
+	return &Gpos{LayoutTable{
+		ScriptList: []*ScriptRecord{
+			{Tag: ScriptTagDefault, Script: &Script{
+				DefaultLangSys: &LangSys{RequiredFeatureIndex: RequiredFeatureIndexNone, FeatureIndices: []uint16{0}},
+			}},
+		},
+		FeatureList: []*FeatureRecord{
+			{Tag: feature, Feature: &Feature{LookupListIndices: []uint16{0}}},
+		},
+		LookupList: []*Lookup{
+			{LookupType: GposLookupTypePair, Subtables: []LookupSubtable{
+				&PairPos{
+					Format:       1,
+					Coverage:     &Coverage{Glyphs: []uint16{1}},
+					ValueFormat1: ValueFormatXAdvance,
+					PairSets: [][]*PairValueRecord{
+						{{SecondGlyph: 3, Value1: &ValueRecord{XAdvance: -40}}},
+					},
+				},
+			}},
+		},
+	}}
+}
+
+func TestKernRoundTrip(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Kern = newTestKern()
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Kern, parsed.Kern) {
+		t.Errorf("kern is %+v, want %+v", parsed.Kern, font.Kern)
+	}
+	tests := []struct {
+		left, right uint16
+		want        int16
+	}{
+		{1, 2, -50},
+		{2, 3, -20},
+		{2, 1, 0},
+		{4, 6, -30},
+		{5, 6, -30},
+		{4, 5, 0},
+		{6, 6, 0},
+	}
+	for _, tt := range tests {
+		if v := parsed.Kerning(tt.left, tt.right); tt.want != v {
+			t.Errorf("kerning of (%d, %d) is %d, want %d", tt.left, tt.right, v, tt.want)
+		}
+	}
+}
+
+func TestKerningPrefersGposKernFeature(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Kern = newTestKern()
+	font.Gpos = newTestKernGpos(String2Tag("kern"))
+	font = writeTestFont(t, font)
+	// the kern table is ignored even for the pairs that GPOS does not kern.
+	for _, p := range [][3]int16{{1, 3, -40}, {1, 2, 0}, {5, 6, 0}} {
+		if v := font.Kerning(uint16(p[0]), uint16(p[1])); p[2] != v {
+			t.Errorf("kerning of (%d, %d) with both tables is %d, want %d", p[0], p[1], v, p[2])
+		}
+	}
+	font.CMap = &CMap{
+		Header: &CMapHeader{NumTables: 1},
+		EncodingRecords: []*EncodingRecord{
+			{PlatformID: PlatformIDWindows, EncodingID: EncodingIDWindowsUnicodeBMP, Subtable: newEncodingRecordSubtableFormat4(0, map[int32]uint16{'A': 1, 'B': 2, 'C': 3})},
+		},
+	}
+	glyphs, err := font.Shape("ABC", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 510 != glyphs[0].XAdvance || 520 != glyphs[1].XAdvance {
+		t.Errorf("advances of the shaped glyphs are %d and %d, want 510 and 520", glyphs[0].XAdvance, glyphs[1].XAdvance)
+	}
+	// GPOS without the kern feature falls back to the kern table.
+	font.Gpos = newTestKernGpos(String2Tag("dist"))
+	for _, p := range [][3]int16{{1, 3, 0}, {1, 2, -50}, {5, 6, -30}} {
+		if v := font.Kerning(uint16(p[0]), uint16(p[1])); p[2] != v {
+			t.Errorf("kerning of (%d, %d) without the kern feature is %d, want %d", p[0], p[1], v, p[2])
+		}
+	}
+	glyphs, err = font.Shape("ABC", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 460 != glyphs[0].XAdvance || 500 != glyphs[1].XAdvance {
+		t.Errorf("advances of the shaped glyphs are %d and %d, want 460 and 500", glyphs[0].XAdvance, glyphs[1].XAdvance)
+	}
+}
@@ -0,0 +1,1959 @@
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// tableReader reads the structures that refer to each other by offsets in a table.
+type tableReader struct {
+	errReader
+	rs io.ReadSeeker
+	// the number of the glyphs of the font, that the ranges of glyph IDs are capped at, or 0 if it is unknown.
+	numGlyphs int
+}
+
+// newTableReader loads the whole table on memory, because the layout tables are read by a lot of small random access.
+func newTableReader(f *os.File, offset, length uint32) (*tableReader, error) {
+	_, err := f.Seek(int64(offset), 0)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, length)
+	_, err = io.ReadFull(f, b)
+	if err != nil {
+		return nil, err
+	}
+	rs := bytes.NewReader(b)
+	return &tableReader{
+		errReader: errReader{
+			r: rs,
+		},
+		rs: rs,
+	}, nil
+}
+
+// seek moves to the offset from the beginning of the table.
+func (r *tableReader) seek(offset int64) {
+	if r.hasErr() {
+		return
+	}
+	_, r.err = r.rs.Seek(offset, 0)
+}
+
+// tell returns the current offset from the beginning of the table.
+func (r *tableReader) tell() int64 {
+	if r.hasErr() {
+		return 0
+	}
+	cur, err := r.rs.Seek(0, 1)
+	r.err = err
+	return cur
+}
+
+func (r *tableReader) uint16() (v uint16) {
+	r.read(&v)
+	return
+}
+
+func (r *tableReader) int16() (v int16) {
+	r.read(&v)
+	return
+}
+
+func (r *tableReader) uint32() (v uint32) {
+	r.read(&v)
+	return
+}
+
+func (r *tableReader) uint16s(n int) []uint16 {
+	if !r.available(n, 2) {
+		return []uint16{}
+	}
+	v := make([]uint16, n)
+	r.read(v)
+	return v
+}
+
+func (r *tableReader) uint32s(n int) []uint32 {
+	if !r.available(n, 4) {
+		return []uint32{}
+	}
+	v := make([]uint32, n)
+	r.read(v)
+	return v
+}
+
+// glyphLimit returns the glyph ID that the ranges of glyph IDs end before.
+func (r *tableReader) glyphLimit() uint32 {
+	if 0 < r.numGlyphs {
+		return uint32(r.numGlyphs)
+	}
+	return 0x10000
+}
+
+// available returns true if n elements of the size can be read, that prevents huge allocation by broken counts.
+func (r *tableReader) available(n, size int) bool {
+	if r.hasErr() {
+		return false
+	}
+	cur, err := r.rs.Seek(0, 1)
+	if err != nil {
+		r.err = err
+		return false
+	}
+	end, err := r.rs.Seek(0, 2)
+	if err != nil {
+		r.err = err
+		return false
+	}
+	r.rs.Seek(cur, 0)
+	if n < 0 || int64(n)*int64(size) > end-cur {
+		r.err = fmt.Errorf("%d elements exceed the table", n)
+		return false
+	}
+	return true
+}
+
+// offsetNode is a binary structure of a table that refers to other structures by offsets.
+type offsetNode struct {
+	buf   bytes.Buffer
+	links []*offsetLink
+}
+
+type offsetLink struct {
+	pos    int
+	size   int
+	target *offsetNode
+}
+
+func (n *offsetNode) uint16(v uint16) {
+	binary.Write(&(n.buf), binary.BigEndian, v)
+}
+
+func (n *offsetNode) int16(v int16) {
+	binary.Write(&(n.buf), binary.BigEndian, v)
+}
+
+func (n *offsetNode) uint32(v uint32) {
+	binary.Write(&(n.buf), binary.BigEndian, v)
+}
+
+func (n *offsetNode) uint16s(v []uint16) {
+	binary.Write(&(n.buf), binary.BigEndian, v)
+}
+
+func (n *offsetNode) bytes(v []byte) {
+	n.buf.Write(v)
+}
+
+// offset16 writes a 16-bit offset to the target, that is relative to the beginning of this node.
+// If the target is nil, NULL offset is written.
+func (n *offsetNode) offset16(target *offsetNode) {
+	n.offset(target, 2)
+}
+
+// offset32 writes a 32-bit offset to the target, that is relative to the beginning of this node.
+func (n *offsetNode) offset32(target *offsetNode) {
+	n.offset(target, 4)
+}
+
+func (n *offsetNode) offset(target *offsetNode, size int) {
+	if target != nil {
+		n.links = append(n.links, &offsetLink{
+			pos:    n.buf.Len(),
+			size:   size,
+			target: target,
+		})
+	}
+	n.buf.Write(make([]byte, size))
+}
+
+// offsetPacker lays out the graph of offsetNode.
+// Identical subgraphs are shared, and the targets of 32-bit offsets are placed after all the structures referred by 16-bit offsets,
+// so that Extension lookups can move their large subtables out of the range of 16-bit offsets.
+type offsetPacker struct {
+	ids   map[*offsetNode]int
+	keys  map[string]int
+	out   bytes.Buffer
+	fixes []*offsetFix
+}
+
+type offsetFix struct {
+	at     int
+	size   int
+	parent int
+	target *offsetNode
+}
+
+type offsetSpace struct {
+	placed map[int]int
+}
+
+func packOffsetNode(root *offsetNode) ([]byte, error) {
+	p := &offsetPacker{
+		ids:  make(map[*offsetNode]int),
+		keys: make(map[string]int),
+	}
+	positions := make(map[*offsetNode]int)
+	roots := []*offsetNode{root}
+	for len(roots) > 0 {
+		space := &offsetSpace{
+			placed: make(map[int]int),
+		}
+		type instance struct {
+			node *offsetNode
+			pos  int
+		}
+		queue := []instance{{node: roots[0], pos: p.place(roots[0])}}
+		positions[roots[0]] = queue[0].pos
+		space.placed[p.id(roots[0])] = queue[0].pos
+		roots = roots[1:]
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, l := range cur.node.links {
+				if 4 == l.size {
+					p.fixes = append(p.fixes, &offsetFix{
+						at:     cur.pos + l.pos,
+						size:   l.size,
+						parent: cur.pos,
+						target: l.target,
+					})
+					if _, ok := positions[l.target]; !ok {
+						positions[l.target] = -1
+						roots = append(roots, l.target)
+					}
+					continue
+				}
+				id := p.id(l.target)
+				pos, ok := space.placed[id]
+				if !ok || pos <= cur.pos {
+					pos = p.place(l.target)
+					space.placed[id] = pos
+					queue = append(queue, instance{node: l.target, pos: pos})
+				}
+				if err := p.patch(cur.pos+l.pos, l.size, pos-cur.pos); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	b := p.out.Bytes()
+	for _, fix := range p.fixes {
+		pos := positions[fix.target]
+		binary.BigEndian.PutUint32(b[fix.at:], uint32(pos-fix.parent))
+	}
+	return b, nil
+}
+
+// id returns the identifier of the node, that is equal for identical subgraphs.
+func (p *offsetPacker) id(n *offsetNode) int {
+	if id, ok := p.ids[n]; ok {
+		return id
+	}
+	key := bytes.NewBuffer(append([]byte{}, n.buf.Bytes()...))
+	for _, l := range n.links {
+		key.WriteString("|" + strconv.Itoa(l.pos) + ":" + strconv.Itoa(l.size) + ":" + strconv.Itoa(p.id(l.target)))
+	}
+	id, ok := p.keys[key.String()]
+	if !ok {
+		id = len(p.keys)
+		p.keys[key.String()] = id
+	}
+	p.ids[n] = id
+	return id
+}
+
+func (p *offsetPacker) place(n *offsetNode) int {
+	pos := p.out.Len()
+	p.out.Write(n.buf.Bytes())
+	return pos
+}
+
+func (p *offsetPacker) patch(at, size, offset int) error {
+	if offset > 0xFFFF {
+		return fmt.Errorf("offset overflow: %d", offset)
+	}
+	b := p.out.Bytes()
+	binary.BigEndian.PutUint16(b[at:], uint16(offset))
+	return nil
+}
+
+// LayoutTable is the common structure of "GSUB" and "GPOS" tables.
+type LayoutTable struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// The scripts and language systems supported by the font.
+	ScriptList []*ScriptRecord
+	// The typographic features of the font.
+	FeatureList []*FeatureRecord
+	// The lookups of the font.
+	LookupList []*Lookup
+	// Alternate feature tables for variable fonts, only in version 1.1.
+	FeatureVariations *FeatureVariations
+}
+
+type lookupSubtableParser func(r *tableReader, lookupType uint16, offset int64) (LookupSubtable, error)
+
+func parseLayoutTable(r *tableReader, extensionType uint16, parser lookupSubtableParser) (t *LayoutTable, err error) {
+	t = &LayoutTable{}
+	t.MajorVersion = r.uint16()
+	t.MinorVersion = r.uint16()
+	scriptListOffset := r.uint16()
+	featureListOffset := r.uint16()
+	lookupListOffset := r.uint16()
+	featureVariationsOffset := uint32(0)
+	if 1 == t.MajorVersion && 1 <= t.MinorVersion {
+		featureVariationsOffset = r.uint32()
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	if 0 != scriptListOffset {
+		t.ScriptList = parseScriptList(r, int64(scriptListOffset))
+	}
+	if 0 != featureListOffset {
+		t.FeatureList = parseFeatureList(r, int64(featureListOffset))
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse layout table: %s")
+	}
+	if 0 != lookupListOffset {
+		t.LookupList, err = parseLookupList(r, int64(lookupListOffset), extensionType, parser)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if 0 != featureVariationsOffset {
+		t.FeatureVariations = parseFeatureVariations(r, int64(featureVariationsOffset))
+	}
+	return t, r.errorf("failed to parse layout table: %s")
+}
+
+// pack creates the binary expression of this table.
+// If the offsets overflow, the lookups are packed with Extension lookups of extensionType.
+func (t *LayoutTable) pack(extensionType uint16) ([]byte, error) {
+	b, err := packOffsetNode(t.node(0))
+	if err != nil {
+		return packOffsetNode(t.node(extensionType))
+	}
+	return b, nil
+}
+
+func (t *LayoutTable) node(extensionType uint16) *offsetNode {
+	n := &offsetNode{}
+	minorVersion := t.MinorVersion
+	if t.FeatureVariations == nil && 1 == minorVersion {
+		minorVersion = 0
+	}
+	if t.FeatureVariations != nil {
+		minorVersion = 1
+	}
+	n.uint16(1)
+	n.uint16(minorVersion)
+	n.offset16(scriptListNode(t.ScriptList))
+	n.offset16(featureListNode(t.FeatureList))
+	n.offset16(lookupListNode(t.LookupList, extensionType))
+	if t.FeatureVariations != nil {
+		n.offset32(t.FeatureVariations.node())
+	}
+	return n
+}
+
+// clone returns a deep copy of this table.
+func (t *LayoutTable) clone() *LayoutTable {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	c.ScriptList = cloneScriptList(t.ScriptList)
+	c.FeatureList = cloneFeatureList(t.FeatureList)
+	c.LookupList = make([]*Lookup, len(t.LookupList))
+	for i, l := range t.LookupList {
+		c.LookupList[i] = l.clone()
+	}
+	c.FeatureVariations = t.FeatureVariations.clone()
+	return &c
+}
+
+// featureLookups returns the indices of the lookups referenced by the features, including the alternate features for variable fonts.
+func (t *LayoutTable) featureLookups() []uint16 {
+	indices := make([]uint16, 0)
+	for _, fr := range t.FeatureList {
+		indices = append(indices, fr.Feature.LookupListIndices...)
+	}
+	if t.FeatureVariations != nil {
+		for _, record := range t.FeatureVariations.FeatureVariationRecords {
+			for _, sub := range record.Substitutions {
+				indices = append(indices, sub.Feature.LookupListIndices...)
+			}
+		}
+	}
+	return indices
+}
+
+// reachableLookups returns the lookups that are referenced by the roots directly or through contextual subtables.
+func reachableLookups(lookups []*Lookup, roots []uint16) []bool {
+	reachable := make([]bool, len(lookups))
+	queue := append([]uint16{}, roots...)
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if int(i) >= len(lookups) || reachable[i] {
+			continue
+		}
+		reachable[i] = true
+		for _, st := range lookups[i].Subtables {
+			if c, ok := st.(*SequenceContext); ok {
+				queue = append(queue, c.lookupIndices()...)
+			}
+		}
+	}
+	return reachable
+}
+
+// filter returns the layout table for the glyphs of a subset.
+// m maps the glyph IDs of the original font to the ones of the subset.
+// Lookups, features, language systems and scripts that become empty or unreachable are removed, and the indices referring to them are remapped.
+func (t *LayoutTable) filter(m map[uint16]uint16) *LayoutTable {
+	if t == nil {
+		return nil
+	}
+	lookups := make([]*Lookup, len(t.LookupList))
+	for i, l := range t.LookupList {
+		nl := &Lookup{
+			LookupType:       l.LookupType,
+			LookupFlag:       l.LookupFlag,
+			Subtables:        make([]LookupSubtable, 0, len(l.Subtables)),
+			MarkFilteringSet: l.MarkFilteringSet,
+		}
+		for _, st := range l.Subtables {
+			if nst := st.filter(m); nst != nil {
+				nl.Subtables = append(nl.Subtables, nst)
+			}
+		}
+		lookups[i] = nl
+	}
+	reachable := reachableLookups(lookups, t.featureLookups())
+	lm := make(map[uint16]uint16)
+	nt := &LayoutTable{
+		MajorVersion: t.MajorVersion,
+		MinorVersion: t.MinorVersion,
+		LookupList:   make([]*Lookup, 0, len(lookups)),
+	}
+	for i, l := range lookups {
+		if reachable[i] && 0 < len(l.Subtables) {
+			lm[uint16(i)] = uint16(len(nt.LookupList))
+			nt.LookupList = append(nt.LookupList, l)
+		}
+	}
+	for _, l := range nt.LookupList {
+		for _, st := range l.Subtables {
+			if c, ok := st.(*SequenceContext); ok {
+				c.remapLookups(lm)
+			}
+		}
+	}
+	features := make([]*Feature, len(t.FeatureList))
+	keep := make([]bool, len(t.FeatureList))
+	for i, fr := range t.FeatureList {
+		features[i] = fr.Feature.filter(lm)
+		keep[i] = 0 < len(features[i].LookupListIndices) || fr.Feature.FeatureParams != nil
+	}
+	var fv *FeatureVariations
+	if t.FeatureVariations != nil {
+		fv = &FeatureVariations{
+			MajorVersion:            t.FeatureVariations.MajorVersion,
+			MinorVersion:            t.FeatureVariations.MinorVersion,
+			FeatureVariationRecords: make([]*FeatureVariationRecord, len(t.FeatureVariations.FeatureVariationRecords)),
+		}
+		for i, record := range t.FeatureVariations.FeatureVariationRecords {
+			nr := &FeatureVariationRecord{
+				ConditionSet:  make([]*Condition, len(record.ConditionSet)),
+				Substitutions: make([]*FeatureTableSubstitution, 0, len(record.Substitutions)),
+			}
+			for j, cond := range record.ConditionSet {
+				cc := *cond
+				nr.ConditionSet[j] = &cc
+			}
+			for _, sub := range record.Substitutions {
+				ns := &FeatureTableSubstitution{
+					FeatureIndex: sub.FeatureIndex,
+					Feature:      sub.Feature.filter(lm),
+				}
+				if int(ns.FeatureIndex) < len(keep) && 0 < len(ns.Feature.LookupListIndices) {
+					keep[ns.FeatureIndex] = true
+				}
+				nr.Substitutions = append(nr.Substitutions, ns)
+			}
+			fv.FeatureVariationRecords[i] = nr
+		}
+	}
+	fm := make(map[uint16]uint16)
+	nt.FeatureList = make([]*FeatureRecord, 0, len(t.FeatureList))
+	for i, fr := range t.FeatureList {
+		if keep[i] {
+			fm[uint16(i)] = uint16(len(nt.FeatureList))
+			nt.FeatureList = append(nt.FeatureList, &FeatureRecord{
+				Tag:     fr.Tag,
+				Feature: features[i],
+			})
+		}
+	}
+	if fv != nil {
+		for _, record := range fv.FeatureVariationRecords {
+			subs := make([]*FeatureTableSubstitution, 0, len(record.Substitutions))
+			for _, sub := range record.Substitutions {
+				if n, ok := fm[sub.FeatureIndex]; ok {
+					sub.FeatureIndex = n
+					subs = append(subs, sub)
+				}
+			}
+			record.Substitutions = subs
+		}
+		nt.FeatureVariations = fv
+	}
+	nt.ScriptList = make([]*ScriptRecord, 0, len(t.ScriptList))
+	for _, sr := range t.ScriptList {
+		s := &Script{
+			DefaultLangSys: sr.Script.DefaultLangSys.filter(fm),
+			LangSysRecords: make([]*LangSysRecord, 0, len(sr.Script.LangSysRecords)),
+		}
+		for _, lsr := range sr.Script.LangSysRecords {
+			if ls := lsr.LangSys.filter(fm); ls != nil {
+				s.LangSysRecords = append(s.LangSysRecords, &LangSysRecord{
+					Tag:     lsr.Tag,
+					LangSys: ls,
+				})
+			}
+		}
+		if s.DefaultLangSys != nil || 0 < len(s.LangSysRecords) {
+			nt.ScriptList = append(nt.ScriptList, &ScriptRecord{
+				Tag:    sr.Tag,
+				Script: s,
+			})
+		}
+	}
+	return nt
+}
+
+// Script returns the script table of the script tag, or nil if it is not found.
+func (t *LayoutTable) Script(script Tag) *Script {
+	for _, sr := range t.ScriptList {
+		if sr.Tag == script {
+			return sr.Script
+		}
+	}
+	return nil
+}
+
+// LangSys returns the language system of the script and the language.
+// If the script is not found, the "DFLT" script is used, and if the language is not found, the default language system of the script is used.
+func (t *LayoutTable) LangSys(script, lang Tag) *LangSys {
+	s := t.Script(script)
+	if s == nil {
+		s = t.Script(ScriptTagDefault)
+	}
+	if s == nil {
+		return nil
+	}
+	for _, lsr := range s.LangSysRecords {
+		if lsr.Tag == lang {
+			return lsr.LangSys
+		}
+	}
+	return s.DefaultLangSys
+}
+
+// hasFeature returns true if the feature list has the feature of the tag.
+func (t *LayoutTable) hasFeature(tag Tag) bool {
+	for _, fr := range t.FeatureList {
+		if tag == fr.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Features returns the features of the language system of the script and the language.
+// The required feature comes first if it exists.
+func (t *LayoutTable) Features(script, lang Tag) []*FeatureRecord {
+	ls := t.LangSys(script, lang)
+	if ls == nil {
+		return []*FeatureRecord{}
+	}
+	ret := make([]*FeatureRecord, 0, len(ls.FeatureIndices)+1)
+	if RequiredFeatureIndexNone != ls.RequiredFeatureIndex && int(ls.RequiredFeatureIndex) < len(t.FeatureList) {
+		ret = append(ret, t.FeatureList[ls.RequiredFeatureIndex])
+	}
+	for _, i := range ls.FeatureIndices {
+		if int(i) < len(t.FeatureList) {
+			ret = append(ret, t.FeatureList[i])
+		}
+	}
+	return ret
+}
+
+const (
+	// ScriptTagDefault : the default script, that is used when the script is not listed in the script list.
+	ScriptTagDefault = Tag(0x44464C54) // DFLT
+	// LangSysTagDefault : the tag that represents the default language system.
+	LangSysTagDefault = Tag(0x64666C74) // dflt
+	// RequiredFeatureIndexNone : no feature is required by the language system.
+	RequiredFeatureIndexNone = uint16(0xFFFF)
+)
+
+// ScriptRecord is a script supported by the font.
+type ScriptRecord struct {
+	// Script tag identifier.
+	Tag    Tag
+	Script *Script
+}
+
+// Script is a script table that identifies each language system that defines how to use the glyphs in a script.
+type Script struct {
+	// The default language system, may be nil.
+	DefaultLangSys *LangSys
+	// Language systems, in alphabetical order by tag.
+	LangSysRecords []*LangSysRecord
+}
+
+// LangSysRecord is a language system of the script.
+type LangSysRecord struct {
+	// Language system tag identifier.
+	Tag     Tag
+	LangSys *LangSys
+}
+
+// LangSys is a language system table that references the features for rendering the language.
+type LangSys struct {
+	// Index of a feature required for this language system, or 0xFFFF.
+	RequiredFeatureIndex uint16
+	// Array of indices into the FeatureList, in arbitrary order.
+	FeatureIndices []uint16
+}
+
+func parseScriptList(r *tableReader, offset int64) []*ScriptRecord {
+	r.seek(offset)
+	count := r.uint16()
+	srs := make([]*ScriptRecord, count)
+	offsets := make([]uint16, count)
+	for i := range srs {
+		srs[i] = &ScriptRecord{}
+		r.read(&(srs[i].Tag))
+		offsets[i] = r.uint16()
+	}
+	for i, sr := range srs {
+		sr.Script = parseScript(r, offset+int64(offsets[i]))
+	}
+	return srs
+}
+
+func parseScript(r *tableReader, offset int64) *Script {
+	s := &Script{}
+	r.seek(offset)
+	defaultLangSysOffset := r.uint16()
+	count := r.uint16()
+	s.LangSysRecords = make([]*LangSysRecord, count)
+	offsets := make([]uint16, count)
+	for i := range s.LangSysRecords {
+		s.LangSysRecords[i] = &LangSysRecord{}
+		r.read(&(s.LangSysRecords[i].Tag))
+		offsets[i] = r.uint16()
+	}
+	if 0 != defaultLangSysOffset {
+		s.DefaultLangSys = parseLangSys(r, offset+int64(defaultLangSysOffset))
+	}
+	for i, lsr := range s.LangSysRecords {
+		lsr.LangSys = parseLangSys(r, offset+int64(offsets[i]))
+	}
+	return s
+}
+
+func parseLangSys(r *tableReader, offset int64) *LangSys {
+	ls := &LangSys{}
+	r.seek(offset)
+	// lookupOrderOffset is reserved.
+	r.uint16()
+	ls.RequiredFeatureIndex = r.uint16()
+	ls.FeatureIndices = r.uint16s(int(r.uint16()))
+	return ls
+}
+
+func scriptListNode(srs []*ScriptRecord) *offsetNode {
+	n := &offsetNode{}
+	n.uint16(uint16(len(srs)))
+	for _, sr := range srs {
+		n.uint32(uint32(sr.Tag))
+		s := &offsetNode{}
+		if sr.Script.DefaultLangSys != nil {
+			s.offset16(sr.Script.DefaultLangSys.node())
+		} else {
+			s.uint16(0)
+		}
+		s.uint16(uint16(len(sr.Script.LangSysRecords)))
+		for _, lsr := range sr.Script.LangSysRecords {
+			s.uint32(uint32(lsr.Tag))
+			s.offset16(lsr.LangSys.node())
+		}
+		n.offset16(s)
+	}
+	return n
+}
+
+func (ls *LangSys) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(0)
+	n.uint16(ls.RequiredFeatureIndex)
+	n.uint16(uint16(len(ls.FeatureIndices)))
+	n.uint16s(ls.FeatureIndices)
+	return n
+}
+
+func (ls *LangSys) clone() *LangSys {
+	if ls == nil {
+		return nil
+	}
+	return &LangSys{
+		RequiredFeatureIndex: ls.RequiredFeatureIndex,
+		FeatureIndices:       append([]uint16{}, ls.FeatureIndices...),
+	}
+}
+
+// filter returns the language system with the feature indices remapped by fm, or nil if no feature remains.
+func (ls *LangSys) filter(fm map[uint16]uint16) *LangSys {
+	if ls == nil {
+		return nil
+	}
+	n := &LangSys{
+		RequiredFeatureIndex: RequiredFeatureIndexNone,
+		FeatureIndices:       make([]uint16, 0, len(ls.FeatureIndices)),
+	}
+	if i, ok := fm[ls.RequiredFeatureIndex]; ok && RequiredFeatureIndexNone != ls.RequiredFeatureIndex {
+		n.RequiredFeatureIndex = i
+	}
+	for _, fi := range ls.FeatureIndices {
+		if i, ok := fm[fi]; ok {
+			n.FeatureIndices = append(n.FeatureIndices, i)
+		}
+	}
+	if RequiredFeatureIndexNone == n.RequiredFeatureIndex && 0 == len(n.FeatureIndices) {
+		return nil
+	}
+	return n
+}
+
+func cloneScriptList(srs []*ScriptRecord) []*ScriptRecord {
+	c := make([]*ScriptRecord, len(srs))
+	for i, sr := range srs {
+		s := &Script{
+			DefaultLangSys: sr.Script.DefaultLangSys.clone(),
+			LangSysRecords: make([]*LangSysRecord, len(sr.Script.LangSysRecords)),
+		}
+		for j, lsr := range sr.Script.LangSysRecords {
+			s.LangSysRecords[j] = &LangSysRecord{
+				Tag:     lsr.Tag,
+				LangSys: lsr.LangSys.clone(),
+			}
+		}
+		c[i] = &ScriptRecord{
+			Tag:    sr.Tag,
+			Script: s,
+		}
+	}
+	return c
+}
+
+// FeatureRecord is a feature of the font.
+type FeatureRecord struct {
+	// Feature tag identifier.
+	Tag     Tag
+	Feature *Feature
+}
+
+// Feature is a feature table that lists the lookups of the feature.
+type Feature struct {
+	// Binary expression of the feature parameters table, that is defined for "size", "ssXX" and "cvXX" features, or nil.
+	FeatureParams []byte
+	// Array of indices into the LookupList.
+	LookupListIndices []uint16
+}
+
+func parseFeatureList(r *tableReader, offset int64) []*FeatureRecord {
+	r.seek(offset)
+	count := r.uint16()
+	frs := make([]*FeatureRecord, count)
+	offsets := make([]uint16, count)
+	for i := range frs {
+		frs[i] = &FeatureRecord{}
+		r.read(&(frs[i].Tag))
+		offsets[i] = r.uint16()
+	}
+	for i, fr := range frs {
+		fr.Feature = parseFeature(r, offset+int64(offsets[i]), fr.Tag)
+	}
+	return frs
+}
+
+func parseFeature(r *tableReader, offset int64, tag Tag) *Feature {
+	f := &Feature{}
+	r.seek(offset)
+	featureParamsOffset := r.uint16()
+	f.LookupListIndices = r.uint16s(int(r.uint16()))
+	if 0 != featureParamsOffset {
+		r.seek(offset + int64(featureParamsOffset))
+		name := tag.String()
+		switch {
+		case "size" == name:
+			f.FeatureParams = make([]byte, 10)
+		case "ss" == name[:2]:
+			f.FeatureParams = make([]byte, 4)
+		case "cv" == name[:2]:
+			b := make([]byte, 14)
+			r.read(b)
+			charCount := binary.BigEndian.Uint16(b[12:])
+			r.seek(offset + int64(featureParamsOffset))
+			f.FeatureParams = make([]byte, 14+3*int(charCount))
+		default:
+			return f
+		}
+		r.read(f.FeatureParams)
+	}
+	return f
+}
+
+func featureListNode(frs []*FeatureRecord) *offsetNode {
+	n := &offsetNode{}
+	n.uint16(uint16(len(frs)))
+	for _, fr := range frs {
+		n.uint32(uint32(fr.Tag))
+		n.offset16(fr.Feature.node())
+	}
+	return n
+}
+
+func (f *Feature) node() *offsetNode {
+	n := &offsetNode{}
+	if f.FeatureParams != nil {
+		p := &offsetNode{}
+		p.bytes(f.FeatureParams)
+		n.offset16(p)
+	} else {
+		n.uint16(0)
+	}
+	n.uint16(uint16(len(f.LookupListIndices)))
+	n.uint16s(f.LookupListIndices)
+	return n
+}
+
+func (f *Feature) clone() *Feature {
+	c := &Feature{
+		LookupListIndices: append([]uint16{}, f.LookupListIndices...),
+	}
+	if f.FeatureParams != nil {
+		c.FeatureParams = append([]byte{}, f.FeatureParams...)
+	}
+	return c
+}
+
+// filter returns the feature with the lookup indices remapped by lm.
+func (f *Feature) filter(lm map[uint16]uint16) *Feature {
+	n := f.clone()
+	n.LookupListIndices = make([]uint16, 0, len(f.LookupListIndices))
+	for _, li := range f.LookupListIndices {
+		if i, ok := lm[li]; ok {
+			n.LookupListIndices = append(n.LookupListIndices, i)
+		}
+	}
+	return n
+}
+
+func cloneFeatureList(frs []*FeatureRecord) []*FeatureRecord {
+	c := make([]*FeatureRecord, len(frs))
+	for i, fr := range frs {
+		c[i] = &FeatureRecord{
+			Tag:     fr.Tag,
+			Feature: fr.Feature.clone(),
+		}
+	}
+	return c
+}
+
+// Lookup is a lookup table that defines the specific conditions, type, and results of a substitution or positioning action.
+type Lookup struct {
+	// Different enumerations for GSUB and GPOS.
+	// Extension lookups are resolved, so that this is the type of the extension subtables.
+	LookupType uint16
+	// Lookup qualifiers.
+	LookupFlag uint16
+	// Array of subtables.
+	Subtables []LookupSubtable
+	// Index (base 0) into GDEF mark glyph sets structure, used only if UseMarkFilteringSet is set.
+	MarkFilteringSet uint16
+}
+
+// LookupSubtable is a subtable of a lookup.
+type LookupSubtable interface {
+	// node creates the binary expression of this subtable.
+	node() *offsetNode
+	// clone returns a deep copy of this subtable.
+	cloneSubtable() LookupSubtable
+	// filter returns the subtable for the glyphs of a subset, or nil if nothing remains.
+	// m maps the glyph IDs of the original font to the ones of the subset.
+	filter(m map[uint16]uint16) LookupSubtable
+}
+
+const (
+	// LookupFlagRightToLeft : the last glyph in the sequence is the one attached, for cursive attachment.
+	LookupFlagRightToLeft = uint16(0x0001)
+	// LookupFlagIgnoreBaseGlyphs : skips over base glyphs.
+	LookupFlagIgnoreBaseGlyphs = uint16(0x0002)
+	// LookupFlagIgnoreLigatures : skips over ligatures.
+	LookupFlagIgnoreLigatures = uint16(0x0004)
+	// LookupFlagIgnoreMarks : skips over all combining marks.
+	LookupFlagIgnoreMarks = uint16(0x0008)
+	// LookupFlagUseMarkFilteringSet : the lookup table structure is followed by a MarkFilteringSet field.
+	LookupFlagUseMarkFilteringSet = uint16(0x0010)
+	// LookupFlagMarkAttachmentTypeMask : if not zero, skips over all marks of attachment type different from specified.
+	LookupFlagMarkAttachmentTypeMask = uint16(0xFF00)
+)
+
+func parseLookupList(r *tableReader, offset int64, extensionType uint16, parser lookupSubtableParser) ([]*Lookup, error) {
+	r.seek(offset)
+	offsets := r.uint16s(int(r.uint16()))
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse lookup list: %s")
+	}
+	ls := make([]*Lookup, len(offsets))
+	for i, o := range offsets {
+		l, err := parseLookup(r, offset+int64(o), extensionType, parser)
+		if err != nil {
+			return nil, fmt.Errorf("lookup %d: %s", i, err)
+		}
+		ls[i] = l
+	}
+	return ls, nil
+}
+
+func parseLookup(r *tableReader, offset int64, extensionType uint16, parser lookupSubtableParser) (l *Lookup, err error) {
+	l = &Lookup{}
+	r.seek(offset)
+	l.LookupType = r.uint16()
+	l.LookupFlag = r.uint16()
+	offsets := r.uint16s(int(r.uint16()))
+	if l.LookupFlag&LookupFlagUseMarkFilteringSet != 0 {
+		l.MarkFilteringSet = r.uint16()
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse lookup: %s")
+	}
+	l.Subtables = make([]LookupSubtable, len(offsets))
+	for i, o := range offsets {
+		lookupType := l.LookupType
+		subtableOffset := offset + int64(o)
+		if extensionType == lookupType {
+			r.seek(subtableOffset)
+			format := r.uint16()
+			lookupType = r.uint16()
+			extensionOffset := r.uint32()
+			if r.hasErr() {
+				return nil, r.errorf("failed to parse extension subtable: %s")
+			}
+			if 1 != format {
+				return nil, fmt.Errorf("extension subtable format %d is not supported", format)
+			}
+			subtableOffset += int64(extensionOffset)
+			l.LookupType = lookupType
+		}
+		l.Subtables[i], err = parser(r, lookupType, subtableOffset)
+		if err != nil {
+			return nil, err
+		}
+		if r.hasErr() {
+			return nil, r.errorf("failed to parse lookup subtable: %s")
+		}
+	}
+	return
+}
+
+func lookupListNode(ls []*Lookup, extensionType uint16) *offsetNode {
+	n := &offsetNode{}
+	n.uint16(uint16(len(ls)))
+	for _, l := range ls {
+		n.offset16(l.node(extensionType))
+	}
+	return n
+}
+
+// node creates the binary expression of this lookup.
+// If extensionType is not 0, the subtables are wrapped by Extension subtables.
+func (l *Lookup) node(extensionType uint16) *offsetNode {
+	n := &offsetNode{}
+	if 0 == extensionType {
+		n.uint16(l.LookupType)
+	} else {
+		n.uint16(extensionType)
+	}
+	n.uint16(l.LookupFlag)
+	n.uint16(uint16(len(l.Subtables)))
+	for _, st := range l.Subtables {
+		if 0 == extensionType {
+			n.offset16(st.node())
+			continue
+		}
+		ext := &offsetNode{}
+		ext.uint16(1)
+		ext.uint16(l.LookupType)
+		ext.offset32(st.node())
+		n.offset16(ext)
+	}
+	if l.LookupFlag&LookupFlagUseMarkFilteringSet != 0 {
+		n.uint16(l.MarkFilteringSet)
+	}
+	return n
+}
+
+func (l *Lookup) clone() *Lookup {
+	c := *l
+	c.Subtables = make([]LookupSubtable, len(l.Subtables))
+	for i, st := range l.Subtables {
+		c.Subtables[i] = st.cloneSubtable()
+	}
+	return &c
+}
+
+// Coverage is a coverage table that identifies the glyphs affected by a subtable.
+// The index of a glyph in Glyphs is the coverage index.
+type Coverage struct {
+	// Glyph IDs in numerical order.
+	Glyphs []uint16
+}
+
+func parseCoverage(r *tableReader, offset int64) *Coverage {
+	c := &Coverage{}
+	r.seek(offset)
+	format := r.uint16()
+	switch format {
+	case 1:
+		c.Glyphs = r.uint16s(int(r.uint16()))
+	case 2:
+		count := int(r.uint16())
+		if !r.available(count, 6) {
+			break
+		}
+		c.Glyphs = make([]uint16, 0, count)
+		// the ranges must be in increasing order without overlap, that bounds the number of the glyphs.
+		// The glyphs beyond the font are dropped, that are only at the end.
+		limit, next := r.glyphLimit(), uint32(0)
+		for i := 0; i < count && !r.hasErr(); i++ {
+			start := uint32(r.uint16())
+			end := uint32(r.uint16())
+			// startCoverageIndex is implied by the order of ranges.
+			r.uint16()
+			if start < next || end < start {
+				r.err = fmt.Errorf("coverage ranges are not in increasing order")
+				break
+			}
+			for gid := start; gid <= end && gid < limit; gid++ {
+				c.Glyphs = append(c.Glyphs, uint16(gid))
+			}
+			next = end + 1
+		}
+	default:
+		if !r.hasErr() {
+			r.err = fmt.Errorf("coverage format %d is not supported", format)
+		}
+	}
+	return c
+}
+
+// Index returns the coverage index of the glyph.
+func (c *Coverage) Index(gid uint16) (int, bool) {
+	i := sort.Search(len(c.Glyphs), func(i int) bool {
+		return c.Glyphs[i] >= gid
+	})
+	if i < len(c.Glyphs) && c.Glyphs[i] == gid {
+		return i, true
+	}
+	return -1, false
+}
+
+// Contains returns true if the glyph is covered.
+func (c *Coverage) Contains(gid uint16) bool {
+	_, ok := c.Index(gid)
+	return ok
+}
+
+// node creates the binary expression of this coverage, in the smaller format.
+func (c *Coverage) node() *offsetNode {
+	n := &offsetNode{}
+	ranges := make([][2]uint16, 0)
+	for _, gid := range c.Glyphs {
+		if len(ranges) > 0 && ranges[len(ranges)-1][1]+1 == gid {
+			ranges[len(ranges)-1][1] = gid
+		} else {
+			ranges = append(ranges, [2]uint16{gid, gid})
+		}
+	}
+	if 6*len(ranges) < 2*len(c.Glyphs) {
+		n.uint16(2)
+		n.uint16(uint16(len(ranges)))
+		index := uint16(0)
+		for _, rg := range ranges {
+			n.uint16(rg[0])
+			n.uint16(rg[1])
+			n.uint16(index)
+			index += rg[1] - rg[0] + 1
+		}
+		return n
+	}
+	n.uint16(1)
+	n.uint16(uint16(len(c.Glyphs)))
+	n.uint16s(c.Glyphs)
+	return n
+}
+
+func (c *Coverage) clone() *Coverage {
+	if c == nil {
+		return nil
+	}
+	return &Coverage{
+		Glyphs: append([]uint16{}, c.Glyphs...),
+	}
+}
+
+// filter returns the coverage for the glyphs of a subset,
+// and the original coverage indices in the order of the new coverage.
+func (c *Coverage) filter(m map[uint16]uint16) (*Coverage, []int) {
+	type entry struct {
+		gid   uint16
+		index int
+	}
+	entries := make([]entry, 0, len(c.Glyphs))
+	for i, gid := range c.Glyphs {
+		if n, ok := m[gid]; ok {
+			entries = append(entries, entry{gid: n, index: i})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].gid < entries[j].gid
+	})
+	nc := &Coverage{
+		Glyphs: make([]uint16, len(entries)),
+	}
+	indices := make([]int, len(entries))
+	for i, e := range entries {
+		nc.Glyphs[i] = e.gid
+		indices[i] = e.index
+	}
+	return nc, indices
+}
+
+func parseCoverages(r *tableReader, offset int64, offsets []uint16) []*Coverage {
+	cs := make([]*Coverage, len(offsets))
+	for i, o := range offsets {
+		cs[i] = parseCoverage(r, offset+int64(o))
+	}
+	return cs
+}
+
+func cloneCoverages(cs []*Coverage) []*Coverage {
+	c := make([]*Coverage, len(cs))
+	for i, cov := range cs {
+		c[i] = cov.clone()
+	}
+	return c
+}
+
+// filterCoverages returns the coverages for the glyphs of a subset, and false if any of them becomes empty.
+func filterCoverages(cs []*Coverage, m map[uint16]uint16) ([]*Coverage, bool) {
+	c := make([]*Coverage, len(cs))
+	for i, cov := range cs {
+		c[i], _ = cov.filter(m)
+		if 0 == len(c[i].Glyphs) {
+			return nil, false
+		}
+	}
+	return c, true
+}
+
+// filterGlyphs returns the glyph IDs of a subset, and false if any of the glyphs is not in the subset.
+func filterGlyphs(gids []uint16, m map[uint16]uint16) ([]uint16, bool) {
+	ret := make([]uint16, len(gids))
+	for i, gid := range gids {
+		n, ok := m[gid]
+		if !ok {
+			return nil, false
+		}
+		ret[i] = n
+	}
+	return ret, true
+}
+
+// ClassDef is a class definition table that groups glyphs into classes.
+// Glyphs not assigned to any class belong to class 0.
+type ClassDef struct {
+	// Class values indexed by glyph ID.
+	Classes map[uint16]uint16
+}
+
+func parseClassDef(r *tableReader, offset int64) *ClassDef {
+	c := &ClassDef{
+		Classes: make(map[uint16]uint16),
+	}
+	r.seek(offset)
+	format := r.uint16()
+	switch format {
+	case 1:
+		start := uint32(r.uint16())
+		values := r.uint16s(int(r.uint16()))
+		limit := r.glyphLimit()
+		for i, v := range values {
+			if 0 != v && start+uint32(i) < limit {
+				c.Classes[uint16(start+uint32(i))] = v
+			}
+		}
+	case 2:
+		count := int(r.uint16())
+		if !r.available(count, 6) {
+			break
+		}
+		// the ranges must be in increasing order without overlap, that bounds the number of the glyphs.
+		limit, next := r.glyphLimit(), uint32(0)
+		for i := 0; i < count && !r.hasErr(); i++ {
+			start := uint32(r.uint16())
+			end := uint32(r.uint16())
+			class := r.uint16()
+			if start < next || end < start {
+				r.err = fmt.Errorf("class ranges are not in increasing order")
+				break
+			}
+			next = end + 1
+			if 0 == class {
+				continue
+			}
+			for gid := start; gid <= end && gid < limit; gid++ {
+				c.Classes[uint16(gid)] = class
+			}
+		}
+	default:
+		if !r.hasErr() {
+			r.err = fmt.Errorf("class definition format %d is not supported", format)
+		}
+	}
+	return c
+}
+
+// Class returns the class of the glyph.
+func (c *ClassDef) Class(gid uint16) uint16 {
+	if c == nil {
+		return 0
+	}
+	return c.Classes[gid]
+}
+
+// Glyphs returns glyph IDs assigned to the class other than 0, in numerical order.
+func (c *ClassDef) Glyphs() []uint16 {
+	gids := make([]uint16, 0, len(c.Classes))
+	for gid, class := range c.Classes {
+		if 0 != class {
+			gids = append(gids, gid)
+		}
+	}
+	sort.Slice(gids, func(i, j int) bool {
+		return gids[i] < gids[j]
+	})
+	return gids
+}
+
+// node creates the binary expression of this class definition, in the smaller format.
+func (c *ClassDef) node() *offsetNode {
+	n := &offsetNode{}
+	gids := c.Glyphs()
+	ranges := make([][3]uint16, 0)
+	for _, gid := range gids {
+		class := c.Classes[gid]
+		if len(ranges) > 0 && ranges[len(ranges)-1][1]+1 == gid && ranges[len(ranges)-1][2] == class {
+			ranges[len(ranges)-1][1] = gid
+		} else {
+			ranges = append(ranges, [3]uint16{gid, gid, class})
+		}
+	}
+	if 0 == len(gids) || 6*len(ranges) < 2*int(gids[len(gids)-1]-gids[0]+1)+2 {
+		n.uint16(2)
+		n.uint16(uint16(len(ranges)))
+		for _, rg := range ranges {
+			n.uint16(rg[0])
+			n.uint16(rg[1])
+			n.uint16(rg[2])
+		}
+		return n
+	}
+	n.uint16(1)
+	n.uint16(gids[0])
+	n.uint16(gids[len(gids)-1] - gids[0] + 1)
+	for gid := uint32(gids[0]); gid <= uint32(gids[len(gids)-1]); gid++ {
+		n.uint16(c.Classes[uint16(gid)])
+	}
+	return n
+}
+
+func (c *ClassDef) clone() *ClassDef {
+	if c == nil {
+		return nil
+	}
+	cd := &ClassDef{
+		Classes: make(map[uint16]uint16, len(c.Classes)),
+	}
+	for gid, class := range c.Classes {
+		cd.Classes[gid] = class
+	}
+	return cd
+}
+
+// filter returns the class definition for the glyphs of a subset.
+func (c *ClassDef) filter(m map[uint16]uint16) *ClassDef {
+	if c == nil {
+		return nil
+	}
+	cd := &ClassDef{
+		Classes: make(map[uint16]uint16),
+	}
+	for gid, class := range c.Classes {
+		if n, ok := m[gid]; ok {
+			cd.Classes[n] = class
+		}
+	}
+	return cd
+}
+
+// Device is a device table that adjusts a value at specific sizes in pixels per em,
+// or a VariationIndex table that refers to the delta-set in the ItemVariationStore for variable fonts.
+type Device struct {
+	// Smallest size to correct, in ppem.
+	StartSize uint16
+	// Largest size to correct, in ppem.
+	EndSize uint16
+	// Format of deltaValue array data: 1, 2 or 3, or 0x8000 for the VariationIndex table.
+	DeltaFormat uint16
+	// Adjustments for each size from StartSize to EndSize.
+	DeltaValues []int8
+	// A delta-set outer index, used only for the VariationIndex table.
+	DeltaSetOuterIndex uint16
+	// A delta-set inner index, used only for the VariationIndex table.
+	DeltaSetInnerIndex uint16
+}
+
+const (
+	// DeltaFormatLocal2BitDeltas : signed 2-bit value, 8 values per uint16.
+	DeltaFormatLocal2BitDeltas = uint16(0x0001)
+	// DeltaFormatLocal4BitDeltas : signed 4-bit value, 4 values per uint16.
+	DeltaFormatLocal4BitDeltas = uint16(0x0002)
+	// DeltaFormatLocal8BitDeltas : signed 8-bit value, 2 values per uint16.
+	DeltaFormatLocal8BitDeltas = uint16(0x0003)
+	// DeltaFormatVariationIndex : VariationIndex table, contains a delta-set index pair.
+	DeltaFormatVariationIndex = uint16(0x8000)
+)
+
+// IsVariationIndex returns true if this is a VariationIndex table.
+func (d *Device) IsVariationIndex() bool {
+	return DeltaFormatVariationIndex == d.DeltaFormat
+}
+
+// Delta returns the adjustment at the size in ppem.
+func (d *Device) Delta(ppem uint16) int {
+	if d == nil || d.IsVariationIndex() || ppem < d.StartSize || ppem > d.EndSize {
+		return 0
+	}
+	i := int(ppem - d.StartSize)
+	if i >= len(d.DeltaValues) {
+		return 0
+	}
+	return int(d.DeltaValues[i])
+}
+
+func parseDevice(r *tableReader, offset int64) *Device {
+	d := &Device{}
+	r.seek(offset)
+	d.StartSize = r.uint16()
+	d.EndSize = r.uint16()
+	d.DeltaFormat = r.uint16()
+	if d.IsVariationIndex() {
+		d.DeltaSetOuterIndex, d.DeltaSetInnerIndex = d.StartSize, d.EndSize
+		d.StartSize, d.EndSize = 0, 0
+		return d
+	}
+	if d.DeltaFormat < DeltaFormatLocal2BitDeltas || d.DeltaFormat > DeltaFormatLocal8BitDeltas || d.EndSize < d.StartSize {
+		return d
+	}
+	bits := uint(1) << d.DeltaFormat
+	count := int(d.EndSize-d.StartSize) + 1
+	words := r.uint16s((count*int(bits) + 15) / 16)
+	d.DeltaValues = make([]int8, count)
+	for i := range d.DeltaValues {
+		word := words[i*int(bits)/16]
+		shift := 16 - bits - uint(i*int(bits)%16)
+		v := int16(word<<(16-bits-shift)) >> (16 - bits)
+		d.DeltaValues[i] = int8(v)
+	}
+	return d
+}
+
+func (d *Device) node() *offsetNode {
+	n := &offsetNode{}
+	if d.IsVariationIndex() {
+		n.uint16(d.DeltaSetOuterIndex)
+		n.uint16(d.DeltaSetInnerIndex)
+		n.uint16(d.DeltaFormat)
+		return n
+	}
+	n.uint16(d.StartSize)
+	n.uint16(d.EndSize)
+	n.uint16(d.DeltaFormat)
+	if d.DeltaFormat < DeltaFormatLocal2BitDeltas || d.DeltaFormat > DeltaFormatLocal8BitDeltas {
+		return n
+	}
+	bits := uint(1) << d.DeltaFormat
+	words := make([]uint16, (len(d.DeltaValues)*int(bits)+15)/16)
+	mask := uint16(1)<<bits - 1
+	for i, v := range d.DeltaValues {
+		shift := 16 - bits - uint(i*int(bits)%16)
+		words[i*int(bits)/16] |= (uint16(v) & mask) << shift
+	}
+	n.uint16s(words)
+	return n
+}
+
+func (d *Device) clone() *Device {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	c.DeltaValues = append([]int8{}, d.DeltaValues...)
+	return &c
+}
+
+// SequenceLookupRecord applies the lookup at the position of the input sequence.
+type SequenceLookupRecord struct {
+	// Index (zero-based) into the input glyph sequence.
+	SequenceIndex uint16
+	// Index (zero-based) into the LookupList.
+	LookupListIndex uint16
+}
+
+// SequenceRule is a rule of the sequence context.
+// Sequences consist of glyph IDs for format 1 and class values for format 2.
+type SequenceRule struct {
+	// Backtrack sequence, in the order of the distance from the input sequence, only for chained contexts.
+	Backtrack []uint16
+	// Input sequence, beginning with the second glyph.
+	Input []uint16
+	// Lookahead sequence, only for chained contexts.
+	Lookahead []uint16
+	// Array of sequence lookup records.
+	SeqLookupRecords []*SequenceLookupRecord
+}
+
+// SequenceContext is a contextual or a chained contextual subtable, that is common to GSUB and GPOS.
+type SequenceContext struct {
+	// True if this is a chained contextual subtable.
+	Chained bool
+	// Format identifier: 1 for glyph sequences, 2 for class sequences, 3 for coverage sequences.
+	Format uint16
+	// Coverage of the first glyph of the input sequence, for format 1 and 2.
+	Coverage *Coverage
+	// Rule sets indexed by coverage index for format 1, and by class of the first input glyph for format 2.
+	RuleSets [][]*SequenceRule
+	// Class definitions of format 2.
+	BacktrackClassDef *ClassDef
+	InputClassDef     *ClassDef
+	LookaheadClassDef *ClassDef
+	// Coverages of format 3.
+	BacktrackCoverages []*Coverage
+	InputCoverages     []*Coverage
+	LookaheadCoverages []*Coverage
+	// Sequence lookup records of format 3.
+	SeqLookupRecords []*SequenceLookupRecord
+}
+
+func parseSequenceLookupRecords(r *tableReader, count uint16) []*SequenceLookupRecord {
+	records := make([]*SequenceLookupRecord, count)
+	for i := range records {
+		records[i] = &SequenceLookupRecord{}
+		r.read(records[i])
+	}
+	return records
+}
+
+func parseSequenceContext(r *tableReader, offset int64, chained bool) (*SequenceContext, error) {
+	c := &SequenceContext{
+		Chained: chained,
+	}
+	r.seek(offset)
+	c.Format = r.uint16()
+	switch c.Format {
+	case 1, 2:
+		coverageOffset := r.uint16()
+		var classDefOffsets []uint16
+		if 2 == c.Format {
+			if chained {
+				classDefOffsets = r.uint16s(3)
+			} else {
+				classDefOffsets = r.uint16s(1)
+			}
+		}
+		ruleSetOffsets := r.uint16s(int(r.uint16()))
+		c.Coverage = parseCoverage(r, offset+int64(coverageOffset))
+		for i, o := range classDefOffsets {
+			if 0 == o {
+				continue
+			}
+			cd := parseClassDef(r, offset+int64(o))
+			switch {
+			case !chained || 1 == i:
+				c.InputClassDef = cd
+			case 0 == i:
+				c.BacktrackClassDef = cd
+			default:
+				c.LookaheadClassDef = cd
+			}
+		}
+		c.RuleSets = make([][]*SequenceRule, len(ruleSetOffsets))
+		for i, o := range ruleSetOffsets {
+			if 0 != o {
+				c.RuleSets[i] = parseSequenceRuleSet(r, offset+int64(o), chained)
+			}
+		}
+	case 3:
+		if chained {
+			backtrackOffsets := r.uint16s(int(r.uint16()))
+			inputOffsets := r.uint16s(int(r.uint16()))
+			lookaheadOffsets := r.uint16s(int(r.uint16()))
+			c.SeqLookupRecords = parseSequenceLookupRecords(r, r.uint16())
+			c.BacktrackCoverages = parseCoverages(r, offset, backtrackOffsets)
+			c.InputCoverages = parseCoverages(r, offset, inputOffsets)
+			c.LookaheadCoverages = parseCoverages(r, offset, lookaheadOffsets)
+		} else {
+			glyphCount := r.uint16()
+			seqLookupCount := r.uint16()
+			inputOffsets := r.uint16s(int(glyphCount))
+			c.SeqLookupRecords = parseSequenceLookupRecords(r, seqLookupCount)
+			c.InputCoverages = parseCoverages(r, offset, inputOffsets)
+		}
+	default:
+		return nil, fmt.Errorf("sequence context format %d is not supported", c.Format)
+	}
+	return c, r.errorf("failed to parse sequence context: %s")
+}
+
+func parseSequenceRuleSet(r *tableReader, offset int64, chained bool) []*SequenceRule {
+	r.seek(offset)
+	offsets := r.uint16s(int(r.uint16()))
+	rules := make([]*SequenceRule, len(offsets))
+	for i, o := range offsets {
+		rule := &SequenceRule{}
+		r.seek(offset + int64(o))
+		if chained {
+			rule.Backtrack = r.uint16s(int(r.uint16()))
+			rule.Input = r.uint16s(inputCount(r.uint16()))
+			rule.Lookahead = r.uint16s(int(r.uint16()))
+			rule.SeqLookupRecords = parseSequenceLookupRecords(r, r.uint16())
+		} else {
+			glyphCount := r.uint16()
+			seqLookupCount := r.uint16()
+			rule.Input = r.uint16s(inputCount(glyphCount))
+			rule.SeqLookupRecords = parseSequenceLookupRecords(r, seqLookupCount)
+		}
+		rules[i] = rule
+	}
+	return rules
+}
+
+// inputCount returns the length of the input sequence except for the first glyph.
+func inputCount(glyphCount uint16) int {
+	if 0 == glyphCount {
+		return 0
+	}
+	return int(glyphCount) - 1
+}
+
+func sequenceLookupRecordsNode(n *offsetNode, records []*SequenceLookupRecord) {
+	for _, record := range records {
+		n.uint16(record.SequenceIndex)
+		n.uint16(record.LookupListIndex)
+	}
+}
+
+func (c *SequenceContext) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(c.Format)
+	switch c.Format {
+	case 1, 2:
+		n.offset16(c.Coverage.node())
+		if 2 == c.Format {
+			if c.Chained {
+				n.offset16(classDefNode(c.BacktrackClassDef))
+				n.offset16(classDefNode(c.InputClassDef))
+				n.offset16(classDefNode(c.LookaheadClassDef))
+			} else {
+				n.offset16(classDefNode(c.InputClassDef))
+			}
+		}
+		n.uint16(uint16(len(c.RuleSets)))
+		for _, rs := range c.RuleSets {
+			if rs == nil {
+				n.uint16(0)
+				continue
+			}
+			n.offset16(c.ruleSetNode(rs))
+		}
+	case 3:
+		if c.Chained {
+			coveragesNode(n, c.BacktrackCoverages)
+			coveragesNode(n, c.InputCoverages)
+			coveragesNode(n, c.LookaheadCoverages)
+			n.uint16(uint16(len(c.SeqLookupRecords)))
+		} else {
+			n.uint16(uint16(len(c.InputCoverages)))
+			n.uint16(uint16(len(c.SeqLookupRecords)))
+			for _, cov := range c.InputCoverages {
+				n.offset16(cov.node())
+			}
+		}
+		sequenceLookupRecordsNode(n, c.SeqLookupRecords)
+	}
+	return n
+}
+
+func (c *SequenceContext) ruleSetNode(rules []*SequenceRule) *offsetNode {
+	n := &offsetNode{}
+	n.uint16(uint16(len(rules)))
+	for _, rule := range rules {
+		rn := &offsetNode{}
+		if c.Chained {
+			rn.uint16(uint16(len(rule.Backtrack)))
+			rn.uint16s(rule.Backtrack)
+			rn.uint16(uint16(len(rule.Input) + 1))
+			rn.uint16s(rule.Input)
+			rn.uint16(uint16(len(rule.Lookahead)))
+			rn.uint16s(rule.Lookahead)
+			rn.uint16(uint16(len(rule.SeqLookupRecords)))
+		} else {
+			rn.uint16(uint16(len(rule.Input) + 1))
+			rn.uint16(uint16(len(rule.SeqLookupRecords)))
+			rn.uint16s(rule.Input)
+		}
+		sequenceLookupRecordsNode(rn, rule.SeqLookupRecords)
+		n.offset16(rn)
+	}
+	return n
+}
+
+func classDefNode(c *ClassDef) *offsetNode {
+	if c == nil {
+		return nil
+	}
+	return c.node()
+}
+
+func coveragesNode(n *offsetNode, cs []*Coverage) {
+	n.uint16(uint16(len(cs)))
+	for _, c := range cs {
+		n.offset16(c.node())
+	}
+}
+
+func (c *SequenceContext) cloneSubtable() LookupSubtable {
+	n := *c
+	n.Coverage = c.Coverage.clone()
+	n.RuleSets = make([][]*SequenceRule, len(c.RuleSets))
+	for i, rs := range c.RuleSets {
+		if rs == nil {
+			continue
+		}
+		n.RuleSets[i] = make([]*SequenceRule, len(rs))
+		for j, rule := range rs {
+			n.RuleSets[i][j] = rule.clone()
+		}
+	}
+	n.BacktrackClassDef = c.BacktrackClassDef.clone()
+	n.InputClassDef = c.InputClassDef.clone()
+	n.LookaheadClassDef = c.LookaheadClassDef.clone()
+	n.BacktrackCoverages = cloneCoverages(c.BacktrackCoverages)
+	n.InputCoverages = cloneCoverages(c.InputCoverages)
+	n.LookaheadCoverages = cloneCoverages(c.LookaheadCoverages)
+	n.SeqLookupRecords = cloneSequenceLookupRecords(c.SeqLookupRecords)
+	return &n
+}
+
+func (rule *SequenceRule) clone() *SequenceRule {
+	return &SequenceRule{
+		Backtrack:        append([]uint16{}, rule.Backtrack...),
+		Input:            append([]uint16{}, rule.Input...),
+		Lookahead:        append([]uint16{}, rule.Lookahead...),
+		SeqLookupRecords: cloneSequenceLookupRecords(rule.SeqLookupRecords),
+	}
+}
+
+func cloneSequenceLookupRecords(records []*SequenceLookupRecord) []*SequenceLookupRecord {
+	c := make([]*SequenceLookupRecord, len(records))
+	for i, record := range records {
+		r := *record
+		c[i] = &r
+	}
+	return c
+}
+
+func (c *SequenceContext) filter(m map[uint16]uint16) LookupSubtable {
+	n := &SequenceContext{
+		Chained: c.Chained,
+		Format:  c.Format,
+	}
+	switch c.Format {
+	case 1:
+		cov, indices := c.Coverage.filter(m)
+		n.Coverage = &Coverage{
+			Glyphs: make([]uint16, 0, len(cov.Glyphs)),
+		}
+		n.RuleSets = make([][]*SequenceRule, 0, len(cov.Glyphs))
+		for i, gid := range cov.Glyphs {
+			if indices[i] >= len(c.RuleSets) {
+				continue
+			}
+			rules := make([]*SequenceRule, 0)
+			for _, rule := range c.RuleSets[indices[i]] {
+				if nr := rule.filter(m); nr != nil {
+					rules = append(rules, nr)
+				}
+			}
+			if 0 == len(rules) {
+				continue
+			}
+			n.Coverage.Glyphs = append(n.Coverage.Glyphs, gid)
+			n.RuleSets = append(n.RuleSets, rules)
+		}
+		if 0 == len(n.Coverage.Glyphs) {
+			return nil
+		}
+	case 2:
+		n.Coverage, _ = c.Coverage.filter(m)
+		if 0 == len(n.Coverage.Glyphs) {
+			return nil
+		}
+		n.RuleSets = c.cloneSubtable().(*SequenceContext).RuleSets
+		n.BacktrackClassDef = c.BacktrackClassDef.filter(m)
+		n.InputClassDef = c.InputClassDef.filter(m)
+		n.LookaheadClassDef = c.LookaheadClassDef.filter(m)
+	case 3:
+		var ok bool
+		if n.BacktrackCoverages, ok = filterCoverages(c.BacktrackCoverages, m); !ok {
+			return nil
+		}
+		if n.InputCoverages, ok = filterCoverages(c.InputCoverages, m); !ok {
+			return nil
+		}
+		if n.LookaheadCoverages, ok = filterCoverages(c.LookaheadCoverages, m); !ok {
+			return nil
+		}
+		n.SeqLookupRecords = cloneSequenceLookupRecords(c.SeqLookupRecords)
+	}
+	return n
+}
+
+// filter returns the rule of glyph sequences for the glyphs of a subset, or nil if any of the glyphs is not in the subset.
+func (rule *SequenceRule) filter(m map[uint16]uint16) *SequenceRule {
+	n := &SequenceRule{
+		SeqLookupRecords: cloneSequenceLookupRecords(rule.SeqLookupRecords),
+	}
+	var ok bool
+	if n.Backtrack, ok = filterGlyphs(rule.Backtrack, m); !ok {
+		return nil
+	}
+	if n.Input, ok = filterGlyphs(rule.Input, m); !ok {
+		return nil
+	}
+	if n.Lookahead, ok = filterGlyphs(rule.Lookahead, m); !ok {
+		return nil
+	}
+	return n
+}
+
+// lookupIndices returns the indices of the lookups referenced by this subtable.
+func (c *SequenceContext) lookupIndices() []uint16 {
+	indices := make([]uint16, 0)
+	for _, record := range c.SeqLookupRecords {
+		indices = append(indices, record.LookupListIndex)
+	}
+	for _, rules := range c.RuleSets {
+		for _, rule := range rules {
+			for _, record := range rule.SeqLookupRecords {
+				indices = append(indices, record.LookupListIndex)
+			}
+		}
+	}
+	return indices
+}
+
+// remapLookups updates the lookup indices referenced by this subtable, and removes the records of the lookups not in lm.
+func (c *SequenceContext) remapLookups(lm map[uint16]uint16) {
+	c.SeqLookupRecords = remapSequenceLookupRecords(c.SeqLookupRecords, lm)
+	for _, rules := range c.RuleSets {
+		for _, rule := range rules {
+			rule.SeqLookupRecords = remapSequenceLookupRecords(rule.SeqLookupRecords, lm)
+		}
+	}
+}
+
+func remapSequenceLookupRecords(records []*SequenceLookupRecord, lm map[uint16]uint16) []*SequenceLookupRecord {
+	ret := make([]*SequenceLookupRecord, 0, len(records))
+	for _, record := range records {
+		if n, ok := lm[record.LookupListIndex]; ok {
+			record.LookupListIndex = n
+			ret = append(ret, record)
+		}
+	}
+	return ret
+}
+
+// FeatureVariations is a table that substitutes feature tables under conditions of the variation space.
+type FeatureVariations struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Array of feature variation records, the first matched record is applied.
+	FeatureVariationRecords []*FeatureVariationRecord
+}
+
+// FeatureVariationRecord is a pair of a condition set and feature table substitutions.
+type FeatureVariationRecord struct {
+	// All conditions must be satisfied.
+	ConditionSet []*Condition
+	// Alternate feature tables.
+	Substitutions []*FeatureTableSubstitution
+}
+
+// Condition is a range of the normalized value of an axis.
+type Condition struct {
+	// Format of the condition, only 1 is defined.
+	Format uint16
+	// Index (zero-based) for the variation axis within the "fvar" table.
+	AxisIndex uint16
+	// Minimum value of the font variation instances that satisfy this condition.
+	FilterRangeMinValue F2Dot14
+	// Maximum value of the font variation instances that satisfy this condition.
+	FilterRangeMaxValue F2Dot14
+}
+
+// FeatureTableSubstitution is an alternate feature table of the feature.
+type FeatureTableSubstitution struct {
+	// The feature table index to match.
+	FeatureIndex uint16
+	// The alternate feature table.
+	Feature *Feature
+}
+
+func parseFeatureVariations(r *tableReader, offset int64) *FeatureVariations {
+	fv := &FeatureVariations{}
+	r.seek(offset)
+	fv.MajorVersion = r.uint16()
+	fv.MinorVersion = r.uint16()
+	count := r.uint32()
+	offsets := r.uint32s(2 * int(count))
+	fv.FeatureVariationRecords = make([]*FeatureVariationRecord, count)
+	for i := range fv.FeatureVariationRecords {
+		record := &FeatureVariationRecord{}
+		if 0 != offsets[2*i] {
+			conditionSetOffset := offset + int64(offsets[2*i])
+			r.seek(conditionSetOffset)
+			conditionOffsets := r.uint32s(int(r.uint16()))
+			record.ConditionSet = make([]*Condition, len(conditionOffsets))
+			for j, o := range conditionOffsets {
+				c := &Condition{}
+				r.seek(conditionSetOffset + int64(o))
+				r.read(c)
+				record.ConditionSet[j] = c
+			}
+		}
+		if 0 != offsets[2*i+1] {
+			substitutionOffset := offset + int64(offsets[2*i+1])
+			r.seek(substitutionOffset)
+			// version of FeatureTableSubstitution table
+			r.uint32()
+			count := r.uint16()
+			record.Substitutions = make([]*FeatureTableSubstitution, count)
+			featureOffsets := make([]uint32, count)
+			for j := range record.Substitutions {
+				record.Substitutions[j] = &FeatureTableSubstitution{
+					FeatureIndex: r.uint16(),
+				}
+				featureOffsets[j] = r.uint32()
+			}
+			for j, s := range record.Substitutions {
+				s.Feature = parseFeature(r, substitutionOffset+int64(featureOffsets[j]), 0)
+			}
+		}
+		fv.FeatureVariationRecords[i] = record
+	}
+	return fv
+}
+
+func (fv *FeatureVariations) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(fv.MajorVersion)
+	n.uint16(fv.MinorVersion)
+	n.uint32(uint32(len(fv.FeatureVariationRecords)))
+	for _, record := range fv.FeatureVariationRecords {
+		cs := &offsetNode{}
+		cs.uint16(uint16(len(record.ConditionSet)))
+		for _, c := range record.ConditionSet {
+			cn := &offsetNode{}
+			cn.uint16(c.Format)
+			cn.uint16(c.AxisIndex)
+			cn.int16(int16(c.FilterRangeMinValue))
+			cn.int16(int16(c.FilterRangeMaxValue))
+			cs.offset32(cn)
+		}
+		n.offset32(cs)
+		s := &offsetNode{}
+		s.uint16(1)
+		s.uint16(0)
+		s.uint16(uint16(len(record.Substitutions)))
+		for _, sub := range record.Substitutions {
+			s.uint16(sub.FeatureIndex)
+			s.offset32(sub.Feature.node())
+		}
+		n.offset32(s)
+	}
+	return n
+}
+
+func (fv *FeatureVariations) clone() *FeatureVariations {
+	if fv == nil {
+		return nil
+	}
+	c := *fv
+	c.FeatureVariationRecords = make([]*FeatureVariationRecord, len(fv.FeatureVariationRecords))
+	for i, record := range fv.FeatureVariationRecords {
+		r := &FeatureVariationRecord{
+			ConditionSet:  make([]*Condition, len(record.ConditionSet)),
+			Substitutions: make([]*FeatureTableSubstitution, len(record.Substitutions)),
+		}
+		for j, cond := range record.ConditionSet {
+			cc := *cond
+			r.ConditionSet[j] = &cc
+		}
+		for j, s := range record.Substitutions {
+			r.Substitutions[j] = &FeatureTableSubstitution{
+				FeatureIndex: s.FeatureIndex,
+				Feature:      s.Feature.clone(),
+			}
+		}
+		c.FeatureVariationRecords[i] = r
+	}
+	return &c
+}
@@ -0,0 +1,134 @@
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// newBytesTableReader returns the tableReader of the table data for the font of numGlyphs glyphs.
+func newBytesTableReader(b []byte, numGlyphs int) *tableReader {
+	rs := bytes.NewReader(b)
+	return &tableReader{
+		errReader: errReader{
+			r: rs,
+		},
+		rs:        rs,
+		numGlyphs: numGlyphs,
+	}
+}
+
+// testTableData encodes the values as uint16.
+func testTableData(values ...uint16) []byte {
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.BigEndian, values)
+	return b.Bytes()
+}
+
+// rawTable is a table of the raw data, to write the tables that this package can not create.
+type rawTable struct {
+	tag  string
+	data []byte
+}
+
+func (t *rawTable) Tag() Tag {
+	return String2Tag(t.tag)
+}
+
+func (t *rawTable) store(w *errWriter) {
+	w.writeBin(t.data)
+	padSpace(w, t.Length())
+}
+
+func (t *rawTable) CheckSum() (uint32, error) {
+	return simpleCheckSum(t)
+}
+
+func (t *rawTable) Length() uint32 {
+	return uint32(len(t.data))
+}
+
+func (t *rawTable) Exists() bool {
+	return t != nil
+}
+
+func TestParseCoverageCapsRanges(t *testing.T) {
+	r := newBytesTableReader(testTableData(2, 2, 3, 5, 0, 8, 0xFFFF, 3), 10)
+	c := parseCoverage(r, 0)
+	if r.hasErr() {
+		t.Fatal(r.err)
+	}
+	want := []uint16{3, 4, 5, 8, 9}
+	if len(c.Glyphs) != len(want) {
+		t.Fatalf("coverage has %v, want %v", c.Glyphs, want)
+	}
+	for i, gid := range want {
+		if c.Glyphs[i] != gid {
+			t.Fatalf("coverage has %v, want %v", c.Glyphs, want)
+		}
+	}
+	r = newBytesTableReader(testTableData(2, 2, 5, 8, 0, 3, 6, 4), 10)
+	parseCoverage(r, 0)
+	if !r.hasErr() {
+		t.Errorf("overlapping ranges are accepted")
+	}
+	r = newBytesTableReader(testTableData(2, 0xFFFF, 0, 0xFFFF, 0), 0)
+	parseCoverage(r, 0)
+	if !r.hasErr() {
+		t.Errorf("ranges beyond the table are accepted")
+	}
+}
+
+func TestParseClassDefCapsRanges(t *testing.T) {
+	r := newBytesTableReader(testTableData(2, 2, 1, 2, 1, 4, 0xFFFF, 2), 6)
+	c := parseClassDef(r, 0)
+	if r.hasErr() {
+		t.Fatal(r.err)
+	}
+	if 4 != len(c.Classes) || 1 != c.Class(2) || 2 != c.Class(5) || 0 != c.Class(6) {
+		t.Errorf("classes are %v", c.Classes)
+	}
+	r = newBytesTableReader(testTableData(1, 0xFFFE, 3, 1, 1, 1), 0)
+	c = parseClassDef(r, 0)
+	if 2 != len(c.Classes) {
+		t.Errorf("classes are %v", c.Classes)
+	}
+}
+
+func TestParseFontDropsUnsupportedGsub(t *testing.T) {
+	font := newTestSubsetFont(t)
+	// a lookup of the unknown type 9.
+	gsub := testTableData(1, 0, 0, 0, 10, 1, 4, 9, 0, 1, 8, 1)
+	parsed := writeTestFont(t, &Font{
+		SfntVersion: font.SfntVersion,
+		Name:        font.Name,
+		Head:        font.Head,
+		Hhea:        font.Hhea,
+		Maxp:        font.Maxp,
+		Hmtx:        font.Hmtx,
+		Loca:        font.Loca,
+		Glyf:        font.Glyf,
+	}, &rawTable{tag: "GSUB", data: gsub})
+	if parsed.Gsub.Exists() {
+		t.Errorf("GSUB is parsed")
+	}
+}
+
+func TestParseFontDropsUnsupportedGpos(t *testing.T) {
+	font := newTestSubsetFont(t)
+	// PairPos of the unknown format 3.
+	gpos := testTableData(1, 0, 0, 0, 10, 1, 4, 2, 0, 1, 8, 3, 0, 0, 0)
+	parsed := writeTestFont(t, &Font{
+		SfntVersion: font.SfntVersion,
+		Name:        font.Name,
+		Head:        font.Head,
+		Hhea:        font.Hhea,
+		Maxp:        font.Maxp,
+		Hmtx:        font.Hmtx,
+		Loca:        font.Loca,
+		Glyf:        font.Glyf,
+	}, &rawTable{tag: "GPOS", data: gpos})
+	if parsed.Gpos.Exists() {
+		t.Errorf("GPOS is parsed")
+	}
+}
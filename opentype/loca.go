@@ -95,3 +95,15 @@ func (l *Loca) Length() uint32 {
 func (l *Loca) Exists() bool {
 	return l != nil
 }
+
+// clone returns a deep copy of this table.
+func (l *Loca) clone() *Loca {
+	if l == nil {
+		return nil
+	}
+	return &Loca{
+		indexToLocFormat: l.indexToLocFormat,
+		offsetsShort:     append([]uint16{}, l.offsetsShort...),
+		offsetsLong:      append([]uint32{}, l.offsetsLong...),
+	}
+}
@@ -86,3 +86,12 @@ func (m *Maxp) Length() uint32 {
 func (m *Maxp) Exists() bool {
 	return m != nil
 }
+
+// clone returns a copy of this table.
+func (m *Maxp) clone() *Maxp {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	return &c
+}
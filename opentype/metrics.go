@@ -0,0 +1,92 @@
+package opentype
+
+import (
+	"fmt"
+)
+
+// LineMetrics are the metrics of a line of the font, scaled to a font size.
+type LineMetrics struct {
+	// Distance from the baseline to the top of the line, that is usually positive.
+	Ascender float64
+	// Distance from the baseline to the bottom of the line, that is usually negative.
+	Descender float64
+	// Extra space between the lines.
+	LineGap float64
+}
+
+// LineHeight returns the distance between the baselines of the consecutive lines.
+func (m *LineMetrics) LineHeight() float64 {
+	return m.Ascender - m.Descender + m.LineGap
+}
+
+// Advance returns the advance width of the glyph, in font design units.
+// The glyphs after numberOfHMetrics have the advance width of the last entry of hmtx.
+func (font *Font) Advance(gid uint16) (uint16, error) {
+	err := tableRequired(font.Hmtx)
+	if err != nil {
+		return 0, fmt.Errorf("advance is not available: %s", err)
+	}
+	advanceWidth, _ := font.Hmtx.get(gid)
+	return advanceWidth, nil
+}
+
+// MeasureString returns the width of the string set at the size, in the same unit as the size, such as points.
+// Each character is mapped by the Unicode cmap, and the characters not in the font are measured as the glyph 0.
+// If kerning is true, the kerning values of GPOS or kern between the adjacent glyphs are applied.
+// No other GSUB or GPOS features are applied; Font.Shape measures the text as it is drawn.
+func (font *Font) MeasureString(s string, size float64, kerning bool) (float64, error) {
+	err := tableRequired(font.Head, font.CMap, font.Hmtx)
+	if err != nil {
+		return 0, fmt.Errorf("measuring string failed: %s", err)
+	}
+	cmap := font.CMap.UnicodeCMap()
+	if cmap == nil {
+		return 0, fmt.Errorf("measuring string failed: no Unicode cmap")
+	}
+	width := 0
+	prev := -1
+	for _, r := range s {
+		gid := cmap[int32(r)]
+		advanceWidth, _ := font.Hmtx.get(gid)
+		width += int(advanceWidth)
+		if kerning && 0 <= prev {
+			width += int(font.Kerning(uint16(prev), gid))
+		}
+		prev = int(gid)
+	}
+	return font.scale(width, size), nil
+}
+
+// LineMetrics returns the ascender, the descender and the line gap of the font, scaled to the size.
+// If USE_TYPO_METRICS of OS/2 is set, the typographic metrics of OS/2 are used.
+// Otherwise those of hhea are used, and if they are all zero, the Windows metrics of OS/2 are used.
+func (font *Font) LineMetrics(size float64) (*LineMetrics, error) {
+	err := tableRequired(font.Head)
+	if err != nil {
+		return nil, fmt.Errorf("line metrics are not available: %s", err)
+	}
+	var ascender, descender, lineGap int
+	switch {
+	case font.OS2.Exists() && 0 != font.OS2.FsSelection&FsSelectionUseTypoMetrics:
+		ascender, descender, lineGap = int(font.OS2.STypoAscender), int(font.OS2.STypoDescender), int(font.OS2.STypoLineGap)
+	case font.Hhea.Exists() && (0 != font.Hhea.Ascender || 0 != font.Hhea.Descender || 0 != font.Hhea.LineGap):
+		ascender, descender, lineGap = int(font.Hhea.Ascender), int(font.Hhea.Descender), int(font.Hhea.LineGap)
+	case font.OS2.Exists():
+		ascender, descender = int(font.OS2.UsWinAscent), -int(font.OS2.UsWinDescent)
+	default:
+		return nil, fmt.Errorf("line metrics are not available: requires hhea or OS/2")
+	}
+	return &LineMetrics{
+		Ascender:  font.scale(ascender, size),
+		Descender: font.scale(descender, size),
+		LineGap:   font.scale(lineGap, size),
+	}, nil
+}
+
+// scale converts the value in font design units to the size.
+func (font *Font) scale(v int, size float64) float64 {
+	if 0 == font.Head.UnitsPerEm {
+		return 0
+	}
+	return float64(v) * size / float64(font.Head.UnitsPerEm)
+}
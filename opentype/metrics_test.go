@@ -0,0 +1,78 @@
+package opentype
+
+import (
+	"testing"
+)
+
+func TestMeasureString(t *testing.T) {
+	font := newTestShapeFont(t)
+	tests := []struct {
+		text    string
+		kerning bool
+		want    float64
+	}{
+		{"", true, 0},
+		{"AV", false, 21.4},
+		{"AV", true, 19.8},
+		// the ligature is not applied, and the character not in the font is the glyph 0.
+		{"fiz", true, 30.6},
+	}
+	for _, tt := range tests {
+		w, err := font.MeasureString(tt.text, 20, tt.kerning)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !approxEqual(tt.want, w) {
+			t.Errorf("width of %q with kerning %v is %g, want %g", tt.text, tt.kerning, w, tt.want)
+		}
+	}
+	font.CMap = nil
+	if _, err := font.MeasureString("AV", 20, true); err == nil {
+		t.Errorf("string is measured without cmap")
+	}
+}
+
+func TestLineMetrics(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Hhea.LineGap = 50
+	font.OS2 = &OS2{
+		Version:        4,
+		STypoAscender:  900,
+		STypoDescender: -300,
+		STypoLineGap:   100,
+		UsWinAscent:    1000,
+		UsWinDescent:   250,
+	}
+	tests := []struct {
+		name        string
+		fsSelection uint16
+		hhea        [3]int16
+		want        LineMetrics
+	}{
+		{"hhea", 0, [3]int16{800, -200, 50}, LineMetrics{Ascender: 16, Descender: -4, LineGap: 1}},
+		{"USE_TYPO_METRICS", FsSelectionUseTypoMetrics, [3]int16{800, -200, 50}, LineMetrics{Ascender: 18, Descender: -6, LineGap: 2}},
+		{"Windows metrics", 0, [3]int16{0, 0, 0}, LineMetrics{Ascender: 20, Descender: -5}},
+	}
+	for _, tt := range tests {
+		font.OS2.FsSelection = tt.fsSelection
+		font.Hhea.Ascender, font.Hhea.Descender, font.Hhea.LineGap = tt.hhea[0], tt.hhea[1], tt.hhea[2]
+		m, err := writeTestFont(t, font).LineMetrics(20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !approxEqual(tt.want.Ascender, m.Ascender) || !approxEqual(tt.want.Descender, m.Descender) || !approxEqual(tt.want.LineGap, m.LineGap) {
+			t.Errorf("%s: line metrics are %+v, want %+v", tt.name, *m, tt.want)
+		}
+		if !approxEqual(tt.want.LineHeight(), m.LineHeight()) {
+			t.Errorf("%s: line height is %g, want %g", tt.name, m.LineHeight(), tt.want.LineHeight())
+		}
+	}
+	font.OS2 = nil
+	if _, err := font.LineMetrics(20); err == nil {
+		t.Errorf("line metrics are available without hhea metrics and OS/2")
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
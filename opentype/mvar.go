@@ -0,0 +1,291 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mvar is a "MVAR" table.
+// The metrics variations table has the deltas of the font-wide metrics of OS/2, hhea, vhea and the other tables, identified by tags.
+type Mvar struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Value records of the metrics, that are sorted by the tags when written.
+	ValueRecords []*MetricValueRecord
+	// Item variation store of the deltas.
+	ItemVarStore *ItemVariationStore
+}
+
+// MetricValueRecord is a delta-set of a metric.
+type MetricValueRecord struct {
+	// Tag identifying the metric, such as "hasc" for sTypoAscender of OS/2.
+	ValueTag Tag
+	// The delta-set of the metric in the item variation store.
+	DeltaSetOuterIndex uint16
+	DeltaSetInnerIndex uint16
+}
+
+// mvarHeaderSize is the size of the header, that the value records follow.
+const mvarHeaderSize = 12
+
+func parseMvar(f *os.File, offset, length uint32) (m *Mvar, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	m = &Mvar{}
+	m.MajorVersion = r.uint16()
+	m.MinorVersion = r.uint16()
+	// reserved
+	r.uint16()
+	valueRecordSize := int(r.uint16())
+	valueRecordCount := int(r.uint16())
+	itemVariationStoreOffset := r.uint16()
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	if valueRecordSize < 8 {
+		return nil, fmt.Errorf("failed to parse MVAR: invalid value record size %d", valueRecordSize)
+	}
+	m.ValueRecords = make([]*MetricValueRecord, valueRecordCount)
+	for i := range m.ValueRecords {
+		// the records can be extended in the future versions.
+		r.seek(int64(mvarHeaderSize + i*valueRecordSize))
+		v := &MetricValueRecord{}
+		r.read(v)
+		m.ValueRecords[i] = v
+	}
+	if 0 != itemVariationStoreOffset {
+		m.ItemVarStore = parseItemVariationStore(r, int64(itemVariationStoreOffset))
+	}
+	return m, r.errorf("failed to parse MVAR: %s")
+}
+
+// Tag is table name.
+func (m *Mvar) Tag() Tag {
+	return String2Tag("MVAR")
+}
+
+// store writes binary expression of this table.
+func (m *Mvar) store(w *errWriter) {
+	b, err := packOffsetNode(m.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+func (m *Mvar) node() *offsetNode {
+	records := append([]*MetricValueRecord{}, m.ValueRecords...)
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].ValueTag < records[j].ValueTag
+	})
+	n := &offsetNode{}
+	n.uint16(m.MajorVersion)
+	n.uint16(m.MinorVersion)
+	n.uint16(0)
+	n.uint16(8)
+	n.uint16(uint16(len(records)))
+	if m.ItemVarStore == nil {
+		n.uint16(0)
+	} else {
+		n.offset16(m.ItemVarStore.node())
+	}
+	for _, v := range records {
+		n.uint32(uint32(v.ValueTag))
+		n.uint16(v.DeltaSetOuterIndex)
+		n.uint16(v.DeltaSetInnerIndex)
+	}
+	return n
+}
+
+// CheckSum for this table.
+func (m *Mvar) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(m)
+}
+
+// Length returns the size(byte) of this table.
+func (m *Mvar) Length() uint32 {
+	b, err := packOffsetNode(m.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (m *Mvar) Exists() bool {
+	return m != nil
+}
+
+// clone returns a deep copy of this table.
+func (m *Mvar) clone() *Mvar {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	c.ValueRecords = make([]*MetricValueRecord, len(m.ValueRecords))
+	for i, v := range m.ValueRecords {
+		cv := *v
+		c.ValueRecords[i] = &cv
+	}
+	c.ItemVarStore = m.ItemVarStore.clone()
+	return &c
+}
+
+// metricField returns the pointer to the field of the metric identified by the tag of MVAR, that is *int16 or *uint16.
+// It returns nil if the tag is unknown, or the font does not have the table of the metric.
+func (font *Font) metricField(tag Tag) interface{} {
+	if o := font.OS2; o.Exists() {
+		switch tag.String() {
+		case "hasc":
+			return &o.STypoAscender
+		case "hdsc":
+			return &o.STypoDescender
+		case "hlgp":
+			return &o.STypoLineGap
+		case "hcla":
+			return &o.UsWinAscent
+		case "hcld":
+			return &o.UsWinDescent
+		case "xhgt":
+			return &o.SxHeight
+		case "cpht":
+			return &o.SCapHeight
+		case "sbxs":
+			return &o.YSubscriptXSize
+		case "sbys":
+			return &o.YSubscriptYSize
+		case "sbxo":
+			return &o.YSubscriptXOffset
+		case "sbyo":
+			return &o.YSubscriptYOffset
+		case "spxs":
+			return &o.YSuperscriptXSize
+		case "spys":
+			return &o.YSuperscriptYSize
+		case "spxo":
+			return &o.YSuperscriptXOffset
+		case "spyo":
+			return &o.YSuperscriptYOffset
+		case "strs":
+			return &o.YStrikeoutSize
+		case "stro":
+			return &o.YStrikeoutPosition
+		}
+	}
+	if h := font.Hhea; h.Exists() {
+		switch tag.String() {
+		case "hcrs":
+			return &h.CaretSlopeRise
+		case "hcrn":
+			return &h.CaretSlopeRun
+		case "hcof":
+			return &h.CaretOffset
+		}
+	}
+	if v := font.Vhea; v.Exists() {
+		switch tag.String() {
+		case "vasc":
+			return &v.VertTypoAscender
+		case "vdsc":
+			return &v.VertTypoDescender
+		case "vlgp":
+			return &v.VertTypoLineGap
+		case "vcrs":
+			return &v.CaretSlopeRise
+		case "vcrn":
+			return &v.CaretSlopeRun
+		case "vcof":
+			return &v.CaretOffset
+		}
+	}
+	if p := font.Post; p.Exists() {
+		switch tag.String() {
+		case "undo":
+			return &p.UnderlinePosition
+		case "unds":
+			return &p.UnderlineThickness
+		}
+	}
+	if g := font.Gasp; g.Exists() {
+		// "gsp0" to "gsp9" are the rangeMaxPPEM of the ranges in the order of gasp.
+		if s := tag.String(); strings.HasPrefix(s, "gsp") {
+			i, err := strconv.Atoi(s[3:])
+			if err == nil && 0 <= i && i < len(g.GaspRanges) {
+				return &g.GaspRanges[i].RangeMaxPPEM
+			}
+		}
+	}
+	return nil
+}
+
+// metricValue returns the value of the field that metricField returns.
+func metricValue(field interface{}) int {
+	switch p := field.(type) {
+	case *int16:
+		return int(*p)
+	case *uint16:
+		return int(*p)
+	}
+	return 0
+}
+
+// setMetricValue sets the value rounded and clamped to the type of the field that metricField returns.
+func setMetricValue(field interface{}, v float64) {
+	v = math.Floor(v + 0.5)
+	switch p := field.(type) {
+	case *int16:
+		*p = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+	case *uint16:
+		*p = uint16(math.Max(0, math.Min(math.MaxUint16, v)))
+	}
+}
+
+// applyMetricDeltas adds the deltas of the delta-sets of MVAR to the metrics.
+// The metrics whose tables the font does not have are ignored.
+func (font *Font) applyMetricDeltas(delta func(outer, inner uint16) float64) {
+	for _, v := range font.Mvar.ValueRecords {
+		field := font.metricField(v.ValueTag)
+		if field == nil {
+			continue
+		}
+		d := delta(v.DeltaSetOuterIndex, v.DeltaSetInnerIndex)
+		setMetricValue(field, float64(metricValue(field))+d)
+	}
+}
+
+// MetricAt returns the font-wide metric identified by the tag of MVAR at the location in the user space of the variation axes, in font design units.
+// The tags are such as "hasc" for sTypoAscender of OS/2, "hcrs" for caretSlopeRise of hhea, "vasc" for vertTypoAscender of vhea,
+// "undo" for underlinePosition of post and "gsp0" for rangeMaxPPEM of the first range of gasp.
+// The axes missing in the location are at their default values, and the metric is the default one if MVAR has no deltas for it.
+func (font *Font) MetricAt(tag Tag, location map[Tag]float64) (int, error) {
+	field := font.metricField(tag)
+	if field == nil {
+		return 0, fmt.Errorf("metric %s is not available", tag)
+	}
+	c, err := font.NormalizeCoordinates(location)
+	if err != nil {
+		return 0, err
+	}
+	v := metricValue(field)
+	if !font.Mvar.Exists() {
+		return v, nil
+	}
+	coords := coordinatesFloat(c)
+	for _, r := range font.Mvar.ValueRecords {
+		if r.ValueTag == tag {
+			d := font.Mvar.ItemVarStore.Delta(r.DeltaSetOuterIndex, r.DeltaSetInnerIndex, coords)
+			return int(math.Floor(float64(v) + d + 0.5)), nil
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,34 @@
+package opentype
+
+import (
+	"testing"
+)
+
+func TestApplyMetricDeltas(t *testing.T) {
+	font := &Font{
+		OS2:  &OS2{STypoAscender: 800},
+		Post: &Post{Version: PostVersion3, UnderlinePosition: -100, UnderlineThickness: 50},
+		Gasp: &Gasp{Version: 1, GaspRanges: []*GaspRange{{RangeMaxPPEM: 8}, {RangeMaxPPEM: 0xFFFF}}},
+		Mvar: &Mvar{
+			ValueRecords: []*MetricValueRecord{
+				{ValueTag: String2Tag("hasc"), DeltaSetInnerIndex: 0},
+				{ValueTag: String2Tag("undo"), DeltaSetInnerIndex: 1},
+				{ValueTag: String2Tag("unds"), DeltaSetInnerIndex: 2},
+				{ValueTag: String2Tag("gsp0"), DeltaSetInnerIndex: 3},
+				// the metrics of the range and the table that the font does not have are ignored.
+				{ValueTag: String2Tag("gsp2"), DeltaSetInnerIndex: 3},
+				{ValueTag: String2Tag("vasc"), DeltaSetInnerIndex: 0},
+			},
+		},
+	}
+	font.applyMetricDeltas(deltaSetLookup([][]float64{{20, -10.4, 5.5, 4}}))
+	if 820 != font.OS2.STypoAscender {
+		t.Errorf("sTypoAscender is %d, want 820", font.OS2.STypoAscender)
+	}
+	if -110 != font.Post.UnderlinePosition || 56 != font.Post.UnderlineThickness {
+		t.Errorf("underline is %d, %d, want -110, 56", font.Post.UnderlinePosition, font.Post.UnderlineThickness)
+	}
+	if 12 != font.Gasp.GaspRanges[0].RangeMaxPPEM || 0xFFFF != font.Gasp.GaspRanges[1].RangeMaxPPEM {
+		t.Errorf("rangeMaxPPEM of gasp are %d, %d, want 12, 65535", font.Gasp.GaspRanges[0].RangeMaxPPEM, font.Gasp.GaspRanges[1].RangeMaxPPEM)
+	}
+}
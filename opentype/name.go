@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"unicode/utf16"
 )
@@ -69,17 +70,24 @@ func (n *Name) Tag() Tag {
 }
 
 // store writes binary expression of this table.
+// The lengths and the offsets of the strings are recalculated from the values, so that the edited names are written correctly.
 func (n *Name) store(w *errWriter) {
+	values := n.encodeValues()
+	count := uint16(len(n.NameRecords))
+	stringOffset := n.stringOffset()
 	w.write(&(n.Format))
-	w.write(&(n.Count))
-	w.write(&(n.StringOffset))
-	for _, nr := range n.NameRecords {
+	w.write(&count)
+	w.write(&stringOffset)
+	offset := uint16(0)
+	for i, nr := range n.NameRecords {
+		length := uint16(len(values[i]))
 		w.write(&(nr.PlatformID))
 		w.write(&(nr.EncodingID))
 		w.write(&(nr.LanguageID))
 		w.write(&(nr.NameID))
-		w.write(&(nr.Length))
-		w.write(&(nr.Offset))
+		w.write(&length)
+		w.write(&offset)
+		offset += length
 	}
 	if 1 == n.Format {
 		w.write(&(n.LangTagCount))
@@ -88,16 +96,33 @@ func (n *Name) store(w *errWriter) {
 			w.write(&(ltr.Offset))
 		}
 	}
-	for _, nr := range n.NameRecords {
+	for _, v := range values {
+		w.writeBin(v)
+	}
+	padSpace(w, n.Length())
+}
+
+// encodeValues returns the strings of the name records, in Mac Roman for Macintosh platform and in UTF-16BE for the others.
+func (n *Name) encodeValues() [][]byte {
+	values := make([][]byte, len(n.NameRecords))
+	for i, nr := range n.NameRecords {
 		if PlatformIDMacintosh == nr.PlatformID {
-			w.writeBin([]byte(nr.Value))
-		} else {
-			for _, u := range utf16.Encode([]rune(nr.Value)) {
-				w.write(&(u))
-			}
+			values[i] = []byte(nr.Value)
+			continue
+		}
+		for _, u := range utf16.Encode([]rune(nr.Value)) {
+			values[i] = append(values[i], byte(u>>8), byte(u))
 		}
 	}
-	padSpace(w, n.Length())
+	return values
+}
+
+func (n *Name) stringOffset() uint16 {
+	l := 6 + 12*len(n.NameRecords)
+	if 1 == n.Format {
+		l += 2 + 4*len(n.LangTagRecords)
+	}
+	return uint16(l)
 }
 
 // CheckSum for this table.
@@ -107,15 +132,11 @@ func (n *Name) CheckSum() (checkSum uint32, err error) {
 
 // Length returns the size(byte) of this table.
 func (n *Name) Length() uint32 {
-	l := 6 + 12*n.Count
-	s := uint32(0)
-	for _, nr := range n.NameRecords {
-		s += (uint32)(nr.Length)
-	}
-	if 1 == n.Format {
-		l += 2 + 4*n.LangTagCount
+	l := uint32(n.stringOffset())
+	for _, v := range n.encodeValues() {
+		l += uint32(len(v))
 	}
-	return uint32(l) + uint32(s)
+	return l
 }
 
 // Exists returns true if this is not nil.
@@ -123,6 +144,25 @@ func (n *Name) Exists() bool {
 	return n != nil
 }
 
+// clone returns a deep copy of this table.
+func (n *Name) clone() *Name {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.NameRecords = make([]*NameRecord, len(n.NameRecords))
+	for i, nr := range n.NameRecords {
+		r := *nr
+		c.NameRecords[i] = &r
+	}
+	c.LangTagRecords = make([]*LangTagRecord, len(n.LangTagRecords))
+	for i, ltr := range n.LangTagRecords {
+		r := *ltr
+		c.LangTagRecords[i] = &r
+	}
+	return &c
+}
+
 // Get returns name value specified by Name Record keys.
 func (n *Name) Get(platformID PlatformID, encodingID EncodingID, languageID LanguageID, nameID NameID) (value string) {
 	for _, nr := range n.NameRecords {
@@ -133,6 +173,74 @@ func (n *Name) Get(platformID PlatformID, encodingID EncodingID, languageID Lang
 	return
 }
 
+// Find returns the name of the name ID for display, or empty if there is no such name.
+// The name in English of the Windows platform is preferred, followed by the other Windows, Unicode and Macintosh names.
+func (n *Name) Find(nameID NameID) string {
+	if n == nil {
+		return ""
+	}
+	var found *NameRecord
+	rank := 0
+	for _, nr := range n.NameRecords {
+		if nr.NameID != nameID {
+			continue
+		}
+		r := 1
+		switch {
+		case PlatformIDWindows == nr.PlatformID && LanguageIDWindowsEnglishUnitedStates == nr.LanguageID:
+			r = 4
+		case PlatformIDWindows == nr.PlatformID:
+			r = 3
+		case PlatformIDUnicode == nr.PlatformID:
+			r = 2
+		}
+		if r > rank {
+			found, rank = nr, r
+		}
+	}
+	if found == nil {
+		return ""
+	}
+	return found.Value
+}
+
+// set replaces the values of all records of the name ID.
+// If there is no such record, a record of Windows platform in English is added.
+func (n *Name) set(nameID NameID, value string) {
+	found := false
+	for _, nr := range n.NameRecords {
+		if nr.NameID == nameID {
+			nr.Value = value
+			found = true
+		}
+	}
+	if found {
+		return
+	}
+	n.NameRecords = append(n.NameRecords, &NameRecord{
+		PlatformID: PlatformIDWindows,
+		EncodingID: EncodingIDWindowsUnicodeBMP,
+		LanguageID: LanguageIDWindowsEnglishUnitedStates,
+		NameID:     nameID,
+		Value:      value,
+	})
+	// the name records are sorted by the platform, the encoding, the language and the name IDs.
+	sort.SliceStable(n.NameRecords, func(i, j int) bool {
+		a, b := n.NameRecords[i], n.NameRecords[j]
+		if a.PlatformID != b.PlatformID {
+			return a.PlatformID < b.PlatformID
+		}
+		if a.EncodingID != b.EncodingID {
+			return a.EncodingID < b.EncodingID
+		}
+		if a.LanguageID != b.LanguageID {
+			return a.LanguageID < b.LanguageID
+		}
+		return a.NameID < b.NameID
+	})
+	n.Count = uint16(len(n.NameRecords))
+}
+
 // NameRecord contains platform specific metadata of the OpenType Font.
 type NameRecord struct {
 	// Platform ID.
@@ -0,0 +1,161 @@
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// OS2 is a "OS/2" table.
+// This table consists of a set of metrics and other data that are required in OpenType fonts.
+// The fields that the version of the table does not have are zero.
+type OS2 struct {
+	// Version of the table, 0 to 5.
+	Version uint16
+	// The average weighted escapement.
+	XAvgCharWidth int16
+	// The visual weight (degree of blackness or thickness of strokes) of the characters, from 1 to 1000.
+	UsWeightClass uint16
+	// A relative change from the normal aspect ratio (width to height ratio), from 1 to 9.
+	UsWidthClass uint16
+	// Font embedding licensing rights for the font.
+	FsType              uint16
+	YSubscriptXSize     int16
+	YSubscriptYSize     int16
+	YSubscriptXOffset   int16
+	YSubscriptYOffset   int16
+	YSuperscriptXSize   int16
+	YSuperscriptYSize   int16
+	YSuperscriptXOffset int16
+	YSuperscriptYOffset int16
+	// Thickness of the strikeout stroke.
+	YStrikeoutSize int16
+	// The position of the top of the strikeout stroke relative to the baseline.
+	YStrikeoutPosition int16
+	// The font-family class and subclass.
+	SFamilyClass int16
+	// PANOSE classification number.
+	Panose [10]uint8
+	// Unicode blocks supported by the font.
+	UlUnicodeRange1 uint32
+	UlUnicodeRange2 uint32
+	UlUnicodeRange3 uint32
+	UlUnicodeRange4 uint32
+	// The four character identifier for the vendor of the font.
+	AchVendID Tag
+	// Font selection flags.
+	FsSelection uint16
+	// The minimum Unicode index (character code) in this font.
+	UsFirstCharIndex uint16
+	// The maximum Unicode index (character code) in this font.
+	UsLastCharIndex uint16
+	// The typographic ascender for this font.
+	STypoAscender int16
+	// The typographic descender for this font, that is usually negative.
+	STypoDescender int16
+	// The typographic line gap for this font.
+	STypoLineGap int16
+	// The "Windows ascender" metric, that specifies the top of the clipping region.
+	UsWinAscent uint16
+	// The "Windows descender" metric, that specifies the bottom of the clipping region as a positive value.
+	UsWinDescent uint16
+	// Code page character ranges, since version 1.
+	UlCodePageRange1 uint32
+	UlCodePageRange2 uint32
+	// The distance between the baseline and the approximate height of non-ascending lowercase letters, since version 2.
+	SxHeight int16
+	// The distance between the baseline and the approximate height of uppercase letters, since version 2.
+	SCapHeight int16
+	// The Unicode code point of the default character, since version 2.
+	UsDefaultChar uint16
+	// The Unicode code point of the break character, since version 2.
+	UsBreakChar uint16
+	// The maximum length of a target glyph context for any feature in this font, since version 2.
+	UsMaxContext uint16
+	// The lower end of the size range for which this font has been designed, since version 5.
+	UsLowerOpticalPointSize uint16
+	// The upper end of the size range for which this font has been designed, since version 5.
+	UsUpperOpticalPointSize uint16
+}
+
+const (
+	// FsSelectionItalic : font contains italic or oblique glyphs.
+	FsSelectionItalic = uint16(0x0001)
+	// FsSelectionBold : glyphs are emboldened.
+	FsSelectionBold = uint16(0x0020)
+	// FsSelectionRegular : glyphs are in the standard weight/style for the font.
+	FsSelectionRegular = uint16(0x0040)
+	// FsSelectionUseTypoMetrics : use STypoAscender, STypoDescender and STypoLineGap as the default line spacing.
+	FsSelectionUseTypoMetrics = uint16(0x0080)
+	// FsSelectionOblique : font contains oblique glyphs.
+	FsSelectionOblique = uint16(0x0200)
+)
+
+func parseOS2(f *os.File, offset, length uint32) (o *OS2, err error) {
+	o = &OS2{}
+	size := uint32(binary.Size(o))
+	if length > size {
+		length = size
+	}
+	// the fields after the end of the table, such as the typographic metrics of the oldest fonts, remain zero.
+	b := make([]byte, size)
+	f.Seek(int64(offset), 0)
+	_, err = io.ReadFull(f, b[:length])
+	if err != nil {
+		return
+	}
+	err = binary.Read(bytes.NewReader(b), binary.BigEndian, o)
+	return
+}
+
+// Tag is table name.
+func (o *OS2) Tag() Tag {
+	return String2Tag("OS/2")
+}
+
+// store writes binary expression of this table.
+func (o *OS2) store(w *errWriter) {
+	b := bytes.NewBuffer([]byte{})
+	err := binary.Write(b, binary.BigEndian, o)
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b.Bytes()[:o.Length()])
+	padSpace(w, o.Length())
+}
+
+// CheckSum for this table.
+func (o *OS2) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(o)
+}
+
+// Length returns the size(byte) of this table.
+func (o *OS2) Length() uint32 {
+	switch o.Version {
+	case 0:
+		return uint32(78)
+	case 1:
+		return uint32(86)
+	case 2, 3, 4:
+		return uint32(96)
+	}
+	return uint32(100)
+}
+
+// Exists returns true if this is not nil.
+func (o *OS2) Exists() bool {
+	return o != nil
+}
+
+// clone returns a copy of this table.
+func (o *OS2) clone() *OS2 {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	return &c
+}
@@ -0,0 +1,1018 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AxisLimit is a new range of a variation axis in the user space, with its new default value.
+type AxisLimit struct {
+	Min     float64
+	Default float64
+	Max     float64
+}
+
+// LimitAxes creates a variable font whose variation axes are restricted to the ranges in the user space.
+// The values of a limit are clamped to the range of the axis, and the axes missing in the limits are not changed.
+// The tuple variations of gvar and cvar and the regions of the item variation stores of GDEF, HVAR, VVAR and MVAR are re-normalized to the new ranges,
+// and split where a region is cut by a limit. The deltas at a new default are applied to the glyphs, the metrics, the control values of cvt and the values of GDEF and GPOS.
+// The advances at a new default are the ones varied by HVAR if the font has it, or by gvar otherwise, as Instantiate does.
+// The conditions of the feature variations of GSUB and GPOS are re-normalized as well.
+// The axes and the named instances outside of the ranges are updated in fvar, and the segment maps of avar are re-normalized.
+// The axis values of STAT outside of the ranges are removed.
+// An axis is pinned by a limit whose minimum and maximum are the same: its deltas are applied, and it remains in fvar with the same minimum, default and maximum.
+// The rebased tuple variations of gvar and cvar have the deltas of the points that can not be interpolated, and no deltas if all of them are zero.
+// avar version 2 is not supported.
+// The receiver is never modified.
+func (font *Font) LimitAxes(limits map[Tag]AxisLimit) (*Font, error) {
+	err := tableRequired(font.Fvar, font.Head, font.Hhea, font.Maxp, font.Hmtx, font.Glyf)
+	if err != nil {
+		return nil, fmt.Errorf("limiting axes failed: %s", err)
+	}
+	if font.Avar.Exists() && (font.Avar.VarStore != nil || font.Avar.AxisIndexMap != nil) {
+		return nil, fmt.Errorf("limiting axes failed: avar version 2 is not supported")
+	}
+	axisLimits := make([]*axisLimit, len(font.Fvar.Axes))
+	avarLimits := make([]*axisLimit, len(font.Fvar.Axes))
+	userLimits := make([]*AxisLimit, len(font.Fvar.Axes))
+	statLimits := make(map[Tag]AxisLimit)
+	for tag, l := range limits {
+		if !font.hasAxis(tag) {
+			return nil, fmt.Errorf("limiting axes failed: no axis %s", tag)
+		}
+		if !(l.Min <= l.Default && l.Default <= l.Max) {
+			return nil, fmt.Errorf("limiting axes failed: invalid limit of axis %s: %g, %g, %g", tag, l.Min, l.Default, l.Max)
+		}
+		for i, a := range font.Fvar.Axes {
+			if a.AxisTag != tag {
+				continue
+			}
+			min, max := a.MinValue.Float(), a.MaxValue.Float()
+			clamped := AxisLimit{
+				Min:     math.Max(min, math.Min(max, l.Min)),
+				Default: math.Max(min, math.Min(max, l.Default)),
+				Max:     math.Max(min, math.Min(max, l.Max)),
+			}
+			userLimits[i] = &clamped
+			statLimits[tag] = clamped
+			axisLimits[i], err = font.normalizeAxisLimit(i, clamped, true)
+			if err != nil {
+				return nil, fmt.Errorf("limiting axes failed: %s", err)
+			}
+			avarLimits[i], err = font.normalizeAxisLimit(i, clamped, false)
+			if err != nil {
+				return nil, fmt.Errorf("limiting axes failed: %s", err)
+			}
+		}
+	}
+	new := font.Clone()
+	if new.Gvar.Exists() {
+		err = new.limitGlyphs(font, axisLimits)
+		if err != nil {
+			return nil, fmt.Errorf("limiting axes failed: %s", err)
+		}
+	}
+	if new.Cvar.Exists() && new.Cvt.Exists() {
+		new.limitCvt(axisLimits)
+	}
+	new.limitLayout(axisLimits)
+	new.limitMetrics(axisLimits)
+	if new.Avar.Exists() {
+		new.Avar.limitAxes(avarLimits)
+	}
+	new.Fvar.limitAxes(userLimits)
+	if new.Stat.Exists() {
+		new.Stat.limitAxes(statLimits)
+	}
+	new.instantiateOS2(nil)
+	err = new.UpdateLoca()
+	if err != nil {
+		return nil, fmt.Errorf("limiting axes failed: %s", err)
+	}
+	err = new.RecalculateMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("limiting axes failed: %s", err)
+	}
+	return new, nil
+}
+
+// normalizeAxisLimit returns the limit of the axis in the normalized coordinates, that are modified by avar if usingAvar is true.
+func (font *Font) normalizeAxisLimit(axisIndex int, l AxisLimit, usingAvar bool) (*axisLimit, error) {
+	a := font.Fvar.Axes[axisIndex]
+	values := []float64{l.Min, l.Default, l.Max}
+	normalized := make([]float64, len(values))
+	for i, v := range values {
+		if usingAvar {
+			coords, err := font.NormalizeCoordinates(map[Tag]float64{a.AxisTag: v})
+			if err != nil {
+				return nil, err
+			}
+			normalized[i] = coords[axisIndex].Float()
+		} else {
+			normalized[i] = roundF2Dot14(normalizeAxisValue(a, v) * 0x4000).Float()
+		}
+	}
+	return &axisLimit{
+		min:              normalized[0],
+		def:              normalized[1],
+		max:              normalized[2],
+		distanceNegative: a.DefaultValue.Float() - a.MinValue.Float(),
+		distancePositive: a.MaxValue.Float() - a.DefaultValue.Float(),
+	}, nil
+}
+
+// limitGlyphs re-solves the tuple variations of gvar of the variable font for the limits, indexed by the axis index.
+// The deltas at the new default are applied to the glyphs and the metrics.
+func (font *Font) limitGlyphs(variable *Font, limits []*axisLimit) error {
+	n := variable.Glyf.Len()
+	gvar := &Gvar{
+		MajorVersion: variable.Gvar.MajorVersion,
+		MinorVersion: variable.Gvar.MinorVersion,
+		AxisCount:    variable.Gvar.AxisCount,
+		Variations:   make([][]*TupleVariation, n),
+	}
+	gains := &Gvar{
+		AxisCount:  variable.Gvar.AxisCount,
+		Variations: make([][]*TupleVariation, n),
+	}
+	hasGain := false
+	// the tuple variations for all points, that are optimized after the gains move the outlines.
+	dense := map[*TupleVariation]bool{}
+	for i := 0; i < n && i < len(variable.Gvar.Variations); i++ {
+		gid := uint16(i)
+		g, err := variable.Glyf.Glyph(gid)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		points, ends := variable.variationPoints(gid, g)
+		var kept []*TupleVariation
+		var deltas []*regionDeltas
+		for _, tv := range variable.Gvar.Variations[i] {
+			r := tupleRegion(tv, int(gvar.AxisCount))
+			if !r.isLimited(limits) {
+				kept = append(kept, tv)
+				continue
+			}
+			tx, ty, ok := tv.pointDeltas(points, ends)
+			if !ok {
+				continue
+			}
+			for _, s := range r.rebase(limits) {
+				deltas = appendRegionDeltas(deltas, s.region, s.scalar, tx, ty)
+			}
+		}
+		var rebased []*TupleVariation
+		for _, d := range deltas {
+			tv := d.tupleVariation()
+			if tv == nil {
+				continue
+			}
+			if d.region.isDefault() {
+				gains.Variations[i] = append(gains.Variations[i], tv)
+				continue
+			}
+			dense[tv] = true
+			rebased = append(rebased, tv)
+		}
+		for _, tv := range kept {
+			if 0 == len(gains.Variations[i]) || tv.PointNumbers == nil || ends == nil {
+				gvar.Variations[i] = append(gvar.Variations[i], tv.clone())
+				continue
+			}
+			// the untouched points are interpolated from the original outline, not the one moved by the gains.
+			tx, ty, ok := tv.pointDeltas(points, ends)
+			if !ok {
+				continue
+			}
+			if full := (&regionDeltas{region: tupleRegion(tv, int(gvar.AxisCount)), dx: tx, dy: ty}).tupleVariation(); full != nil {
+				dense[full] = true
+				gvar.Variations[i] = append(gvar.Variations[i], full)
+			}
+		}
+		gvar.Variations[i] = append(gvar.Variations[i], rebased...)
+		if 0 < len(gains.Variations[i]) {
+			hasGain = true
+		}
+	}
+	if hasGain {
+		base := *font
+		base.Gvar = gains
+		// the advances are kept if the font has HVAR, whose deltas at the new default are applied by limitMetrics.
+		err := font.instantiateGlyphs(&base, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for i, tvs := range gvar.Variations {
+		if 0 == len(tvs) {
+			continue
+		}
+		gid := uint16(i)
+		g, err := font.Glyf.Glyph(gid)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		points, ends := font.variationPoints(gid, g)
+		for k, tv := range tvs {
+			if dense[tv] {
+				tvs[k] = tv.optimize(points, ends)
+			}
+		}
+	}
+	font.Gvar = gvar
+	return nil
+}
+
+// limitCvt re-solves the tuple variations of cvar for the limits, indexed by the axis index.
+// The deltas at the new default are applied to the control values of cvt.
+func (font *Font) limitCvt(limits []*axisLimit) {
+	n := len(font.Cvt.Values)
+	axisCount := len(font.Fvar.Axes)
+	var variations []*TupleVariation
+	var deltas []*regionDeltas
+	for _, tv := range font.Cvar.Variations {
+		r := tupleRegion(tv, axisCount)
+		if !r.isLimited(limits) {
+			variations = append(variations, tv)
+			continue
+		}
+		d, ok := tv.valueDeltas(n)
+		if !ok {
+			continue
+		}
+		for _, s := range r.rebase(limits) {
+			deltas = appendRegionDeltas(deltas, s.region, s.scalar, d, nil)
+		}
+	}
+	for _, d := range deltas {
+		tv := d.tupleVariation()
+		if tv == nil {
+			continue
+		}
+		if !d.region.isDefault() {
+			variations = append(variations, tv.optimize(make([]GlyphPoint, n), nil))
+			continue
+		}
+		for i, v := range tv.Deltas {
+			c := int32(font.Cvt.Values[i]) + v
+			font.Cvt.Values[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, float64(c))))
+		}
+	}
+	font.Cvar.Variations = variations
+}
+
+// limitLayout re-solves the regions of the item variation store of GDEF for the limits, indexed by the axis index,
+// and applies the deltas at the new default to the values of GDEF and GPOS, keeping their VariationIndex tables.
+// The feature variations of GSUB and GPOS are re-normalized.
+func (font *Font) limitLayout(limits []*axisLimit) {
+	if font.Gdef.Exists() && font.Gdef.ItemVarStore != nil {
+		di := &deviceInstancer{
+			delta: deltaSetLookup(font.Gdef.ItemVarStore.limitAxes(limits)),
+			keep:  true,
+		}
+		di.layout(font)
+	}
+	if font.Gpos.Exists() {
+		font.Gpos.limitFeatureVariations(limits)
+	}
+	if font.Gsub.Exists() {
+		font.Gsub.limitFeatureVariations(limits)
+	}
+}
+
+// limitMetrics re-solves the regions of the item variation stores of HVAR, VVAR and MVAR for the limits, indexed by the axis index.
+// The deltas of HVAR at the new default are applied to the advance widths, and the ones of MVAR to the font-wide metrics of OS/2, hhea, vhea, post and gasp.
+func (font *Font) limitMetrics(limits []*axisLimit) {
+	if font.Hvar.Exists() && font.Hvar.ItemVarStore != nil {
+		font.limitAdvances(deltaSetLookup(font.Hvar.ItemVarStore.limitAxes(limits)))
+	}
+	if font.Vvar.Exists() && font.Vvar.ItemVarStore != nil {
+		font.Vvar.ItemVarStore.limitAxes(limits)
+	}
+	if font.Mvar.Exists() && font.Mvar.ItemVarStore != nil {
+		font.applyMetricDeltas(deltaSetLookup(font.Mvar.ItemVarStore.limitAxes(limits)))
+	}
+}
+
+// limitAdvances adds the deltas of the advance widths of HVAR at the new default to hmtx.
+func (font *Font) limitAdvances(delta func(outer, inner uint16) float64) {
+	hmtx := &Hmtx{HMetrics: make([]*LongHorMetric, font.Maxp.NumGlyphs)}
+	for i := range hmtx.HMetrics {
+		gid := uint16(i)
+		advanceWidth, lsb := font.Hmtx.get(gid)
+		outer, inner := uint16(0), gid
+		if font.Hvar.AdvanceWidthMapping != nil {
+			outer, inner = font.Hvar.AdvanceWidthMapping.Get(i)
+		}
+		v := math.Floor(float64(advanceWidth) + delta(outer, inner) + 0.5)
+		hmtx.HMetrics[i] = &LongHorMetric{
+			AdvanceWidth: uint16(math.Max(0, math.Min(0xFFFF, v))),
+			Lsb:          lsb,
+		}
+	}
+	hmtx.Optimize(font.Hhea)
+	font.Hmtx = hmtx
+}
+
+// deltaSetLookup returns the function that looks up the deltas indexed by the outer-level and the inner-level indices.
+func deltaSetLookup(deltas [][]float64) func(outer, inner uint16) float64 {
+	return func(outer, inner uint16) float64 {
+		if int(outer) >= len(deltas) || int(inner) >= len(deltas[outer]) {
+			return 0
+		}
+		return deltas[outer][inner]
+	}
+}
+
+// limitAxes re-solves the regions of the store for the limits, indexed by the axis index.
+// The inner and the outer indices of the delta-sets are kept, and the returned deltas at the new default are indexed by them.
+func (s *ItemVariationStore) limitAxes(limits []*axisLimit) [][]float64 {
+	var regions [][]*RegionAxisCoordinates
+	regionIndices := map[string]int{}
+	defaults := make([][]float64, len(s.ItemVariationData))
+	for outer, d := range s.ItemVariationData {
+		defaults[outer] = make([]float64, len(d.DeltaSets))
+		columns := map[int]int{}
+		var regionIndexes []uint16
+		rows := make([][]float64, len(d.DeltaSets))
+		for j, ri := range d.RegionIndexes {
+			if int(ri) >= len(s.VariationRegions) {
+				continue
+			}
+			for _, sol := range storeRegion(s.VariationRegions[ri]).rebase(limits) {
+				if sol.region.isDefault() {
+					for inner, row := range d.DeltaSets {
+						if j < len(row) {
+							defaults[outer][inner] += sol.scalar * float64(row[j])
+						}
+					}
+					continue
+				}
+				coords := sol.region.regionAxisCoordinates()
+				key := fmt.Sprint(regionKey(coords))
+				index, ok := regionIndices[key]
+				if !ok {
+					index = len(regions)
+					regionIndices[key] = index
+					regions = append(regions, coords)
+				}
+				column, ok := columns[index]
+				if !ok {
+					column = len(regionIndexes)
+					columns[index] = column
+					regionIndexes = append(regionIndexes, uint16(index))
+				}
+				for inner, row := range d.DeltaSets {
+					if len(rows[inner]) <= column {
+						rows[inner] = append(rows[inner], make([]float64, column+1-len(rows[inner]))...)
+					}
+					if j < len(row) {
+						rows[inner][column] += sol.scalar * float64(row[j])
+					}
+				}
+			}
+		}
+		d.RegionIndexes = regionIndexes
+		for inner, row := range rows {
+			d.DeltaSets[inner] = make([]int32, len(regionIndexes))
+			for k, v := range row {
+				d.DeltaSets[inner][k] = int32(math.Floor(v + 0.5))
+			}
+		}
+	}
+	s.VariationRegions = regions
+	return defaults
+}
+
+// regionKey returns the coordinates of the region, that identifies it.
+func regionKey(region []*RegionAxisCoordinates) []RegionAxisCoordinates {
+	key := make([]RegionAxisCoordinates, len(region))
+	for i, c := range region {
+		key[i] = *c
+	}
+	return key
+}
+
+// limitFeatureVariations re-normalizes the conditions of the feature variations for the limits, indexed by the axis index.
+// The records that can not match in the new ranges are removed, and the conditions that always match are removed from the records.
+// The first record matched at the new default replaces the feature tables, and a record to restore the original feature tables is added if the other records remain.
+// The records after a record that always matches are removed, since they never match.
+func (t *LayoutTable) limitFeatureVariations(limits []*axisLimit) {
+	if t.FeatureVariations == nil {
+		return
+	}
+	var records []*FeatureVariationRecord
+	var defaults []*FeatureTableSubstitution
+	applied, universal := false, false
+	keys := map[string]bool{}
+	for _, record := range t.FeatureVariations.FeatureVariationRecords {
+		applies, conditions, keep := limitConditionSet(record.ConditionSet, limits)
+		if keep {
+			record.ConditionSet = conditions
+			key := fmt.Sprint(conditionSetKey(conditions))
+			if !keys[key] {
+				keys[key] = true
+				records = append(records, record)
+			}
+		}
+		if applies && !applied {
+			for _, s := range record.Substitutions {
+				if int(s.FeatureIndex) >= len(t.FeatureList) {
+					continue
+				}
+				defaults = append(defaults, &FeatureTableSubstitution{
+					FeatureIndex: s.FeatureIndex,
+					Feature:      t.FeatureList[s.FeatureIndex].Feature,
+				})
+				t.FeatureList[s.FeatureIndex].Feature = s.Feature
+			}
+			applied = true
+		}
+		if keep && 0 == len(conditions) {
+			universal = true
+			break
+		}
+	}
+	if applied && 0 < len(records) && !universal {
+		records = append(records, &FeatureVariationRecord{
+			ConditionSet:  []*Condition{},
+			Substitutions: defaults,
+		})
+	}
+	if 0 == len(records) {
+		t.FeatureVariations = nil
+		return
+	}
+	t.FeatureVariations.FeatureVariationRecords = records
+}
+
+// limitConditionSet returns whether the conditions match at the new default, and the conditions re-normalized for the limits.
+// keep is false if the conditions can not match in the new ranges.
+func limitConditionSet(conditions []*Condition, limits []*axisLimit) (applies bool, limited []*Condition, keep bool) {
+	applies = true
+	limited = []*Condition{}
+	for _, c := range conditions {
+		if 1 != c.Format {
+			applies = false
+			limited = append(limited, c)
+			continue
+		}
+		l := &axisLimit{min: -1, def: 0, max: 1}
+		if int(c.AxisIndex) < len(limits) && limits[c.AxisIndex] != nil {
+			l = limits[c.AxisIndex]
+		}
+		min, max := c.FilterRangeMinValue.Float(), c.FilterRangeMaxValue.Float()
+		if l.def < min || l.def > max {
+			applies = false
+		}
+		if min > max || l.min > max || l.max < min {
+			return applies, nil, false
+		}
+		// the coordinate of a pinned axis is always zero, that matches the condition.
+		if l.min == l.max {
+			continue
+		}
+		newMin := roundF2Dot14(l.renormalize(min, false) * 0x4000)
+		newMax := roundF2Dot14(l.renormalize(max, false) * 0x4000)
+		if -0x4000 == newMin && 0x4000 == newMax {
+			continue
+		}
+		limited = append(limited, &Condition{
+			Format:              c.Format,
+			AxisIndex:           c.AxisIndex,
+			FilterRangeMinValue: newMin,
+			FilterRangeMaxValue: newMax,
+		})
+	}
+	return applies, limited, true
+}
+
+// conditionSetKey returns the values of the conditions, that identifies the condition set.
+func conditionSetKey(conditions []*Condition) []Condition {
+	key := make([]Condition, len(conditions))
+	for i, c := range conditions {
+		key[i] = *c
+	}
+	return key
+}
+
+// limitAxes re-normalizes the segment maps for the limits in the normalized coordinates without avar, indexed by the axis index.
+// The entries outside of a limit are removed, and the entries of -1, 0 and 1 are always added.
+func (a *Avar) limitAxes(limits []*axisLimit) {
+	for i, sm := range a.SegmentMaps {
+		if i >= len(limits) || limits[i] == nil || 0 == len(sm) {
+			continue
+		}
+		l := limits[i]
+		mapped := &axisLimit{
+			min:              mapSegment(sm, roundF2Dot14(l.min*0x4000)).Float(),
+			def:              mapSegment(sm, roundF2Dot14(l.def*0x4000)).Float(),
+			max:              mapSegment(sm, roundF2Dot14(l.max*0x4000)).Float(),
+			distanceNegative: l.distanceNegative,
+			distancePositive: l.distancePositive,
+		}
+		entries := map[F2Dot14]F2Dot14{-0x4000: -0x4000, 0: 0, 0x4000: 0x4000}
+		for _, m := range sm {
+			from, to := m.FromCoordinate.Float(), m.ToCoordinate.Float()
+			if from < l.min || from > l.max || to < mapped.min || to > mapped.max {
+				continue
+			}
+			newFrom := roundF2Dot14(l.renormalize(from, true) * 0x4000)
+			if -0x4000 == newFrom || 0 == newFrom || 0x4000 == newFrom {
+				continue
+			}
+			entries[newFrom] = roundF2Dot14(mapped.renormalize(to, true) * 0x4000)
+		}
+		newMap := make([]*AxisValueMap, 0, len(entries))
+		for from, to := range entries {
+			newMap = append(newMap, &AxisValueMap{FromCoordinate: from, ToCoordinate: to})
+		}
+		sort.Slice(newMap, func(i, j int) bool {
+			return newMap[i].FromCoordinate < newMap[j].FromCoordinate
+		})
+		a.SegmentMaps[i] = newMap
+	}
+}
+
+// limitAxes sets the ranges and the defaults of the axes to the limits in the user space, indexed by the axis index,
+// and removes the named instances outside of them.
+func (fv *Fvar) limitAxes(limits []*AxisLimit) {
+	for i, l := range limits {
+		if l == nil || i >= len(fv.Axes) {
+			continue
+		}
+		a := fv.Axes[i]
+		a.MinValue, a.DefaultValue, a.MaxValue = float2Fixed(l.Min), float2Fixed(l.Default), float2Fixed(l.Max)
+	}
+	instances := make([]*InstanceRecord, 0, len(fv.Instances))
+	for _, ir := range fv.Instances {
+		inside := true
+		for i, a := range fv.Axes {
+			if i < len(ir.Coordinates) && (ir.Coordinates[i] < a.MinValue || ir.Coordinates[i] > a.MaxValue) {
+				inside = false
+				break
+			}
+		}
+		if inside {
+			instances = append(instances, ir)
+		}
+	}
+	fv.Instances = instances
+}
+
+// float2Fixed converts the value into the nearest 16.16 fixed-point number.
+func float2Fixed(v float64) Fixed {
+	return Fixed(math.Floor(v*65536 + 0.5))
+}
+
+// axisLimit is a limit of an axis in the normalized coordinates,
+// with the distances from the original default to the original minimum and maximum in the user space.
+type axisLimit struct {
+	min, def, max                      float64
+	distanceNegative, distancePositive float64
+}
+
+// reverseNegate returns the limit mirrored at zero.
+func (l *axisLimit) reverseNegate() *axisLimit {
+	return &axisLimit{
+		min:              -l.max,
+		def:              -l.def,
+		max:              -l.min,
+		distanceNegative: l.distancePositive,
+		distancePositive: l.distanceNegative,
+	}
+}
+
+// renormalize converts the normalized coordinate v into the one normalized to the limit.
+// Where the limit spans the original default, the negative and the positive sides keep the ratio of the distances in the user space.
+// v is clamped to the limit unless extrapolate is true. Every coordinate is zero if the limit pins the axis.
+func (l *axisLimit) renormalize(v float64, extrapolate bool) float64 {
+	if !extrapolate {
+		v = math.Max(l.min, math.Min(l.max, v))
+	}
+	if v == l.def || l.min == l.max {
+		return 0
+	}
+	if l.def < 0 {
+		return -l.reverseNegate().renormalize(-v, extrapolate)
+	}
+	if v > l.def {
+		return (v - l.def) / (l.max - l.def)
+	}
+	if l.min >= 0 {
+		return (v - l.def) / (l.def - l.min)
+	}
+	total := l.distanceNegative*-l.min + l.distancePositive*l.def
+	distance := -v*l.distanceNegative + l.distancePositive*l.def
+	if v >= 0 {
+		distance = (l.def - v) * l.distancePositive
+	}
+	return -distance / total
+}
+
+// tent is the range of a region on an axis, where the scalar rises from the lower to the peak and falls to the upper.
+// The axis does not participate in the region if the peak is zero.
+type tent struct {
+	lower, peak, upper float64
+}
+
+// reverseNegate returns the tent mirrored at zero.
+func (t tent) reverseNegate() tent {
+	return tent{lower: -t.upper, peak: -t.peak, upper: -t.lower}
+}
+
+func (t tent) scalar(v float64) float64 {
+	return axisScalar(t.lower, t.peak, t.upper, v)
+}
+
+// tentSolution is a part of a tent re-solved for a limit, whose deltas are multiplied by the scalar.
+// The tent is nil if the part is the constant at the new default.
+type tentSolution struct {
+	scalar float64
+	tent   *tent
+}
+
+// tentEpsilon is the smallest step of the normalized coordinates.
+const tentEpsilon = 1.0 / 0x4000
+
+// rebase returns the tents in the coordinates normalized to the limit, whose sum of the scalars is the same as the tent in the limit.
+// The tent must not cross zero, and its peak must not be zero.
+func (l *axisLimit) rebase(t tent) []*tentSolution {
+	var sols []*tentSolution
+	for _, s := range l.solve(t) {
+		if 0 == s.scalar {
+			continue
+		}
+		if s.tent != nil {
+			s.tent = &tent{
+				lower: l.renormalize(s.tent.lower, true),
+				peak:  l.renormalize(s.tent.peak, true),
+				upper: l.renormalize(s.tent.upper, true),
+			}
+			// the tent whose peak is at the new default is the constant.
+			if 0 == s.tent.peak {
+				s.tent = nil
+			}
+		}
+		sols = append(sols, s)
+	}
+	return sols
+}
+
+// solve splits the tent into the tents that reproduce its scalars in the limit, in the original normalized coordinates.
+// A limit that pins the axis has only the constant at the new default.
+func (l *axisLimit) solve(t tent) []*tentSolution {
+	if l.min == l.max {
+		return []*tentSolution{{scalar: t.scalar(l.def)}}
+	}
+	// mirror the problem such that the default is not after the peak.
+	if l.def > t.peak {
+		sols := l.reverseNegate().solve(t.reverseNegate())
+		for _, s := range sols {
+			if s.tent != nil {
+				r := s.tent.reverseNegate()
+				s.tent = &r
+			}
+		}
+		return sols
+	}
+	lower, peak, upper := t.lower, t.peak, t.upper
+	// the whole tent is outside of the limit.
+	if l.max <= lower && l.max < peak {
+		return nil
+	}
+	// the peak is outside of the limit; cut the tent at the maximum, and scale it.
+	if l.max < peak {
+		mult := t.scalar(l.max)
+		sols := l.solve(tent{lower: lower, peak: l.max, upper: l.max})
+		for _, s := range sols {
+			s.scalar *= mult
+		}
+		return sols
+	}
+	gain := t.scalar(l.def)
+	sols := []*tentSolution{{scalar: gain}}
+	// the positive side.
+	outGain := t.scalar(l.max)
+	if gain >= outGain {
+		// the tent falls below the gain before the maximum.
+		crossing := peak + (1-gain)*(upper-peak)
+		sols = append(sols, &tentSolution{scalar: 1 - gain, tent: &tent{lower: math.Max(lower, l.def), peak: peak, upper: crossing}})
+		if upper >= l.max {
+			sols = append(sols, &tentSolution{scalar: outGain - gain, tent: &tent{lower: crossing, peak: l.max, upper: l.max}})
+		} else {
+			// a peak can not be at the default.
+			if upper == l.def {
+				upper += tentEpsilon
+			}
+			sols = append(sols, &tentSolution{scalar: -gain, tent: &tent{lower: crossing, peak: upper, upper: l.max}})
+			sols = append(sols, &tentSolution{scalar: -gain, tent: &tent{lower: upper, peak: l.max, upper: l.max}})
+		}
+	} else {
+		// the tent is cut at the maximum, that needs two tents.
+		sols = append(sols, &tentSolution{scalar: 1 - gain, tent: &tent{lower: math.Max(l.def, lower), peak: peak, upper: l.max}})
+		if peak < l.max {
+			sols = append(sols, &tentSolution{scalar: outGain - gain, tent: &tent{lower: peak, peak: l.max, upper: l.max}})
+		}
+	}
+	// the negative side.
+	if lower <= l.min {
+		sols = append(sols, &tentSolution{scalar: t.scalar(l.min) - gain, tent: &tent{lower: l.min, peak: l.min, upper: l.def}})
+	} else {
+		// a peak can not be at the default.
+		if lower == l.def {
+			lower -= tentEpsilon
+		}
+		sols = append(sols, &tentSolution{scalar: -gain, tent: &tent{lower: l.min, peak: lower, upper: l.def}})
+		sols = append(sols, &tentSolution{scalar: -gain, tent: &tent{lower: l.min, peak: l.min, upper: lower}})
+	}
+	return sols
+}
+
+// variationRegion is a region of the variation space, that has a tent for every axis.
+type variationRegion []tent
+
+// regionSolution is a part of a region re-solved for the limits, whose deltas are multiplied by the scalar.
+type regionSolution struct {
+	scalar float64
+	region variationRegion
+}
+
+// tupleRegion returns the region of the tuple variation.
+// The axes whose ranges are invalid do not participate in the region, as they are ignored for the scalar.
+func tupleRegion(tv *TupleVariation, axisCount int) variationRegion {
+	r := make(variationRegion, axisCount)
+	intermediate := tv.IntermediateStartTuple != nil && tv.IntermediateEndTuple != nil
+	for i := range r {
+		if i >= len(tv.PeakTuple) {
+			continue
+		}
+		peak := tv.PeakTuple[i].Float()
+		lower, upper := math.Min(0, peak), math.Max(0, peak)
+		if intermediate && i < len(tv.IntermediateStartTuple) && i < len(tv.IntermediateEndTuple) {
+			lower, upper = tv.IntermediateStartTuple[i].Float(), tv.IntermediateEndTuple[i].Float()
+		}
+		r[i] = tent{lower: lower, peak: peak, upper: upper}.valid()
+	}
+	return r
+}
+
+// storeRegion returns the region of an item variation store.
+func storeRegion(region []*RegionAxisCoordinates) variationRegion {
+	r := make(variationRegion, len(region))
+	for i, c := range region {
+		r[i] = tent{lower: c.StartCoord.Float(), peak: c.PeakCoord.Float(), upper: c.EndCoord.Float()}.valid()
+	}
+	return r
+}
+
+// valid returns the tent, or the tent that does not participate if it is invalid.
+func (t tent) valid() tent {
+	if 0 == t.peak || t.lower > t.peak || t.peak > t.upper || (t.lower < 0 && t.upper > 0) {
+		return tent{}
+	}
+	return t
+}
+
+// isLimited returns true if any axis of the region is limited.
+func (r variationRegion) isLimited(limits []*axisLimit) bool {
+	for i, t := range r {
+		if i < len(limits) && limits[i] != nil && 0 != t.peak {
+			return true
+		}
+	}
+	return false
+}
+
+// isDefault returns true if no axis participates in the region, that is the constant deltas.
+func (r variationRegion) isDefault() bool {
+	for _, t := range r {
+		if 0 != t.peak {
+			return false
+		}
+	}
+	return true
+}
+
+// rebase re-solves the region for the limits, indexed by the axis index.
+func (r variationRegion) rebase(limits []*axisLimit) []*regionSolution {
+	sols := []*regionSolution{{scalar: 1, region: r}}
+	for i, l := range limits {
+		if l == nil || i >= len(r) {
+			continue
+		}
+		var next []*regionSolution
+		for _, s := range sols {
+			if 0 == s.region[i].peak {
+				next = append(next, s)
+				continue
+			}
+			for _, ts := range l.rebase(s.region[i]) {
+				region := append(variationRegion{}, s.region...)
+				region[i] = tent{}
+				if ts.tent != nil {
+					region[i] = *ts.tent
+				}
+				next = append(next, &regionSolution{scalar: s.scalar * ts.scalar, region: region})
+			}
+		}
+		sols = next
+	}
+	return sols
+}
+
+// quantize returns the region in 2.14 units, that is the region stored in the tables.
+func (r variationRegion) quantize() variationRegion {
+	q := make(variationRegion, len(r))
+	for i, t := range r {
+		q[i] = tent{lower: quantizeF2Dot14(t.lower), peak: quantizeF2Dot14(t.peak), upper: quantizeF2Dot14(t.upper)}
+		if 0 == q[i].peak {
+			q[i] = tent{}
+		}
+	}
+	return q
+}
+
+// quantizeF2Dot14 rounds the value to 2.14, in the range that 2.14 can express.
+func quantizeF2Dot14(v float64) float64 {
+	return F2Dot14(math.Max(-0x8000, math.Min(0x7FFF, math.Floor(v*0x4000+0.5)))).Float()
+}
+
+// regionAxisCoordinates returns the region in the item variation store.
+func (r variationRegion) regionAxisCoordinates() []*RegionAxisCoordinates {
+	coords := make([]*RegionAxisCoordinates, len(r))
+	for i, t := range r.quantize() {
+		coords[i] = &RegionAxisCoordinates{
+			StartCoord: F2Dot14(t.lower * 0x4000),
+			PeakCoord:  F2Dot14(t.peak * 0x4000),
+			EndCoord:   F2Dot14(t.upper * 0x4000),
+		}
+	}
+	return coords
+}
+
+// regionDeltas are the deltas of the points of a glyph for a region.
+type regionDeltas struct {
+	region variationRegion
+	dx, dy []float64
+}
+
+// appendRegionDeltas adds the deltas multiplied by the scalar into the deltas of the same region, or appends them for a new region.
+func appendRegionDeltas(deltas []*regionDeltas, region variationRegion, scalar float64, dx, dy []float64) []*regionDeltas {
+	region = region.quantize()
+	var d *regionDeltas
+	for _, rd := range deltas {
+		if fmt.Sprint(rd.region) == fmt.Sprint(region) {
+			d = rd
+			break
+		}
+	}
+	if d == nil {
+		d = &regionDeltas{region: region, dx: make([]float64, len(dx))}
+		if dy != nil {
+			d.dy = make([]float64, len(dy))
+		}
+		deltas = append(deltas, d)
+	}
+	for i := range dx {
+		d.dx[i] += scalar * dx[i]
+		if dy != nil {
+			d.dy[i] += scalar * dy[i]
+		}
+	}
+	return deltas
+}
+
+// tupleVariation returns the tuple variation of the rounded deltas for all points, or nil if all of them are zero.
+// The deltas of Y coordinates are omitted if dy is nil, as the deltas of the control values of cvar.
+// The intermediate region is used only if the region of an axis does not span from zero to the peak.
+func (d *regionDeltas) tupleVariation() *TupleVariation {
+	n := len(d.dx)
+	tv := &TupleVariation{
+		PeakTuple: make([]F2Dot14, len(d.region)),
+		Deltas:    make([]int32, n),
+	}
+	if d.dy != nil {
+		tv.DeltasY = make([]int32, n)
+	}
+	zero := true
+	for i := 0; i < n; i++ {
+		tv.Deltas[i] = int32(math.Floor(d.dx[i] + 0.5))
+		if 0 != tv.Deltas[i] {
+			zero = false
+		}
+		if d.dy != nil {
+			tv.DeltasY[i] = int32(math.Floor(d.dy[i] + 0.5))
+			if 0 != tv.DeltasY[i] {
+				zero = false
+			}
+		}
+	}
+	if zero {
+		return nil
+	}
+	intermediate := false
+	for i, t := range d.region {
+		tv.PeakTuple[i] = F2Dot14(t.peak * 0x4000)
+		if t.lower != math.Min(0, t.peak) || t.upper != math.Max(0, t.peak) {
+			intermediate = true
+		}
+	}
+	if intermediate {
+		tv.IntermediateStartTuple = make([]F2Dot14, len(d.region))
+		tv.IntermediateEndTuple = make([]F2Dot14, len(d.region))
+		for i, t := range d.region {
+			tv.IntermediateStartTuple[i] = F2Dot14(t.lower * 0x4000)
+			tv.IntermediateEndTuple[i] = F2Dot14(t.upper * 0x4000)
+		}
+	}
+	return tv
+}
+
+// optimize returns the tuple variation that has the deltas only of the points whose deltas can not be interpolated from the others, in the same way as IUP instruction,
+// or the tuple variation itself if every point needs its delta. The tuple variation must have the deltas for all points.
+// The points out of the contours, or all of them if ends is nil, have the deltas only if they are not zero, and a contour whose deltas are all zero has no deltas.
+func (tv *TupleVariation) optimize(points []GlyphPoint, ends []uint16) *TupleVariation {
+	n := len(points)
+	if tv.PointNumbers != nil || len(tv.Deltas) != n || (tv.DeltasY != nil && len(tv.DeltasY) != n) {
+		return tv
+	}
+	deltaY := func(i int) float64 {
+		if tv.DeltasY == nil {
+			return 0
+		}
+		return float64(tv.DeltasY[i])
+	}
+	touched := make([]bool, n)
+	for i := range touched {
+		touched[i] = 0 != tv.Deltas[i] || 0 != deltaY(i)
+	}
+	// whether the deltas of the contour from start to end are reproduced by the touched points.
+	reproduced := func(start, end int) bool {
+		dx, dy := make([]float64, n), make([]float64, n)
+		for i := range touched {
+			if touched[i] {
+				dx[i], dy[i] = float64(tv.Deltas[i]), deltaY(i)
+			}
+		}
+		interpolateUntouched(dx, dy, touched, points, ends)
+		for i := start; i <= end; i++ {
+			if math.Abs(dx[i]-float64(tv.Deltas[i])) > 0.5 || math.Abs(dy[i]-deltaY(i)) > 0.5 {
+				return false
+			}
+		}
+		return true
+	}
+	start := 0
+	for _, e := range ends {
+		end := int(e)
+		if end >= n {
+			break
+		}
+		zero := true
+		for i := start; i <= end; i++ {
+			if touched[i] {
+				zero = false
+			}
+		}
+		if !zero {
+			for i := start; i <= end; i++ {
+				touched[i] = true
+			}
+			for i := start; i <= end; i++ {
+				touched[i] = false
+				if !reproduced(start, end) {
+					touched[i] = true
+				}
+			}
+		}
+		start = end + 1
+	}
+	var pointNumbers []uint16
+	for i, t := range touched {
+		if t {
+			pointNumbers = append(pointNumbers, uint16(i))
+		}
+	}
+	if len(pointNumbers) == n {
+		return tv
+	}
+	optimized := &TupleVariation{
+		PeakTuple:              tv.PeakTuple,
+		IntermediateStartTuple: tv.IntermediateStartTuple,
+		IntermediateEndTuple:   tv.IntermediateEndTuple,
+		PointNumbers:           pointNumbers,
+		Deltas:                 make([]int32, len(pointNumbers)),
+	}
+	if tv.DeltasY != nil {
+		optimized.DeltasY = make([]int32, len(pointNumbers))
+	}
+	for k, p := range pointNumbers {
+		optimized.Deltas[k] = tv.Deltas[p]
+		if tv.DeltasY != nil {
+			optimized.DeltasY[k] = tv.DeltasY[p]
+		}
+	}
+	return optimized
+}
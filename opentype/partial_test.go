@@ -0,0 +1,163 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAxisLimitSolvePinned(t *testing.T) {
+	l := &axisLimit{min: 0.5, def: 0.5, max: 0.5, distanceNegative: 300, distancePositive: 500}
+	sols := l.rebase(tent{lower: 0, peak: 1, upper: 1})
+	if 1 != len(sols) || nil != sols[0].tent || 0.5 != sols[0].scalar {
+		t.Fatalf("solutions of a pinned axis are %v, want the constant of 0.5", sols)
+	}
+	if v := l.renormalize(1, true); 0 != v {
+		t.Errorf("coordinate of a pinned axis is %g, want 0", v)
+	}
+}
+
+func TestTupleVariationOptimize(t *testing.T) {
+	a, b := testSquare(0, 0, 100), testSquare(200, 0, 100)
+	points := append(append(append([]GlyphPoint{}, a.Points...), b.Points...), make([]GlyphPoint, 4)...)
+	ends := []uint16{3, 7}
+	// the first square is shifted, the second one is not moved, and the advance grows.
+	tv := &TupleVariation{
+		PeakTuple: []F2Dot14{0x4000},
+		Deltas:    []int32{10, 10, 10, 10, 0, 0, 0, 0, 0, 10, 0, 0},
+		DeltasY:   make([]int32, 12),
+	}
+	o := tv.optimize(points, ends)
+	if !reflect.DeepEqual(o.PointNumbers, []uint16{3, 9}) {
+		t.Fatalf("point numbers are %v, want [3 9]", o.PointNumbers)
+	}
+	tx, ty, ok := o.pointDeltas(points, ends)
+	if !ok {
+		t.Fatal("deltas of the optimized tuple variation are invalid")
+	}
+	for i := range points {
+		if tx[i] != float64(tv.Deltas[i]) || ty[i] != float64(tv.DeltasY[i]) {
+			t.Errorf("delta of point %d is (%g, %g), want (%d, %d)", i, tx[i], ty[i], tv.Deltas[i], tv.DeltasY[i])
+		}
+	}
+	// the deltas that can not be interpolated are kept.
+	tv.Deltas = []int32{10, -10, 10, -10, 0, 0, 0, 0, 0, 0, 0, 0}
+	if o := tv.optimize(points, ends); !reflect.DeepEqual(o.PointNumbers, []uint16{0, 1, 2, 3}) {
+		t.Errorf("point numbers are %v, want [0 1 2 3]", o.PointNumbers)
+	}
+	// the control values of cvar have no contours.
+	cvar := &TupleVariation{PeakTuple: []F2Dot14{0x4000}, Deltas: []int32{0, 3, 0}}
+	if o := cvar.optimize(make([]GlyphPoint, 3), nil); !reflect.DeepEqual(o.PointNumbers, []uint16{1}) || !reflect.DeepEqual(o.Deltas, []int32{3}) || nil != o.DeltasY {
+		t.Errorf("optimized cvar is %+v, want the delta of value 1", *o)
+	}
+}
+
+func TestLimitAxesPinsAxis(t *testing.T) {
+	font := newTestFont(t, []*Glyph{{}, testSquare(0, 0, 100)}, []uint16{500, 600})
+	wght, wdth := String2Tag("wght"), String2Tag("wdth")
+	font.Fvar = &Fvar{
+		MajorVersion: 1,
+		Axes: []*VariationAxisRecord{
+			{AxisTag: wght, MinValue: 100 << 16, DefaultValue: 400 << 16, MaxValue: 900 << 16},
+			{AxisTag: wdth, MinValue: 50 << 16, DefaultValue: 100 << 16, MaxValue: 200 << 16},
+		},
+		Instances: []*InstanceRecord{
+			{Coordinates: []Fixed{700 << 16, 150 << 16}},
+			{Coordinates: []Fixed{700 << 16, 100 << 16}},
+		},
+	}
+	font.Gvar = &Gvar{
+		MajorVersion: 1,
+		AxisCount:    2,
+		Variations: [][]*TupleVariation{nil, {
+			{PeakTuple: []F2Dot14{0x4000, 0}, Deltas: []int32{100, 100, 100, 100, 0, 100, 0, 0}, DeltasY: make([]int32, 8)},
+			{PeakTuple: []F2Dot14{0, 0x4000}, PointNumbers: []uint16{2, 3, 5}, Deltas: []int32{50, 50, 50}, DeltasY: []int32{0, 0, 0}},
+		}},
+	}
+	limited, err := font.LimitAxes(map[Tag]AxisLimit{
+		wght: {Min: 300, Default: 400, Max: 700},
+		wdth: {Min: 150, Default: 150, Max: 150},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	axes := limited.Axes()
+	if 150 != axes[1].Min || 150 != axes[1].Default || 150 != axes[1].Max {
+		t.Errorf("pinned axis is %+v", axes[1])
+	}
+	if 1 != len(limited.NamedInstances()) {
+		t.Errorf("%d named instances remain, want 1", len(limited.NamedInstances()))
+	}
+	for _, tv := range limited.Gvar.Variations[1] {
+		if 0 != tv.PeakTuple[1] {
+			t.Errorf("tuple variation %+v varies the pinned axis", *tv)
+		}
+		if nil == tv.PointNumbers {
+			t.Errorf("tuple variation %+v has the deltas for all points", *tv)
+		}
+	}
+	for _, w := range []float64{300, 350, 400, 550, 700} {
+		g, pp, err := font.GlyphOutlineAt(1, map[Tag]float64{wght: w, wdth: 150})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lg, lpp, err := limited.GlyphOutlineAt(1, map[Tag]float64{wght: w, wdth: 150})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(g.Points, lg.Points) || pp.AdvanceWidth() != lpp.AdvanceWidth() {
+			t.Errorf("glyph at %g is %v and %d, want %v and %d", w, lg.Points, lpp.AdvanceWidth(), g.Points, pp.AdvanceWidth())
+		}
+	}
+}
+
+func TestLimitAxesMetrics(t *testing.T) {
+	font := newTestGvarFont(t)
+	font.Post = &Post{Version: PostVersion3, UnderlinePosition: -100, UnderlineThickness: 50}
+	font.Gasp = &Gasp{Version: 1, GaspRanges: []*GaspRange{{RangeMaxPPEM: 8, RangeGaspBehavior: GaspDogray}, {RangeMaxPPEM: 0xFFFF}}}
+	font.Mvar = &Mvar{
+		MajorVersion: 1,
+		ValueRecords: []*MetricValueRecord{
+			{ValueTag: String2Tag("undo"), DeltaSetInnerIndex: 0},
+			{ValueTag: String2Tag("unds"), DeltaSetInnerIndex: 1},
+			{ValueTag: String2Tag("gsp0"), DeltaSetInnerIndex: 2},
+		},
+		ItemVarStore: newTestMetricsVariationStore(-20, 10, 4),
+	}
+	font.Hvar = &Hvar{MajorVersion: 1, ItemVarStore: newTestMetricsVariationStore(0, 100, 0)}
+	font = writeTestFont(t, font)
+	wght := String2Tag("wght")
+	limited, err := font.LimitAxes(map[Tag]AxisLimit{wght: {Min: 650, Default: 650, Max: 900}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited = writeTestFont(t, limited)
+	// the deltas at the new default are applied.
+	if p := limited.Post; -110 != p.UnderlinePosition || 55 != p.UnderlineThickness {
+		t.Errorf("underline is %d, %d, want -110, 55", p.UnderlinePosition, p.UnderlineThickness)
+	}
+	if v := limited.Gasp.GaspRanges[0].RangeMaxPPEM; 10 != v {
+		t.Errorf("rangeMaxPPEM is %d, want 10", v)
+	}
+	if aw, _ := limited.Hmtx.get(1); 650 != aw {
+		t.Errorf("advance width is %d, want 650", aw)
+	}
+	for _, w := range []float64{650, 775, 900} {
+		location := map[Tag]float64{wght: w}
+		for _, tag := range []Tag{String2Tag("undo"), String2Tag("unds"), String2Tag("gsp0")} {
+			v, err := font.MetricAt(tag, location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lv, err := limited.MetricAt(tag, location); err != nil || v != lv {
+				t.Errorf("metric %s at %g is %d, %v, want %d", tag, w, lv, err, v)
+			}
+		}
+		aw, err := font.AdvanceAt(1, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if law, err := limited.AdvanceAt(1, location); err != nil || aw != law {
+			t.Errorf("advance width at %g is %d, %v, want %d", w, law, err, aw)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package opentype
+
+import (
+	"os"
+)
+
+// Post is a "post" table.
+// This table contains additional information needed to use TrueType or OpenType fonts on PostScript printers,
+// such as the underline metrics and the PostScript names of the glyphs.
+type Post struct {
+	// 0x00010000 for version 1.0, 0x00020000 for version 2.0, 0x00025000 for version 2.5 (deprecated), 0x00030000 for version 3.0.
+	Version Fixed
+	// Italic angle in counter-clockwise degrees from the vertical. Zero for upright text, negative for text that leans to the right (forward).
+	ItalicAngle Fixed
+	// Suggested y-coordinate of the top of the underline.
+	UnderlinePosition int16
+	// Suggested values for the underline thickness.
+	UnderlineThickness int16
+	// Set to 0 if the font is proportionally spaced, non-zero if the font is not proportionally spaced (i.e. monospaced).
+	IsFixedPitch uint32
+	// Minimum memory usage when an OpenType font is downloaded.
+	MinMemType42 uint32
+	// Maximum memory usage when an OpenType font is downloaded.
+	MaxMemType42 uint32
+	// Minimum memory usage when an OpenType font is downloaded as a Type 1 font.
+	MinMemType1 uint32
+	// Maximum memory usage when an OpenType font is downloaded as a Type 1 font.
+	MaxMemType1 uint32
+	// Array of indices into the list of the standard Macintosh glyph names, or 258 and above for Names, only for version 2.0.
+	GlyphNameIndex []uint16
+	// The glyph names that are not the standard Macintosh ones, only for version 2.0.
+	Names []string
+	// The body following the header of the other versions, that is kept as it is.
+	data []byte
+}
+
+const (
+	// PostVersion1 : the glyphs have the standard Macintosh glyph names.
+	PostVersion1 = Fixed(0x00010000)
+	// PostVersion2 : the glyph names are given by GlyphNameIndex and Names.
+	PostVersion2 = Fixed(0x00020000)
+	// PostVersion3 : no glyph names are provided.
+	PostVersion3 = Fixed(0x00030000)
+)
+
+// postHeaderSize is the size of the header, that is common to all versions.
+const postHeaderSize = 32
+
+// postNumStandardNames is the number of the standard Macintosh glyph names, that the indices of Names begin at.
+const postNumStandardNames = 258
+
+func parsePost(f *os.File, offset, length uint32) (p *Post, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	p = &Post{}
+	r.read(&(p.Version))
+	r.read(&(p.ItalicAngle))
+	r.read(&(p.UnderlinePosition))
+	r.read(&(p.UnderlineThickness))
+	r.read(&(p.IsFixedPitch))
+	r.read(&(p.MinMemType42))
+	r.read(&(p.MaxMemType42))
+	r.read(&(p.MinMemType1))
+	r.read(&(p.MaxMemType1))
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse post header: %s")
+	}
+	switch p.Version {
+	case PostVersion1, PostVersion3:
+	case PostVersion2:
+		numGlyphs := r.uint16()
+		p.GlyphNameIndex = r.uint16s(int(numGlyphs))
+		// the names are the Pascal strings, that are stored until the end of the table.
+		p.Names = make([]string, 0)
+		for !r.hasErr() && r.tell() < int64(length) {
+			var n uint8
+			r.read(&n)
+			if !r.available(int(n), 1) {
+				break
+			}
+			name := make([]byte, n)
+			r.read(name)
+			p.Names = append(p.Names, string(name))
+		}
+	default:
+		if length > postHeaderSize {
+			p.data = make([]byte, length-postHeaderSize)
+			r.read(p.data)
+		}
+	}
+	return p, r.errorf("failed to parse post: %s")
+}
+
+// filter returns post of the glyphs of f, whose glyph IDs are the indices in f.
+// The glyph names of version 2.0 are remapped, and the other versions that have the data per glyph become version 3.0.
+func (p *Post) filter(f []uint16) *Post {
+	new := *p
+	new.data = nil
+	switch p.Version {
+	case PostVersion1, PostVersion3:
+		return &new
+	case PostVersion2:
+	default:
+		new.Version = PostVersion3
+		return &new
+	}
+	new.GlyphNameIndex = make([]uint16, len(f))
+	new.Names = make([]string, 0)
+	m := make(map[uint16]uint16)
+	for i, gid := range f {
+		index := uint16(0)
+		if int(gid) < len(p.GlyphNameIndex) {
+			index = p.GlyphNameIndex[gid]
+		}
+		if index >= postNumStandardNames {
+			n := int(index - postNumStandardNames)
+			if n >= len(p.Names) {
+				// the name that does not exist is the “.notdef”.
+				index = 0
+			} else if ni, ok := m[index]; ok {
+				index = ni
+			} else {
+				m[index] = uint16(postNumStandardNames + len(new.Names))
+				new.Names = append(new.Names, p.Names[n])
+				index = m[index]
+			}
+		}
+		new.GlyphNameIndex[i] = index
+	}
+	return &new
+}
+
+// Tag is table name.
+func (p *Post) Tag() Tag {
+	return String2Tag("post")
+}
+
+// store writes binary expression of this table.
+func (p *Post) store(w *errWriter) {
+	w.write(&(p.Version))
+	w.write(&(p.ItalicAngle))
+	w.write(&(p.UnderlinePosition))
+	w.write(&(p.UnderlineThickness))
+	w.write(&(p.IsFixedPitch))
+	w.write(&(p.MinMemType42))
+	w.write(&(p.MaxMemType42))
+	w.write(&(p.MinMemType1))
+	w.write(&(p.MaxMemType1))
+	switch p.Version {
+	case PostVersion1, PostVersion3:
+	case PostVersion2:
+		numGlyphs := uint16(len(p.GlyphNameIndex))
+		w.write(&numGlyphs)
+		w.write(p.GlyphNameIndex)
+		for _, name := range p.Names {
+			w.write(uint8(len(name)))
+			w.write([]byte(name))
+		}
+	default:
+		w.write(p.data)
+	}
+	padSpace(w, p.Length())
+}
+
+// CheckSum for this table.
+func (p *Post) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(p)
+}
+
+// Length returns the size(byte) of this table.
+func (p *Post) Length() uint32 {
+	switch p.Version {
+	case PostVersion1, PostVersion3:
+		return postHeaderSize
+	case PostVersion2:
+		l := uint32(postHeaderSize + 2 + 2*len(p.GlyphNameIndex))
+		for _, name := range p.Names {
+			l += uint32(1 + len(name))
+		}
+		return l
+	}
+	return uint32(postHeaderSize + len(p.data))
+}
+
+// Exists returns true if this is not nil.
+func (p *Post) Exists() bool {
+	return p != nil
+}
+
+// clone returns a deep copy of this table.
+func (p *Post) clone() *Post {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	if p.GlyphNameIndex != nil {
+		c.GlyphNameIndex = append([]uint16{}, p.GlyphNameIndex...)
+	}
+	if p.Names != nil {
+		c.Names = append([]string{}, p.Names...)
+	}
+	if p.data != nil {
+		c.data = append([]byte{}, p.data...)
+	}
+	return &c
+}
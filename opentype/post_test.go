@@ -0,0 +1,51 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPostRoundTrip(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Post = &Post{
+		Version:            PostVersion2,
+		ItalicAngle:        -12 << 16,
+		UnderlinePosition:  -100,
+		UnderlineThickness: 50,
+		IsFixedPitch:       1,
+		GlyphNameIndex:     []uint16{0, 258, 259, 36, 258, 260, 3, 0},
+		Names:              []string{"f_i", "alpha", "beta"},
+	}
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Post, parsed.Post) {
+		t.Errorf("post is %+v, want %+v", *parsed.Post, *font.Post)
+	}
+	// the custom names are renumbered in the order of the new glyphs, and the unused one is dropped.
+	subset, err := parsed.FilterGlyf([]uint16{0, 5, 2, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset = writeTestFont(t, subset)
+	if want := []uint16{0, 258, 259, 260}; !reflect.DeepEqual(want, subset.Post.GlyphNameIndex) {
+		t.Errorf("glyph name indices of the subset are %v, want %v", subset.Post.GlyphNameIndex, want)
+	}
+	if want := []string{"beta", "alpha", "f_i"}; !reflect.DeepEqual(want, subset.Post.Names) {
+		t.Errorf("glyph names of the subset are %v, want %v", subset.Post.Names, want)
+	}
+	if -100 != subset.Post.UnderlinePosition || 50 != subset.Post.UnderlineThickness {
+		t.Errorf("underline of the subset is %d, %d", subset.Post.UnderlinePosition, subset.Post.UnderlineThickness)
+	}
+	// the body of version 2.5 is kept, and the subset has no glyph names.
+	font.Post = &Post{Version: 0x00025000, UnderlinePosition: -100, data: []byte{0, 8, 0, 1, 2, 3, 4, 5, 6, 7}}
+	parsed = writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Post, parsed.Post) {
+		t.Errorf("post of version 2.5 is %+v, want %+v", *parsed.Post, *font.Post)
+	}
+	subset, err = parsed.FilterGlyf([]uint16{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Post{Version: PostVersion3, UnderlinePosition: -100}); !reflect.DeepEqual(want, writeTestFont(t, subset).Post) {
+		t.Errorf("post of the subset is %+v, want %+v", *subset.Post, *want)
+	}
+}
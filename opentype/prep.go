@@ -53,3 +53,13 @@ func (prep *Prep) Length() uint32 {
 func (prep *Prep) Exists() bool {
 	return prep != nil
 }
+
+// clone returns a deep copy of this table.
+func (prep *Prep) clone() *Prep {
+	if prep == nil {
+		return nil
+	}
+	return &Prep{
+		Values: append([]uint8{}, prep.Values...),
+	}
+}
@@ -0,0 +1,311 @@
+package opentype
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Rasterizer converts paths into anti-aliased coverage of the pixels with the nonzero winding rule.
+// The coordinates are in pixels, where x grows rightward and y grows downward.
+// The coverage of each pixel is calculated from the exact area covered by the line segments, and curves are flattened into them.
+type Rasterizer struct {
+	width  int
+	height int
+	// accumulated signed area of each row, that has an extra cell at the right end for the segments beyond the width.
+	area []float32
+	// start point of the current contour.
+	firstX float64
+	firstY float64
+	// current point.
+	penX float64
+	penY float64
+}
+
+// NewRasterizer returns a Rasterizer for the image of the size.
+func NewRasterizer(width, height int) *Rasterizer {
+	z := &Rasterizer{}
+	z.Reset(width, height)
+	return z
+}
+
+// Reset clears the paths, and changes the size of the image.
+func (z *Rasterizer) Reset(width, height int) {
+	if width < 0 {
+		width = 0
+	}
+	if height < 0 {
+		height = 0
+	}
+	z.width, z.height = width, height
+	n := (width + 1) * height
+	if cap(z.area) < n {
+		z.area = make([]float32, n)
+	} else {
+		z.area = z.area[:n]
+		for i := range z.area {
+			z.area[i] = 0
+		}
+	}
+	z.firstX, z.firstY, z.penX, z.penY = 0, 0, 0, 0
+}
+
+// MoveTo closes the current contour, and starts a new contour at the point.
+func (z *Rasterizer) MoveTo(x, y float64) {
+	z.ClosePath()
+	z.firstX, z.firstY, z.penX, z.penY = x, y, x, y
+}
+
+// LineTo adds a line from the current point to the point.
+func (z *Rasterizer) LineTo(x, y float64) {
+	z.line(z.penX, z.penY, x, y)
+	z.penX, z.penY = x, y
+}
+
+// QuadTo adds a quadratic Bézier curve from the current point to (x, y) with the control point (cx, cy).
+func (z *Rasterizer) QuadTo(cx, cy, x, y float64) {
+	ax, ay := z.penX, z.penY
+	n := flatteningSegments(deviation(ax, ay, cx, cy, x, y))
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		z.LineTo(u*u*ax+2*u*t*cx+t*t*x, u*u*ay+2*u*t*cy+t*t*y)
+	}
+	z.LineTo(x, y)
+}
+
+// CubeTo adds a cubic Bézier curve from the current point to (x, y) with the control points (c1x, c1y) and (c2x, c2y).
+func (z *Rasterizer) CubeTo(c1x, c1y, c2x, c2y, x, y float64) {
+	ax, ay := z.penX, z.penY
+	// the second derivative of a cubic curve is up to 3 times that of a quadratic curve of the same deviation.
+	n := flatteningSegments(3 * math.Max(deviation(ax, ay, c1x, c1y, c2x, c2y), deviation(c1x, c1y, c2x, c2y, x, y)))
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		z.LineTo(
+			u*u*u*ax+3*u*u*t*c1x+3*u*t*t*c2x+t*t*t*x,
+			u*u*u*ay+3*u*u*t*c1y+3*u*t*t*c2y+t*t*t*y,
+		)
+	}
+	z.LineTo(x, y)
+}
+
+// ClosePath adds a line from the current point to the start point of the current contour.
+func (z *Rasterizer) ClosePath() {
+	if z.penX != z.firstX || z.penY != z.firstY {
+		z.LineTo(z.firstX, z.firstY)
+	}
+}
+
+// deviation returns how far the middle point is from the midpoint of the others, that is twice the distance from the chord to the curve.
+func deviation(ax, ay, bx, by, cx, cy float64) float64 {
+	return math.Hypot(ax-2*bx+cx, ay-2*by+cy)
+}
+
+// flatteningSegments returns the number of line segments for a curve of the deviation, so that the error is at most about 0.1 pixels.
+func flatteningSegments(dev float64) int {
+	const tolerance = 0.1
+	n := int(math.Ceil(math.Sqrt(dev / (4 * tolerance))))
+	if n < 1 {
+		return 1
+	}
+	if n > 100 {
+		return 100
+	}
+	return n
+}
+
+// line accumulates the signed area between the line and the left edge of the image.
+// The area of each pixel that the line crosses is split between it and the pixel on its right,
+// so that the running sum along the row is the winding coverage.
+func (z *Rasterizer) line(ax, ay, bx, by float64) {
+	if ay == by {
+		return
+	}
+	dir := float32(1)
+	if ay > by {
+		dir = -1
+		ax, ay, bx, by = bx, by, ax, ay
+	}
+	dxdy := (bx - ax) / (by - ay)
+	y := int(math.Floor(ay))
+	yMax := int(math.Ceil(by))
+	if yMax > z.height {
+		yMax = z.height
+	}
+	x := ax
+	if y < 0 {
+		x += (0 - ay) * dxdy
+		y = 0
+		ay = 0
+	}
+	stride := z.width + 1
+	for ; y < yMax; y++ {
+		dy := math.Min(float64(y+1), by) - math.Max(float64(y), ay)
+		xNext := x + dy*dxdy
+		row := z.area[y*stride : (y+1)*stride]
+		d := float32(dy) * dir
+		x0, x1 := x, xNext
+		if x0 > x1 {
+			x0, x1 = x1, x0
+		}
+		x0i := int(math.Floor(x0))
+		x1i := int(math.Ceil(x1))
+		if x1i <= x0i+1 {
+			// the line stays in a pixel.
+			xm := float32(0.5*(x+xNext)) - float32(x0i)
+			z.add(row, x0i, d-d*xm)
+			z.add(row, x0i+1, d*xm)
+		} else {
+			s := float32(1 / (x1 - x0))
+			x0f := float32(x0) - float32(x0i)
+			a0 := 0.5 * s * (1 - x0f) * (1 - x0f)
+			x1f := float32(x1) - float32(x1i) + 1
+			am := 0.5 * s * x1f * x1f
+			z.add(row, x0i, d*a0)
+			if x1i == x0i+2 {
+				z.add(row, x0i+1, d*(1-a0-am))
+			} else {
+				a1 := s * (1.5 - x0f)
+				z.add(row, x0i+1, d*(a1-a0))
+				for xi := x0i + 2; xi < x1i-1; xi++ {
+					z.add(row, xi, d*s)
+				}
+				a2 := a1 + s*float32(x1i-x0i-3)
+				z.add(row, x1i-1, d*(1-a2-am))
+			}
+			z.add(row, x1i, d*am)
+		}
+		x = xNext
+		ay = float64(y + 1)
+	}
+}
+
+// add adds the area to the cell of the row, clamping the index in the row.
+func (z *Rasterizer) add(row []float32, i int, a float32) {
+	if i < 0 {
+		i = 0
+	} else if i > z.width {
+		i = z.width
+	}
+	row[i] += a
+}
+
+// Draw sets the accumulated coverage to the image, whose origin corresponds to the origin of the rasterizer.
+// The pixels outside of the rasterizer are not changed.
+func (z *Rasterizer) Draw(dst *image.Alpha) {
+	z.ClosePath()
+	r := dst.Rect.Intersect(image.Rect(dst.Rect.Min.X, dst.Rect.Min.Y, dst.Rect.Min.X+z.width, dst.Rect.Min.Y+z.height))
+	stride := z.width + 1
+	for y := 0; y < r.Dy(); y++ {
+		acc := float32(0)
+		row := z.area[y*stride : (y+1)*stride]
+		pix := dst.Pix[y*dst.Stride:]
+		for x := 0; x < r.Dx(); x++ {
+			acc += row[x]
+			a := acc
+			if a < 0 {
+				a = -a
+			}
+			if a > 1 {
+				a = 1
+			}
+			pix[x] = uint8(a*0xFF + 0.5)
+		}
+	}
+}
+
+// AddGlyph adds the outline of the glyph scaled by the scale, with the origin of the glyph at (x, y).
+// The y axis of the glyph is flipped, so that the glyph stands upright on the image.
+// The consecutive off-curve points have the implied on-curve point at the midpoint of them.
+func (z *Rasterizer) AddGlyph(g *Glyph, scale, x, y float64) {
+	z.addContours(g.EndPtsOfContours, len(g.Points), func(i int) (float64, float64, bool) {
+		p := g.Points[i]
+		return x + float64(p.X)*scale, y - float64(p.Y)*scale, p.OnCurve
+	})
+}
+
+// AddHintedGlyph adds the grid-fitted outline of the glyph, with the origin of the glyph at (x, y).
+func (z *Rasterizer) AddHintedGlyph(g *HintedGlyph, x, y float64) {
+	z.addContours(g.EndPtsOfContours, len(g.Points), func(i int) (float64, float64, bool) {
+		p := g.Points[i]
+		return x + p.X, y - p.Y, p.OnCurve
+	})
+}
+
+// addContours adds the quadratic contours of the points, that point returns in pixels.
+func (z *Rasterizer) addContours(endPts []uint16, n int, point func(i int) (float64, float64, bool)) {
+	start := 0
+	for _, e := range endPts {
+		end := int(e)
+		if end >= n || end < start {
+			break
+		}
+		// begins at an on-curve point, or the midpoint of the last and the first off-curve points.
+		sx, sy, on := point(start)
+		first, last := start+1, end
+		if !on {
+			lx, ly, lastOn := point(end)
+			if lastOn {
+				sx, sy = lx, ly
+				first, last = start, end-1
+			} else {
+				sx, sy = (lx+sx)/2, (ly+sy)/2
+				first = start
+			}
+		}
+		start = end + 1
+		z.MoveTo(sx, sy)
+		var cx, cy float64
+		control := false
+		for i := first; i <= last; i++ {
+			qx, qy, on := point(i)
+			switch {
+			case on && control:
+				z.QuadTo(cx, cy, qx, qy)
+				control = false
+			case on:
+				z.LineTo(qx, qy)
+			case control:
+				z.QuadTo(cx, cy, (cx+qx)/2, (cy+qy)/2)
+				cx, cy = qx, qy
+			default:
+				cx, cy = qx, qy
+				control = true
+			}
+		}
+		if control {
+			z.QuadTo(cx, cy, sx, sy)
+		}
+		z.ClosePath()
+	}
+}
+
+// GlyphImage renders the glyph at the size in pixels per em, with the origin of the glyph at (x, y) of the pixel grid.
+// x and y may have fractions for subpixel positioning, and y grows downward.
+// The bounds of the returned image are the pixels that the glyph covers, which are empty for a glyph without outline.
+func (font *Font) GlyphImage(gid uint16, size, x, y float64) (*image.Alpha, error) {
+	err := tableRequired(font.Head, font.Glyf)
+	if err != nil {
+		return nil, fmt.Errorf("rendering glyph failed: %s", err)
+	}
+	o, err := font.Glyf.Outline(gid)
+	if err != nil {
+		return nil, fmt.Errorf("rendering glyph failed: %s", err)
+	}
+	if 0 == len(o.Points) || 0 == font.Head.UnitsPerEm {
+		return image.NewAlpha(image.Rectangle{}), nil
+	}
+	scale := size / float64(font.Head.UnitsPerEm)
+	bounds := image.Rect(
+		int(math.Floor(x+float64(o.XMin)*scale)),
+		int(math.Floor(y-float64(o.YMax)*scale)),
+		int(math.Ceil(x+float64(o.XMax)*scale)),
+		int(math.Ceil(y-float64(o.YMin)*scale)),
+	)
+	z := NewRasterizer(bounds.Dx(), bounds.Dy())
+	z.AddGlyph(o, scale, x-float64(bounds.Min.X), y-float64(bounds.Min.Y))
+	dst := image.NewAlpha(bounds)
+	z.Draw(dst)
+	return dst, nil
+}
@@ -0,0 +1,167 @@
+package opentype
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// testRectangle adds the rectangle, clockwise on the image unless reverse is true.
+func testRectangle(z *Rasterizer, x0, y0, x1, y1 float64, reverse bool) {
+	z.MoveTo(x0, y0)
+	if reverse {
+		z.LineTo(x0, y1)
+		z.LineTo(x1, y1)
+		z.LineTo(x1, y0)
+	} else {
+		z.LineTo(x1, y0)
+		z.LineTo(x1, y1)
+		z.LineTo(x0, y1)
+	}
+	z.ClosePath()
+}
+
+// testCoverage returns the sum of the coverage of the pixels, where 1 is a fully covered pixel.
+func testCoverage(img *image.Alpha) float64 {
+	sum := 0.0
+	for _, a := range img.Pix {
+		sum += float64(a) / 0xFF
+	}
+	return sum
+}
+
+func TestRasterizerRectangle(t *testing.T) {
+	for _, reverse := range []bool{false, true} {
+		z := NewRasterizer(4, 4)
+		// covers the pixels (1, 1) and (2, 1), and the upper halves of (1, 2) and (2, 2).
+		testRectangle(z, 1, 1, 3, 2.5, reverse)
+		img := image.NewAlpha(image.Rect(0, 0, 4, 4))
+		z.Draw(img)
+		want := []uint8{
+			0, 0, 0, 0,
+			0, 0xFF, 0xFF, 0,
+			0, 0x80, 0x80, 0,
+			0, 0, 0, 0,
+		}
+		if string(want) != string(img.Pix) {
+			t.Errorf("coverage of the rectangle in reverse %v is %v, want %v", reverse, img.Pix, want)
+		}
+	}
+}
+
+func TestRasterizerNonzeroWinding(t *testing.T) {
+	tests := []struct {
+		name    string
+		reverse bool
+		want    uint8
+	}{
+		{"same direction", false, 0xFF},
+		{"opposite direction", true, 0},
+	}
+	for _, tt := range tests {
+		z := NewRasterizer(8, 8)
+		testRectangle(z, 0, 0, 8, 8, false)
+		testRectangle(z, 2, 2, 6, 6, tt.reverse)
+		img := image.NewAlpha(image.Rect(0, 0, 8, 8))
+		z.Draw(img)
+		if a := img.AlphaAt(4, 4).A; tt.want != a {
+			t.Errorf("%s: coverage of the inner rectangle is %d, want %d", tt.name, a, tt.want)
+		}
+		if a := img.AlphaAt(1, 1).A; 0xFF != a {
+			t.Errorf("%s: coverage of the outer rectangle is %d, want 255", tt.name, a)
+		}
+	}
+}
+
+func TestRasterizerAntiAliasing(t *testing.T) {
+	z := NewRasterizer(4, 4)
+	// the diagonal of the triangle crosses the pixels in the middle.
+	z.MoveTo(0, 0)
+	z.LineTo(4, 4)
+	z.LineTo(0, 4)
+	z.ClosePath()
+	img := image.NewAlpha(image.Rect(0, 0, 4, 4))
+	z.Draw(img)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := uint8(0)
+			switch {
+			case x == y:
+				want = 0x80
+			case x < y:
+				want = 0xFF
+			}
+			if a := img.AlphaAt(x, y).A; want != a {
+				t.Errorf("coverage of the pixel (%d, %d) is %d, want %d", x, y, a, want)
+			}
+		}
+	}
+}
+
+func TestRasterizerCurves(t *testing.T) {
+	// the circle of the radius 10 at (12, 12) by the cubic curves, and the same circle by the quadratic curves of 8 arcs.
+	const r, c = 10.0, 12.0
+	k := r * 4 * (math.Sqrt2 - 1) / 3
+	cubic := NewRasterizer(24, 24)
+	cubic.MoveTo(c+r, c)
+	cubic.CubeTo(c+r, c+k, c+k, c+r, c, c+r)
+	cubic.CubeTo(c-k, c+r, c-r, c+k, c-r, c)
+	cubic.CubeTo(c-r, c-k, c-k, c-r, c, c-r)
+	cubic.CubeTo(c+k, c-r, c+r, c-k, c+r, c)
+	quad := NewRasterizer(24, 24)
+	quad.MoveTo(c+r, c)
+	d := r / math.Cos(math.Pi/8)
+	for i := 1; i <= 8; i++ {
+		a := float64(i) * math.Pi / 4
+		m := a - math.Pi/8
+		quad.QuadTo(c+d*math.Cos(m), c+d*math.Sin(m), c+r*math.Cos(a), c+r*math.Sin(a))
+	}
+	for name, z := range map[string]*Rasterizer{"cubic": cubic, "quadratic": quad} {
+		img := image.NewAlpha(image.Rect(0, 0, 24, 24))
+		z.Draw(img)
+		// the flattened curves are off by 0.1 pixels at most.
+		if area := testCoverage(img); math.Abs(area-math.Pi*r*r) > 2*math.Pi*r*0.1 {
+			t.Errorf("area of the %s circle is %g, want %g", name, area, math.Pi*r*r)
+		}
+		if a := img.AlphaAt(12, 12).A; 0xFF != a {
+			t.Errorf("coverage of the center of the %s circle is %d, want 255", name, a)
+		}
+		if a := img.AlphaAt(3, 3).A; 0 != a {
+			t.Errorf("coverage of the corner of the %s circle is %d, want 0", name, a)
+		}
+	}
+}
+
+func TestGlyphImage(t *testing.T) {
+	font := newTestSubsetFont(t)
+	// the square of the size 101 at (10, 0) is scaled to 10.1 pixels at (1, 0).
+	img, err := font.GlyphImage(1, 100, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(1, 9, 12, 20); want != img.Rect {
+		t.Fatalf("bounds of the glyph image are %v, want %v", img.Rect, want)
+	}
+	tests := []struct {
+		x, y int
+		want uint8
+	}{
+		{1, 19, 0xFF},
+		{10, 10, 0xFF},
+		{11, 15, 0x1A},
+		{5, 9, 0x1A},
+		{11, 9, 0x03},
+	}
+	for _, tt := range tests {
+		if a := img.AlphaAt(tt.x, tt.y).A; tt.want != a {
+			t.Errorf("coverage of the pixel (%d, %d) is %d, want %d", tt.x, tt.y, a, tt.want)
+		}
+	}
+	img, err = font.GlyphImage(0, 100, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !img.Rect.Empty() {
+		t.Errorf("image of the empty glyph has the bounds %v", img.Rect)
+	}
+}
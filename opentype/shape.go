@@ -0,0 +1,769 @@
+package opentype
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// ShapedGlyph is a glyph positioned by Font.Shape, in font design units.
+type ShapedGlyph struct {
+	// Glyph ID.
+	GlyphID uint16
+	// Byte index in the text of the first character of the cluster that the glyph belongs to.
+	Cluster int
+	// How much the line advances after drawing this glyph.
+	XAdvance int32
+	YAdvance int32
+	// How much the glyph moves from the current point without affecting the line.
+	XOffset int32
+	YOffset int32
+}
+
+// ShapeOptions are options of Font.Shape.
+type ShapeOptions struct {
+	// Script tag of the text, such as "latn", "cyrl", "grek" and "hani".
+	Script Tag
+	// Language system tag of the text, or LangSysTagDefault.
+	Language Tag
+	// Features to be applied.
+	// If this is nil, DefaultFeatures or DefaultVerticalFeatures are applied.
+	Features []Tag
+	// True for vertical layout, in which the line advances downward with negative YAdvance.
+	Vertical bool
+}
+
+var (
+	// DefaultFeatures are the features applied to horizontal text by default.
+	DefaultFeatures = []Tag{
+		String2Tag("ccmp"), String2Tag("locl"), String2Tag("rlig"), String2Tag("abvm"), String2Tag("blwm"),
+		String2Tag("mark"), String2Tag("mkmk"), String2Tag("calt"), String2Tag("clig"), String2Tag("curs"),
+		String2Tag("dist"), String2Tag("kern"), String2Tag("liga"), String2Tag("rclt"),
+	}
+	// DefaultVerticalFeatures are the features applied to vertical text by default.
+	DefaultVerticalFeatures = []Tag{
+		String2Tag("ccmp"), String2Tag("locl"), String2Tag("rlig"), String2Tag("abvm"), String2Tag("blwm"),
+		String2Tag("mark"), String2Tag("mkmk"), String2Tag("vert"),
+	}
+)
+
+const (
+	attachNone = iota
+	attachMark
+	attachCursive
+)
+
+// shapingGlyph is a glyph in the buffer of the shaper.
+type shapingGlyph struct {
+	gid     uint16
+	cluster int
+	class   uint16
+	// the ligature that the mark belongs to, and the component of the ligature.
+	ligID   int
+	ligComp int
+	// the number of the components if the glyph is a ligature.
+	ligComps int
+	xAdvance int32
+	yAdvance int32
+	xOffset  int32
+	yOffset  int32
+	// the index of the glyph that this glyph is attached to.
+	attachTo   int
+	attachType int
+}
+
+type shaper struct {
+	font     *Font
+	glyphs   []*shapingGlyph
+	vertical bool
+	table    *LayoutTable
+	apply    func(l *Lookup, st LookupSubtable, i int) (bool, int)
+	ligID    int
+	// depth of nested lookups applied by contextual subtables.
+	depth int
+}
+
+// maxContextDepth limits the nesting of contextual lookups, that may be recursive in broken fonts.
+const maxContextDepth = 8
+
+// Shape converts the text into positioned glyphs with the "cmap", "GSUB" and "GPOS" tables.
+// The lookups of the features are applied in the order of the lookup list, as the OpenType specification requires,
+// so that the features take effect in the order the font designs, like ccmp, locl, liga, clig, calt and vert.
+// Only simple scripts written from left to right or from top to bottom, such as Latin, Cyrillic, Greek and CJK, are supported.
+func (font *Font) Shape(text string, opts *ShapeOptions) ([]*ShapedGlyph, error) {
+	err := tableRequired(font.CMap, font.Hmtx)
+	if err != nil {
+		return nil, fmt.Errorf("shaping failed: %s", err)
+	}
+	if opts == nil {
+		opts = &ShapeOptions{}
+	}
+	cmap := font.CMap.UnicodeCMap()
+	if cmap == nil {
+		return nil, fmt.Errorf("shaping failed: no Unicode cmap")
+	}
+	features := opts.Features
+	if features == nil {
+		features = DefaultFeatures
+		if opts.Vertical {
+			features = DefaultVerticalFeatures
+		}
+	}
+	script, lang := opts.Script, opts.Language
+	if 0 == script {
+		script = ScriptTagDefault
+	}
+	if 0 == lang {
+		lang = LangSysTagDefault
+	}
+	s := &shaper{
+		font:     font,
+		glyphs:   make([]*shapingGlyph, 0, len(text)),
+		vertical: opts.Vertical,
+	}
+	for i, r := range text {
+		g := &shapingGlyph{
+			gid:      cmap[int32(r)],
+			cluster:  i,
+			attachTo: -1,
+		}
+		class := GlyphClassBase
+		if unicode.Is(unicode.Mn, r) {
+			class = GlyphClassMark
+		}
+		s.setGlyph(g, g.gid, class)
+		s.glyphs = append(s.glyphs, g)
+	}
+	if font.Gsub.Exists() {
+		s.table = &font.Gsub.LayoutTable
+		s.apply = s.substitute
+		for _, l := range s.lookups(script, lang, features) {
+			s.applyLookup(l)
+		}
+	}
+	for _, g := range s.glyphs {
+		s.defaultPosition(g)
+	}
+	if font.Gpos.Exists() {
+		s.table = &font.Gpos.LayoutTable
+		s.apply = s.position
+		for _, l := range s.lookups(script, lang, features) {
+			s.applyLookup(l)
+		}
+	}
+	// the "kern" table is ignored if GPOS has the "kern" feature.
+	kern := String2Tag("kern")
+	if !s.vertical && font.Kern.Exists() && containsTag(features, kern) && !(font.Gpos.Exists() && font.Gpos.hasFeature(kern)) {
+		for i := 0; i+1 < len(s.glyphs); i++ {
+			s.glyphs[i].xAdvance += int32(font.Kern.Kerning(s.glyphs[i].gid, s.glyphs[i+1].gid))
+		}
+	}
+	return s.finish(), nil
+}
+
+func containsTag(tags []Tag, tag Tag) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// setGlyph changes the glyph ID, and the glyph class by GDEF or the fallback.
+func (s *shaper) setGlyph(g *shapingGlyph, gid uint16, fallback uint16) {
+	g.gid = gid
+	g.class = fallback
+	if s.font.Gdef.Exists() && s.font.Gdef.GlyphClassDef != nil {
+		g.class = s.font.Gdef.GlyphClass(gid)
+	}
+}
+
+// lookups returns the lookups of the features of the language system, in the order of the lookup list.
+func (s *shaper) lookups(script, lang Tag, features []Tag) []*Lookup {
+	enabled := make(map[Tag]bool)
+	for _, tag := range features {
+		enabled[tag] = true
+	}
+	indices := make(map[uint16]bool)
+	var required *FeatureRecord
+	ls := s.table.LangSys(script, lang)
+	if ls != nil && int(ls.RequiredFeatureIndex) < len(s.table.FeatureList) {
+		required = s.table.FeatureList[ls.RequiredFeatureIndex]
+	}
+	for _, fr := range s.table.Features(script, lang) {
+		if fr == required || enabled[fr.Tag] {
+			for _, li := range fr.Feature.LookupListIndices {
+				indices[li] = true
+			}
+		}
+	}
+	sorted := make([]uint16, 0, len(indices))
+	for li := range indices {
+		if int(li) < len(s.table.LookupList) {
+			sorted = append(sorted, li)
+		}
+	}
+	sortGlyphs(sorted)
+	lookups := make([]*Lookup, len(sorted))
+	for i, li := range sorted {
+		lookups[i] = s.table.LookupList[li]
+	}
+	return lookups
+}
+
+// applyLookup applies the lookup to the whole buffer.
+func (s *shaper) applyLookup(l *Lookup) {
+	if GsubLookupTypeReverseChainingSingle == l.LookupType && s.table == &s.font.Gsub.LayoutTable {
+		for i := len(s.glyphs) - 1; i >= 0; i-- {
+			if s.skip(s.glyphs[i], l) {
+				continue
+			}
+			for _, st := range l.Subtables {
+				if ok, _ := s.apply(l, st, i); ok {
+					break
+				}
+			}
+		}
+		return
+	}
+	for i := 0; i < len(s.glyphs); {
+		if s.skip(s.glyphs[i], l) {
+			i++
+			continue
+		}
+		next := i + 1
+		for _, st := range l.Subtables {
+			if ok, n := s.apply(l, st, i); ok {
+				next = n
+				break
+			}
+		}
+		i = next
+	}
+}
+
+// applyAt applies the first applicable subtable of the lookup at the glyph, used by contextual subtables.
+func (s *shaper) applyAt(l *Lookup, i int) {
+	if i >= len(s.glyphs) || s.skip(s.glyphs[i], l) {
+		return
+	}
+	for _, st := range l.Subtables {
+		if ok, _ := s.apply(l, st, i); ok {
+			return
+		}
+	}
+}
+
+// skip returns true if the lookup ignores the glyph by the lookup flag.
+func (s *shaper) skip(g *shapingGlyph, l *Lookup) bool {
+	switch g.class {
+	case GlyphClassBase:
+		return 0 != l.LookupFlag&LookupFlagIgnoreBaseGlyphs
+	case GlyphClassLigature:
+		return 0 != l.LookupFlag&LookupFlagIgnoreLigatures
+	case GlyphClassMark:
+		if 0 != l.LookupFlag&LookupFlagIgnoreMarks {
+			return true
+		}
+		if 0 != l.LookupFlag&LookupFlagUseMarkFilteringSet {
+			return !s.font.Gdef.InMarkGlyphSet(l.MarkFilteringSet, g.gid)
+		}
+		if t := l.LookupFlag & LookupFlagMarkAttachmentTypeMask; 0 != t {
+			return s.font.Gdef.MarkAttachClass(g.gid) != t>>8
+		}
+	}
+	return false
+}
+
+// next returns the index of the next glyph that the lookup does not ignore, or -1.
+func (s *shaper) next(i int, l *Lookup) int {
+	for j := i + 1; j < len(s.glyphs); j++ {
+		if !s.skip(s.glyphs[j], l) {
+			return j
+		}
+	}
+	return -1
+}
+
+// prev returns the index of the previous glyph that the lookup does not ignore, or -1.
+func (s *shaper) prev(i int, l *Lookup) int {
+	for j := i - 1; 0 <= j; j-- {
+		if !s.skip(s.glyphs[j], l) {
+			return j
+		}
+	}
+	return -1
+}
+
+// matchInput returns the positions of the input sequence that begins at i and continues with count glyphs that satisfy match, or nil.
+func (s *shaper) matchInput(i int, l *Lookup, count int, match func(k int, gid uint16) bool) []int {
+	positions := []int{i}
+	j := i
+	for k := 0; k < count; k++ {
+		j = s.next(j, l)
+		if j < 0 || !match(k, s.glyphs[j].gid) {
+			return nil
+		}
+		positions = append(positions, j)
+	}
+	return positions
+}
+
+// matchBacktrack returns true if the glyphs before i satisfy match, in the order of the distance.
+func (s *shaper) matchBacktrack(i int, l *Lookup, count int, match func(k int, gid uint16) bool) bool {
+	j := i
+	for k := 0; k < count; k++ {
+		j = s.prev(j, l)
+		if j < 0 || !match(k, s.glyphs[j].gid) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLookahead returns true if the glyphs after i satisfy match.
+func (s *shaper) matchLookahead(i int, l *Lookup, count int, match func(k int, gid uint16) bool) bool {
+	j := i
+	for k := 0; k < count; k++ {
+		j = s.next(j, l)
+		if j < 0 || !match(k, s.glyphs[j].gid) {
+			return false
+		}
+	}
+	return true
+}
+
+// replace replaces the glyph at i with the glyphs.
+func (s *shaper) replace(i int, gids []uint16) {
+	g := s.glyphs[i]
+	inserted := make([]*shapingGlyph, len(gids))
+	for k, gid := range gids {
+		c := *g
+		s.setGlyph(&c, gid, g.class)
+		inserted[k] = &c
+	}
+	rest := append(inserted, s.glyphs[i+1:]...)
+	s.glyphs = append(s.glyphs[:i], rest...)
+}
+
+// applyContext applies the sequence context at i.
+func (s *shaper) applyContext(l *Lookup, c *SequenceContext, i int) (bool, int) {
+	gid := s.glyphs[i].gid
+	var positions []int
+	var records []*SequenceLookupRecord
+	switch c.Format {
+	case 1, 2:
+		index, ok := c.Coverage.Index(gid)
+		if !ok {
+			return false, 0
+		}
+		classOf := func(cd *ClassDef) func(k int, gid uint16) uint16 {
+			return func(k int, gid uint16) uint16 {
+				if 1 == c.Format {
+					return gid
+				}
+				return cd.Class(gid)
+			}
+		}
+		if 2 == c.Format {
+			index = int(c.InputClassDef.Class(gid))
+		}
+		if index >= len(c.RuleSets) {
+			return false, 0
+		}
+		backtrack, input, lookahead := classOf(c.BacktrackClassDef), classOf(c.InputClassDef), classOf(c.LookaheadClassDef)
+		for _, rule := range c.RuleSets[index] {
+			positions = s.matchInput(i, l, len(rule.Input), func(k int, gid uint16) bool {
+				return input(k, gid) == rule.Input[k]
+			})
+			if positions == nil {
+				continue
+			}
+			last := positions[len(positions)-1]
+			if !s.matchBacktrack(i, l, len(rule.Backtrack), func(k int, gid uint16) bool {
+				return backtrack(k, gid) == rule.Backtrack[k]
+			}) || !s.matchLookahead(last, l, len(rule.Lookahead), func(k int, gid uint16) bool {
+				return lookahead(k, gid) == rule.Lookahead[k]
+			}) {
+				positions = nil
+				continue
+			}
+			records = rule.SeqLookupRecords
+			break
+		}
+	case 3:
+		if 0 == len(c.InputCoverages) || !c.InputCoverages[0].Contains(gid) {
+			return false, 0
+		}
+		positions = s.matchInput(i, l, len(c.InputCoverages)-1, func(k int, gid uint16) bool {
+			return c.InputCoverages[k+1].Contains(gid)
+		})
+		if positions != nil {
+			last := positions[len(positions)-1]
+			if !s.matchBacktrack(i, l, len(c.BacktrackCoverages), func(k int, gid uint16) bool {
+				return c.BacktrackCoverages[k].Contains(gid)
+			}) || !s.matchLookahead(last, l, len(c.LookaheadCoverages), func(k int, gid uint16) bool {
+				return c.LookaheadCoverages[k].Contains(gid)
+			}) {
+				positions = nil
+			}
+		}
+		records = c.SeqLookupRecords
+	}
+	if positions == nil {
+		return false, 0
+	}
+	end := positions[len(positions)-1] + 1
+	if s.depth >= maxContextDepth {
+		return true, end
+	}
+	s.depth++
+	for _, record := range records {
+		if int(record.SequenceIndex) >= len(positions) || int(record.LookupListIndex) >= len(s.table.LookupList) {
+			continue
+		}
+		pos := positions[record.SequenceIndex]
+		before := len(s.glyphs)
+		s.applyAt(s.table.LookupList[record.LookupListIndex], pos)
+		delta := len(s.glyphs) - before
+		// the positions after the changed glyph are shifted by the glyphs inserted or removed.
+		for k, p := range positions {
+			if p > pos {
+				positions[k] = p + delta
+			}
+		}
+		end += delta
+	}
+	s.depth--
+	if end <= i {
+		end = i + 1
+	}
+	return true, end
+}
+
+// substitute applies the GSUB subtable at i, and returns the index to continue.
+func (s *shaper) substitute(l *Lookup, st LookupSubtable, i int) (bool, int) {
+	g := s.glyphs[i]
+	switch st := st.(type) {
+	case *SingleSubst:
+		if out, ok := st.Substitutes[g.gid]; ok {
+			s.setGlyph(g, out, g.class)
+			return true, i + 1
+		}
+	case *MultipleSubst:
+		if seq, ok := st.Sequences[g.gid]; ok {
+			s.replace(i, seq)
+			return true, i + len(seq)
+		}
+	case *AlternateSubst:
+		if alternates, ok := st.Alternates[g.gid]; ok && 0 < len(alternates) {
+			s.setGlyph(g, alternates[0], g.class)
+			return true, i + 1
+		}
+	case *LigatureSubst:
+		for _, lig := range st.LigatureSets[g.gid] {
+			positions := s.matchInput(i, l, len(lig.ComponentGlyphIDs), func(k int, gid uint16) bool {
+				return gid == lig.ComponentGlyphIDs[k]
+			})
+			if positions == nil {
+				continue
+			}
+			s.ligate(i, positions, lig.LigatureGlyph)
+			return true, i + 1
+		}
+	case *SequenceContext:
+		return s.applyContext(l, st, i)
+	case *ReverseChainSingleSubst:
+		index, ok := st.Coverage.Index(g.gid)
+		if !ok || index >= len(st.SubstituteGlyphIDs) {
+			return false, 0
+		}
+		if !s.matchBacktrack(i, l, len(st.BacktrackCoverages), func(k int, gid uint16) bool {
+			return st.BacktrackCoverages[k].Contains(gid)
+		}) || !s.matchLookahead(i, l, len(st.LookaheadCoverages), func(k int, gid uint16) bool {
+			return st.LookaheadCoverages[k].Contains(gid)
+		}) {
+			return false, 0
+		}
+		s.setGlyph(g, st.SubstituteGlyphIDs[index], g.class)
+		return true, i + 1
+	}
+	return false, 0
+}
+
+// ligate replaces the glyphs at the positions with the ligature glyph.
+// The marks skipped between the components remain after the ligature, and remember the component they belong to.
+func (s *shaper) ligate(i int, positions []int, gid uint16) {
+	s.ligID++
+	first := s.glyphs[i]
+	first.ligComps = len(positions)
+	for k := 1; k < len(positions); k++ {
+		for j := positions[k-1] + 1; j < positions[k]; j++ {
+			if GlyphClassMark == s.glyphs[j].class {
+				s.glyphs[j].ligID = s.ligID
+				s.glyphs[j].ligComp = k - 1
+			}
+		}
+	}
+	// the marks after the last component belong to it.
+	for j := positions[len(positions)-1] + 1; j < len(s.glyphs) && GlyphClassMark == s.glyphs[j].class; j++ {
+		s.glyphs[j].ligID = s.ligID
+		s.glyphs[j].ligComp = len(positions) - 1
+	}
+	first.ligID = s.ligID
+	s.setGlyph(first, gid, GlyphClassLigature)
+	for k := len(positions) - 1; 1 <= k; k-- {
+		p := positions[k]
+		s.glyphs = append(s.glyphs[:p], s.glyphs[p+1:]...)
+	}
+}
+
+// defaultPosition sets the advance of the glyph by the metrics tables.
+func (s *shaper) defaultPosition(g *shapingGlyph) {
+	advanceWidth, _ := s.font.Hmtx.get(g.gid)
+	if !s.vertical {
+		g.xAdvance = int32(advanceWidth)
+		return
+	}
+	var advanceHeight int32
+	if s.font.Vmtx.Exists() {
+		a, _ := s.font.Vmtx.get(g.gid)
+		advanceHeight = int32(a)
+	} else if s.font.Hhea.Exists() {
+		advanceHeight = int32(s.font.Hhea.Ascender) - int32(s.font.Hhea.Descender)
+	}
+	g.yAdvance = -advanceHeight
+	// the origin of the glyph moves from the horizontal origin to the vertical origin.
+	g.xOffset = -int32(advanceWidth) / 2
+	if y, err := s.font.VerticalOrigin(g.gid); err == nil {
+		g.yOffset = -int32(y)
+	}
+}
+
+func (g *shapingGlyph) adjust(v *ValueRecord) {
+	if v == nil {
+		return
+	}
+	g.xOffset += int32(v.XPlacement)
+	g.yOffset += int32(v.YPlacement)
+	g.xAdvance += int32(v.XAdvance)
+	g.yAdvance += int32(v.YAdvance)
+}
+
+// position applies the GPOS subtable at i, and returns the index to continue.
+func (s *shaper) position(l *Lookup, st LookupSubtable, i int) (bool, int) {
+	g := s.glyphs[i]
+	switch st := st.(type) {
+	case *SinglePos:
+		if v, ok := st.ValueRecord(g.gid); ok {
+			g.adjust(v)
+			return true, i + 1
+		}
+	case *PairPos:
+		if !st.Coverage.Contains(g.gid) {
+			return false, 0
+		}
+		j := s.next(i, l)
+		if j < 0 {
+			return false, 0
+		}
+		v1, v2, ok := st.Pair(g.gid, s.glyphs[j].gid)
+		if !ok {
+			return false, 0
+		}
+		g.adjust(v1)
+		s.glyphs[j].adjust(v2)
+		if 0 != st.ValueFormat2 {
+			return true, j + 1
+		}
+		return true, j
+	case *CursivePos:
+		return s.applyCursive(l, st, i)
+	case *MarkBasePos:
+		markIndex, ok := st.MarkCoverage.Index(g.gid)
+		if !ok || markIndex >= len(st.MarkArray) {
+			return false, 0
+		}
+		j := s.prevBase(i)
+		if j < 0 {
+			return false, 0
+		}
+		baseIndex, ok := st.BaseCoverage.Index(s.glyphs[j].gid)
+		if !ok || baseIndex >= len(st.BaseArray) {
+			return false, 0
+		}
+		return s.attachMark(i, j, st.MarkArray[markIndex], st.BaseArray[baseIndex])
+	case *MarkLigPos:
+		markIndex, ok := st.MarkCoverage.Index(g.gid)
+		if !ok || markIndex >= len(st.MarkArray) {
+			return false, 0
+		}
+		j := s.prevBase(i)
+		if j < 0 {
+			return false, 0
+		}
+		ligIndex, ok := st.LigatureCoverage.Index(s.glyphs[j].gid)
+		if !ok || ligIndex >= len(st.LigatureArray) || 0 == len(st.LigatureArray[ligIndex]) {
+			return false, 0
+		}
+		components := st.LigatureArray[ligIndex]
+		comp := len(components) - 1
+		if 0 != g.ligID && g.ligID == s.glyphs[j].ligID && g.ligComp < len(components) {
+			comp = g.ligComp
+		}
+		return s.attachMark(i, j, st.MarkArray[markIndex], components[comp])
+	case *MarkMarkPos:
+		markIndex, ok := st.Mark1Coverage.Index(g.gid)
+		if !ok || markIndex >= len(st.Mark1Array) {
+			return false, 0
+		}
+		j := s.prev(i, l)
+		if j < 0 || GlyphClassMark != s.glyphs[j].class {
+			return false, 0
+		}
+		mark2Index, ok := st.Mark2Coverage.Index(s.glyphs[j].gid)
+		if !ok || mark2Index >= len(st.Mark2Array) {
+			return false, 0
+		}
+		return s.attachMark(i, j, st.Mark1Array[markIndex], st.Mark2Array[mark2Index])
+	case *SequenceContext:
+		return s.applyContext(l, st, i)
+	}
+	return false, 0
+}
+
+// prevBase returns the index of the previous glyph that is not a mark, or -1.
+func (s *shaper) prevBase(i int) int {
+	for j := i - 1; 0 <= j; j-- {
+		if GlyphClassMark != s.glyphs[j].class {
+			return j
+		}
+	}
+	return -1
+}
+
+// attachMark attaches the mark glyph at i to the glyph at j by the anchors of the mark class.
+func (s *shaper) attachMark(i, j int, mark *MarkRecord, anchors []*Anchor) (bool, int) {
+	if int(mark.MarkClass) >= len(anchors) || anchors[mark.MarkClass] == nil || mark.MarkAnchor == nil {
+		return false, 0
+	}
+	base := anchors[mark.MarkClass]
+	g := s.glyphs[i]
+	g.xOffset = int32(base.XCoordinate) - int32(mark.MarkAnchor.XCoordinate)
+	g.yOffset = int32(base.YCoordinate) - int32(mark.MarkAnchor.YCoordinate)
+	g.attachTo = j
+	g.attachType = attachMark
+	return true, i + 1
+}
+
+// applyCursive connects the exit anchor of the previous glyph to the entry anchor of the glyph at i.
+func (s *shaper) applyCursive(l *Lookup, st *CursivePos, i int) (bool, int) {
+	g := s.glyphs[i]
+	index, ok := st.Coverage.Index(g.gid)
+	if !ok || index >= len(st.EntryExitRecords) || st.EntryExitRecords[index].EntryAnchor == nil {
+		return false, 0
+	}
+	j := s.prev(i, l)
+	if j < 0 {
+		return false, 0
+	}
+	prevIndex, ok := st.Coverage.Index(s.glyphs[j].gid)
+	if !ok || prevIndex >= len(st.EntryExitRecords) || st.EntryExitRecords[prevIndex].ExitAnchor == nil {
+		return false, 0
+	}
+	entry := st.EntryExitRecords[index].EntryAnchor
+	exit := st.EntryExitRecords[prevIndex].ExitAnchor
+	p := s.glyphs[j]
+	if s.vertical {
+		p.yAdvance = int32(exit.YCoordinate) + p.yOffset
+		d := int32(entry.YCoordinate) + g.yOffset
+		g.yAdvance -= d
+		g.yOffset -= d
+	} else {
+		p.xAdvance = int32(exit.XCoordinate) + p.xOffset
+		d := int32(entry.XCoordinate) + g.xOffset
+		g.xAdvance -= d
+		g.xOffset -= d
+	}
+	child, parent := g, p
+	childIndex, parentIndex := i, j
+	dx, dy := int32(exit.XCoordinate)-int32(entry.XCoordinate), int32(exit.YCoordinate)-int32(entry.YCoordinate)
+	if 0 != l.LookupFlag&LookupFlagRightToLeft {
+		child, parent = p, g
+		childIndex, parentIndex = j, i
+		dx, dy = -dx, -dy
+	}
+	if parent.attachTo == childIndex {
+		// break a cycle.
+		parent.attachTo = -1
+		parent.attachType = attachNone
+	}
+	child.attachTo = parentIndex
+	child.attachType = attachCursive
+	if s.vertical {
+		child.xOffset = dx
+	} else {
+		child.yOffset = dy
+	}
+	return true, i + 1
+}
+
+// finish zeroes the advances of marks, resolves the offsets of the attached glyphs, and creates the result.
+func (s *shaper) finish() []*ShapedGlyph {
+	for _, g := range s.glyphs {
+		if GlyphClassMark == g.class && s.font.Gpos.Exists() {
+			g.xAdvance = 0
+			g.yAdvance = 0
+		}
+	}
+	resolved := make([]bool, len(s.glyphs))
+	var resolve func(i, depth int)
+	resolve = func(i, depth int) {
+		g := s.glyphs[i]
+		if resolved[i] {
+			return
+		}
+		resolved[i] = true
+		j := g.attachTo
+		if j < 0 || j >= len(s.glyphs) || attachNone == g.attachType || depth > len(s.glyphs) {
+			return
+		}
+		resolve(j, depth+1)
+		p := s.glyphs[j]
+		if attachCursive == g.attachType {
+			if s.vertical {
+				g.xOffset += p.xOffset
+			} else {
+				g.yOffset += p.yOffset
+			}
+			return
+		}
+		g.xOffset += p.xOffset
+		g.yOffset += p.yOffset
+		// the mark is drawn relative to the current point, that has moved by the advances from the attached glyph.
+		if j < i {
+			for k := j; k < i; k++ {
+				g.xOffset -= s.glyphs[k].xAdvance
+				g.yOffset -= s.glyphs[k].yAdvance
+			}
+		} else {
+			for k := i; k < j; k++ {
+				g.xOffset += s.glyphs[k].xAdvance
+				g.yOffset += s.glyphs[k].yAdvance
+			}
+		}
+	}
+	ret := make([]*ShapedGlyph, len(s.glyphs))
+	for i, g := range s.glyphs {
+		resolve(i, 0)
+		ret[i] = &ShapedGlyph{
+			GlyphID:  g.gid,
+			Cluster:  g.cluster,
+			XAdvance: g.xAdvance,
+			YAdvance: g.yAdvance,
+			XOffset:  g.xOffset,
+			YOffset:  g.yOffset,
+		}
+	}
+	return ret
+}
@@ -0,0 +1,128 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestShapeFont creates a font of newTestSubsetFont with GSUB and GPOS, whose characters are
+// 'f', 'i', 'A', 'V' and U+0301 for the glyphs 1 to 5.
+// GSUB has the "liga" feature of the ligature of "fi", and the required feature of the ligature of "if" for the default script.
+// The "latn" script has the required feature index out of the feature list.
+// GPOS has the "kern" feature of the pair "AV", and the "mark" feature that attaches U+0301 to 'A'.
+func newTestShapeFont(t *testing.T) *Font {
+	font := newTestSubsetFont(t)
+	font.CMap = &CMap{
+		Header: &CMapHeader{NumTables: 1},
+		EncodingRecords: []*EncodingRecord{
+			{PlatformID: PlatformIDWindows, EncodingID: EncodingIDWindowsUnicodeBMP, Subtable: newEncodingRecordSubtableFormat4(0, map[int32]uint16{
+				'f': 1, 'i': 2, 'A': 3, 'V': 4, 0x0301: 5,
+			})},
+		},
+	}
+	font.Gsub = &Gsub{LayoutTable{
+		ScriptList: []*ScriptRecord{
+			{Tag: ScriptTagDefault, Script: &Script{
+				DefaultLangSys: &LangSys{RequiredFeatureIndex: 1, FeatureIndices: []uint16{0}},
+			}},
+			{Tag: String2Tag("latn"), Script: &Script{
+				DefaultLangSys: &LangSys{RequiredFeatureIndex: 9, FeatureIndices: []uint16{0}},
+			}},
+		},
+		FeatureList: []*FeatureRecord{
+			{Tag: String2Tag("liga"), Feature: &Feature{LookupListIndices: []uint16{0}}},
+			{Tag: String2Tag("ss01"), Feature: &Feature{LookupListIndices: []uint16{1}}},
+		},
+		LookupList: []*Lookup{
+			{LookupType: GsubLookupTypeLigature, Subtables: []LookupSubtable{
+				&LigatureSubst{LigatureSets: map[uint16][]*Ligature{1: {{LigatureGlyph: 7, ComponentGlyphIDs: []uint16{2}}}}},
+			}},
+			{LookupType: GsubLookupTypeLigature, Subtables: []LookupSubtable{
+				&LigatureSubst{LigatureSets: map[uint16][]*Ligature{2: {{LigatureGlyph: 6, ComponentGlyphIDs: []uint16{1}}}}},
+			}},
+		},
+	}}
+	font.Gpos = &Gpos{LayoutTable{
+		ScriptList: []*ScriptRecord{
+			{Tag: ScriptTagDefault, Script: &Script{
+				DefaultLangSys: &LangSys{RequiredFeatureIndex: RequiredFeatureIndexNone, FeatureIndices: []uint16{0, 1}},
+			}},
+		},
+		FeatureList: []*FeatureRecord{
+			{Tag: String2Tag("kern"), Feature: &Feature{LookupListIndices: []uint16{0}}},
+			{Tag: String2Tag("mark"), Feature: &Feature{LookupListIndices: []uint16{1}}},
+		},
+		LookupList: []*Lookup{
+			{LookupType: GposLookupTypePair, Subtables: []LookupSubtable{
+				&PairPos{
+					Format:       1,
+					Coverage:     &Coverage{Glyphs: []uint16{3}},
+					ValueFormat1: ValueFormatXAdvance,
+					PairSets: [][]*PairValueRecord{
+						{{SecondGlyph: 4, Value1: &ValueRecord{XAdvance: -80}}},
+					},
+				},
+			}},
+			{LookupType: GposLookupTypeMarkToBase, Subtables: []LookupSubtable{
+				&MarkBasePos{
+					MarkCoverage:   &Coverage{Glyphs: []uint16{5}},
+					BaseCoverage:   &Coverage{Glyphs: []uint16{3}},
+					MarkClassCount: 1,
+					MarkArray:      []*MarkRecord{{MarkClass: 0, MarkAnchor: &Anchor{Format: 1, XCoordinate: 50}}},
+					BaseArray:      [][]*Anchor{{{Format: 1, XCoordinate: 250, YCoordinate: 700}}},
+				},
+			}},
+		},
+	}}
+	return writeTestFont(t, font)
+}
+
+func TestShape(t *testing.T) {
+	font := newTestShapeFont(t)
+	latn := String2Tag("latn")
+	tests := []struct {
+		name string
+		text string
+		opts *ShapeOptions
+		want []ShapedGlyph
+	}{
+		{"ligature", "fi", nil, []ShapedGlyph{{GlyphID: 7, XAdvance: 570}}},
+		{"ligature disabled", "fi", &ShapeOptions{Features: []Tag{}}, []ShapedGlyph{
+			{GlyphID: 1, XAdvance: 510},
+			{GlyphID: 2, Cluster: 1, XAdvance: 520},
+		}},
+		{"required feature", "if", &ShapeOptions{Features: []Tag{}}, []ShapedGlyph{{GlyphID: 6, XAdvance: 560}}},
+		// the first feature is not required if the required feature index is out of the feature list.
+		{"required feature out of range", "fi", &ShapeOptions{Script: latn, Features: []Tag{}}, []ShapedGlyph{
+			{GlyphID: 1, XAdvance: 510},
+			{GlyphID: 2, Cluster: 1, XAdvance: 520},
+		}},
+		{"pair kerning", "AV", nil, []ShapedGlyph{
+			{GlyphID: 3, XAdvance: 450},
+			{GlyphID: 4, Cluster: 1, XAdvance: 540},
+		}},
+		{"pair kerning disabled", "AV", &ShapeOptions{Features: []Tag{String2Tag("liga")}}, []ShapedGlyph{
+			{GlyphID: 3, XAdvance: 530},
+			{GlyphID: 4, Cluster: 1, XAdvance: 540},
+		}},
+		// the mark has no advance, and is drawn at the anchor of the base from the current point after the base.
+		{"mark attachment", "A\u0301V", nil, []ShapedGlyph{
+			{GlyphID: 3, XAdvance: 530},
+			{GlyphID: 5, Cluster: 1, XOffset: 200 - 530, YOffset: 700},
+			{GlyphID: 4, Cluster: 3, XAdvance: 540},
+		}},
+	}
+	for _, tt := range tests {
+		glyphs, err := font.Shape(tt.text, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]ShapedGlyph, len(glyphs))
+		for i, g := range glyphs {
+			got[i] = *g
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%s: glyphs are %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package opentype
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Stat is a "STAT" table.
+// The style attributes table describes the design axes of the font family and the names of the values on them,
+// that the applications use to name the styles of the fonts and the instances of the variable fonts.
+type Stat struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Design axes, referred by the axis indices of the axis values.
+	DesignAxes []*DesignAxisRecord
+	// Axis values, that name the values or the ranges of the design axes.
+	AxisValues []*AxisValue
+	// The name ID of the name used when all axis values are elided, such as "Regular", since version 1.1.
+	// The subfamily name is used if it is zero.
+	ElidedFallbackNameID NameID
+}
+
+// DesignAxisRecord is a design axis.
+type DesignAxisRecord struct {
+	// Tag identifying the axis of design variation, such as "wght".
+	AxisTag Tag
+	// The name ID for entries in the "name" table that provide a display string for this axis.
+	AxisNameID NameID
+	// A value that applications can use to determine the order of the names of the axis values in a style name.
+	AxisOrdering uint16
+}
+
+// AxisValue is a name of a value or a range of a design axis, or a combination of the values of the design axes.
+type AxisValue struct {
+	// Format identifier: 1 for a value, 2 for a range, 3 for a value with its linked value, and 4 for a combination of the values.
+	Format uint16
+	// Index of the design axis, for format 1, 2 and 3.
+	AxisIndex uint16
+	// Flags of the axis value, see AxisValueFlag* constants.
+	Flags uint16
+	// The name ID for entries in the "name" table that provide a display string for this axis value.
+	ValueNameID NameID
+	// The value of the axis for format 1 and 3, or the nominal value of the range for format 2.
+	Value Fixed
+	// The minimum and the maximum values of the range, for format 2.
+	RangeMinValue Fixed
+	RangeMaxValue Fixed
+	// The value of the style linked to this value, such as Bold for Regular, for format 3.
+	LinkedValue Fixed
+	// The values of the design axes, for format 4.
+	AxisValueRecords []*AxisValueRecord
+}
+
+// AxisValueRecord is a value of a design axis in a combination of format 4.
+type AxisValueRecord struct {
+	// Index of the design axis.
+	AxisIndex uint16
+	// A numeric value for this axis.
+	Value Fixed
+}
+
+const (
+	// AxisValueFlagOlderSiblingFontAttribute : the axis value represents the attribute of other fonts in the family.
+	AxisValueFlagOlderSiblingFontAttribute = uint16(0x0001)
+	// AxisValueFlagElidableAxisValueName : the name of the axis value can be omitted in a style name, such as "Regular".
+	AxisValueFlagElidableAxisValueName = uint16(0x0002)
+)
+
+func parseStat(f *os.File, offset, length uint32) (s *Stat, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	s = &Stat{}
+	s.MajorVersion = r.uint16()
+	s.MinorVersion = r.uint16()
+	designAxisSize := int(r.uint16())
+	designAxisCount := int(r.uint16())
+	designAxesOffset := r.uint32()
+	axisValueCount := int(r.uint16())
+	offsetToAxisValueOffsets := r.uint32()
+	if 1 <= s.MinorVersion {
+		s.ElidedFallbackNameID = NameID(r.uint16())
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	if 0 < designAxisCount && designAxisSize < 8 {
+		return nil, fmt.Errorf("failed to parse STAT: invalid design axis size %d", designAxisSize)
+	}
+	s.DesignAxes = make([]*DesignAxisRecord, designAxisCount)
+	for i := range s.DesignAxes {
+		// the records can be extended in the future versions.
+		r.seek(int64(designAxesOffset) + int64(i*designAxisSize))
+		a := &DesignAxisRecord{}
+		r.read(a)
+		s.DesignAxes[i] = a
+	}
+	if 0 != offsetToAxisValueOffsets {
+		r.seek(int64(offsetToAxisValueOffsets))
+		for _, o := range r.uint16s(axisValueCount) {
+			v := parseAxisValue(r, int64(offsetToAxisValueOffsets)+int64(o))
+			if v != nil {
+				s.AxisValues = append(s.AxisValues, v)
+			}
+		}
+	}
+	return s, r.errorf("failed to parse STAT: %s")
+}
+
+// parseAxisValue parses an axis value, or returns nil if the format is unknown.
+func parseAxisValue(r *tableReader, offset int64) *AxisValue {
+	r.seek(offset)
+	v := &AxisValue{}
+	v.Format = r.uint16()
+	switch v.Format {
+	case 1, 2, 3:
+		v.AxisIndex = r.uint16()
+		v.Flags = r.uint16()
+		v.ValueNameID = NameID(r.uint16())
+		v.Value = Fixed(r.uint32())
+		switch v.Format {
+		case 2:
+			v.RangeMinValue = Fixed(r.uint32())
+			v.RangeMaxValue = Fixed(r.uint32())
+		case 3:
+			v.LinkedValue = Fixed(r.uint32())
+		}
+	case 4:
+		axisCount := int(r.uint16())
+		v.Flags = r.uint16()
+		v.ValueNameID = NameID(r.uint16())
+		if !r.available(axisCount, 6) {
+			return nil
+		}
+		v.AxisValueRecords = make([]*AxisValueRecord, axisCount)
+		for i := range v.AxisValueRecords {
+			rec := &AxisValueRecord{}
+			r.read(rec)
+			v.AxisValueRecords[i] = rec
+		}
+	default:
+		return nil
+	}
+	return v
+}
+
+// Tag is table name.
+func (s *Stat) Tag() Tag {
+	return String2Tag("STAT")
+}
+
+// store writes binary expression of this table.
+func (s *Stat) store(w *errWriter) {
+	b, err := packOffsetNode(s.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+func (s *Stat) node() *offsetNode {
+	minorVersion := s.MinorVersion
+	if 0 != s.ElidedFallbackNameID && minorVersion < 1 {
+		minorVersion = 1
+	}
+	n := &offsetNode{}
+	n.uint16(s.MajorVersion)
+	n.uint16(minorVersion)
+	n.uint16(8)
+	n.uint16(uint16(len(s.DesignAxes)))
+	if 0 == len(s.DesignAxes) {
+		n.uint32(0)
+	} else {
+		axes := &offsetNode{}
+		for _, a := range s.DesignAxes {
+			axes.uint32(uint32(a.AxisTag))
+			axes.uint16(uint16(a.AxisNameID))
+			axes.uint16(a.AxisOrdering)
+		}
+		n.offset32(axes)
+	}
+	n.uint16(uint16(len(s.AxisValues)))
+	if 0 == len(s.AxisValues) {
+		n.uint32(0)
+	} else {
+		values := &offsetNode{}
+		for _, v := range s.AxisValues {
+			values.offset16(v.node())
+		}
+		n.offset32(values)
+	}
+	if 1 <= minorVersion {
+		n.uint16(uint16(s.ElidedFallbackNameID))
+	}
+	return n
+}
+
+func (v *AxisValue) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(v.Format)
+	if 4 == v.Format {
+		n.uint16(uint16(len(v.AxisValueRecords)))
+		n.uint16(v.Flags)
+		n.uint16(uint16(v.ValueNameID))
+		for _, rec := range v.AxisValueRecords {
+			n.uint16(rec.AxisIndex)
+			n.uint32(uint32(rec.Value))
+		}
+		return n
+	}
+	n.uint16(v.AxisIndex)
+	n.uint16(v.Flags)
+	n.uint16(uint16(v.ValueNameID))
+	n.uint32(uint32(v.Value))
+	switch v.Format {
+	case 2:
+		n.uint32(uint32(v.RangeMinValue))
+		n.uint32(uint32(v.RangeMaxValue))
+	case 3:
+		n.uint32(uint32(v.LinkedValue))
+	}
+	return n
+}
+
+// CheckSum for this table.
+func (s *Stat) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(s)
+}
+
+// Length returns the size(byte) of this table.
+func (s *Stat) Length() uint32 {
+	b, err := packOffsetNode(s.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (s *Stat) Exists() bool {
+	return s != nil
+}
+
+// clone returns a deep copy of this table.
+func (s *Stat) clone() *Stat {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.DesignAxes = make([]*DesignAxisRecord, len(s.DesignAxes))
+	for i, a := range s.DesignAxes {
+		ca := *a
+		c.DesignAxes[i] = &ca
+	}
+	if s.AxisValues != nil {
+		c.AxisValues = make([]*AxisValue, len(s.AxisValues))
+		for i, v := range s.AxisValues {
+			c.AxisValues[i] = v.clone()
+		}
+	}
+	return &c
+}
+
+func (v *AxisValue) clone() *AxisValue {
+	c := *v
+	if v.AxisValueRecords != nil {
+		c.AxisValueRecords = make([]*AxisValueRecord, len(v.AxisValueRecords))
+		for i, rec := range v.AxisValueRecords {
+			cr := *rec
+			c.AxisValueRecords[i] = &cr
+		}
+	}
+	return &c
+}
+
+// limitAxes removes the axis values whose values are outside of the ranges of the axes in the user space.
+// The nominal value is compared for a range of format 2, and a combination of format 4 is removed if any of its values is outside.
+func (s *Stat) limitAxes(limits map[Tag]AxisLimit) {
+	outside := func(axisIndex uint16, v Fixed) bool {
+		if int(axisIndex) >= len(s.DesignAxes) {
+			return false
+		}
+		l, ok := limits[s.DesignAxes[axisIndex].AxisTag]
+		return ok && (v < float2Fixed(l.Min) || v > float2Fixed(l.Max))
+	}
+	values := make([]*AxisValue, 0, len(s.AxisValues))
+	for _, v := range s.AxisValues {
+		if 4 == v.Format {
+			inside := true
+			for _, rec := range v.AxisValueRecords {
+				if outside(rec.AxisIndex, rec.Value) {
+					inside = false
+					break
+				}
+			}
+			if !inside {
+				continue
+			}
+		} else if outside(v.AxisIndex, v.Value) {
+			continue
+		}
+		values = append(values, v)
+	}
+	s.AxisValues = values
+}
+
+// axisValuesAt returns the axis values that name the location, in the order of the axis ordering.
+// values are the values of the design axes, and an axis whose value is unknown matches its first axis value.
+// A combination of format 4 is preferred to the other axis values of its axes, and the combination of more axes is preferred.
+// For each axis, the first axis value that matches the value is used.
+func (s *Stat) axisValuesAt(values []float64, known []bool) []*AxisValue {
+	matches := func(axisIndex uint16, v *AxisValue, value Fixed) bool {
+		if int(axisIndex) >= len(values) {
+			return false
+		}
+		if !known[axisIndex] {
+			return true
+		}
+		x := float2Fixed(values[axisIndex])
+		if 2 == v.Format {
+			return v.RangeMinValue <= x && x <= v.RangeMaxValue
+		}
+		return x == value
+	}
+	used := make([]bool, len(s.DesignAxes))
+	var matched []*AxisValue
+	var combinations []*AxisValue
+	for _, v := range s.AxisValues {
+		if 4 == v.Format {
+			combinations = append(combinations, v)
+		}
+	}
+	sort.SliceStable(combinations, func(i, j int) bool {
+		return len(combinations[i].AxisValueRecords) > len(combinations[j].AxisValueRecords)
+	})
+	for _, v := range combinations {
+		ok := 0 < len(v.AxisValueRecords)
+		for _, rec := range v.AxisValueRecords {
+			if !matches(rec.AxisIndex, v, rec.Value) || used[rec.AxisIndex] {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		for _, rec := range v.AxisValueRecords {
+			used[rec.AxisIndex] = true
+		}
+		matched = append(matched, v)
+	}
+	for _, v := range s.AxisValues {
+		if 4 == v.Format || int(v.AxisIndex) >= len(used) || used[v.AxisIndex] || !matches(v.AxisIndex, v, v.Value) {
+			continue
+		}
+		used[v.AxisIndex] = true
+		matched = append(matched, v)
+	}
+	ordering := func(v *AxisValue) int {
+		if 4 != v.Format {
+			return int(s.DesignAxes[v.AxisIndex].AxisOrdering)
+		}
+		min := math.MaxInt32
+		for _, rec := range v.AxisValueRecords {
+			if o := int(s.DesignAxes[rec.AxisIndex].AxisOrdering); o < min {
+				min = o
+			}
+		}
+		return min
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return ordering(matched[i]) < ordering(matched[j])
+	})
+	return matched
+}
+
+// StyleNameAt returns the style name at the location in the user space of the axes, such as "Semibold Condensed",
+// that is composed of the names of the axis values of STAT in the order of the axis ordering.
+// The axes of fvar missing in the location are at their default values, and the first axis value is used for the other axes missing in it.
+// The names of the elidable axis values are omitted, and the name of elidedFallbackNameID is returned if all of them are omitted.
+func (font *Font) StyleNameAt(location map[Tag]float64) (string, error) {
+	err := tableRequired(font.Stat, font.Name)
+	if err != nil {
+		return "", fmt.Errorf("style name is not available: %s", err)
+	}
+	for tag := range location {
+		if !font.Stat.hasAxis(tag) && !(font.Fvar.Exists() && font.hasAxis(tag)) {
+			return "", fmt.Errorf("style name is not available: no axis %s", tag)
+		}
+	}
+	values := make([]float64, len(font.Stat.DesignAxes))
+	known := make([]bool, len(font.Stat.DesignAxes))
+	for i, a := range font.Stat.DesignAxes {
+		values[i], known[i] = location[a.AxisTag]
+		for _, axis := range font.Axes() {
+			if axis.Tag != a.AxisTag {
+				continue
+			}
+			if !known[i] {
+				values[i], known[i] = axis.Default, true
+			}
+			values[i] = math.Max(axis.Min, math.Min(axis.Max, values[i]))
+		}
+	}
+	var names []string
+	for _, v := range font.Stat.axisValuesAt(values, known) {
+		if 0 != v.Flags&AxisValueFlagElidableAxisValueName {
+			continue
+		}
+		if name := font.Name.Find(v.ValueNameID); "" != name {
+			names = append(names, name)
+		}
+	}
+	if 0 == len(names) {
+		id := font.Stat.ElidedFallbackNameID
+		if 0 == id {
+			id = NameIDFontSubfamilyName
+		}
+		return font.Name.Find(id), nil
+	}
+	return strings.Join(names, " "), nil
+}
+
+// hasAxis returns true if STAT has the design axis.
+func (s *Stat) hasAxis(tag Tag) bool {
+	for _, a := range s.DesignAxes {
+		if a.AxisTag == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestStatFont creates a font of newTestFvarFont with STAT, whose width axis precedes the weight axis in the style names.
+// The weight has the elidable Regular at 400 linked to 700, the range of Semibold from 500 to 650 and Bold at 700,
+// the width has the range of Condensed from 50 to 80 and the elidable Normal at 100,
+// and the combination of the weight 300 and the width 75 is Light Condensed.
+func newTestStatFont(t *testing.T) *Font {
+	font := newTestFvarFont(t)
+	font.Name.set(NameIDFontFamilyName, "Test")
+	for id, value := range map[NameID]string{261: "Regular", 262: "Semibold", 263: "Bold", 264: "Condensed", 265: "Normal", 266: "Light Condensed"} {
+		font.Name.set(id, value)
+	}
+	font.Stat = &Stat{
+		MajorVersion: 1,
+		MinorVersion: 2,
+		DesignAxes: []*DesignAxisRecord{
+			{AxisTag: String2Tag("wght"), AxisNameID: 256, AxisOrdering: 1},
+			{AxisTag: String2Tag("wdth"), AxisNameID: 257, AxisOrdering: 0},
+		},
+		AxisValues: []*AxisValue{
+			{Format: 3, AxisIndex: 0, Flags: AxisValueFlagElidableAxisValueName, ValueNameID: 261, Value: 400 << 16, LinkedValue: 700 << 16},
+			{Format: 2, AxisIndex: 0, ValueNameID: 262, Value: 600 << 16, RangeMinValue: 500 << 16, RangeMaxValue: 650 << 16},
+			{Format: 1, AxisIndex: 0, ValueNameID: 263, Value: 700 << 16},
+			{Format: 2, AxisIndex: 1, ValueNameID: 264, Value: 75 << 16, RangeMinValue: 50 << 16, RangeMaxValue: 80 << 16},
+			{Format: 1, AxisIndex: 1, Flags: AxisValueFlagElidableAxisValueName, ValueNameID: 265, Value: 100 << 16},
+			{Format: 4, ValueNameID: 266, AxisValueRecords: []*AxisValueRecord{{AxisIndex: 0, Value: 300 << 16}, {AxisIndex: 1, Value: 75 << 16}}},
+		},
+		ElidedFallbackNameID: 261,
+	}
+	return font
+}
+
+func TestStatRoundTrip(t *testing.T) {
+	font := newTestStatFont(t)
+	want := font.Stat.clone()
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(want, parsed.Stat) {
+		t.Errorf("STAT is %+v, want %+v", *parsed.Stat, *want)
+	}
+	// elidedFallbackNameID needs version 1.1.
+	font.Stat.MinorVersion = 0
+	parsed = writeTestFont(t, font)
+	if 1 != parsed.Stat.MinorVersion || 261 != parsed.Stat.ElidedFallbackNameID {
+		t.Errorf("STAT of version 1.0 with elidedFallbackNameID is version 1.%d with %d", parsed.Stat.MinorVersion, parsed.Stat.ElidedFallbackNameID)
+	}
+	font.Stat.ElidedFallbackNameID = 0
+	parsed = writeTestFont(t, font)
+	if 0 != parsed.Stat.MinorVersion || font.Stat.Length() != want.Length()-2 {
+		t.Errorf("STAT of version 1.0 is version 1.%d", parsed.Stat.MinorVersion)
+	}
+}
+
+func TestStyleNameAt(t *testing.T) {
+	font := writeTestFont(t, newTestStatFont(t))
+	wght, wdth := String2Tag("wght"), String2Tag("wdth")
+	tests := []struct {
+		name     string
+		location map[Tag]float64
+		want     string
+	}{
+		{"default", nil, "Regular"},
+		{"value", map[Tag]float64{wght: 700}, "Bold"},
+		{"range", map[Tag]float64{wght: 550}, "Semibold"},
+		{"axis ordering", map[Tag]float64{wght: 600, wdth: 60}, "Condensed Semibold"},
+		{"combination", map[Tag]float64{wght: 300, wdth: 75}, "Light Condensed"},
+		{"no axis value", map[Tag]float64{wght: 300}, "Regular"},
+		{"clamped", map[Tag]float64{wdth: 10}, "Condensed"},
+	}
+	for _, tt := range tests {
+		name, err := font.StyleNameAt(tt.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want != name {
+			t.Errorf("%s: style name is %q, want %q", tt.name, name, tt.want)
+		}
+	}
+	if _, err := font.StyleNameAt(map[Tag]float64{String2Tag("slnt"): 0}); err == nil {
+		t.Errorf("style name is available at the location of the missing axis")
+	}
+	// the subfamily name is used without elidedFallbackNameID.
+	font.Stat.ElidedFallbackNameID = 0
+	font.Name.set(NameIDFontSubfamilyName, "Book")
+	if name, err := font.StyleNameAt(nil); err != nil || "Book" != name {
+		t.Errorf("style name without elidedFallbackNameID is %q, %v, want %q", name, err, "Book")
+	}
+}
+
+func TestInstantiateStat(t *testing.T) {
+	font := writeTestFont(t, newTestStatFont(t))
+	wght, wdth := String2Tag("wght"), String2Tag("wdth")
+	tests := []struct {
+		name     string
+		location map[Tag]float64
+		style    string
+		values   []NameID
+	}{
+		{"named instance", map[Tag]float64{wght: 700}, "Bold", []NameID{263, 265}},
+		{"style name of STAT", map[Tag]float64{wght: 600, wdth: 75}, "Condensed Semibold", []NameID{262, 264}},
+		// the name of elidedFallbackNameID is used if no axis value names the location.
+		{"no axis value", map[Tag]float64{wght: 300, wdth: 90}, "Regular", []NameID{}},
+	}
+	for _, tt := range tests {
+		instance, err := font.Instantiate(tt.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance = writeTestFont(t, instance)
+		values := []NameID{}
+		for _, v := range instance.Stat.AxisValues {
+			values = append(values, v.ValueNameID)
+		}
+		if !reflect.DeepEqual(tt.values, values) {
+			t.Errorf("%s: axis values are %v, want %v", tt.name, values, tt.values)
+		}
+		if s := instance.Name.Find(NameIDTypographicSubfamilyName); tt.style != s {
+			t.Errorf("%s: typographic subfamily name is %q, want %q", tt.name, s, tt.style)
+		}
+		if s := instance.Name.Find(NameIDFontFullName); "Test "+tt.style != s {
+			t.Errorf("%s: full name is %q, want %q", tt.name, s, "Test "+tt.style)
+		}
+	}
+}
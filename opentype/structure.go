@@ -12,6 +12,19 @@ import (
 // Fixed is a 32-bit signed fixed-point number (16.16)
 type Fixed int32
 
+// Float returns the float64 value of this number.
+func (n Fixed) Float() float64 {
+	return float64(n) / 65536
+}
+
+// F2Dot14 is a 16-bit signed fixed number with the low 14 bits of fraction (2.14).
+type F2Dot14 int16
+
+// Float returns the float64 value of this number.
+func (n F2Dot14) Float() float64 {
+	return float64(n) / 16384
+}
+
 // LongDateTime is a Date represented in number of seconds since 12:00 midnight, January 1, 1904. The value is represented as a signed 64-bit integer.
 type LongDateTime int64
 
@@ -117,7 +117,7 @@ func (p *optionalFontParser) err() error {
 func tableRequired(target ...Table) error {
 	missed := make([]string, 0)
 	for _, t := range target {
-		if t == nil {
+		if !t.Exists() {
 			missed = append(missed, t.Tag().String())
 		}
 	}
@@ -126,3 +126,24 @@ func tableRequired(target ...Table) error {
 	}
 	return nil
 }
+
+func minInt16(a, b int16) int16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt16(a, b int16) int16 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func maxUint16(a, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
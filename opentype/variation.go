@@ -0,0 +1,863 @@
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ItemVariationStore is a store of the delta-sets for the variable fonts, used by "GDEF", "HVAR", "VVAR" and "MVAR" tables.
+type ItemVariationStore struct {
+	// Format of the store, only 1 is defined.
+	Format uint16
+	// The number of variation axes for the regions.
+	AxisCount uint16
+	// Variation regions referenced by the item variation data, each of them has the coordinates for every axis.
+	VariationRegions [][]*RegionAxisCoordinates
+	// Item variation data, indexed by the outer-level index of a delta-set.
+	ItemVariationData []*ItemVariationData
+}
+
+// RegionAxisCoordinates is a range of the normalized value of an axis for a variation region.
+type RegionAxisCoordinates struct {
+	// The region start coordinate value for the current axis.
+	StartCoord F2Dot14
+	// The region peak coordinate value for the current axis.
+	PeakCoord F2Dot14
+	// The region end coordinate value for the current axis.
+	EndCoord F2Dot14
+}
+
+// ItemVariationData is a set of rows of deltas.
+type ItemVariationData struct {
+	// Indices into the variation region list for the columns of the delta-sets.
+	RegionIndexes []uint16
+	// Delta-sets, indexed by the inner-level index of a delta-set.
+	DeltaSets [][]int32
+}
+
+const (
+	// itemVariationDataLongWords : the word deltas are 32-bit, and the other deltas are 16-bit.
+	itemVariationDataLongWords     = uint16(0x8000)
+	itemVariationDataWordCountMask = uint16(0x7FFF)
+)
+
+func parseItemVariationStore(r *tableReader, offset int64) *ItemVariationStore {
+	s := &ItemVariationStore{}
+	r.seek(offset)
+	s.Format = r.uint16()
+	regionListOffset := r.uint32()
+	dataOffsets := r.uint32s(int(r.uint16()))
+	if 0 != regionListOffset {
+		r.seek(offset + int64(regionListOffset))
+		s.AxisCount = r.uint16()
+		regionCount := r.uint16()
+		if !r.available(int(s.AxisCount)*int(regionCount), 6) {
+			return s
+		}
+		s.VariationRegions = make([][]*RegionAxisCoordinates, regionCount)
+		for i := range s.VariationRegions {
+			s.VariationRegions[i] = make([]*RegionAxisCoordinates, s.AxisCount)
+			for j := range s.VariationRegions[i] {
+				c := &RegionAxisCoordinates{}
+				r.read(c)
+				s.VariationRegions[i][j] = c
+			}
+		}
+	}
+	s.ItemVariationData = make([]*ItemVariationData, len(dataOffsets))
+	for i, o := range dataOffsets {
+		s.ItemVariationData[i] = parseItemVariationData(r, offset+int64(o))
+	}
+	return s
+}
+
+func parseItemVariationData(r *tableReader, offset int64) *ItemVariationData {
+	d := &ItemVariationData{}
+	r.seek(offset)
+	itemCount := r.uint16()
+	wordDeltaCount := r.uint16()
+	d.RegionIndexes = r.uint16s(int(r.uint16()))
+	longWords := 0 != wordDeltaCount&itemVariationDataLongWords
+	wordCount := int(wordDeltaCount & itemVariationDataWordCountMask)
+	wordSize, shortSize := 2, 1
+	if longWords {
+		wordSize, shortSize = 4, 2
+	}
+	rowSize := wordCount*wordSize + (len(d.RegionIndexes)-wordCount)*shortSize
+	if !r.available(int(itemCount), rowSize) {
+		return d
+	}
+	d.DeltaSets = make([][]int32, itemCount)
+	for i := range d.DeltaSets {
+		row := make([]int32, len(d.RegionIndexes))
+		for j := range row {
+			switch {
+			case j < wordCount && longWords:
+				row[j] = int32(r.uint32())
+			case j < wordCount || longWords:
+				row[j] = int32(r.int16())
+			default:
+				var v int8
+				r.read(&v)
+				row[j] = int32(v)
+			}
+		}
+		d.DeltaSets[i] = row
+	}
+	return d
+}
+
+func (s *ItemVariationStore) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(s.Format)
+	rl := &offsetNode{}
+	rl.uint16(s.AxisCount)
+	rl.uint16(uint16(len(s.VariationRegions)))
+	for _, region := range s.VariationRegions {
+		for _, c := range region {
+			rl.int16(int16(c.StartCoord))
+			rl.int16(int16(c.PeakCoord))
+			rl.int16(int16(c.EndCoord))
+		}
+	}
+	n.offset32(rl)
+	n.uint16(uint16(len(s.ItemVariationData)))
+	for _, d := range s.ItemVariationData {
+		n.offset32(d.node())
+	}
+	return n
+}
+
+// node writes the item variation data.
+// The columns that need the larger size are moved to the front, as the format requires.
+func (d *ItemVariationData) node() *offsetNode {
+	columns := len(d.RegionIndexes)
+	words := make([]bool, columns)
+	longWords := false
+	for _, row := range d.DeltaSets {
+		for j, v := range row {
+			if v < -128 || v > 127 {
+				words[j] = true
+			}
+			if v < -32768 || v > 32767 {
+				longWords = true
+			}
+		}
+	}
+	if longWords {
+		for j := range words {
+			words[j] = false
+			for _, row := range d.DeltaSets {
+				if row[j] < -32768 || row[j] > 32767 {
+					words[j] = true
+				}
+			}
+		}
+	}
+	order := make([]int, 0, columns)
+	for j := 0; j < columns; j++ {
+		if words[j] {
+			order = append(order, j)
+		}
+	}
+	wordCount := len(order)
+	for j := 0; j < columns; j++ {
+		if !words[j] {
+			order = append(order, j)
+		}
+	}
+	wordDeltaCount := uint16(wordCount)
+	if longWords {
+		wordDeltaCount |= itemVariationDataLongWords
+	}
+	n := &offsetNode{}
+	n.uint16(uint16(len(d.DeltaSets)))
+	n.uint16(wordDeltaCount)
+	n.uint16(uint16(columns))
+	for _, j := range order {
+		n.uint16(d.RegionIndexes[j])
+	}
+	for _, row := range d.DeltaSets {
+		for k, j := range order {
+			switch {
+			case k < wordCount && longWords:
+				n.uint32(uint32(row[j]))
+			case k < wordCount || longWords:
+				n.int16(int16(row[j]))
+			default:
+				n.bytes([]byte{byte(int8(row[j]))})
+			}
+		}
+	}
+	return n
+}
+
+func (s *ItemVariationStore) clone() *ItemVariationStore {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.VariationRegions = make([][]*RegionAxisCoordinates, len(s.VariationRegions))
+	for i, region := range s.VariationRegions {
+		c.VariationRegions[i] = make([]*RegionAxisCoordinates, len(region))
+		for j, coords := range region {
+			cc := *coords
+			c.VariationRegions[i][j] = &cc
+		}
+	}
+	c.ItemVariationData = make([]*ItemVariationData, len(s.ItemVariationData))
+	for i, d := range s.ItemVariationData {
+		cd := &ItemVariationData{
+			RegionIndexes: append([]uint16{}, d.RegionIndexes...),
+			DeltaSets:     make([][]int32, len(d.DeltaSets)),
+		}
+		for j, row := range d.DeltaSets {
+			cd.DeltaSets[j] = append([]int32{}, row...)
+		}
+		c.ItemVariationData[i] = cd
+	}
+	return &c
+}
+
+// Delta returns the interpolated delta of the delta-set at the normalized coordinates of the axes.
+func (s *ItemVariationStore) Delta(outer, inner uint16, coords []float64) float64 {
+	if s == nil || int(outer) >= len(s.ItemVariationData) {
+		return 0
+	}
+	d := s.ItemVariationData[outer]
+	if int(inner) >= len(d.DeltaSets) {
+		return 0
+	}
+	delta := 0.0
+	for j, v := range d.DeltaSets[inner] {
+		if 0 == v {
+			continue
+		}
+		ri := int(d.RegionIndexes[j])
+		if ri >= len(s.VariationRegions) {
+			continue
+		}
+		delta += float64(v) * regionScalar(s.VariationRegions[ri], coords)
+	}
+	return delta
+}
+
+// regionScalar returns the scalar of the region at the normalized coordinates.
+func regionScalar(region []*RegionAxisCoordinates, coords []float64) float64 {
+	scalar := 1.0
+	for i, c := range region {
+		coord := 0.0
+		if i < len(coords) {
+			coord = coords[i]
+		}
+		scalar *= axisScalar(c.StartCoord.Float(), c.PeakCoord.Float(), c.EndCoord.Float(), coord)
+		if 0 == scalar {
+			return 0
+		}
+	}
+	return scalar
+}
+
+// axisScalar returns the scalar of a tent of an axis at the normalized coordinate.
+func axisScalar(start, peak, end, coord float64) float64 {
+	switch {
+	case start > peak || peak > end:
+		return 1
+	case start < 0 && end > 0 && 0 != peak:
+		return 1
+	case 0 == peak:
+		return 1
+	case coord == peak:
+		return 1
+	case coord <= start || coord >= end:
+		return 0
+	case coord < peak:
+		return (coord - start) / (peak - start)
+	default:
+		return (end - coord) / (end - peak)
+	}
+}
+
+// DeltaSetIndexMap maps the indices, such as glyph IDs and axis indices, to the delta-sets of an ItemVariationStore.
+type DeltaSetIndexMap struct {
+	// Delta-set indices, with the outer-level index in the high 16 bits and the inner-level index in the low 16 bits.
+	// The indices after the end use the last entry.
+	Map []uint32
+}
+
+const (
+	// deltaSetIndexMapInnerIndexBitCountMask : the number of the bits of the inner-level index minus 1.
+	deltaSetIndexMapInnerIndexBitCountMask = uint8(0x0F)
+	// deltaSetIndexMapEntrySizeMask : the size of an entry in bytes minus 1.
+	deltaSetIndexMapEntrySizeMask = uint8(0x30)
+)
+
+func parseDeltaSetIndexMap(r *tableReader, offset int64) *DeltaSetIndexMap {
+	m := &DeltaSetIndexMap{}
+	r.seek(offset)
+	var format, entryFormat uint8
+	r.read(&format)
+	r.read(&entryFormat)
+	var mapCount int
+	if 0 == format {
+		mapCount = int(r.uint16())
+	} else {
+		mapCount = int(r.uint32())
+	}
+	entrySize := int(entryFormat&deltaSetIndexMapEntrySizeMask>>4) + 1
+	innerBits := uint(entryFormat&deltaSetIndexMapInnerIndexBitCountMask) + 1
+	if !r.available(mapCount, entrySize) {
+		return m
+	}
+	b := make([]byte, mapCount*entrySize)
+	r.read(b)
+	m.Map = make([]uint32, mapCount)
+	for i := range m.Map {
+		entry := uint32(0)
+		for _, v := range b[i*entrySize : (i+1)*entrySize] {
+			entry = entry<<8 | uint32(v)
+		}
+		m.Map[i] = entry>>innerBits<<16 | entry&(1<<innerBits-1)
+	}
+	return m
+}
+
+// Get returns the outer-level and the inner-level indices of the delta-set for the index.
+func (m *DeltaSetIndexMap) Get(i int) (outer, inner uint16) {
+	if 0 == len(m.Map) {
+		return 0, 0
+	}
+	if i >= len(m.Map) {
+		i = len(m.Map) - 1
+	}
+	return uint16(m.Map[i] >> 16), uint16(m.Map[i])
+}
+
+// node writes the map in the smallest entry format.
+func (m *DeltaSetIndexMap) node() *offsetNode {
+	innerBits := uint(1)
+	maxOuter := uint32(0)
+	for _, v := range m.Map {
+		for v&0xFFFF >= 1<<innerBits {
+			innerBits++
+		}
+		if maxOuter < v>>16 {
+			maxOuter = v >> 16
+		}
+	}
+	entrySize := 1
+	for (uint64(maxOuter)<<innerBits|(1<<innerBits-1))>>(8*uint(entrySize)) > 0 {
+		entrySize++
+	}
+	n := &offsetNode{}
+	entryFormat := uint8(entrySize-1)<<4 | uint8(innerBits-1)
+	if len(m.Map) > 0xFFFF {
+		n.bytes([]byte{1, entryFormat})
+		n.uint32(uint32(len(m.Map)))
+	} else {
+		n.bytes([]byte{0, entryFormat})
+		n.uint16(uint16(len(m.Map)))
+	}
+	for _, v := range m.Map {
+		entry := v>>16<<innerBits | v&0xFFFF
+		b := make([]byte, entrySize)
+		for j := entrySize - 1; j >= 0; j-- {
+			b[j] = byte(entry)
+			entry >>= 8
+		}
+		n.bytes(b)
+	}
+	return n
+}
+
+func (m *DeltaSetIndexMap) clone() *DeltaSetIndexMap {
+	if m == nil {
+		return nil
+	}
+	return &DeltaSetIndexMap{
+		Map: append([]uint32{}, m.Map...),
+	}
+}
+
+// filter returns the map for the glyphs of a subset.
+// If the map is nil, the glyph ID is mapped to the inner-level index of the outer-level index 0, as the implicit map of HVAR and VVAR.
+func (m *DeltaSetIndexMap) filter(f []uint16) *DeltaSetIndexMap {
+	c := &DeltaSetIndexMap{Map: make([]uint32, len(f))}
+	for i, gid := range f {
+		if m == nil {
+			c.Map[i] = uint32(gid)
+			continue
+		}
+		outer, inner := m.Get(int(gid))
+		c.Map[i] = uint32(outer)<<16 | uint32(inner)
+	}
+	return c
+}
+
+// TupleVariation is a set of the deltas for a region of the variation space, used by "gvar" and "cvar" tables.
+type TupleVariation struct {
+	// The peak coordinates of the region for every axis.
+	PeakTuple []F2Dot14
+	// The start and the end coordinates of an intermediate region, or nil if the region spans from zero to the peak.
+	IntermediateStartTuple []F2Dot14
+	IntermediateEndTuple   []F2Dot14
+	// Indices of the points or the values that have the deltas, in increasing order, or nil if all of them have.
+	PointNumbers []uint16
+	// Deltas of the points or the values. They are X coordinates in gvar, and the values of cvt in cvar.
+	Deltas []int32
+	// Deltas of Y coordinates of the points, only in gvar.
+	DeltasY []int32
+}
+
+const (
+	tupleVariationSharedPointNumbers = uint16(0x8000)
+	tupleVariationCountMask          = uint16(0x0FFF)
+	tupleEmbeddedPeakTuple           = uint16(0x8000)
+	tupleIntermediateRegion          = uint16(0x4000)
+	tuplePrivatePointNumbers         = uint16(0x2000)
+	tupleIndexMask                   = uint16(0x0FFF)
+	pointsAreWords                   = uint8(0x80)
+	pointRunCountMask                = uint8(0x7F)
+	deltasAreZero                    = uint8(0x80)
+	deltasAreWords                   = uint8(0x40)
+	deltasAreLongs                   = uint8(0xC0)
+	deltaRunCountMask                = uint8(0x3F)
+)
+
+// parseTupleVariations parses the tuple variation headers at base and the serialized data that they refer.
+// The offset of the serialized data is relative to the beginning of b.
+// dimensions is the number of the delta arrays of a tuple variation, 2 for gvar and 1 for cvar.
+func parseTupleVariations(b []byte, base int, axisCount int, sharedTuples [][]F2Dot14, dimensions int) ([]*TupleVariation, error) {
+	if base > len(b) {
+		return nil, fmt.Errorf("tuple variations exceed the data")
+	}
+	r := newErrReader(bytes.NewReader(b[base:]))
+	var count, dataOffset uint16
+	r.read(&count)
+	r.read(&dataOffset)
+	n := int(count & tupleVariationCountMask)
+	tvs := make([]*TupleVariation, n)
+	sizes := make([]int, n)
+	private := make([]bool, n)
+	for i := range tvs {
+		var size, index uint16
+		r.read(&size)
+		r.read(&index)
+		tv := &TupleVariation{}
+		if 0 != index&tupleEmbeddedPeakTuple {
+			tv.PeakTuple = make([]F2Dot14, axisCount)
+			r.read(tv.PeakTuple)
+		} else {
+			k := int(index & tupleIndexMask)
+			if k >= len(sharedTuples) {
+				return nil, fmt.Errorf("shared tuple %d does not exist", k)
+			}
+			tv.PeakTuple = append([]F2Dot14{}, sharedTuples[k]...)
+		}
+		if 0 != index&tupleIntermediateRegion {
+			tv.IntermediateStartTuple = make([]F2Dot14, axisCount)
+			tv.IntermediateEndTuple = make([]F2Dot14, axisCount)
+			r.read(tv.IntermediateStartTuple)
+			r.read(tv.IntermediateEndTuple)
+		}
+		tvs[i] = tv
+		sizes[i] = int(size)
+		private[i] = 0 != index&tuplePrivatePointNumbers
+	}
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse tuple variation headers: %s")
+	}
+	if int(dataOffset) > len(b) {
+		return nil, fmt.Errorf("serialized data exceeds the data")
+	}
+	data := b[dataOffset:]
+	var shared []uint16
+	pos := 0
+	if 0 != count&tupleVariationSharedPointNumbers {
+		var err error
+		shared, pos, err = decodePointNumbers(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, tv := range tvs {
+		if pos+sizes[i] > len(data) {
+			return nil, fmt.Errorf("serialized data exceeds the data")
+		}
+		d := data[pos : pos+sizes[i]]
+		pos += sizes[i]
+		tv.PointNumbers = shared
+		if private[i] {
+			points, k, err := decodePointNumbers(d)
+			if err != nil {
+				return nil, err
+			}
+			tv.PointNumbers = points
+			d = d[k:]
+		}
+		// the number of the deltas for all points is known from the size of the data.
+		count := -1
+		if tv.PointNumbers != nil {
+			count = len(tv.PointNumbers) * dimensions
+		}
+		deltas, err := decodeDeltas(d, count)
+		if err != nil {
+			return nil, err
+		}
+		if 0 != len(deltas)%dimensions {
+			return nil, fmt.Errorf("the number of deltas(%d) is not a multiple of %d", len(deltas), dimensions)
+		}
+		k := len(deltas) / dimensions
+		tv.Deltas = deltas[:k]
+		if 2 == dimensions {
+			tv.DeltasY = deltas[k:]
+		}
+	}
+	return tvs, nil
+}
+
+// decodePointNumbers decodes the packed point numbers, and returns them with the number of the bytes read.
+// nil is returned for all points.
+func decodePointNumbers(b []byte) ([]uint16, int, error) {
+	if len(b) < 1 {
+		return nil, 0, fmt.Errorf("packed point numbers exceed the data")
+	}
+	count, pos := int(b[0]), 1
+	if 0 != b[0]&pointsAreWords {
+		if len(b) < 2 {
+			return nil, 0, fmt.Errorf("packed point numbers exceed the data")
+		}
+		count, pos = int(b[0]&pointRunCountMask)<<8|int(b[1]), 2
+	}
+	if 0 == count {
+		return nil, pos, nil
+	}
+	points := make([]uint16, 0, count)
+	last := 0
+	for len(points) < count {
+		if pos >= len(b) {
+			return nil, 0, fmt.Errorf("packed point numbers exceed the data")
+		}
+		control := b[pos]
+		pos++
+		run := int(control&pointRunCountMask) + 1
+		size := 1
+		if 0 != control&pointsAreWords {
+			size = 2
+		}
+		if pos+run*size > len(b) {
+			return nil, 0, fmt.Errorf("packed point numbers exceed the data")
+		}
+		for j := 0; j < run && len(points) < count; j++ {
+			v := int(b[pos])
+			if 2 == size {
+				v = v<<8 | int(b[pos+1])
+			}
+			pos += size
+			last += v
+			points = append(points, uint16(last))
+		}
+	}
+	return points, pos, nil
+}
+
+// decodeDeltas decodes the packed deltas.
+// If count is negative, the deltas are read until the end of the data.
+func decodeDeltas(b []byte, count int) ([]int32, error) {
+	deltas := make([]int32, 0)
+	pos := 0
+	for (count < 0 && pos < len(b)) || len(deltas) < count {
+		if pos >= len(b) {
+			return nil, fmt.Errorf("packed deltas exceed the data")
+		}
+		control := b[pos]
+		pos++
+		run := int(control&deltaRunCountMask) + 1
+		size := 0
+		switch control & deltasAreLongs {
+		case deltasAreWords:
+			size = 2
+		case deltasAreLongs:
+			size = 4
+		case 0:
+			size = 1
+		}
+		if pos+run*size > len(b) {
+			return nil, fmt.Errorf("packed deltas exceed the data")
+		}
+		for j := 0; j < run; j++ {
+			switch size {
+			case 0:
+				deltas = append(deltas, 0)
+			case 1:
+				deltas = append(deltas, int32(int8(b[pos])))
+			case 2:
+				deltas = append(deltas, int32(int16(binary.BigEndian.Uint16(b[pos:]))))
+			case 4:
+				deltas = append(deltas, int32(binary.BigEndian.Uint32(b[pos:])))
+			}
+			pos += size
+		}
+	}
+	if 0 <= count && len(deltas) > count {
+		deltas = deltas[:count]
+	}
+	return deltas, nil
+}
+
+// encodeTupleVariations encodes the tuple variations into the tuple variation headers followed by the serialized data.
+// The peak tuples in sharedTuples are referred by the indices, and the others are embedded.
+// The offset of the serialized data is written as relative to base bytes before the beginning of the headers.
+// If all tuple variations have the same points, the point numbers are shared.
+func encodeTupleVariations(tvs []*TupleVariation, sharedTuples map[string]int, base int) []byte {
+	valid := make([]*TupleVariation, 0, len(tvs))
+	for _, tv := range tvs {
+		// no point numbers mean all points, so a tuple variation of no point is dropped.
+		if tv.PointNumbers != nil && 0 == len(tv.PointNumbers) {
+			continue
+		}
+		valid = append(valid, tv)
+	}
+	sharePoints := 1 < len(valid)
+	for _, tv := range valid {
+		if !samePointNumbers(tv.PointNumbers, valid[0].PointNumbers) {
+			sharePoints = false
+		}
+	}
+	headers := &bytes.Buffer{}
+	data := &bytes.Buffer{}
+	if sharePoints {
+		points, _, _ := valid[0].sortedDeltas()
+		data.Write(encodePointNumbers(points))
+	}
+	for _, tv := range valid {
+		d := &bytes.Buffer{}
+		index := uint16(0)
+		points, deltas, deltasY := tv.sortedDeltas()
+		if !sharePoints && points != nil {
+			index |= tuplePrivatePointNumbers
+			d.Write(encodePointNumbers(points))
+		}
+		d.Write(encodeDeltas(append(append([]int32{}, deltas...), deltasY...)))
+		k, ok := sharedTuples[tupleKey(tv.PeakTuple)]
+		if ok {
+			index |= uint16(k)
+		} else {
+			index |= tupleEmbeddedPeakTuple
+		}
+		intermediate := tv.IntermediateStartTuple != nil && tv.IntermediateEndTuple != nil
+		if intermediate {
+			index |= tupleIntermediateRegion
+		}
+		binary.Write(headers, binary.BigEndian, uint16(d.Len()))
+		binary.Write(headers, binary.BigEndian, index)
+		if !ok {
+			binary.Write(headers, binary.BigEndian, tv.PeakTuple)
+		}
+		if intermediate {
+			binary.Write(headers, binary.BigEndian, tv.IntermediateStartTuple)
+			binary.Write(headers, binary.BigEndian, tv.IntermediateEndTuple)
+		}
+		data.Write(d.Bytes())
+	}
+	count := uint16(len(valid))
+	if sharePoints {
+		count |= tupleVariationSharedPointNumbers
+	}
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.BigEndian, count)
+	binary.Write(b, binary.BigEndian, uint16(base+4+headers.Len()))
+	b.Write(headers.Bytes())
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+// sortedDeltas returns the point numbers and the deltas in the order of the point numbers, that the packed point numbers require.
+func (tv *TupleVariation) sortedDeltas() ([]uint16, []int32, []int32) {
+	if tv.PointNumbers == nil || sort.SliceIsSorted(tv.PointNumbers, func(i, j int) bool { return tv.PointNumbers[i] < tv.PointNumbers[j] }) {
+		return tv.PointNumbers, tv.Deltas, tv.DeltasY
+	}
+	order := make([]int, len(tv.PointNumbers))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return tv.PointNumbers[order[i]] < tv.PointNumbers[order[j]] })
+	points := make([]uint16, len(order))
+	deltas := make([]int32, 0, len(order))
+	var deltasY []int32
+	for i, k := range order {
+		points[i] = tv.PointNumbers[k]
+		deltas = append(deltas, tv.Deltas[k])
+		if k < len(tv.DeltasY) {
+			deltasY = append(deltasY, tv.DeltasY[k])
+		}
+	}
+	return points, deltas, deltasY
+}
+
+func samePointNumbers(a, b []uint16) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tupleKey returns the key of the peak tuple to find the shared tuples.
+func tupleKey(t []F2Dot14) string {
+	return fmt.Sprint(t)
+}
+
+// encodePointNumbers encodes the point numbers, nil means all points.
+func encodePointNumbers(points []uint16) []byte {
+	n := len(points)
+	var b []byte
+	if n < 0x80 {
+		b = []byte{byte(n)}
+	} else {
+		b = []byte{byte(n>>8) | pointsAreWords, byte(n)}
+	}
+	last := 0
+	for i := 0; i < n; {
+		words := int(points[i])-last > 0xFF
+		diffs := make([]int, 0)
+		for i < n && len(diffs) <= int(pointRunCountMask) {
+			d := int(points[i]) - last
+			if (d > 0xFF) != words {
+				break
+			}
+			diffs = append(diffs, d)
+			last = int(points[i])
+			i++
+		}
+		control := uint8(len(diffs) - 1)
+		if words {
+			control |= pointsAreWords
+		}
+		b = append(b, control)
+		for _, d := range diffs {
+			if words {
+				b = append(b, byte(d>>8))
+			}
+			b = append(b, byte(d))
+		}
+	}
+	return b
+}
+
+// encodeDeltas encodes the deltas into the runs of zeros, bytes, words and longs.
+// A run of bytes is broken by two zeros, and a run of words is broken by a zero or two bytes, that are smaller in the other runs.
+func encodeDeltas(deltas []int32) []byte {
+	b := make([]byte, 0)
+	fitsByte := func(v int32) bool { return -128 <= v && v <= 127 }
+	fitsWord := func(v int32) bool { return -32768 <= v && v <= 32767 }
+	n := len(deltas)
+	for i := 0; i < n; {
+		j := i
+		var control uint8
+		switch v := deltas[i]; {
+		case 0 == v:
+			for j < n && j-i <= int(deltaRunCountMask) && 0 == deltas[j] {
+				j++
+			}
+			control = deltasAreZero
+		case fitsByte(v):
+			for j < n && j-i <= int(deltaRunCountMask) && fitsByte(deltas[j]) {
+				if 0 == deltas[j] && j+1 < n && 0 == deltas[j+1] {
+					break
+				}
+				j++
+			}
+		case fitsWord(v):
+			for j < n && j-i <= int(deltaRunCountMask) && fitsWord(deltas[j]) && 0 != deltas[j] {
+				if fitsByte(deltas[j]) && j+1 < n && fitsByte(deltas[j+1]) {
+					break
+				}
+				j++
+			}
+			control = deltasAreWords
+		default:
+			for j < n && j-i <= int(deltaRunCountMask) && !fitsWord(deltas[j]) {
+				j++
+			}
+			control = deltasAreLongs
+		}
+		b = append(b, control|uint8(j-i-1))
+		for _, v := range deltas[i:j] {
+			switch control {
+			case 0:
+				b = append(b, byte(int8(v)))
+			case deltasAreWords:
+				b = append(b, byte(v>>8), byte(v))
+			case deltasAreLongs:
+				b = append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+			}
+		}
+		i = j
+	}
+	return b
+}
+
+// scalar returns the scalar of the deltas at the normalized coordinates.
+func (tv *TupleVariation) scalar(coords []float64) float64 {
+	scalar := 1.0
+	intermediate := tv.IntermediateStartTuple != nil && tv.IntermediateEndTuple != nil
+	for i, p := range tv.PeakTuple {
+		peak := p.Float()
+		if 0 == peak {
+			continue
+		}
+		coord := 0.0
+		if i < len(coords) {
+			coord = coords[i]
+		}
+		// the region of a peak spans from zero to it.
+		start, end := math.Min(0, peak), math.Max(0, peak)
+		if intermediate && i < len(tv.IntermediateStartTuple) && i < len(tv.IntermediateEndTuple) {
+			start, end = tv.IntermediateStartTuple[i].Float(), tv.IntermediateEndTuple[i].Float()
+		}
+		scalar *= axisScalar(start, peak, end, coord)
+		if 0 == scalar {
+			return 0
+		}
+	}
+	return scalar
+}
+
+func (tv *TupleVariation) clone() *TupleVariation {
+	c := &TupleVariation{
+		PeakTuple: append([]F2Dot14{}, tv.PeakTuple...),
+		Deltas:    append([]int32{}, tv.Deltas...),
+	}
+	if tv.IntermediateStartTuple != nil {
+		c.IntermediateStartTuple = append([]F2Dot14{}, tv.IntermediateStartTuple...)
+	}
+	if tv.IntermediateEndTuple != nil {
+		c.IntermediateEndTuple = append([]F2Dot14{}, tv.IntermediateEndTuple...)
+	}
+	if tv.PointNumbers != nil {
+		c.PointNumbers = append([]uint16{}, tv.PointNumbers...)
+	}
+	if tv.DeltasY != nil {
+		c.DeltasY = append([]int32{}, tv.DeltasY...)
+	}
+	return c
+}
+
+func cloneTupleVariations(tvs []*TupleVariation) []*TupleVariation {
+	if tvs == nil {
+		return nil
+	}
+	c := make([]*TupleVariation, len(tvs))
+	for i, tv := range tvs {
+		c[i] = tv.clone()
+	}
+	return c
+}
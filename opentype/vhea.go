@@ -0,0 +1,82 @@
+package opentype
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+// Vhea is a "vhea" table.
+// This table contains information for vertical layout.
+type Vhea struct {
+	// Version number of the vertical header table; 0x00010000 for version 1.0, 0x00011000 for version 1.1.
+	Version Fixed
+	// The vertical typographic ascender for this font. It is the distance in font design units from the ideographic em-box center baseline for the vertical axis to the right edge of the ideographic em-box.
+	VertTypoAscender int16
+	// The vertical typographic descender for this font. It is the distance in font design units from the ideographic em-box center baseline for the vertical axis to the left edge of the ideographic em-box.
+	VertTypoDescender int16
+	// The vertical typographic gap for this font.
+	VertTypoLineGap int16
+	// The maximum advance height measurement found in the font.
+	AdvanceHeightMax int16
+	// The minimum top side bearing measurement found in the font.
+	MinTopSideBearing int16
+	// The minimum bottom side bearing measurement found in the font.
+	MinBottomSideBearing int16
+	// This is defined as the value of the minTopSideBearing field added to the result of the value of the yMin field subtracted from the value of the yMax field.
+	YMaxExtent int16
+	// The value of the caretSlopeRise field divided by the value of the caretSlopeRun field determines the slope of the caret.
+	CaretSlopeRise int16
+	// See the caretSlopeRise field. Value = 0 for fonts with vertical carets.
+	CaretSlopeRun int16
+	// The amount by which a slanted highlight on a glyph needs to be shifted away from the glyph in order to produce the best appearance.
+	CaretOffset      int16
+	Reserved1        int16
+	Reserved2        int16
+	Reserved3        int16
+	Reserved4        int16
+	MetricDataFormat int16
+	// Number of advance heights in the vertical metrics table.
+	NumOfLongVerMetrics uint16
+}
+
+func parseVhea(f *os.File, offset uint32) (v *Vhea, err error) {
+	v = &Vhea{}
+	f.Seek(int64(offset), 0)
+	err = binary.Read(f, binary.BigEndian, v)
+	return
+}
+
+// Tag is table name.
+func (v *Vhea) Tag() Tag {
+	return String2Tag("vhea")
+}
+
+// store writes binary expression of this table.
+func (v *Vhea) store(w *errWriter) {
+	w.write(v)
+	padSpace(w, v.Length())
+}
+
+// CheckSum for this table.
+func (v *Vhea) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(v)
+}
+
+// Length returns the size(byte) of this table.
+func (v *Vhea) Length() uint32 {
+	return uint32(36)
+}
+
+// Exists returns true if this is not nil.
+func (v *Vhea) Exists() bool {
+	return v != nil
+}
+
+// clone returns a copy of this table.
+func (v *Vhea) clone() *Vhea {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
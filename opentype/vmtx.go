@@ -0,0 +1,148 @@
+package opentype
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+// LongVerMetric is a paired advance height and top side bearing values for each glyph.
+type LongVerMetric struct {
+	// The advance height of the glyph. Unsigned integer in font design units.
+	AdvanceHeight uint16
+	// The top sidebearing of the glyph. Signed integer in font design units.
+	TopSideBearing int16
+}
+
+// Vmtx is a "vmtx" table.
+// The vertical metrics ('vmtx') table provides glyph advance heights and top side bearings.
+// Top side bearing: The vertical distance from the vertical origin to the top edge of the glyph's bounding box.
+// Advance height: The vertical distance from the current vertical origin and the next vertical origin.
+type Vmtx struct {
+	// Paired advance height and top side bearing values for each glyph. Records are indexed by glyph ID.
+	VMetrics []*LongVerMetric
+	// Top side bearings for glyph IDs greater than or equal to numOfLongVerMetrics.
+	TopSideBearings []int16
+}
+
+func parseVmtx(f *os.File, offset uint32, numGlyphs, numOfLongVerMetrics uint16) (v *Vmtx, err error) {
+	v = &Vmtx{}
+	f.Seek(int64(offset), 0)
+	v.VMetrics = make([]*LongVerMetric, int(numOfLongVerMetrics))
+	for i := 0; i < int(numOfLongVerMetrics); i++ {
+		m := &LongVerMetric{}
+		err = binary.Read(f, binary.BigEndian, m)
+		if err != nil {
+			return
+		}
+		v.VMetrics[i] = m
+	}
+	if numGlyphs < numOfLongVerMetrics {
+		numGlyphs = numOfLongVerMetrics
+	}
+	v.TopSideBearings = make([]int16, int(numGlyphs-numOfLongVerMetrics))
+	err = binary.Read(f, binary.BigEndian, v.TopSideBearings)
+	return
+}
+
+func (v *Vmtx) filter(f []uint16) *Vmtx {
+	new := &Vmtx{
+		VMetrics:        make([]*LongVerMetric, len(f)),
+		TopSideBearings: make([]int16, 0),
+	}
+	for i, gid := range f {
+		advanceHeight, tsb := v.get(gid)
+		new.VMetrics[i] = &LongVerMetric{
+			AdvanceHeight:  advanceHeight,
+			TopSideBearing: tsb,
+		}
+	}
+	return new
+}
+
+// Optimize minimizes numOfLongVerMetrics.
+// The trailing entries of VMetrics that have the same advance height as the last one are moved into TopSideBearings,
+// that is common for CJK fonts.
+// If vhea is not nil, its NumOfLongVerMetrics is updated.
+func (v *Vmtx) Optimize(vhea *Vhea) {
+	n := len(v.VMetrics)
+	if n > 0 {
+		last := v.VMetrics[n-1].AdvanceHeight
+		k := n - 1
+		for k > 0 && v.VMetrics[k-1].AdvanceHeight == last {
+			k--
+		}
+		tsbs := make([]int16, 0, n-k-1+len(v.TopSideBearings))
+		for _, vm := range v.VMetrics[k+1:] {
+			tsbs = append(tsbs, vm.TopSideBearing)
+		}
+		v.TopSideBearings = append(tsbs, v.TopSideBearings...)
+		v.VMetrics = v.VMetrics[:k+1]
+	}
+	if vhea != nil {
+		vhea.NumOfLongVerMetrics = uint16(len(v.VMetrics))
+	}
+}
+
+// get returns the advance height and top side bearing of the glyph.
+// Glyphs beyond numOfLongVerMetrics share the advance height of the last entry of VMetrics.
+func (v *Vmtx) get(gid uint16) (advanceHeight uint16, tsb int16) {
+	th := uint16(len(v.VMetrics))
+	if gid < th {
+		return v.VMetrics[gid].AdvanceHeight, v.VMetrics[gid].TopSideBearing
+	}
+	if th > 0 {
+		advanceHeight = v.VMetrics[th-1].AdvanceHeight
+	}
+	if int(gid-th) < len(v.TopSideBearings) {
+		tsb = v.TopSideBearings[gid-th]
+	}
+	return
+}
+
+// Tag is table name.
+func (v *Vmtx) Tag() Tag {
+	return String2Tag("vmtx")
+}
+
+// store writes binary expression of this table.
+func (v *Vmtx) store(w *errWriter) {
+	for _, vm := range v.VMetrics {
+		w.write(vm)
+	}
+	for _, tsb := range v.TopSideBearings {
+		w.write(&(tsb))
+	}
+	padSpace(w, v.Length())
+}
+
+// CheckSum for this table.
+func (v *Vmtx) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(v)
+}
+
+// Length returns the size(byte) of this table.
+func (v *Vmtx) Length() uint32 {
+	return uint32(4*len(v.VMetrics) + 2*len(v.TopSideBearings))
+}
+
+// Exists returns true if this is not nil.
+func (v *Vmtx) Exists() bool {
+	return v != nil
+}
+
+// clone returns a deep copy of this table.
+func (v *Vmtx) clone() *Vmtx {
+	if v == nil {
+		return nil
+	}
+	c := &Vmtx{
+		VMetrics:        make([]*LongVerMetric, len(v.VMetrics)),
+		TopSideBearings: make([]int16, len(v.TopSideBearings)),
+	}
+	for i, vm := range v.VMetrics {
+		m := *vm
+		c.VMetrics[i] = &m
+	}
+	copy(c.TopSideBearings, v.TopSideBearings)
+	return c
+}
@@ -0,0 +1,66 @@
+package opentype
+
+import (
+	"testing"
+)
+
+// newTestVerticalFont creates the font of newTestSubsetFont with vhea and vmtx,
+// whose advance heights are 1000 except the one of glyph 1, and top side bearings are 10 times the glyph IDs.
+func newTestVerticalFont(t *testing.T) *Font {
+	font := newTestSubsetFont(t)
+	font.Vhea = &Vhea{
+		Version:           0x00011000,
+		VertTypoAscender:  500,
+		VertTypoDescender: -500,
+		AdvanceHeightMax:  1000,
+		CaretSlopeRise:    0,
+		CaretSlopeRun:     1,
+	}
+	font.Vmtx = &Vmtx{}
+	for i := 0; i < 8; i++ {
+		font.Vmtx.VMetrics = append(font.Vmtx.VMetrics, &LongVerMetric{AdvanceHeight: 1000, TopSideBearing: int16(10 * i)})
+	}
+	font.Vmtx.VMetrics[1].AdvanceHeight = 900
+	font.Vhea.NumOfLongVerMetrics = 8
+	return writeTestFont(t, font)
+}
+
+func TestVerticalMetricsRoundTrip(t *testing.T) {
+	font := newTestVerticalFont(t)
+	if !font.Vhea.Exists() || !font.Vmtx.Exists() {
+		t.Fatalf("vhea and vmtx are not written")
+	}
+	if 0x00011000 != font.Vhea.Version || 500 != font.Vhea.VertTypoAscender || -500 != font.Vhea.VertTypoDescender || 1 != font.Vhea.CaretSlopeRun {
+		t.Errorf("vhea is %+v", *font.Vhea)
+	}
+	// the trailing advance heights of 1000 are collapsed into the top side bearings.
+	if 3 != font.Vhea.NumOfLongVerMetrics || 3 != len(font.Vmtx.VMetrics) || 5 != len(font.Vmtx.TopSideBearings) {
+		t.Errorf("numOfLongVerMetrics is %d with %d top side bearings, want 3 and 5", font.Vhea.NumOfLongVerMetrics, len(font.Vmtx.TopSideBearings))
+	}
+	for gid := uint16(0); gid < 8; gid++ {
+		want := uint16(1000)
+		if 1 == gid {
+			want = 900
+		}
+		if advanceHeight, tsb := font.Vmtx.get(gid); want != advanceHeight || int16(10*gid) != tsb {
+			t.Errorf("metrics of glyph %d are %d, %d, want %d, %d", gid, advanceHeight, tsb, want, 10*gid)
+		}
+	}
+}
+
+func TestFilterGlyfVerticalMetrics(t *testing.T) {
+	font := newTestVerticalFont(t)
+	subset, err := font.FilterGlyf([]uint16{0, 5, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset = writeTestFont(t, subset)
+	if 3 != subset.Vhea.NumOfLongVerMetrics {
+		t.Errorf("numOfLongVerMetrics is %d, want 3", subset.Vhea.NumOfLongVerMetrics)
+	}
+	for gid, want := range []LongVerMetric{{1000, 0}, {1000, 50}, {900, 10}} {
+		if advanceHeight, tsb := subset.Vmtx.get(uint16(gid)); want.AdvanceHeight != advanceHeight || want.TopSideBearing != tsb {
+			t.Errorf("metrics of glyph %d are %d, %d, want %+v", gid, advanceHeight, tsb, want)
+		}
+	}
+}
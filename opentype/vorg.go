@@ -0,0 +1,122 @@
+package opentype
+
+import (
+	"os"
+	"sort"
+)
+
+// VertOriginYMetrics is the y coordinate of the vertical origin of a glyph.
+type VertOriginYMetrics struct {
+	// Glyph index.
+	GlyphIndex uint16
+	// Y coordinate, in the font's design coordinate system, of the vertical origin of glyph with index glyphIndex.
+	VertOriginY int16
+}
+
+// Vorg is a "VORG" table.
+// This optional table specifies the y coordinate of the vertical origin of every glyph in the font, used by CFF OpenType fonts for vertical writing.
+type Vorg struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// The y coordinate of a glyph’s vertical origin, in the font’s design coordinate system, to be used if no entry is present for the glyph in the VertOriginYMetrics.
+	DefaultVertOriginY int16
+	// Array of VertOriginYMetrics sorted by increasing glyphIndex.
+	VertOriginYMetrics []*VertOriginYMetrics
+}
+
+func parseVorg(f *os.File, offset uint32) (v *Vorg, err error) {
+	v = &Vorg{}
+	f.Seek(int64(offset), 0)
+	r := newErrReader(f)
+	r.read(&(v.MajorVersion))
+	r.read(&(v.MinorVersion))
+	r.read(&(v.DefaultVertOriginY))
+	var numVertOriginYMetrics uint16
+	r.read(&numVertOriginYMetrics)
+	v.VertOriginYMetrics = make([]*VertOriginYMetrics, numVertOriginYMetrics)
+	for i := range v.VertOriginYMetrics {
+		m := &VertOriginYMetrics{}
+		r.read(m)
+		v.VertOriginYMetrics[i] = m
+	}
+	return v, r.errorf("failed to parse VORG: %s")
+}
+
+// Get returns the y coordinate of the vertical origin of the glyph.
+func (v *Vorg) Get(gid uint16) int16 {
+	i := sort.Search(len(v.VertOriginYMetrics), func(i int) bool {
+		return v.VertOriginYMetrics[i].GlyphIndex >= gid
+	})
+	if i < len(v.VertOriginYMetrics) && v.VertOriginYMetrics[i].GlyphIndex == gid {
+		return v.VertOriginYMetrics[i].VertOriginY
+	}
+	return v.DefaultVertOriginY
+}
+
+// filter returns the vertical origins of the glyphs of f, whose glyph IDs are the indices in f.
+// Only the glyphs whose origins differ from the default have the entries.
+func (v *Vorg) filter(f []uint16) *Vorg {
+	new := &Vorg{
+		MajorVersion:       v.MajorVersion,
+		MinorVersion:       v.MinorVersion,
+		DefaultVertOriginY: v.DefaultVertOriginY,
+		VertOriginYMetrics: make([]*VertOriginYMetrics, 0),
+	}
+	for i, gid := range f {
+		y := v.Get(gid)
+		if y != v.DefaultVertOriginY {
+			new.VertOriginYMetrics = append(new.VertOriginYMetrics, &VertOriginYMetrics{
+				GlyphIndex:  uint16(i),
+				VertOriginY: y,
+			})
+		}
+	}
+	return new
+}
+
+// Tag is table name.
+func (v *Vorg) Tag() Tag {
+	return String2Tag("VORG")
+}
+
+// store writes binary expression of this table.
+func (v *Vorg) store(w *errWriter) {
+	w.write(&(v.MajorVersion))
+	w.write(&(v.MinorVersion))
+	w.write(&(v.DefaultVertOriginY))
+	numVertOriginYMetrics := uint16(len(v.VertOriginYMetrics))
+	w.write(&numVertOriginYMetrics)
+	for _, m := range v.VertOriginYMetrics {
+		w.write(m)
+	}
+	padSpace(w, v.Length())
+}
+
+// CheckSum for this table.
+func (v *Vorg) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(v)
+}
+
+// Length returns the size(byte) of this table.
+func (v *Vorg) Length() uint32 {
+	return uint32(8 + 4*len(v.VertOriginYMetrics))
+}
+
+// Exists returns true if this is not nil.
+func (v *Vorg) Exists() bool {
+	return v != nil
+}
+
+// clone returns a deep copy of this table.
+func (v *Vorg) clone() *Vorg {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	c.VertOriginYMetrics = make([]*VertOriginYMetrics, len(v.VertOriginYMetrics))
+	for i, m := range v.VertOriginYMetrics {
+		vm := *m
+		c.VertOriginYMetrics[i] = &vm
+	}
+	return &c
+}
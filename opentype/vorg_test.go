@@ -0,0 +1,79 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestVorg returns VORG whose default is 880, with the overrides of glyphs 2 and 5.
+func newTestVorg() *Vorg {
+	return &Vorg{
+		MajorVersion:       1,
+		DefaultVertOriginY: 880,
+		VertOriginYMetrics: []*VertOriginYMetrics{
+			{GlyphIndex: 2, VertOriginY: 900},
+			{GlyphIndex: 5, VertOriginY: 700},
+		},
+	}
+}
+
+func TestVorgRoundTrip(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Vorg = newTestVorg()
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(font.Vorg, parsed.Vorg) {
+		t.Errorf("VORG is %+v, want %+v", parsed.Vorg, font.Vorg)
+	}
+	for gid, want := range map[uint16]int16{0: 880, 2: 900, 3: 880, 5: 700} {
+		if y, err := parsed.VerticalOrigin(gid); err != nil || want != y {
+			t.Errorf("vertical origin of glyph %d is %d, want %d", gid, y, want)
+		}
+	}
+}
+
+func TestFilterGlyfRemapsVorg(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.Vorg = newTestVorg()
+	subset, err := font.FilterGlyf([]uint16{0, 5, 3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset = writeTestFont(t, subset)
+	want := []*VertOriginYMetrics{
+		{GlyphIndex: 1, VertOriginY: 700},
+		{GlyphIndex: 3, VertOriginY: 900},
+	}
+	if !reflect.DeepEqual(want, subset.Vorg.VertOriginYMetrics) || 880 != subset.Vorg.DefaultVertOriginY {
+		t.Errorf("VORG of the subset is %+v", *subset.Vorg)
+	}
+	if !reflect.DeepEqual(newTestVorg(), font.Vorg) {
+		t.Errorf("VORG of the source is modified")
+	}
+}
+
+func TestVerticalOriginOfCff(t *testing.T) {
+	font := newTestSubsetFont(t)
+	font.SfntVersion = SfntVersionCFFOpenType
+	font.Glyf, font.Loca = nil, nil
+	font.Cff = newTestCff(t)
+	font.Vhea = &Vhea{Version: 0x00010000}
+	font.Vmtx = &Vmtx{}
+	for i := 0; i < 8; i++ {
+		font.Vmtx.VMetrics = append(font.Vmtx.VMetrics, &LongVerMetric{AdvanceHeight: 1000, TopSideBearing: 40})
+	}
+	font = writeTestFont(t, font)
+	if !font.Cff.Exists() || 4 != font.Cff.Len() {
+		t.Fatalf("CFF is not written")
+	}
+	// the top side bearing is added to the maximum y of the curve, not of its control points.
+	if y, err := font.VerticalOrigin(1); err != nil || 100 != y {
+		t.Errorf("vertical origin of glyph 1 is %d, %v, want 100", y, err)
+	}
+	if y, err := font.VerticalOrigin(2); err != nil || 100 != y {
+		t.Errorf("vertical origin of glyph 2 is %d, %v, want 100", y, err)
+	}
+	font.Vmtx = nil
+	if y, err := font.VerticalOrigin(1); err != nil || font.Hhea.Ascender != y {
+		t.Errorf("vertical origin without vmtx is %d, %v, want the ascender %d", y, err, font.Hhea.Ascender)
+	}
+}
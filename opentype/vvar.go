@@ -0,0 +1,144 @@
+package opentype
+
+import (
+	"os"
+)
+
+// Vvar is a "VVAR" table.
+// The vertical metrics variations table has the deltas of the advance heights, the side bearings and the vertical origins of the glyphs.
+type Vvar struct {
+	MajorVersion uint16
+	MinorVersion uint16
+	// Item variation store of the deltas.
+	ItemVarStore *ItemVariationStore
+	// Map from the glyph IDs to the delta-sets of the advance heights.
+	// If it is nil, the glyph ID is the inner-level index of the outer-level index 0.
+	AdvanceHeightMapping *DeltaSetIndexMap
+	// Map from the glyph IDs to the delta-sets of the top side bearings, or nil if they have no deltas.
+	TsbMapping *DeltaSetIndexMap
+	// Map from the glyph IDs to the delta-sets of the bottom side bearings, or nil if they have no deltas.
+	BsbMapping *DeltaSetIndexMap
+	// Map from the glyph IDs to the delta-sets of the Y coordinates of the vertical origins, or nil if they have no deltas.
+	VOrgMapping *DeltaSetIndexMap
+}
+
+func parseVvar(f *os.File, offset, length uint32) (v *Vvar, err error) {
+	r, err := newTableReader(f, offset, length)
+	if err != nil {
+		return
+	}
+	v = &Vvar{}
+	v.MajorVersion = r.uint16()
+	v.MinorVersion = r.uint16()
+	itemVariationStoreOffset := r.uint32()
+	advanceHeightMappingOffset := r.uint32()
+	tsbMappingOffset := r.uint32()
+	bsbMappingOffset := r.uint32()
+	vOrgMappingOffset := r.uint32()
+	if r.hasErr() {
+		return nil, r.errorf("failed to parse header: %s")
+	}
+	if 0 != itemVariationStoreOffset {
+		v.ItemVarStore = parseItemVariationStore(r, int64(itemVariationStoreOffset))
+	}
+	if 0 != advanceHeightMappingOffset {
+		v.AdvanceHeightMapping = parseDeltaSetIndexMap(r, int64(advanceHeightMappingOffset))
+	}
+	if 0 != tsbMappingOffset {
+		v.TsbMapping = parseDeltaSetIndexMap(r, int64(tsbMappingOffset))
+	}
+	if 0 != bsbMappingOffset {
+		v.BsbMapping = parseDeltaSetIndexMap(r, int64(bsbMappingOffset))
+	}
+	if 0 != vOrgMappingOffset {
+		v.VOrgMapping = parseDeltaSetIndexMap(r, int64(vOrgMappingOffset))
+	}
+	return v, r.errorf("failed to parse VVAR: %s")
+}
+
+// Tag is table name.
+func (v *Vvar) Tag() Tag {
+	return String2Tag("VVAR")
+}
+
+// store writes binary expression of this table.
+func (v *Vvar) store(w *errWriter) {
+	b, err := packOffsetNode(v.node())
+	if err != nil {
+		if !w.hasErr() {
+			w.err = err
+		}
+		return
+	}
+	w.writeBin(b)
+	padSpace(w, uint32(len(b)))
+}
+
+func (v *Vvar) node() *offsetNode {
+	n := &offsetNode{}
+	n.uint16(v.MajorVersion)
+	n.uint16(v.MinorVersion)
+	if v.ItemVarStore == nil {
+		n.uint32(0)
+	} else {
+		n.offset32(v.ItemVarStore.node())
+	}
+	for _, m := range []*DeltaSetIndexMap{v.AdvanceHeightMapping, v.TsbMapping, v.BsbMapping, v.VOrgMapping} {
+		if m == nil {
+			n.uint32(0)
+		} else {
+			n.offset32(m.node())
+		}
+	}
+	return n
+}
+
+// CheckSum for this table.
+func (v *Vvar) CheckSum() (checkSum uint32, err error) {
+	return simpleCheckSum(v)
+}
+
+// Length returns the size(byte) of this table.
+func (v *Vvar) Length() uint32 {
+	b, err := packOffsetNode(v.node())
+	if err != nil {
+		return 0
+	}
+	return uint32(len(b))
+}
+
+// Exists returns true if this is not nil.
+func (v *Vvar) Exists() bool {
+	return v != nil
+}
+
+// clone returns a deep copy of this table.
+func (v *Vvar) clone() *Vvar {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	c.ItemVarStore = v.ItemVarStore.clone()
+	c.AdvanceHeightMapping = v.AdvanceHeightMapping.clone()
+	c.TsbMapping = v.TsbMapping.clone()
+	c.BsbMapping = v.BsbMapping.clone()
+	c.VOrgMapping = v.VOrgMapping.clone()
+	return &c
+}
+
+// filter returns the table for the glyphs of a subset.
+// The item variation store is kept as it is, and the maps are rebuilt for the new glyph IDs.
+func (v *Vvar) filter(f []uint16) *Vvar {
+	c := v.clone()
+	c.AdvanceHeightMapping = v.AdvanceHeightMapping.filter(f)
+	if v.TsbMapping != nil {
+		c.TsbMapping = v.TsbMapping.filter(f)
+	}
+	if v.BsbMapping != nil {
+		c.BsbMapping = v.BsbMapping.filter(f)
+	}
+	if v.VOrgMapping != nil {
+		c.VOrgMapping = v.VOrgMapping.filter(f)
+	}
+	return c
+}
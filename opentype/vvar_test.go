@@ -0,0 +1,59 @@
+package opentype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVvarRoundTrip(t *testing.T) {
+	font := newTestVerticalFont(t)
+	font.Fvar = newTestCvarFont(t).Fvar
+	font.Vvar = &Vvar{
+		MajorVersion: 1,
+		ItemVarStore: &ItemVariationStore{
+			Format:            1,
+			AxisCount:         1,
+			VariationRegions:  [][]*RegionAxisCoordinates{{{StartCoord: 0, PeakCoord: 0x4000, EndCoord: 0x4000}}},
+			ItemVariationData: []*ItemVariationData{{RegionIndexes: []uint16{0}, DeltaSets: [][]int32{{0}, {-40}, {300}}}},
+		},
+		AdvanceHeightMapping: &DeltaSetIndexMap{Map: []uint32{0, 1, 0, 2}},
+		TsbMapping:           &DeltaSetIndexMap{Map: []uint32{0, 2}},
+		VOrgMapping:          &DeltaSetIndexMap{Map: []uint32{1}},
+	}
+	want := font.Vvar.clone()
+	parsed := writeTestFont(t, font)
+	if !reflect.DeepEqual(want, parsed.Vvar) {
+		t.Errorf("VVAR is %+v, want %+v", *parsed.Vvar, *want)
+	}
+	subset, err := parsed.FilterGlyf([]uint16{0, 3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset = writeTestFont(t, subset)
+	maps := []struct {
+		name string
+		got  *DeltaSetIndexMap
+		want []uint32
+	}{
+		{"advance height", subset.Vvar.AdvanceHeightMapping, []uint32{0, 2, 1}},
+		{"tsb", subset.Vvar.TsbMapping, []uint32{0, 2, 2}},
+		{"vertical origin", subset.Vvar.VOrgMapping, []uint32{1, 1, 1}},
+	}
+	for _, m := range maps {
+		if m.got == nil || !reflect.DeepEqual(m.want, m.got.Map) {
+			t.Errorf("%s mapping of the subset is %v, want %v", m.name, m.got, m.want)
+		}
+	}
+	if nil != subset.Vvar.BsbMapping || !reflect.DeepEqual(want.ItemVarStore, subset.Vvar.ItemVarStore) {
+		t.Errorf("VVAR of the subset is %+v", *subset.Vvar)
+	}
+	// the map of the advance heights is created for the implicit map.
+	parsed.Vvar.AdvanceHeightMapping = nil
+	subset, err = parsed.FilterGlyf([]uint16{0, 3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := []uint32{0, 3, 1}; !reflect.DeepEqual(m, writeTestFont(t, subset).Vvar.AdvanceHeightMapping.Map) {
+		t.Errorf("advance height mapping of the subset is %v, want %v", subset.Vvar.AdvanceHeightMapping.Map, m)
+	}
+}